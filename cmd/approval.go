@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/i18n"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// approvalKeys are the answers to an approval prompt.
+var approvalKeys = map[string]engine.Decision{
+	"y": engine.Allow,
+	"n": engine.Deny,
+	"a": engine.AllowTool,
+	"A": engine.AllowAll,
+}
+
+// approvalPreview says what a tool call is about to do: the command, the
+// file and what happens to it, or else the arguments.
+func approvalPreview(name string, args map[string]any) string {
+	path, _ := args["path"].(string)
+	switch name {
+	case "bash":
+		cmd, _ := args["command"].(string)
+		return "$ " + clipText(cmd, 200)
+	case "file_write":
+		content, _ := args["content"].(string)
+		verb := "write"
+		switch args["mode"] {
+		case "append":
+			verb = "append to"
+		case "create":
+			verb = "create"
+		}
+		return fmt.Sprintf("%s %s (%d lines)", verb, path, strings.Count(content, "\n")+1)
+	case "file_edit":
+		return fmt.Sprintf("edit %s, lines %v-%v", path, args["start_line"], args["end_line"])
+	case "file_patch":
+		return "patch " + path
+	case "apply_patch":
+		patch, _ := args["patch"].(string)
+		var names []string
+		for _, p := range tool.PatchFiles(patch) {
+			names = append(names, tool.ShowPath(p))
+		}
+		return fmt.Sprintf("apply a patch to %s", clipText(strings.Join(names, ", "), 200))
+	case "git":
+		preview := "git " + fmt.Sprint(args["action"])
+		if name, _ := args["name"].(string); name != "" {
+			preview += " " + name
+		}
+		if msg, _ := args["message"].(string); msg != "" {
+			preview += fmt.Sprintf(" %q", clipText(msg, 100))
+		}
+		if paths, _ := args["paths"].([]any); len(paths) > 0 {
+			preview += " -- " + clipText(strings.Trim(fmt.Sprint(paths...), "[]"), 100)
+		}
+		return preview
+	}
+	b, _ := json.Marshal(args)
+	return clipText(string(b), 200)
+}
+
+// askApproval shows the approval prompt for a tool call; the turn waits
+// until answerApproval.
+func (m model) askApproval(msg toolConfirmMsg) (tea.Model, tea.Cmd) {
+	m.confirmMode = true
+	m.confirmToolName = msg.toolName
+	m.waiting = false
+	prompt := sInfo.Render(i18n.T("approval.ask", msg.toolName)) + msg.preview
+	return m, printAbove(prompt)
+}
+
+// answerApproval sends the decision for key, if it is one, to the turn.
+func (m model) answerApproval(key string) (tea.Model, tea.Cmd) {
+	d, ok := approvalKeys[key]
+	if !ok {
+		return m, nil
+	}
+	m.confirmMode = false
+	m.waiting = true
+	// the buffered channel never blocks; the turn may have been cancelled
+	m.approvalCh <- d
+	echo := sFaint.Render("  → " + decisionText(d))
+	if d == engine.Deny {
+		echo = sErr.Render("  → " + decisionText(d))
+	}
+	return m, tea.Batch(printAbove(echo), waitForStream(m.streamCh))
+}
+
+// confirmStatus is the status line of an approval prompt.
+func (m model) confirmStatus() string {
+	return sInfo.Render("y") + sFaint.Render(i18n.T("approval.allow")) +
+		sInfo.Render("n") + sFaint.Render(i18n.T("approval.deny")) +
+		sInfo.Render("a") + sFaint.Render(i18n.T("approval.always", m.confirmToolName)) +
+		sInfo.Render("A") + sFaint.Render(i18n.T("approval.all"))
+}
+
+// decisionText is how an answer is echoed; Decision.String stays English
+// for the debug log.
+func decisionText(d engine.Decision) string {
+	switch d {
+	case engine.Allow:
+		return i18n.T("approval.allowed")
+	case engine.AllowTool:
+		return i18n.T("approval.allowed_tool")
+	case engine.AllowAll:
+		return i18n.T("approval.allowed_all")
+	}
+	return i18n.T("approval.denied")
+}
+
+// onceApproval answers approval requests in non-interactive mode: every call
+// runs with --yes, none without it, and each denial is reported.
+func onceApproval(opts onceOptions) engine.Approver {
+	return func(name string, args map[string]any) (engine.Decision, error) {
+		if opts.yes {
+			return engine.AllowAll, nil
+		}
+		switch {
+		case opts.jsonOut:
+			json.NewEncoder(os.Stdout).Encode(map[string]any{"type": "denied", "tool": name, "preview": approvalPreview(name, args)})
+		case !opts.quiet:
+			fmt.Fprintf(os.Stderr, "%s denied %s (%s): -m runs tools that change things only with --yes or tools.approve\n",
+				opts.mark("✘", "[denied]"), name, opts.text(approvalPreview(name, args)))
+		}
+		return engine.Deny, nil
+	}
+}
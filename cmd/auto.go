@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+)
+
+// autoStartMsg starts an autonomous run: /auto <duration> <task>.
+type autoStartMsg struct {
+	budget time.Duration
+	task   string
+}
+
+// checkpointMsg is progress the model reported during an autonomous run.
+type checkpointMsg engine.Checkpoint
+
+const autoUsage = "Usage: /auto <duration> <task>, e.g. /auto 20m move the config loader to its own package"
+
+// parseBudget reads a time budget such as 20m or 1h30m; a bare number is
+// minutes.
+func parseBudget(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		n, nerr := strconv.Atoi(s)
+		if nerr != nil {
+			return 0, fmt.Errorf("invalid duration %q (e.g. 20m or 1h30m)", s)
+		}
+		d = time.Duration(n) * time.Minute
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+// handleAuto reads /auto <duration> <task>.
+func (m *model) handleAuto(input string) tea.Msg {
+	spec, task, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(input, "/auto")), " ")
+	task = strings.TrimSpace(task)
+	if spec == "" || task == "" {
+		return sErr.Render(autoUsage)
+	}
+	budget, err := parseBudget(spec)
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	if m.eng.ToolsOff() {
+		return sErr.Render("✘ " + m.eng.Agent.CurrentModel + " can't call tools, which autonomous runs need")
+	}
+	return autoStartMsg{budget: budget, task: task}
+}
+
+// startAuto starts the autonomous run; it ends like a turn, in streamDoneMsg
+// or streamErrMsg, or is cancelled with Esc.
+func (m model) startAuto(msg autoStartMsg) (tea.Model, tea.Cmd) {
+	m.waiting = true
+	m.startTime = time.Now()
+	m.lastMessage = msg.task
+	m.autoUntil = m.startTime.Add(msg.budget)
+	m.autoCheckpoints = nil
+	header := sPrompt.Render("▶ ") + msg.task + "\n" +
+		sFaint.Render(fmt.Sprintf("⏱ autonomous for %s, until %s · tools without asking: %s · Esc stops",
+			msg.budget, m.autoUntil.Format("15:04"), approvedList(m.eng)))
+	return m, tea.Batch(printAbove(header), m.sendCmd("", &msg, false))
+}
+
+// approvedList names the tools an autonomous run may call, or "none".
+func approvedList(eng *engine.Engine) string {
+	if names := eng.AutoApproved(); len(names) > 0 {
+		return strings.Join(names, ", ")
+	}
+	return "none"
+}
+
+// autoStatus is the time an autonomous run has left, for the status line.
+func (m *model) autoStatus() string {
+	if m.autoUntil.IsZero() {
+		return ""
+	}
+	left := time.Until(m.autoUntil).Round(time.Second)
+	if left <= 0 {
+		return " · auto: summing up"
+	}
+	return fmt.Sprintf(" · auto: %s left", left)
+}
+
+// renderCheckpoint shows a checkpoint as it comes in.
+func renderCheckpoint(c engine.Checkpoint) string {
+	return sOK.Render("📍 ") + c.Text + sFaint.Render(" "+c.Time.Format("15:04"))
+}
+
+// autoCancelNote tells what is left of an autonomous run stopped with Esc:
+// the checkpoints so far, and the finished rounds, which stay in the
+// conversation.
+func (m *model) autoCancelNote() string {
+	out := sErr.Render("✘ Autonomous run stopped") + sDim.Render(" · the work so far stays in the conversation")
+	if len(m.autoCheckpoints) == 0 {
+		return out + "\n" + sDim.Render("  no checkpoints were made")
+	}
+	out += "\n" + sDim.Render("  checkpoints:")
+	for _, c := range m.autoCheckpoints {
+		out += "\n  " + renderCheckpoint(c)
+	}
+	return out
+}
+
+// runAutoOnce runs task as an autonomous run for -m --auto. Ctrl+C stops it
+// the way Esc does in the chat: the checkpoints and the finished rounds are
+// kept in the session.
+func runAutoOnce(ctx context.Context, eng *engine.Engine, task string, opts onceOptions, onText, onToolCall, onToolResult func(string)) (*engine.AutoRun, error) {
+	budget, err := parseBudget(opts.auto)
+	if err != nil {
+		return nil, err
+	}
+	if eng.ToolsOff() {
+		return nil, fmt.Errorf("%s can't call tools, which autonomous runs need", eng.Agent.CurrentModel)
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if !opts.quiet && !opts.jsonOut {
+		fmt.Fprintf(os.Stderr, "%s autonomous for %s, until %s %s tools without asking: %s\n",
+			opts.mark("⏱", "[auto]"), budget, time.Now().Add(budget).Format("15:04"), opts.mark("·", "-"), approvedList(eng))
+	}
+	run, err := eng.RunAuto(ctx, task, budget, onText, onToolCall, onToolResult)
+	if err != nil && ctx.Err() != nil {
+		err = errors.New("autonomous run interrupted")
+	}
+	return run, err
+}
+
+// autoOnceLine is the run's report for stderr in -m mode.
+func autoOnceLine(run *engine.AutoRun, opts onceOptions) string {
+	return opts.mark("⏱", "[auto]") + opts.text(strings.TrimPrefix(run.Report(), "⏱"))
+}
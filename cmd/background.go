@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/i18n"
+)
+
+// bgTask is a turn running or finished in the background (/bg), on a fork of
+// the conversation.
+type bgTask struct {
+	id      int
+	prompt  string
+	eng     *engine.Engine
+	start   time.Time
+	cancel  context.CancelFunc
+	done    bool
+	elapsed time.Duration
+}
+
+// bgTasks are the chat's background turns. The model is copied on every
+// update, so it holds them by pointer.
+type bgTasks struct {
+	ctx    context.Context // cancelled on quit
+	stop   context.CancelFunc
+	max    int // concurrent turns, background_tasks in gal.yaml
+	nextID int
+	tasks  []*bgTask        // in start order
+	usage  []*engine.Engine // finished forks whose usage isn't counted yet, see countBg
+}
+
+// bgStartMsg starts a background turn.
+type bgStartMsg string
+
+// bgDoneMsg ends a background turn.
+type bgDoneMsg struct {
+	id  int
+	err error
+}
+
+const bgUsage = "Usage: /bg <message>, /bg list, /bg merge [n] or /bg drop [n]"
+
+func newBgTasks(max int) *bgTasks {
+	ctx, stop := context.WithCancel(context.Background())
+	return &bgTasks{ctx: ctx, stop: stop, max: max}
+}
+
+// running counts the turns that haven't finished.
+func (b *bgTasks) running() int {
+	n := 0
+	for _, t := range b.tasks {
+		if !t.done {
+			n++
+		}
+	}
+	return n
+}
+
+// find returns task n, or with n == "" the only task that is done.
+func (b *bgTasks) find(n string) (*bgTask, error) {
+	if n == "" {
+		var found *bgTask
+		for _, t := range b.tasks {
+			if t.done {
+				if found != nil {
+					return nil, fmt.Errorf("several background tasks are done; say which, e.g. #%d", t.id)
+				}
+				found = t
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("no background task is done")
+		}
+		return found, nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(n, "#"))
+	if err == nil {
+		for _, t := range b.tasks {
+			if t.id == id {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no background task %s (/bg list)", n)
+}
+
+func (b *bgTasks) remove(t *bgTask) {
+	for i, x := range b.tasks {
+		if x == t {
+			b.tasks = append(b.tasks[:i], b.tasks[i+1:]...)
+			return
+		}
+	}
+}
+
+// handleBg runs /bg <message>, /bg list, /bg merge [n] and /bg drop [n].
+func (m *model) handleBg(input string) tea.Msg {
+	rest := strings.TrimSpace(strings.TrimPrefix(input, "/bg"))
+	sub, arg, _ := strings.Cut(rest, " ")
+	arg = strings.TrimSpace(arg)
+	switch sub {
+	case "":
+		return sErr.Render(bgUsage)
+	case "list":
+		return m.bgList()
+	case "merge":
+		t, err := m.bg.find(arg)
+		if err != nil {
+			return sErr.Render("✘ " + err.Error())
+		}
+		if !t.done {
+			return sErr.Render(i18n.T("bg.still_running", t.id))
+		}
+		msgs := t.eng.Exchange()
+		m.eng.Messages = append(m.eng.Messages, msgs...)
+		m.bg.remove(t)
+		return sOK.Render(i18n.T("bg.merged", t.id, len(msgs)))
+	case "drop":
+		t, err := m.bg.find(arg)
+		if err != nil {
+			return sErr.Render("✘ " + err.Error())
+		}
+		m.bg.remove(t)
+		if !t.done {
+			t.cancel()
+			return sOK.Render(i18n.T("bg.stopped", t.id))
+		}
+		return sOK.Render(i18n.T("bg.dropped", t.id))
+	}
+	if n := m.bg.running(); n >= m.bg.max {
+		return sErr.Render(i18n.T("bg.limit", bgCount(n), m.bg.max))
+	}
+	return bgStartMsg(rest)
+}
+
+// startBg sends prompt on a fork of the conversation, limited to read-only
+// tools, and reports with bgDoneMsg.
+func (m model) startBg(prompt string) (tea.Model, tea.Cmd) {
+	b := m.bg
+	b.nextID++
+	ctx, cancel := context.WithCancel(b.ctx)
+	t := &bgTask{id: b.nextID, prompt: prompt, start: time.Now(), cancel: cancel}
+	t.eng = m.eng.Fork(fmt.Sprintf("[bg %d]", t.id))
+	b.tasks = append(b.tasks, t)
+
+	tools := "no tools"
+	if names := toolNames(t.eng.Agent.ToolDefs); len(names) > 0 {
+		tools = "read-only tools: " + strings.Join(names, ", ")
+	}
+	note := sPrompt.Render(fmt.Sprintf("◆ #%d ", t.id)) + prompt + "\n" +
+		sFaint.Render(i18n.T("bg.started", tools))
+	run := func() tea.Msg {
+		defer cancel()
+		return bgDoneMsg{id: t.id, err: t.eng.Send(ctx, prompt, nil)}
+	}
+	return m, tea.Batch(printAbove(note), run)
+}
+
+// finishBg reports a background turn that ended. Its tokens count in the
+// session whether or not it is merged; a failed turn leaves nothing to merge.
+func (m model) finishBg(msg bgDoneMsg) (tea.Model, tea.Cmd) {
+	var t *bgTask
+	for _, x := range m.bg.tasks {
+		if x.id == msg.id {
+			t = x
+		}
+	}
+	if t == nil || m.bg.ctx.Err() != nil {
+		return m, nil // dropped while it ran, or the chat is ending
+	}
+	t.done = true
+	t.elapsed = time.Since(t.start)
+	m.bg.usage = append(m.bg.usage, t.eng)
+	if !m.waiting && !m.compressing {
+		m.countBg()
+	}
+	head := sPrompt.Render(fmt.Sprintf("◆ #%d ", t.id)) + clipText(t.prompt, 60)
+	if msg.err != nil {
+		m.bg.remove(t)
+		return m, printAbove(head + "\n" + sErr.Render("✘ "+msg.err.Error()))
+	}
+	answer := ""
+	if ex := t.eng.Exchange(); len(ex) > 0 {
+		answer = ex[len(ex)-1].Content
+	}
+	out := head + sDim.Render(i18n.T("bg.done", t.elapsed.Seconds())) + "\n" +
+		m.renderMarkdown(answer) + "\n" +
+		sDim.Render(i18n.T("bg.merge_hint", t.id, t.id))
+	return m, printAbove(out)
+}
+
+// countBg adds the usage of finished background turns to the session's,
+// merged or not. The engine's totals change during a turn, so it waits for
+// the chat to be idle.
+func (m *model) countBg() {
+	for _, f := range m.bg.usage {
+		m.eng.AddForkUsage(f)
+	}
+	m.bg.usage = nil
+}
+
+// bgList lists the background tasks.
+func (m *model) bgList() string {
+	if len(m.bg.tasks) == 0 {
+		return sInfo.Render(i18n.T("bg.none"))
+	}
+	var out []string
+	for _, t := range m.bg.tasks {
+		state := i18n.T("bg.state_running", time.Since(t.start).Round(time.Second))
+		if t.done {
+			state = i18n.T("bg.state_done", t.elapsed.Round(time.Second), t.id)
+		}
+		out = append(out, fmt.Sprintf("  #%-3d %s  %s", t.id, clipText(t.prompt, 50), sFaint.Render(state)))
+	}
+	return strings.Join(out, "\n")
+}
+
+// bgStatus is the background tasks' part of the status line.
+func (m *model) bgStatus() string {
+	n := m.bg.running()
+	ready := len(m.bg.tasks) - n
+	switch {
+	case n > 0 && ready > 0:
+		return i18n.T("bg.status_both", bgCount(n), ready)
+	case n > 0:
+		return bgCount(n)
+	case ready > 0:
+		return i18n.T("bg.status_ready", bgCount(ready))
+	}
+	return ""
+}
+
+// bgCount renders "1 background task" or "n background tasks".
+func bgCount(n int) string {
+	if n == 1 {
+		return i18n.T("bg.count_one")
+	}
+	return i18n.T("bg.count", n)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/i18n"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// renderChanges lists a session's changelog oldest first, each change with
+// its diff. revert is how to revert change %d where it's shown, e.g.
+// "/changes revert %d".
+func renderChanges(changes []engine.Change, revert string) string {
+	if len(changes) == 0 {
+		return sInfo.Render(i18n.T("changes.none"))
+	}
+	var out []string
+	checkpoint := ""
+	for _, c := range changes {
+		head := fmt.Sprintf("#%-3d %s  %s  %s", c.Seq, c.Time.Format("01-02 15:04:05"), sTool.Render(c.Tool), changeTarget(c))
+		switch {
+		case c.Reverted:
+			head += sFaint.Render(i18n.T("changes.reverted"))
+		case c.Revertible():
+			head += sFaint.Render("  (" + fmt.Sprintf(revert, c.Seq) + ")")
+		}
+		out = append(out, head)
+		if c.Error != "" {
+			out = append(out, "    "+sErr.Render("✘ "+c.Error))
+		}
+		if c.Checkpoint != "" && c.Checkpoint != checkpoint {
+			// the state before this and later changes; git can put it back
+			out = append(out, "    "+sFaint.Render(i18n.T("changes.checkpoint", c.Checkpoint, c.Checkpoint, c.Path)))
+			checkpoint = c.Checkpoint
+		}
+		if c.Diff != "" {
+			lines := strings.Split(c.Diff, "\n")
+			if len(lines) > maxDiffLines {
+				lines = append(lines[:maxDiffLines], i18n.T("changes.more_diff", len(lines)-maxDiffLines))
+			}
+			for _, line := range lines {
+				switch {
+				case strings.HasPrefix(line, "+ "):
+					line = sDiffAdd.Render(line)
+				case strings.HasPrefix(line, "- "):
+					line = sDiffDel.Render(line)
+				default:
+					line = sFaint.Render(line)
+				}
+				out = append(out, "    "+line)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// changeTarget says what a change was done to: the file, the command run,
+// or else the call's arguments.
+func changeTarget(c engine.Change) string {
+	if c.Path != "" {
+		switch {
+		case c.Created:
+			return c.Path + " (created)"
+		case c.Deleted:
+			return c.Path + " (deleted)"
+		}
+		return c.Path
+	}
+	var args map[string]any
+	if json.Unmarshal([]byte(c.Args), &args) == nil {
+		if cmd, ok := args["command"].(string); ok && c.Tool == "bash" {
+			return "$ " + clipText(cmd, 100)
+		}
+	}
+	return clipText(c.Args, 100)
+}
+
+// revertChange reverts change n (as typed, "3" or "#3") of changes.
+func revertChange(changes []engine.Change, n string) (string, error) {
+	seq, err := strconv.Atoi(strings.TrimPrefix(n, "#"))
+	if err != nil || seq < 1 || seq > len(changes) {
+		return "", fmt.Errorf("no change %s; the session has %d", n, len(changes))
+	}
+	c := &changes[seq-1]
+	if err := c.Revert(); err != nil {
+		return "", err
+	}
+	if c.Created {
+		return i18n.T("changes.reverted_removed", seq, c.Path), nil
+	}
+	return i18n.T("changes.reverted_restored", seq, c.Path), nil
+}
+
+// handleCheckpoint runs /checkpoint: save the state of the working
+// directory's git repository, or list the session's checkpoints.
+func (m *model) handleCheckpoint(parts []string) tea.Msg {
+	if len(parts) > 1 && parts[1] == "list" {
+		if len(m.eng.GitCheckpoints) == 0 {
+			return sInfo.Render(i18n.T("checkpoint.none"))
+		}
+		var out []string
+		for _, cp := range m.eng.GitCheckpoints {
+			out = append(out, fmt.Sprintf("%s  %s  %s  %s", cp.Time.Format("01-02 15:04:05"), sTool.Render(cp.Short()), cp.Ref, sFaint.Render(cp.Repo)))
+		}
+		return strings.Join(out, "\n")
+	}
+	if len(parts) > 1 {
+		return sErr.Render(i18n.T("checkpoint.usage"))
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	cp, err := m.eng.SaveCheckpoint(cwd)
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	return sOK.Render(i18n.T("checkpoint.saved", cp.Short(), cp.Repo, cp.Ref)) +
+		sFaint.Render(i18n.T("checkpoint.restore_hint", cp.Ref))
+}
+
+// handleUndoFile runs /undo-file: the file_undo tool for a path, restoring
+// the file from its latest backup, or the list of backups without one.
+func (m *model) handleUndoFile(parts []string) string {
+	args := map[string]any{}
+	if len(parts) > 1 {
+		args["path"] = strings.Join(parts[1:], " ")
+	}
+	res, err := m.eng.Agent.Registry.ExecuteV2(context.Background(), "file_undo", args)
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	if len(parts) == 1 {
+		return res.Text
+	}
+	first, rest, _ := strings.Cut(res.Text, "\n")
+	out := sOK.Render("✔ " + first)
+	if rest != "" {
+		out += "\n" + sFaint.Render(rest)
+	}
+	return out
+}
+
+// backupPaths lists the files backups were taken of, latest first.
+func backupPaths(backups []tool.Backup) []string {
+	var paths []string
+	for i := len(backups) - 1; i >= 0; i-- {
+		if p := tool.ShowPath(backups[i].Path); !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
@@ -3,38 +3,100 @@ package cmd
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mattn/go-runewidth"
-	"github.com/gal-cli/gal-cli/internal/agent"
 	"github.com/gal-cli/gal-cli/internal/config"
 	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/i18n"
 	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
 	"github.com/gal-cli/gal-cli/internal/session"
+	"github.com/gal-cli/gal-cli/internal/setup"
 	"github.com/gal-cli/gal-cli/internal/tool"
+	"github.com/gal-cli/gal-cli/pkg/gal"
 	"github.com/spf13/cobra"
 )
 
+// trimTools and temperature are set by --trim-tools and --temperature and
+// apply to every agent built in this run, including ones switched to with /agent.
+var (
+	trimTools   bool
+	temperature float64
+)
+
+// onceHeartbeatInterval is the idle time between heartbeats in -m mode.
+const onceHeartbeatInterval = 30 * time.Second
+
+// onceOptions controls output of non-interactive (-m) runs.
+type onceOptions struct {
+	quiet           bool   // no tool lines, summary or session hint on stderr
+	jsonOut         bool   // JSON-lines events on stdout instead of plain text
+	showToolResults bool   // truncated tool results on stderr (always included with --json)
+	plain           bool   // ASCII only on stderr: tags instead of emoji
+	responseFormat  string // --response-format: text, json or json_schema
+	jsonSchema      string // --json-schema: a JSON schema, inline or @file
+	toolChoice      string // --tool-choice: auto, none, required or a tool name
+	auto            string // --auto: time budget of an autonomous run of the message
+	yes             bool   // --yes: run tools that change things without approval
+}
+
+// mark returns the stderr prefix for a line: the emoji, or with --plain an
+// ASCII tag that log collectors can handle.
+func (o onceOptions) mark(emoji, tag string) string {
+	if o.plain {
+		return tag
+	}
+	return emoji
+}
+
+// plainText spells the separators and marks of status lines in ASCII.
+var plainText = strings.NewReplacer("·", "-", "→", "->", "…", "...", "—", "-", "›", ">", "✓", "ok", "✗", "x", "✘", "x")
+
+// text returns a status line for stderr, in ASCII with --plain.
+func (o onceOptions) text(s string) string {
+	if o.plain {
+		return plainText.Replace(s)
+	}
+	return s
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 func init() {
 	var agentName string
 	var modelName string
 	var debug bool
+	var debugFormat string
 	var sessionID string
 	var message string
+	var metricsPort int
+	var opts onceOptions
 	chatCmd := &cobra.Command{
 		Use:   "chat",
 		Short: "Start chat (interactive or non-interactive with -m)",
@@ -51,18 +113,39 @@ Non-Interactive Mode (with -m flag):
   echo "test" | gal-cli chat -m -
   gal-cli chat --session abc -m "continue"
   gal-cli chat -a coder -m "write code" > output.txt
+  gal-cli chat -m "list 3 colors" --json-schema @colors.json
 
 Output: stdout = LLM response, stderr = tool calls (use 2>/dev/null to suppress)`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runChat(agentName, modelName, sessionID, message, debug)
+			err := runChat(agentName, modelName, sessionID, message, debug, debugFormat, metricsPort, opts)
+			var exit *exitError
+			if errors.As(err, &exit) {
+				// not a usage mistake; Execute prints it, once
+				cmd.SilenceUsage, cmd.SilenceErrors = true, true
+			}
+			return err
 		},
 	}
 	chatCmd.Flags().StringVarP(&agentName, "agent", "a", "", "Agent name (default: from config)")
 	chatCmd.Flags().StringVar(&modelName, "model", "", "Model to use (overrides agent default)")
 	chatCmd.Flags().StringVar(&sessionID, "session", "", "Session ID to resume or create")
-	chatCmd.Flags().StringVarP(&message, "message", "m", "", "Non-interactive mode: message to send (use @file or - for stdin)")
+	chatCmd.Flags().StringVarP(&message, "message", "m", "", "Non-interactive mode: message to send (use @file, - for stdin or prompt:<name> for a saved prompt)")
+	chatCmd.Flags().BoolVarP(&opts.quiet, "quiet", "q", false, "Non-interactive mode: print only the response (no tool calls, summary or session hint)")
+	chatCmd.Flags().BoolVar(&opts.jsonOut, "json", false, "Non-interactive mode: emit JSON-lines events (text, reasoning, tool_call, tool_result, done, error) on stdout")
+	chatCmd.Flags().BoolVar(&opts.showToolResults, "show-tool-results", false, "Non-interactive mode: print truncated tool results to stderr")
+	chatCmd.Flags().BoolVar(&opts.plain, "plain", false, "Non-interactive mode: plain ASCII on stderr, with tags ([tool], [session]) instead of emoji")
+	chatCmd.Flags().StringVar(&opts.responseFormat, "response-format", "", "Non-interactive mode: text or json; the answer is checked to be valid JSON and printed without rendering")
+	chatCmd.Flags().StringVar(&opts.jsonSchema, "json-schema", "", "Non-interactive mode: JSON schema the answer must match (inline or @file); implies --response-format json")
+	chatCmd.Flags().StringVar(&opts.auto, "auto", "", "Non-interactive mode: work on the message as a task without asking for up to this long (e.g. 20m), with checkpoints")
+	chatCmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Non-interactive mode: run tools that change things (file writes, bash, ...) without approval; otherwise they are denied")
+	chatCmd.Flags().StringVar(&opts.toolChoice, "tool-choice", "", "Non-interactive mode: auto, none, required or a tool name to call first (overrides the agent's tool_choice)")
+	chatCmd.Flags().Float64Var(&temperature, "temperature", 0, "Sampling temperature (overrides the agent's params.temperature)")
+	chatCmd.Flags().BoolVar(&trimTools, "trim-tools", false, "Drop least-recently-used MCP tools when tool definitions exceed provider limits")
+	chatCmd.Flags().IntVar(&metricsPort, "metrics-port", 0, "Serve Prometheus metrics on this port (overrides metrics_port in gal.yaml)")
 	chatCmd.Flags().BoolVar(&debug, "debug", false, "")
 	chatCmd.Flags().MarkHidden("debug")
+	chatCmd.Flags().StringVar(&debugFormat, "debug-format", "text", "")
+	chatCmd.Flags().MarkHidden("debug-format")
 	rootCmd.AddCommand(chatCmd)
 }
 
@@ -82,7 +165,7 @@ var (
 	sDiffDel = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
 )
 
-func banner(agentName, modelName, sessionID string) string {
+func banner(agentName, modelName, sessionID string, toolsOff bool, warning string) string {
 	logo := sLogo.Render(`
    ██████╗  █████╗ ██╗      █████╗ ██╗  ██╗██╗   ██╗
   ██╔════╝ ██╔══██╗██║     ██╔══██╗╚██╗██╔╝╚██╗ ██╔╝
@@ -91,20 +174,59 @@ func banner(agentName, modelName, sessionID string) string {
   ╚██████╔╝██║  ██║███████╗██║  ██║██╔╝ ██╗   ██║
    ╚═════╝ ╚═╝  ╚═╝╚══════╝╚═╝  ╚═╝╚═╝  ╚═╝   ╚═╝`)
 
-	info := sInfo.Render(fmt.Sprintf("  Agent: %s │ Model: %s │ Session: %s", agentName, modelName, sessionID))
-	hints := sDim.Render("  /help commands │ /quit exit │ ↑↓ history │ Tab complete")
+	info := sInfo.Render(i18n.T("banner.info", agentName, modelName, sessionID))
+	if toolsOff {
+		info += sTool.Render(" │ " + i18n.T("status.tools_off"))
+	}
+	hints := sDim.Render(i18n.T("banner.hints"))
 
-	return logo + "\n\n" + info + "\n" + hints
+	out := logo + "\n\n" + info + "\n" + hints
+	if warning != "" {
+		out += "\n\n" + sErr.Render("  ⚠ "+warning)
+	}
+	return out
 }
 
 type streamChunkMsg string
+type streamReasoningMsg string
 type streamToolMsg string
-type streamToolResultMsg string
-type streamDoneMsg struct{ content string }
-type streamErrMsg struct{ err error }
+type streamStatusMsg string
+type streamCompressingMsg bool // compression within the turn started or ended
+type heartbeatMsg engine.Heartbeat
+type streamToolResultMsg struct {
+	preview string
+	meta    map[string]any
+}
+type streamDoneMsg struct {
+	content string
+	auto    *engine.AutoRun // set when the turn was an autonomous run
+}
+type streamErrMsg struct {
+	err  error
+	auto *engine.AutoRun
+}
+
+// streamEvent is a message from the turn whose channel is ch. Events of a
+// cancelled turn are dropped, so a late chunk can't leak into the next one.
+type streamEvent struct {
+	ch  chan tea.Msg
+	msg tea.Msg
+}
+
+// turnStoppedMsg says the cancelled turn of ch has ended: its goroutine
+// returned and closed ch, so the engine is the UI's again.
+type turnStoppedMsg struct {
+	ch    chan tea.Msg
+	clean bool // trim what the rollback left of an unfinished tool round
+}
 type compressStartMsg struct{}
 type compressDoneMsg struct{}
 type compressErrMsg struct{ err error }
+type clearSummaryMsg struct{}
+type clearDoneMsg struct {
+	before, after int
+	err           error
+}
 
 type interactiveRequestMsg struct {
 	requests []engine.InteractiveInputRequest
@@ -118,20 +240,16 @@ type interactiveEchoMsg struct {
 }
 type interactiveNextPromptMsg struct{}
 
+// toolConfirmMsg asks whether a tool call may run (see askApproval).
 type toolConfirmMsg struct {
 	toolName string
-	args     map[string]any
 	preview  string
 }
-type toolConfirmResponseMsg struct {
-	approved bool
-	skipFuture bool
-}
 
 // --- input history persistence ---
 
 func historyPath() string {
-	return filepath.Join(config.GalDir(), "history")
+	return filepath.Join(config.StateDir(), "history")
 }
 
 func loadHistory() []string {
@@ -159,6 +277,7 @@ func saveHistory(hist []string) {
 	if len(hist) > 500 {
 		hist = hist[len(hist)-500:]
 	}
+	os.MkdirAll(filepath.Dir(historyPath()), 0755)
 	f, err := os.Create(historyPath())
 	if err != nil {
 		return
@@ -171,7 +290,7 @@ func saveHistory(hist []string) {
 
 // --- completions ---
 
-var slashCommands = []string{"/agent", "/model", "/skill", "/mcp", "/shell", "/chat", "/clear", "/help", "/quit", "/exit"}
+var slashCommands = []string{"/agent", "/model", "/tools", "/skill", "/mcp", "/shell", "/chat", "/cd", "/clear", "/speak", "/say", "/cost", "/prompt", "/retry", "/undo", "/undo-file", "/continue", "/changes", "/history", "/checkpoint", "/propose", "/auto", "/bg", "/help", "/quit", "/exit"}
 
 func (m *model) completions() []string {
 	val := m.input.Value()
@@ -187,14 +306,7 @@ func (m *model) completions() []string {
 	}
 	parts := strings.Fields(val)
 	if len(parts) == 1 && !strings.HasSuffix(val, " ") {
-		prefix := parts[0]
-		var out []string
-		for _, c := range slashCommands {
-			if strings.HasPrefix(c, prefix) && c != prefix {
-				out = append(out, c)
-			}
-		}
-		return out
+		return rankCandidates(parts[0], slashCommands, m.completionLimit())
 	}
 	if len(parts) >= 1 {
 		cmd := parts[0]
@@ -202,6 +314,15 @@ func (m *model) completions() []string {
 		if len(parts) >= 2 {
 			arg = parts[1]
 		}
+		if cmd == "/cd" {
+			var dirs []string
+			for _, p := range matchPaths(arg, ".", m.completionLimit()) {
+				if strings.HasSuffix(p, string(filepath.Separator)) {
+					dirs = append(dirs, p)
+				}
+			}
+			return dirs
+		}
 		var cands []string
 		switch cmd {
 		case "/agent":
@@ -212,8 +333,29 @@ func (m *model) completions() []string {
 		case "/model":
 			cands = append(cands, "list")
 			cands = append(cands, m.eng.Agent.Conf.Models...)
+		case "/tools":
+			cands = toolNames(m.eng.Agent.ToolDefs)
 		case "/shell":
 			cands = append(cands, "--context")
+		case "/clear":
+			cands = append(cands, "--keep-summary", "--no-summary")
+		case "/speak":
+			cands = append(cands, "on", "off")
+		case "/prompt":
+			cands = append(cands, "list", "save")
+			cands = append(cands, promptNames()...)
+		case "/bg":
+			cands = append(cands, "list", "merge", "drop")
+		case "/changes":
+			cands = append(cands, "revert")
+		case "/undo-file":
+			cands = backupPaths(m.eng.Agent.Registry.Backups())
+		case "/history":
+			cands = append(cands, "full")
+		case "/checkpoint":
+			cands = append(cands, "list")
+		case "/propose":
+			cands = append(cands, "on", "off", "review")
 		}
 		if len(cands) == 0 {
 			return nil
@@ -221,17 +363,29 @@ func (m *model) completions() []string {
 		if arg == "" {
 			return cands
 		}
-		var out []string
-		for _, c := range cands {
-			if strings.HasPrefix(c, arg) && c != arg {
-				out = append(out, c)
-			}
-		}
-		return out
+		return rankCandidates(arg, cands, m.completionLimit())
 	}
 	return nil
 }
 
+// completionQuery returns the token currently being completed.
+func (m *model) completionQuery() string {
+	val := m.input.Value()
+	parts := strings.Fields(val)
+	if len(parts) == 0 || strings.HasSuffix(val, " ") {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// completionLimit is the max number of completion candidates shown (ui.max_completions).
+func (m *model) completionLimit() int {
+	if m.cfg != nil && m.cfg.UI.MaxCompletions > 0 {
+		return m.cfg.UI.MaxCompletions
+	}
+	return 5
+}
+
 func (m *model) applyCompletion() {
 	comps := m.completions()
 	if len(comps) == 0 {
@@ -253,14 +407,30 @@ func (m *model) applyCompletion() {
 
 // --- model ---
 
+// turnRunner runs the turns of the chat. It is the engine; tests drive the
+// model with a scripted one, without a provider.
+//
+// Only the turns go through it. The rest of the model reads and changes the
+// engine's state directly (Agent, Messages, LastTurn, Pending, Changes, ...
+// for the status bar, /model, /tools, compression and the session), so an
+// interface for all of it would be a getter per field; tests build a real
+// engine on the mock provider for that instead, which needs no network.
+type turnRunner interface {
+	SendWithInteractive(ctx context.Context, userMsg string, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]engine.InteractiveInputRequest) (map[string]string, error)) error
+	RetryLast(ctx context.Context, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]engine.InteractiveInputRequest) (map[string]string, error)) error
+	RunAuto(ctx context.Context, task string, budget time.Duration, onText func(string), onToolCall func(string), onToolResult func(string)) (*engine.AutoRun, error)
+}
+
 type model struct {
 	eng      *engine.Engine
+	turns    turnRunner // runs the turns; eng but in tests
 	cfg      *config.Config
 	reg      *tool.Registry
 	sess     *session.Session
 	input    textinput.Model
 	spinner  spinner.Model
 	renderer *glamour.TermRenderer
+	mdStyle  ansi.StyleConfig // resolved once; auto-detection queries the terminal
 	width    int
 	waiting  bool
 	compIdx  int
@@ -270,8 +440,14 @@ type model struct {
 	histBuf   string
 	// streaming
 	streaming    string
-	streamCh     chan tea.Msg
-	lastStreamLn string // last partial line printed during streaming
+	streamCh     chan tea.Msg                // events of the running turn; nil once it ends or is cancelled
+	stopping     chan tea.Msg                // streamCh of a cancelled turn until its goroutine ends
+	answerCh     chan interactiveResponseMsg // answers to the running turn's interactive tool
+	lastStreamLn string                      // last partial line printed during streaming
+	resizeGen    int                         // bumped per resize; only the latest debounce tick rebuilds the renderer
+	heartbeat    engine.Heartbeat            // last idle report for the running request; zero when data is flowing
+	reasoning    string                      // tail of the model's thinking in the current round, for the status line
+	thinkingAt   time.Time                   // when that thinking started; zero once collapsed
 	compressing  bool
 	startTime    time.Time // track request start time
 	// shell mode
@@ -283,12 +459,25 @@ type model struct {
 	interactiveRequests []engine.InteractiveInputRequest
 	interactiveIndex    int
 	interactiveResults  map[string]string
-	// write confirmation
-	confirmMode       bool
-	confirmToolName   string
-	confirmArgs       map[string]any
-	confirmSkipFuture bool
-	isNonInteractive  bool // true for -m mode
+	interactiveChoice   int // the option ↑/↓ highlight in a select
+	// tool approval
+	confirmMode      bool
+	confirmToolName  string
+	approvalCh       chan engine.Decision // answers to the running turn's approval requests
+	review           *review              // the review of staged changes in progress, if any
+	isNonInteractive bool                 // true for -m mode
+	replay           tea.Cmd              // shows the end of a resumed session after the banner
+	queue            []string             // lines typed during a turn, sent in order once it ends
+	lastMessage      string               // last line sent to the model, for /prompt save
+	promptFill       *promptFillMsg       // /prompt waiting for its variables in interactive mode
+	autoUntil        time.Time            // deadline of the running /auto; zero otherwise
+	autoCheckpoints  []engine.Checkpoint  // checkpoints of the running /auto, shown again if it's stopped
+	bg               *bgTasks             // /bg turns
+	// read-aloud
+	speakCommand string             // ui.speak, or the platform default for /speak on
+	speakOn      bool               // speak each reply
+	speakStop    context.CancelFunc // stops the reply being spoken
+	speakWarned  bool               // a speech failure was reported already
 	// cancellation
 	cancelFn context.CancelFunc
 }
@@ -304,18 +493,58 @@ func initialModel(eng *engine.Engine, cfg *config.Config, reg *tool.Registry, se
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 
-	r, _ := glamour.NewTermRenderer(glamour.WithAutoStyle(), glamour.WithWordWrap(100))
-
 	cwd, _ := os.Getwd()
 	m := model{
-		eng: eng, cfg: cfg, reg: reg, sess: sess,
-		input: ti, spinner: sp, renderer: r,
+		eng: eng, turns: eng, cfg: cfg, reg: reg, sess: sess,
+		input: ti, spinner: sp, mdStyle: markdownStyle(),
 		histIdx: -1, inputHist: loadHistory(),
 		shellCwd: cwd,
+		bg:       newBgTasks(cfg.BackgroundTasks),
+	}
+	m.speakCommand = cfg.UI.SpeakCommand()
+	m.speakOn = m.speakCommand != ""
+	if !m.speakOn {
+		m.speakCommand = defaultSpeakCommand()
 	}
+	m.renderer = m.newRenderer()
 	return m
 }
 
+// resizeDebounce is how long the terminal must stay one size before the
+// markdown renderer is rebuilt, so dragging a tmux split doesn't thrash.
+const resizeDebounce = 150 * time.Millisecond
+
+type resizeMsg struct{ gen int }
+
+// markdownStyle picks the glamour style the way WithAutoStyle does, but once,
+// before bubbletea owns the terminal.
+func markdownStyle() ansi.StyleConfig {
+	if !isTerminal(os.Stdout) {
+		return styles.NoTTYStyleConfig
+	}
+	if lipgloss.HasDarkBackground() {
+		return styles.DarkStyleConfig
+	}
+	return styles.LightStyleConfig
+}
+
+// wrapWidth is the markdown wrap width: the terminal width capped at ui.max_width.
+func (m *model) wrapWidth() int {
+	w := m.cfg.UI.MaxWidth
+	if m.width > 0 && m.width < w {
+		w = m.width
+	}
+	return w
+}
+
+func (m *model) newRenderer() *glamour.TermRenderer {
+	r, err := glamour.NewTermRenderer(glamour.WithStyles(m.mdStyle), glamour.WithWordWrap(m.wrapWidth()))
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
 // printAbove returns a tea.Cmd that prints a line above the managed View area.
 func printAbove(s string) tea.Cmd {
 	return tea.Println(s)
@@ -328,8 +557,11 @@ func (m *model) quitCmd() tea.Cmd {
 		m.cancelFn()
 		m.cancelFn = nil
 	}
+	m.bg.stop()
+	m.countBg()
+	m.stopSpeaking()
 	tool.CloseBrowser()
-	bye := sDim.Render(fmt.Sprintf("👋 Bye! Resume with: gal-cli chat --session %s", m.sess.ID))
+	bye := sDim.Render(i18n.T("turn.bye", m.sess.ID))
 	return tea.Sequence(printAbove(bye), tea.Quit)
 }
 
@@ -362,18 +594,19 @@ func (m *model) statusBar() string {
 		elapsed = fmt.Sprintf(" %.1fs", time.Since(m.startTime).Seconds())
 	}
 	if m.waiting {
-		return m.spinner.View() + sFaint.Render(" thinking..."+elapsed)
+		return m.spinner.View() + sFaint.Render(i18n.T("status.thinking")+elapsed)
 	}
 	if m.compressing {
-		return m.spinner.View() + sFaint.Render(" compressing context..."+elapsed)
+		return m.spinner.View() + sFaint.Render(i18n.T("status.compressing")+elapsed)
 	}
 	if comps := m.completions(); len(comps) > 0 {
+		query := m.completionQuery()
 		var hints []string
 		for i, c := range comps {
 			if i == m.compIdx%len(comps) {
-				hints = append(hints, sHintSel.Render(c))
+				hints = append(hints, highlightMatch(c, query, sHintSel))
 			} else {
-				hints = append(hints, sHint.Render(c))
+				hints = append(hints, highlightMatch(c, query, sHint))
 			}
 		}
 		return sHint.Render("Tab: ") + strings.Join(hints, sHint.Render("  "))
@@ -383,9 +616,34 @@ func (m *model) statusBar() string {
 		if m.shellWithContext {
 			modeLabel = "[Shell+Context]"
 		}
+		if wd := m.workdirStatus(); wd != "" {
+			return sTool.Render(modeLabel+" ") + sFaint.Render(wd)
+		}
 		return sTool.Render(modeLabel+" ") + sFaint.Render(m.shellCwd)
 	}
-	return sBar.Render(fmt.Sprintf("%s │ %s", m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel))
+	bar := fmt.Sprintf("%s │ %s", m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel)
+	if m.eng.ToolsOff() {
+		bar += " │ " + i18n.T("status.tools_off")
+	}
+	if wd := m.workdirStatus(); wd != "" {
+		bar += " │ " + wd
+	}
+	if n := len(m.eng.Pending); n > 0 {
+		bar += " │ " + i18n.T("status.propose_staged", n)
+	} else if m.eng.Propose {
+		bar += " │ " + i18n.T("status.propose")
+	}
+	bar += " │ " + m.eng.ContextUsage().String()
+	if u := m.eng.Usage; u.PromptTokens+u.CompletionTokens > 0 {
+		bar += " │ " + record.FormatUsage(u)
+	}
+	if usd := m.eng.Cost.USD; usd > 0 {
+		bar += " │ " + record.FormatUSD(usd)
+	}
+	if bg := m.bgStatus(); bg != "" {
+		bar += " │ " + bg
+	}
+	return sBar.Render(bar)
 }
 
 func setIBeamCursor() tea.Msg {
@@ -399,7 +657,10 @@ func (m model) Init() tea.Cmd {
 		m.input.Cursor.SetMode(cursor.CursorStatic),
 		m.spinner.Tick,
 		setIBeamCursor,
-		tea.Println(banner(m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel, m.sess.ID)),
+		tea.Sequence(
+			tea.Println(banner(m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel, m.sess.ID, m.eng.ToolsOff(), m.eng.SystemPromptWarning())),
+			m.replay,
+		),
 	)
 }
 
@@ -408,52 +669,77 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		// Input wrapping and the streaming view read m.width on the next
+		// render; the renderer rebuild waits for the size to settle.
 		m.width = msg.Width
+		m.resizeGen++
+		gen := m.resizeGen
+		return m, tea.Tick(resizeDebounce, func(time.Time) tea.Msg { return resizeMsg{gen} })
+
+	case replayMsg:
+		return m, printAbove(string(msg))
+
+	case tea.ResumeMsg:
+		// back from Ctrl+Z: the shell reset the cursor and the terminal may
+		// have been resized meanwhile
+		return m, tea.Batch(setIBeamCursor, tea.WindowSize())
+
+	case terminateMsg:
+		return m, m.quitCmd()
+
+	case speakMsg:
+		return m, m.speakCmd(string(msg))
+
+	case speakErrMsg:
+		if m.speakWarned {
+			return m, nil
+		}
+		m.speakWarned = true
+		return m, printAbove(sFaint.Render(i18n.T("speech.failed", msg.err.Error())))
+
+	case resizeMsg:
+		if msg.gen == m.resizeGen {
+			m.renderer = m.newRenderer()
+		}
 		return m, nil
 
 	case tea.KeyMsg:
-		if msg.Type == tea.KeyCtrlC {
-			// If in interactive mode, cancel it
-			if m.interactiveMode {
-				m.interactiveMode = false
-				m.waiting = false
-				if m.cancelFn != nil {
-					m.cancelFn()
-					m.cancelFn = nil
-				}
-				// Send cancellation response to unblock goroutine
-				if m.streamCh != nil {
-					go func() {
-						m.streamCh <- interactiveResponseMsg{
-							results: nil,
-							err:     fmt.Errorf("cancelled"),
-						}
-					}()
-				}
-				// Clean up incomplete tool_call sequences
-				m.eng.Messages = cleanMessages(m.eng.Messages)
-				return m, printAbove(sErr.Render("✘ Interactive input cancelled"))
+		if msg.Type == tea.KeyCtrlZ {
+			if !suspendSupported {
+				return m, nil
 			}
-			// If waiting for LLM/tool response, cancel it
-			if m.waiting || m.compressing {
-				if m.cancelFn != nil {
-					m.cancelFn()
-					m.cancelFn = nil
-				}
-				m.streaming = ""
-				m.waiting = false
-				m.compressing = false
-				// Clean up incomplete tool_call sequences in case rollback didn't cover it
-				m.eng.Messages = cleanMessages(m.eng.Messages)
-				return m, printAbove(sErr.Render("✘ Cancelled"))
+			return m, suspendCmd()
+		}
+		if m.review != nil {
+			if msg.Type == tea.KeyEsc || msg.Type == tea.KeyCtrlC {
+				return m.leaveReview()
+			}
+			return m.answerReview(msg.String())
+		}
+		if msg.Type == tea.KeyCtrlC {
+			if m.interactiveMode || m.confirmMode || m.waiting || m.compressing {
+				return m, m.cancelTurn()
 			}
 			return m, m.quitCmd()
 		}
-		if m.waiting {
-			return m, nil
+		if m.confirmMode && msg.Type != tea.KeyEsc {
+			return m.answerApproval(msg.String())
 		}
 		switch msg.Type {
+		case tea.KeyEsc:
+			// the first Esc forgets queued lines, the next one cancels
+			if note := m.dropQueue(); note != "" {
+				return m, printAbove(note)
+			}
+			if m.interactiveMode || m.confirmMode || m.waiting || m.compressing {
+				return m, m.cancelTurn()
+			}
+			return m, nil
 		case tea.KeyUp:
+			if opts := m.interactiveOptions(); len(opts) > 0 {
+				m.interactiveChoice = (m.interactiveChoice - 1 + len(opts)) % len(opts)
+				return m, nil
+			}
 			if len(m.inputHist) > 0 {
 				if m.histIdx == -1 {
 					m.histBuf = m.input.Value()
@@ -466,6 +752,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 		case tea.KeyDown:
+			if opts := m.interactiveOptions(); len(opts) > 0 {
+				m.interactiveChoice = (m.interactiveChoice + 1) % len(opts)
+				return m, nil
+			}
 			if m.histIdx != -1 {
 				if m.histIdx < len(m.inputHist)-1 {
 					m.histIdx++
@@ -502,7 +792,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			
 			// Handle interactive input mode (allow empty input)
 			if m.interactiveMode {
-				return m, m.handleInteractiveInput(input)
+				cmd := m.handleInteractiveInput(input)
+				return m, cmd
 			}
 			
 			if input == "" {
@@ -510,58 +801,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			
 			m.inputHist = append(m.inputHist, input)
-			
-			// Check if it's a built-in slash command
-			// Extract first word (command part before first space)
-			firstWord := input
-			if idx := strings.Index(input, " "); idx > 0 {
-				firstWord = input[:idx]
-			}
-			
-			// List of built-in commands
-			builtinCommands := []string{
-				"/shell", "/chat", "/quit", "/exit", "/clear", 
-				"/skill", "/mcp", "/help", "/agent", "/model",
-			}
-			
-			isBuiltinCmd := false
-			for _, cmd := range builtinCommands {
-				if firstWord == cmd {
-					isBuiltinCmd = true
-					break
-				}
-			}
-			
-			if isBuiltinCmd {
-				if input == "/quit" || input == "/exit" {
-					return m, m.quitCmd()
-				}
-				msg, quit := m.handleCommand(input)
-				if quit {
-					return m, m.quitCmd()
-				}
-				// Return the message directly to Update
-				return m.Update(msg)
-			}
-			
-			// Not a built-in command
-			// If starts with / in chat mode, it's an unknown command
-			if !m.shellMode && strings.HasPrefix(input, "/") {
-				return m.Update(sErr.Render("Unknown command: " + firstWord + " (type /help)"))
-			}
-			
-			// shell mode: execute command directly
-			if m.shellMode {
-				// Show command being executed
-				return m, tea.Batch(
-					printAbove(sTool.Render("$ ")+input),
-					m.executeShellCmd(input),
-				)
+			if m.waiting || m.compressing || m.stopping != nil {
+				return m.queueInput(input)
 			}
-			// chat mode: send to LLM
-			m.waiting = true
-			m.startTime = time.Now()
-			return m, tea.Batch(printAbove(sPrompt.Render("▶ ")+input), m.sendCmd(input))
+			return m.submit(input)
 		}
 
 	case spinner.TickMsg:
@@ -569,65 +812,160 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.spinner, cmd = m.spinner.Update(msg)
 		cmds = append(cmds, cmd)
 
+	case streamEvent:
+		if msg.ch != m.streamCh || msg.msg == nil {
+			return m, nil // from a cancelled turn
+		}
+		return m.Update(msg.msg)
+
+	case turnStoppedMsg:
+		if msg.ch != m.stopping {
+			return m, nil
+		}
+		m.stopping = nil
+		if msg.clean {
+			m.eng.Messages = gal.CleanMessages(m.eng.Messages)
+		}
+		return m.sendQueued()
+
 	case streamChunkMsg:
 		m.streaming += string(msg)
+		m.heartbeat = engine.Heartbeat{}
+		if line := m.collapseReasoning(); line != "" {
+			return m, tea.Batch(printAbove(line), waitForStream(m.streamCh))
+		}
+		return m, waitForStream(m.streamCh)
+
+	case streamReasoningMsg:
+		if m.thinkingAt.IsZero() {
+			m.thinkingAt = time.Now()
+		}
+		m.reasoning += string(msg)
+		if n := len(m.reasoning) - 1000; n > 0 { // only the tail is shown
+			for n < len(m.reasoning) && !utf8.RuneStart(m.reasoning[n]) {
+				n++
+			}
+			m.reasoning = m.reasoning[n:]
+		}
+		m.heartbeat = engine.Heartbeat{}
+		return m, waitForStream(m.streamCh)
+
+	case heartbeatMsg:
+		m.heartbeat = engine.Heartbeat(msg)
+		return m, waitForStream(m.streamCh)
+
+	case streamStatusMsg:
+		return m, tea.Batch(printAbove(sTool.Render("⚠ "+string(msg))), waitForStream(m.streamCh))
+
+	case streamCompressingMsg:
+		m.compressing = bool(msg)
 		return m, waitForStream(m.streamCh)
 
 	case streamToolMsg:
-		return m, tea.Batch(printAbove(sTool.Render("⚡ "+string(msg))), waitForStream(m.streamCh))
+		m.heartbeat = engine.Heartbeat{}
+		if line := m.collapseReasoning(); line != "" {
+			return m, tea.Batch(printAbove(line+"\n"+sTool.Render(toolLine("⚡", string(msg)))), waitForStream(m.streamCh))
+		}
+		return m, tea.Batch(printAbove(sTool.Render(toolLine("⚡", string(msg)))), waitForStream(m.streamCh))
 
 	case streamToolResultMsg:
-		return m, tea.Batch(printAbove(renderToolResult(string(msg))), waitForStream(m.streamCh))
+		return m, tea.Batch(printAbove(renderToolResultMeta(msg.preview, msg.meta)), waitForStream(m.streamCh))
 
 	case streamDoneMsg:
+		m.heartbeat = engine.Heartbeat{}
+		m.reasoning = ""
+		m.thinkingAt = time.Time{}
 		elapsed := ""
 		if !m.startTime.IsZero() {
 			provider := strings.Split(m.eng.Agent.CurrentModel, "/")[0]
 			model := strings.Split(m.eng.Agent.CurrentModel, "/")[1]
-			elapsed = sDim.Render(fmt.Sprintf("✓ by %s/%s in %.2fs", provider, model, time.Since(m.startTime).Seconds()))
+			elapsed = sDim.Render(i18n.T("turn.done_by", provider, model, time.Since(m.startTime).Seconds()))
 			m.startTime = time.Time{} // reset
 		}
-		rendered := msg.content
-		if m.renderer != nil {
-			if out, err := m.renderer.Render(msg.content); err == nil {
-				rendered = strings.TrimRight(out, "\n")
-			}
+		m.sess.Turns = append(m.sess.Turns, m.eng.LastTurn)
+		if msg.auto != nil {
+			// the last turn is only the summary; the run's report covers it all
+			elapsed += "\n" + sFaint.Render(msg.auto.Report())
+			m.autoUntil, m.autoCheckpoints = time.Time{}, nil
+		} else if m.cfg.UI.ShowTurnSummary() && elapsed != "" {
+			elapsed += "\n" + sFaint.Render(m.eng.LastTurn.Summary())
 		}
+		rendered := m.renderMarkdown(msg.content)
 		m.streaming = ""
 		m.waiting = false
+		var speak tea.Cmd
+		if m.speakOn {
+			speak = m.speakCmd(speechText(msg.content))
+		}
+		if len(m.eng.Pending) > 0 {
+			var review tea.Cmd
+			m, review = m.startReview()
+			if elapsed != "" {
+				return m, tea.Batch(tea.Sequence(printAbove(rendered), printAbove(elapsed), review), speak)
+			}
+			return m, tea.Batch(tea.Sequence(printAbove(rendered), review), speak)
+		}
 		// trigger compression check
 		if m.eng.NeedsCompression() {
 			m.compressing = true
 			m.startTime = time.Now() // restart timer for compression
 			if elapsed != "" {
-				return m, tea.Batch(printAbove(rendered), printAbove(elapsed), m.compressCmd())
+				return m, tea.Batch(printAbove(rendered), printAbove(elapsed), m.compressCmd(), speak)
 			}
-			return m, tea.Batch(printAbove(rendered), m.compressCmd())
+			return m, tea.Batch(printAbove(rendered), m.compressCmd(), speak)
 		}
+		var next tea.Cmd
+		m, next = m.sendQueued()
 		if elapsed != "" {
-			return m, tea.Batch(printAbove(rendered), printAbove(elapsed))
+			return m, tea.Batch(tea.Sequence(printAbove(rendered), printAbove(elapsed), next), speak)
 		}
-		return m, printAbove(rendered)
+		return m, tea.Batch(tea.Sequence(printAbove(rendered), next), speak)
 
 	case shellCwdMsg:
 		m.shellCwd = string(msg)
+		if base, _ := os.Getwd(); base != m.shellCwd {
+			return m, printAbove(sFaint.Render(m.shellCwd) + sDim.Render(i18n.T("shell.cd_note", shortDir(base))))
+		}
 		return m, printAbove(sFaint.Render(m.shellCwd))
 
 	case compressDoneMsg:
 		elapsed := ""
 		if !m.startTime.IsZero() {
-			elapsed = sDim.Render(fmt.Sprintf("✓ context compressed in %.2fs", time.Since(m.startTime).Seconds()))
+			elapsed = sDim.Render(i18n.T("compress.done", time.Since(m.startTime).Seconds()))
 			m.startTime = time.Time{} // reset
 		}
 		m.compressing = false
+		var next tea.Cmd
+		m, next = m.sendQueued()
 		if elapsed != "" {
-			return m, printAbove(elapsed)
+			return m, tea.Sequence(printAbove(elapsed), next)
 		}
-		return m, nil
+		return m, next
 
 	case compressErrMsg:
 		m.compressing = false
-		return m, printAbove(sErr.Render("⚠ compress: " + msg.err.Error()))
+		var next tea.Cmd
+		m, next = m.sendQueued()
+		return m, tea.Sequence(printAbove(sErr.Render(i18n.T("compress.failed", msg.err.Error()))), next)
+
+	case remoteModelsReqMsg:
+		return m, tea.Sequence(printAbove(sFaint.Render(i18n.T("model.asking"))), m.remoteModelsCmd())
+
+	case clearSummaryMsg:
+		m.compressing = true
+		m.startTime = time.Now()
+		return m, m.clearSummaryCmd()
+
+	case clearDoneMsg:
+		m.compressing = false
+		m.startTime = time.Time{}
+		var next tea.Cmd
+		m, next = m.sendQueued()
+		if msg.err != nil {
+			return m, tea.Sequence(printAbove(sErr.Render(i18n.T("clear.failed", msg.err.Error()))), next)
+		}
+		return m, tea.Sequence(printAbove(sOK.Render(i18n.T("clear.summary_kept",
+			record.FormatTokens(msg.before), record.FormatTokens(msg.after)))), next)
 
 	case interactiveRequestMsg:
 		// Enter interactive mode
@@ -639,7 +977,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		
 		// Show first prompt
 		if len(msg.requests) > 0 {
-			return m, m.showInteractivePrompt()
+			cmd := m.showInteractivePrompt()
+			return m, cmd
 		}
 		return m, nil
 
@@ -647,6 +986,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Print echo first
 		cmds = append(cmds, printAbove(msg.echo))
 		// Then trigger next action
+		if m.interactiveIndex >= len(m.interactiveRequests) && m.promptFill != nil {
+			m.interactiveMode = false
+			next, cmd := m.fillPrompt(m.interactiveResults)
+			return next, tea.Sequence(printAbove(msg.echo), cmd)
+		}
 		if m.interactiveIndex < len(m.interactiveRequests) {
 			// More prompts to show - send message to show next prompt
 			cmds = append(cmds, func() tea.Msg {
@@ -656,32 +1000,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// All inputs collected
 			m.interactiveMode = false
 			m.waiting = true
-			cmds = append(cmds, func() tea.Msg {
-				// Send results back through the stream channel
-				m.streamCh <- interactiveResponseMsg{
-					results: m.interactiveResults,
-					err:     nil,
-				}
-				// Continue waiting for stream
-				return waitForStream(m.streamCh)()
-			})
+			// the buffered channel never blocks; the turn may have been cancelled
+			m.answerCh <- interactiveResponseMsg{results: m.interactiveResults}
+			cmds = append(cmds, waitForStream(m.streamCh))
 		}
 		return m, tea.Batch(cmds...)
 
+	case promptFillMsg:
+		return m.askPromptVars(msg)
+
+	case sendTextMsg:
+		return m.sendMessage(string(msg))
+
+	case retryMsg:
+		return m.retryLast()
+
+	case autoStartMsg:
+		return m.startAuto(msg)
+
+	case bgStartMsg:
+		return m.startBg(string(msg))
+
+	case bgDoneMsg:
+		return m.finishBg(msg)
+
+	case checkpointMsg:
+		m.autoCheckpoints = append(m.autoCheckpoints, engine.Checkpoint(msg))
+		return m, tea.Batch(printAbove(renderCheckpoint(engine.Checkpoint(msg))), waitForStream(m.streamCh))
+
+	case toolConfirmMsg:
+		return m.askApproval(msg)
+
+	case reviewStartMsg:
+		var cmd tea.Cmd
+		m, cmd = m.startReview()
+		return m, cmd
+
+	case reviewEditedMsg:
+		return m.reviewEdited(msg)
+
 	case interactiveNextPromptMsg:
 		// Show next prompt after echo has been printed
-		return m, m.showInteractivePrompt()
+		cmd := m.showInteractivePrompt()
+		return m, cmd
 
 	case shellModeMsg:
 		m.shellMode = msg.enable
 		m.shellWithContext = msg.withContext
 		if msg.enable {
 			if msg.withContext {
-				return m, printAbove(sOK.Render("✔ Entered shell mode with context (output will be added to conversation)"))
+				return m, printAbove(sOK.Render(i18n.T("shell.entered_context")))
 			}
-			return m, printAbove(sOK.Render("✔ Entered shell mode (type '/chat' to return)"))
+			return m, printAbove(sOK.Render(i18n.T("shell.entered")))
 		}
-		return m, printAbove(sOK.Render("✔ Returned to chat mode"))
+		return m, printAbove(sOK.Render(i18n.T("shell.returned")))
 
 	case shellOutputMsg:
 		return m, printAbove(string(msg))
@@ -689,10 +1061,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case shellResultMsg:
 		// Add to context if requested
 		if msg.withContext {
-			contextMsg := fmt.Sprintf("Shell command: %s\nOutput:\n%s", msg.command, msg.output)
+			contextMsg := fmt.Sprintf("Shell command: %s\nOutput:\n%s", msg.command, msg.context)
+			now := time.Now()
 			m.eng.Messages = append(m.eng.Messages, provider.Message{
-				Role:    "user",
-				Content: contextMsg,
+				Role:      "user",
+				Content:   contextMsg,
+				Timestamp: &now,
 			})
 		}
 		return m, printAbove(msg.output)
@@ -700,11 +1074,42 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case streamErrMsg:
 		m.streaming = ""
 		m.waiting = false
-		// Suppress cancelled errors (already shown by Ctrl+C handler)
+		m.countBg()
+		m.heartbeat = engine.Heartbeat{}
+		m.reasoning = ""
+		m.thinkingAt = time.Time{}
+		if !m.eng.LastTurn.Start.IsZero() {
+			m.sess.Turns = append(m.sess.Turns, m.eng.LastTurn)
+		}
+		// Suppress cancelled errors (already shown by cancelTurn)
 		if msg.err.Error() == "cancelled" || msg.err.Error() == "context canceled" {
 			return m, nil
 		}
-		return m, printAbove(sErr.Render("✘ " + msg.err.Error()))
+		out := sErr.Render("✘ " + msg.err.Error())
+		var perr *engine.PartialError
+		if errors.As(msg.err, &perr) {
+			out = m.renderMarkdown(perr.Text) + "\n" + sFaint.Render(engine.InterruptedNote) + "\n" + out +
+				sDim.Render(i18n.T("retry.partial_hint"))
+		}
+		var lerr *engine.RoundLimitError
+		if errors.As(msg.err, &lerr) {
+			out = sErr.Render("✘ " + lerr.Error())
+			if lerr.Report != "" {
+				out = m.renderMarkdown(lerr.Report) + "\n" + out
+			}
+			out += sDim.Render(i18n.T("continue.hint"))
+		}
+		if msg.auto != nil {
+			out += "\n" + sFaint.Render(msg.auto.Report()+"\n"+msg.auto.Summary)
+			m.autoUntil, m.autoCheckpoints = time.Time{}, nil
+		}
+		if n := len(m.eng.Pending); n > 0 {
+			out += "\n" + sFaint.Render(i18n.T("propose.waiting", n))
+		}
+		if note := m.dropQueue(); note != "" {
+			out += "\n" + note
+		}
+		return m, printAbove(out)
 	
 	case string:
 		// Handle string messages from handleCommand
@@ -714,11 +1119,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	prev := m.input.Value()
-	if !m.waiting {
-		var cmd tea.Cmd
-		m.input, cmd = m.input.Update(msg)
-		cmds = append(cmds, cmd)
-	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	cmds = append(cmds, cmd)
 	if m.input.Value() != prev {
 		m.compIdx = 0
 	}
@@ -726,6 +1129,90 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// submit runs a line typed in the input: a slash command, a shell command
+// in shell mode, or else a message to the model.
+func (m model) submit(input string) (tea.Model, tea.Cmd) {
+	// Check if it's a built-in slash command
+	// Extract first word (command part before first space)
+	firstWord := input
+	if idx := strings.Index(input, " "); idx > 0 {
+		firstWord = input[:idx]
+	}
+	
+	// List of built-in commands
+	builtinCommands := []string{
+		"/shell", "/chat", "/cd", "/quit", "/exit", "/clear", 
+		"/skill", "/mcp", "/help", "/agent", "/model", "/tools",
+		"/speak", "/say", "/cost", "/prompt", "/retry", "/undo", "/undo-file", "/continue", "/changes", "/history", "/checkpoint", "/propose", "/auto", "/bg",
+	}
+	
+	isBuiltinCmd := false
+	for _, cmd := range builtinCommands {
+		if firstWord == cmd {
+			isBuiltinCmd = true
+			break
+		}
+	}
+	
+	if isBuiltinCmd {
+		if input == "/quit" || input == "/exit" {
+			return m, m.quitCmd()
+		}
+		msg, quit := m.handleCommand(input)
+		if quit {
+			return m, m.quitCmd()
+		}
+		// Return the message directly to Update
+		return m.Update(msg)
+	}
+	
+	// Not a built-in command
+	// If starts with / in chat mode, it's an unknown command
+	if !m.shellMode && strings.HasPrefix(input, "/") {
+		return m.Update(sErr.Render(i18n.T("command.unknown", firstWord)))
+	}
+	
+	// shell mode: execute command directly
+	if m.shellMode {
+		// Show command being executed
+		return m, tea.Batch(
+			printAbove(sTool.Render("$ ")+input),
+			m.executeShellCmd(input),
+		)
+	}
+	return m.sendMessage(input)
+}
+
+// sendMessage starts a turn with text as the user's message.
+func (m model) sendMessage(text string) (tea.Model, tea.Cmd) {
+	m.waiting = true
+	m.startTime = time.Now()
+	m.lastMessage = text
+	return m, tea.Batch(printAbove(sPrompt.Render("▶ ")+text), m.sendCmd(text, nil, false))
+}
+
+// retryLast answers the last message again with the current model, in
+// place of the answer it got.
+func (m model) retryLast() (tea.Model, tea.Cmd) {
+	text := m.eng.LastUserMessage()
+	m.waiting = true
+	m.startTime = time.Now()
+	m.lastMessage = text
+	header := sFaint.Render(i18n.T("retry.answering", m.eng.Agent.CurrentModel)) + "\n" + sPrompt.Render("▶ ") + text
+	return m, tea.Batch(printAbove(header), m.sendCmd(text, nil, true))
+}
+
+// renderMarkdown renders an answer for the terminal, or returns it as is
+// when that fails.
+func (m *model) renderMarkdown(content string) string {
+	if m.renderer != nil {
+		if out, err := m.renderer.Render(content); err == nil {
+			return strings.TrimRight(out, "\n")
+		}
+	}
+	return content
+}
+
 // wrapInput renders the textinput value with soft-wrap and a cursor.
 func (m *model) wrapInput() string {
 	prompt := sPrompt.Render("> ")
@@ -738,6 +1225,9 @@ func (m *model) wrapInput() string {
 	val := m.input.Value()
 	pos := m.input.Position()
 	runes := []rune(val)
+	if m.input.EchoMode == textinput.EchoPassword { // a sensitive interactive field
+		runes = []rune(strings.Repeat("*", len(runes)))
+	}
 
 	// Insert a cursor marker
 	const cur = "\x00"
@@ -813,78 +1303,276 @@ func (m model) View() string {
 	if m.interactiveMode {
 		// Show interactive status
 		progress := fmt.Sprintf("%d/%d", m.interactiveIndex+1, len(m.interactiveRequests))
-		status := sInfo.Render(fmt.Sprintf("📝 Interactive input %s", progress)) + 
-			sFaint.Render(" (Ctrl+C to cancel)")
+		status := sInfo.Render(i18n.T("status.interactive", progress)) +
+			sFaint.Render(i18n.T("status.esc_cancel"))
+		if opts := m.interactiveOptions(); len(opts) > 0 {
+			status += sFaint.Render(i18n.T("status.interactive_choose"))
+			return m.optionsView(opts) + "\n" + m.wrapInput() + "\n" + status
+		}
 		return m.wrapInput() + "\n" + status
 	}
+	if m.confirmMode {
+		return m.confirmStatus()
+	}
+	if m.review != nil {
+		return m.reviewStatus()
+	}
 	if m.waiting {
-		elapsed := ""
-		if !m.startTime.IsZero() {
-			elapsed = fmt.Sprintf(" %.1fs", time.Since(m.startTime).Seconds())
+		// typing stays possible; Enter queues the line
+		return m.waitingView() + "\n" + m.wrapInput()
+	}
+	return m.wrapInput() + "\n" + m.statusBar()
+}
+
+// waitingView shows the turn in progress: the streamed answer, the model's
+// thinking or how long the request has been quiet.
+func (m model) waitingView() string {
+	elapsed := ""
+	if !m.startTime.IsZero() {
+		elapsed = fmt.Sprintf(" %.1fs", time.Since(m.startTime).Seconds())
+	}
+	elapsed += m.autoStatus()
+	if bg := m.bgStatus(); bg != "" {
+		elapsed += " · " + bg
+	}
+	if m.heartbeat.Idle > 0 {
+		status := m.spinner.View() + heartbeatStyle(m.heartbeat).Render(" "+m.heartbeat.String())
+		if m.streaming != "" {
+			return m.wrapStreaming() + "\n" + status
 		}
+		return status
+	}
+	if m.compressing { // between the rounds of a turn
+		status := m.spinner.View() + sFaint.Render(i18n.T("status.compressing")+elapsed)
 		if m.streaming != "" {
-			return m.streaming + "\n" + m.spinner.View() + sFaint.Render(" streaming..."+elapsed)
+			return m.wrapStreaming() + "\n" + status
 		}
-		return m.spinner.View() + sFaint.Render(" thinking..."+elapsed)
+		return status
 	}
-	return m.wrapInput() + "\n" + m.statusBar()
+	if m.streaming != "" {
+		return m.wrapStreaming() + "\n" + m.spinner.View() + sFaint.Render(i18n.T("status.streaming")+elapsed)
+	}
+	if m.reasoning != "" {
+		return m.spinner.View() + sFaint.Render(i18n.T("status.thinking_on", elapsed, m.reasoningPreview(len(elapsed)+14)))
+	}
+	return m.spinner.View() + sFaint.Render(i18n.T("status.thinking")+elapsed)
+}
+
+// collapseReasoning ends the live view of the model's thinking once the
+// answer or a tool call starts, returning a faint one-line trace of it.
+func (m *model) collapseReasoning() string {
+	if m.thinkingAt.IsZero() {
+		return ""
+	}
+	line := sFaint.Render(i18n.T("thinking.done", time.Since(m.thinkingAt).Seconds()))
+	m.reasoning = ""
+	m.thinkingAt = time.Time{}
+	return line
+}
+
+// reasoningPreview returns the end of the model's thinking on one line,
+// cut to fit the terminal next to reserved columns of status text.
+func (m model) reasoningPreview(reserved int) string {
+	s := strings.Join(strings.Fields(m.reasoning), " ")
+	w := 80
+	if m.width > 0 {
+		w = m.width
+	}
+	w = max(w-reserved, 10)
+	if r := []rune(s); len(r) > w {
+		s = "…" + string(r[len(r)-w+1:])
+	}
+	return s
+}
+
+// heartbeatStyle escalates from faint to yellow to red as a quiet stream
+// approaches the idle timeout.
+func heartbeatStyle(hb engine.Heartbeat) lipgloss.Style {
+	switch {
+	case hb.Stalling() >= 0.8:
+		return sErr
+	case hb.Stalling() >= 0.5:
+		return sTool
+	default:
+		return sFaint
+	}
+}
+
+// wrapStreaming soft-wraps the in-progress reply to the current width so a
+// resize mid-stream re-flows it instead of leaving lines cut at the old width.
+func (m *model) wrapStreaming() string {
+	if m.width <= 0 {
+		return m.streaming
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(m.streaming)
 }
 
 // --- send to LLM ---
 
+// waitForStream delivers the next event of a turn. A closed channel means
+// the turn was cancelled and yields nothing.
 func waitForStream(ch chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		return <-ch
+		msg, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return streamEvent{ch, msg}
+	}
+}
+
+// cancelTurn stops the running request, compression or interactive input.
+// The engine rolls the conversation back; events the turn still sends are
+// dropped. The engine is left alone until the turn's goroutine has ended:
+// lines typed meanwhile are queued, see turnStoppedMsg.
+func (m *model) cancelTurn() tea.Cmd {
+	if m.promptFill != nil { // no turn yet, only /prompt asking for variables
+		m.interactiveMode = false
+		m.input.EchoMode = textinput.EchoNormal
+		m.promptFill = nil
+		return printAbove(sErr.Render(i18n.T("prompt.cancelled")))
+	}
+	if m.cancelFn != nil {
+		m.cancelFn()
+		m.cancelFn = nil
+	}
+	wasInteractive := m.interactiveMode
+	stopping, clean := m.streamCh, m.autoUntil.IsZero()
+	m.streamCh = nil
+	m.streaming = ""
+	m.waiting = false
+	m.compressing = false
+	m.interactiveMode = false
+	m.input.EchoMode = textinput.EchoNormal
+	m.confirmMode = false
+	m.heartbeat = engine.Heartbeat{}
+	m.reasoning = ""
+	m.thinkingAt = time.Time{}
+	m.startTime = time.Time{}
+	out := sErr.Render(i18n.T("turn.cancelled"))
+	if !m.autoUntil.IsZero() {
+		// the engine keeps the rounds that finished and closes them itself
+		out = m.autoCancelNote()
+		m.autoUntil, m.autoCheckpoints = time.Time{}, nil
+	}
+	if wasInteractive {
+		m.input.Reset() // a half-typed answer, perhaps a password
+		out = sErr.Render(i18n.T("turn.interactive_cancelled"))
+	}
+	if note := m.dropQueue(); note != "" {
+		out += "\n" + note
+	}
+	if stopping == nil {
+		return printAbove(out)
+	}
+	// Clean up incomplete tool_call sequences in case rollback didn't cover
+	// it, once the turn no longer appends to the messages
+	m.stopping = stopping
+	return tea.Batch(printAbove(out), waitStopped(stopping, clean))
+}
+
+// waitStopped drains the events of the cancelled turn of ch until its
+// goroutine closes ch.
+func waitStopped(ch chan tea.Msg, clean bool) tea.Cmd {
+	return func() tea.Msg {
+		for range ch {
+		}
+		return turnStoppedMsg{ch, clean}
 	}
 }
 
-func (m *model) sendCmd(input string) tea.Cmd {
+// sendCmd runs a turn with input as the user's message, the autonomous run
+// auto when it is set, or with retry the last message's turn again.
+func (m *model) sendCmd(input string, auto *autoStartMsg, retry bool) tea.Cmd {
 	ch := make(chan tea.Msg, 64)
 	m.streamCh = ch
+	answers := make(chan interactiveResponseMsg, 1)
+	m.answerCh = answers
+	approvals := make(chan engine.Decision, 1)
+	m.approvalCh = approvals
 	ctx, cancel := context.WithCancel(context.Background())
 	m.cancelFn = cancel
-	eng := m.eng
+	// send never blocks once the turn is cancelled and nobody reads ch
+	send := func(msg tea.Msg) {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		}
+	}
+	eng, turns := m.eng, m.turns
+	eng.OnStatus = func(s string) { send(streamStatusMsg(s)) }
+	eng.OnCompressing = func(s string) { send(streamCompressingMsg(s != "")) }
+	eng.OnReasoning = func(s string) { send(streamReasoningMsg(s)) }
+	eng.OnHeartbeat = func(hb engine.Heartbeat) {
+		select {
+		case ch <- heartbeatMsg(hb):
+		default: // UI is behind; the next heartbeat will catch up
+		}
+	}
+	var toolMeta map[string]any // only touched from the engine's goroutine
+	eng.OnToolMeta = func(_ string, meta map[string]any) { toolMeta = meta }
+	eng.OnCheckpoint = func(c engine.Checkpoint) { send(checkpointMsg(c)) }
+	eng.OnToolApproval = func(name string, args map[string]any) (engine.Decision, error) {
+		send(toolConfirmMsg{toolName: name, preview: approvalPreview(name, args)})
+		select {
+		case d := <-approvals:
+			return d, nil
+		case <-ctx.Done():
+			return engine.Deny, ctx.Err()
+		}
+	}
+	m.heartbeat = engine.Heartbeat{}
+	m.reasoning = ""
+	m.thinkingAt = time.Time{}
 
 	go func() {
-		defer func() {
-			// Always send a terminal message so waitForStream never blocks forever
-			select {
-			case ch <- streamErrMsg{fmt.Errorf("cancelled")}:
-			default:
-			}
-		}()
+		// closing ends the last waitForStream of a cancelled turn
+		defer close(ch)
+		defer cancel()
 
 		var fullContent string
-		err := eng.SendWithInteractive(ctx, input,
-			func(text string) {
-				fullContent += text
-				ch <- streamChunkMsg(text)
-			},
-			func(name string) {
-				ch <- streamToolMsg(name)
-			},
-			func(preview string) {
-				ch <- streamToolResultMsg(preview)
-			},
-			func(requests []engine.InteractiveInputRequest) (map[string]string, error) {
-				ch <- interactiveRequestMsg{requests: requests}
-				// Wait for response, skip any non-response messages
-				for {
-					response := <-ch
-					if resp, ok := response.(interactiveResponseMsg); ok {
-						return resp.results, resp.err
-					}
-				}
-			},
-		)
+		onText := func(text string) {
+			fullContent += text
+			send(streamChunkMsg(text))
+		}
+		onToolCall := func(name string) {
+			send(streamToolMsg(name))
+		}
+		onToolResult := func(preview string) {
+			send(streamToolResultMsg{preview, toolMeta})
+			toolMeta = nil
+		}
+		var run *engine.AutoRun
+		var err error
+		onInteractive := func(requests []engine.InteractiveInputRequest) (map[string]string, error) {
+			send(interactiveRequestMsg{requests: requests})
+			select {
+			case resp := <-answers:
+				return resp.results, resp.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		switch {
+		case auto != nil:
+			run, err = turns.RunAuto(ctx, auto.task, auto.budget, onText, onToolCall, onToolResult)
+		case retry:
+			err = turns.RetryLast(ctx, onText, onToolCall, onToolResult, onInteractive)
+		default:
+			err = turns.SendWithInteractive(ctx, input, onText, onToolCall, onToolResult, onInteractive)
+		}
 		if err != nil {
 			if ctx.Err() != nil {
 				return // cancelled, rollback already done in engine
 			}
-			ch <- streamErrMsg{err}
+			send(streamErrMsg{err, run})
 			return
 		}
-		ch <- streamDoneMsg{fullContent}
+		if eng.LastTurn.Replays > 0 {
+			// the stored answer, without the repeated ending that streamed
+			fullContent = eng.Messages[len(eng.Messages)-1].Content
+		}
+		send(streamDoneMsg{fullContent, run})
 	}()
 
 	return waitForStream(ch)
@@ -906,6 +1594,21 @@ func (m *model) compressCmd() tea.Cmd {
 	}
 }
 
+// clearSummaryCmd runs /clear --keep-summary: the summary request can take
+// a while, so it runs like compression and can be cancelled with Esc or Ctrl+C.
+func (m *model) clearSummaryCmd() tea.Cmd {
+	eng := m.eng
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelFn = cancel
+	return func() tea.Msg {
+		before, after, err := eng.ClearWithSummary(ctx)
+		if err != nil && ctx.Err() != nil {
+			return nil // cancelled, already reported
+		}
+		return clearDoneMsg{before, after, err}
+	}
+}
+
 // --- slash commands ---
 
 func (m *model) handleCommand(input string) (tea.Msg, bool) {
@@ -921,16 +1624,132 @@ func (m *model) handleCommand(input string) (tea.Msg, bool) {
 		if m.shellMode {
 			return shellModeMsg{enable: false, withContext: false}, false
 		}
-		return sErr.Render("Already in chat mode"), false
+		return sErr.Render(i18n.T("shell.already_chat")), false
 	case "/quit", "/exit":
 		return "", true
 	case "/clear":
+		keep := m.cfg != nil && m.cfg.ClearKeepSummary
+		if len(parts) > 1 {
+			switch parts[1] {
+			case "--keep-summary":
+				keep = true
+			case "--no-summary":
+				keep = false
+			default:
+				return sErr.Render(i18n.T("clear.usage")), false
+			}
+		}
+		if keep && len(m.eng.Messages) > 1 {
+			return clearSummaryMsg{}, false
+		}
 		m.eng.Clear()
-		return sOK.Render("✔ Conversation cleared"), false
+		return sOK.Render(i18n.T("clear.done")), false
+	case "/tools":
+		defs := m.eng.Agent.ToolDefs
+		if len(parts) < 2 {
+			native := m.eng.Agent.Conf.NativeTools
+			if len(defs) == 0 && len(native) == 0 {
+				return sInfo.Render(i18n.T("tools.none")), false
+			}
+			lines := toolListLines(defs, native, m.eng.Agent.Registry)
+			if m.eng.ToolsOff() {
+				lines = append([]string{sTool.Render(i18n.T("tools.off", m.eng.Agent.CurrentModel))}, lines...)
+			}
+			return strings.Join(lines, "\n"), false
+		}
+		d, ok := findToolDef(defs, parts[1])
+		if !ok {
+			return sErr.Render(i18n.T("tools.unknown", parts[1])), false
+		}
+		md := toolSchemaMarkdown(d, m.eng.Agent.Registry.IsReadOnly(d.Name))
+		if m.renderer != nil {
+			if out, err := m.renderer.Render(md); err == nil {
+				return strings.TrimRight(out, "\n"), false
+			}
+		}
+		return md, false
+	case "/speak":
+		return m.handleSpeak(parts), false
+	case "/cost":
+		return m.costReport(), false
+	case "/prompt":
+		return m.handlePrompt(input), false
+	case "/retry":
+		if m.eng.Interrupted() {
+			return sendTextMsg(engine.ResumeMessage), false
+		}
+		if m.lastMessage != "" && m.eng.LastTurn.Error != "" && !m.eng.LastTurn.Retry && !m.eng.RoundLimited() {
+			return sendTextMsg(m.lastMessage), false // it failed and was rolled back
+		}
+		if m.eng.LastUserMessage() == "" {
+			return sErr.Render(i18n.T("retry.nothing")), false
+		}
+		return retryMsg{}, false
+	case "/undo":
+		return m.handleUndo(), false
+	case "/undo-file":
+		return m.handleUndoFile(parts), false
+	case "/continue":
+		if !m.eng.RoundLimited() {
+			return sErr.Render(i18n.T("continue.nothing")), false
+		}
+		return sendTextMsg(engine.ContinueMessage), false
+	case "/changes":
+		if len(parts) == 1 {
+			return renderChanges(m.eng.Changes, "/changes revert %d"), false
+		}
+		if len(parts) != 3 || parts[1] != "revert" {
+			return sErr.Render(i18n.T("changes.usage")), false
+		}
+		msg, err := revertChange(m.eng.Changes, parts[2])
+		if err != nil {
+			return sErr.Render("✘ " + err.Error()), false
+		}
+		return sOK.Render("✔ " + msg), false
+	case "/history":
+		if len(parts) > 2 || len(parts) == 2 && parts[1] != "full" {
+			return sErr.Render(i18n.T("history.usage")), false
+		}
+		var out string
+		if len(parts) == 2 {
+			archive, skipped, err := session.LoadArchive(m.sess.ID)
+			if err != nil {
+				return sErr.Render("✘ " + err.Error()), false
+			}
+			if len(archive) == 0 {
+				out = sInfo.Render(i18n.T("history.nothing_archived")) + "\n"
+			} else {
+				out = archiveList(archive) + "\n"
+			}
+			if skipped > 0 {
+				out += sErr.Render(i18n.T("history.skipped", skipped)) + "\n"
+			}
+		}
+		out += sInfo.Render(i18n.T("history.in_context", len(m.eng.Messages))) + "\n" + messageList(m.eng.Messages)
+		return strings.TrimRight(out, "\n"), false
+	case "/checkpoint":
+		return m.handleCheckpoint(parts), false
+	case "/cd":
+		return m.handleCd(input), false
+	case "/propose":
+		return m.handlePropose(parts), false
+	case "/auto":
+		return m.handleAuto(input), false
+	case "/bg":
+		return m.handleBg(input), false
+	case "/say":
+		text := strings.TrimSpace(strings.TrimPrefix(input, "/say"))
+		if text == "" {
+			return sErr.Render(i18n.T("say.usage")), false
+		}
+		if m.speakCommand == "" {
+			return sErr.Render(noSpeakCommand), false
+		}
+		return speakMsg(text), false
 	case "/skill":
 		skills := m.eng.Agent.Conf.Skills
 		if len(skills) == 0 {
-			return sInfo.Render("No skills loaded"), false
+			return sInfo.Render(i18n.T("skills.none")), false
 		}
 		var out []string
 		for _, s := range skills {
@@ -940,7 +1759,7 @@ func (m *model) handleCommand(input string) (tea.Msg, bool) {
 	case "/mcp":
 		mcps := m.eng.Agent.Conf.MCPs
 		if len(mcps) == 0 {
-			return sInfo.Render("No MCP servers configured"), false
+			return sInfo.Render(i18n.T("mcp.none")), false
 		}
 		var out []string
 		for name, conf := range mcps {
@@ -952,57 +1771,10 @@ func (m *model) handleCommand(input string) (tea.Msg, bool) {
 		for _, t := range m.eng.Agent.ToolDefs {
 			tools = append(tools, t.Name)
 		}
-		return sFaint.Render(fmt.Sprintf(`Session: %s
-Tools:   %s
-
-Commands:
-  /agent list          List agents
-  /agent <name>        Switch agent
-  /model list          List models
-  /model <name>        Switch model
-  /skill               List loaded skills
-  /mcp                 List MCP servers
-  /shell               Enter shell mode (execute commands with tab completion)
-  /shell --context     Enter shell mode and add output to conversation context
-  /chat                Return to chat mode (from shell)
-  /clear               Clear conversation
-  /quit                Exit
-
-Keys:
-  ↑/↓                  Input history (on first/last line)
-  Shift+Enter          New line
-  Tab/Shift+Tab        Autocomplete
-  Mouse wheel          Scroll screen
-
-Shell Mode:
-  - Tab completion for commands and paths (max 5 suggestions)
-  - Supports bash aliases (ll, la, etc.) from ~/.bashrc
-  - Full path commands work (/bin/ls, /usr/bin/python, etc.)
-  - Use '/shell --context' to make LLM aware of command outputs
-  - cd command changes directory
-  - All bash features (pipes, redirects, etc.)
-  - Type '/chat' to return to chat mode
-
-Interactive Tool:
-  - LLM can use 'interactive' tool to collect user input
-  - Supports text input and selection from options
-  - Progressive prompts (one question at a time)
-  - Sensitive fields (passwords) are marked with 🔒
-
-Browser Tool:
-  - LLM can use 'browser' tool for headless browser automation
-  - Actions: navigate, click, fill, select, screenshot, get_text, eval, etc.
-  - Use for web scraping, form filling, login automation, testing
-
-Non-Interactive Mode Examples:
-  gal-cli chat -m "your message"
-  gal-cli chat -m @prompt.txt
-  echo "test" | gal-cli chat -m -
-  gal-cli chat --session abc -m "continue"
-  gal-cli chat -a coder -m "write code" > output.txt`, m.sess.ID, strings.Join(tools, ", "))), false
+		return sFaint.Render(i18n.T("help.body", m.sess.ID, strings.Join(tools, ", "))), false
 	case "/agent":
 		if len(parts) < 2 {
-			return sInfo.Render("Agent: " + m.eng.Agent.Conf.Name), false
+			return sInfo.Render(i18n.T("agent.current", m.eng.Agent.Conf.Name)), false
 		}
 		if parts[1] == "list" {
 			names, err := config.ListAgents()
@@ -1023,13 +1795,23 @@ Non-Interactive Mode Examples:
 		if err != nil {
 			return sErr.Render("✘ " + err.Error()), false
 		}
+		newEng.Usage = m.eng.Usage
+		newEng.Cost = m.eng.Cost
+		newEng.AutoRuns = m.eng.AutoRuns
+		newEng.Changes = m.eng.Changes
+		newEng.GitCheckpoints, newEng.GitTrees = m.eng.GitCheckpoints, m.eng.GitTrees
+		newEng.SessionID = m.eng.SessionID
+		newEng.OnTurnComplete, newEng.OnArchive = m.eng.OnTurnComplete, m.eng.OnArchive // the session's hooks
 		*m.eng = *newEng
 		m.sess.Agent = m.eng.Agent.Conf.Name
 		m.sess.Model = m.eng.Agent.CurrentModel
-		return sOK.Render(fmt.Sprintf("✔ Agent: %s (model: %s)", m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel)), false
+		return sOK.Render(i18n.T("agent.switched", m.eng.Agent.Conf.Name, m.eng.Agent.CurrentModel)), false
 	case "/model":
 		if len(parts) < 2 {
-			return sInfo.Render("Model: " + m.eng.Agent.CurrentModel), false
+			return sInfo.Render(i18n.T("model.current", m.eng.Agent.CurrentModel)), false
+		}
+		if parts[1] == "list" && len(parts) > 2 && parts[2] == "--remote" {
+			return remoteModelsReqMsg{}, false
 		}
 		if parts[1] == "list" {
 			var out []string
@@ -1045,40 +1827,98 @@ Non-Interactive Mode Examples:
 		newModel := parts[1]
 		mp := strings.SplitN(newModel, "/", 2)
 		if len(mp) != 2 {
-			return sErr.Render("✘ invalid model format: " + newModel + " (expected provider/model)"), false
+			return sErr.Render(i18n.T("model.invalid", newModel)), false
 		}
-		p, err := makeProvider(m.cfg, mp[0])
+		p, err := setup.Provider(m.cfg, mp[0])
 		if err != nil {
 			return sErr.Render("✘ " + err.Error()), false
 		}
 		m.eng.Provider = p
 		m.eng.SwitchModel(newModel)
 		m.sess.Model = m.eng.Agent.CurrentModel
-		return sOK.Render("✔ Model: " + m.eng.Agent.CurrentModel), false
+		out := sOK.Render(i18n.T("model.switched", m.eng.Agent.CurrentModel))
+		if m.eng.ToolsOff() {
+			out += sTool.Render(i18n.T("model.no_tools"))
+		}
+		if w := m.eng.SystemPromptWarning(); w != "" {
+			out += "\n" + sErr.Render("⚠ "+w)
+		}
+		return out, false
 	default:
-		return sErr.Render("Unknown command: " + cmd + " (type /help)"), false
+		return sErr.Render(i18n.T("command.unknown", cmd)), false
 	}
 }
 
 // --- entry ---
 
-func runChat(agentName, modelName, sessionID, message string, debug bool) error {
-	session.Cleanup()
-
+func runChat(agentName, modelName, sessionID, message string, debug bool, debugFormat string, metricsPort int, opts onceOptions) error {
+	if note := config.MigrationNote(); note != "" {
+		fmt.Fprintln(os.Stderr, opts.mark("ℹ", "[info]")+" "+opts.text(note))
+	}
 	cfg, err := config.Load()
+	if errors.Is(err, fs.ErrNotExist) {
+		if message != "" || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			return errNoConfig
+		}
+		cfg, err = onboard()
+	}
 	if err != nil {
 		return fmt.Errorf("run 'gal-cli init' first: %w", err)
 	}
+	session.Cleanup(cfg.SessionRetention())
 	if agentName == "" {
 		agentName = cfg.DefaultAgent
 	}
-	reg := tool.NewRegistry()
+	responseFormat, err := parseResponseFormat(opts.responseFormat, opts.jsonSchema)
+	if err != nil {
+		return err
+	}
+	if responseFormat != nil && message == "" {
+		return fmt.Errorf("--response-format and --json-schema need -m")
+	}
+	if opts.toolChoice != "" && message == "" {
+		return fmt.Errorf("--tool-choice needs -m")
+	}
+	if debugFormat != "text" && debugFormat != "jsonl" {
+		return fmt.Errorf("--debug-format %s: use text or jsonl", debugFormat)
+	}
+	if opts.auto != "" {
+		if message == "" {
+			return fmt.Errorf("--auto needs -m")
+		}
+		if responseFormat != nil {
+			return fmt.Errorf("--auto doesn't go with --response-format or --json-schema")
+		}
+		if _, err := parseBudget(opts.auto); err != nil {
+			return fmt.Errorf("--auto: %w", err)
+		}
+	}
+	if metricsPort == 0 {
+		metricsPort = cfg.MetricsPort
+	}
+	metricsAddr := ""
+	if metricsPort > 0 {
+		metricsAddr = fmt.Sprintf("127.0.0.1:%d", metricsPort) // loopback only; the metrics aren't for the network
+	}
+	stopTelemetry, err := engine.EnableTelemetry(context.Background(), metricsAddr)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // flush pending spans
+		defer cancel()
+		stopTelemetry(ctx)
+	}()
+	reg, err := gal.NewRegistry(cfg)
+	if err != nil {
+		return err
+	}
 
 	// load or create session
 	var sess *session.Session
 	var resumed bool
 	if sessionID != "" {
-		sess, err = session.Load(sessionID)
+		sess, err = gal.LoadSession(sessionID)
 		if err == nil {
 			resumed = true
 			agentName = sess.Agent
@@ -1086,7 +1926,7 @@ func runChat(agentName, modelName, sessionID, message string, debug bool) error
 			sess = session.New(sessionID, agentName, "")
 		}
 	} else {
-		sess = session.New(session.NewID(), agentName, "")
+		sess = gal.NewSession(agentName)
 	}
 
 	eng, err := buildEngine(cfg, agentName, reg)
@@ -1094,86 +1934,248 @@ func runChat(agentName, modelName, sessionID, message string, debug bool) error
 		return err
 	}
 
-	// restore model from session if resuming
-	if resumed && sess.Model != "" {
-		mp := strings.SplitN(sess.Model, "/", 2)
-		if len(mp) == 2 {
-			if p, err := makeProvider(cfg, mp[0]); err == nil {
-				eng.Provider = p
-				eng.SwitchModel(sess.Model)
-			}
-		}
-		eng.Messages = sess.Messages
+	if resumed {
+		gal.Resume(cfg, eng, sess)
 	}
+	eng.SessionID = sess.ID
+	reg.SetBackupDir(session.BackupDir(sess.ID))
+	eng.OnTurnComplete = func() { autosave(eng, sess) }
+	gal.KeepArchive(eng, sess)
 
 	// override model if specified via flag
 	if modelName != "" {
-		mp := strings.SplitN(modelName, "/", 2)
-		if len(mp) == 2 {
-			if p, err := makeProvider(cfg, mp[0]); err == nil {
-				eng.Provider = p
-				eng.SwitchModel(modelName)
-			}
-		}
+		gal.SwitchModel(cfg, eng, modelName)
 	}
 
 	sess.Model = eng.Agent.CurrentModel
 
-	eng.ContextLimit = cfg.ContextLimit
 	eng.Debug = debug
+	eng.DebugFormat = debugFormat
 	if debug {
 		eng.InitDebug()
 	}
 	defer eng.Close()
 
+	eng.OnStatus = func(s string) { fmt.Fprintln(os.Stderr, opts.mark("⚠", "[warn]")+" "+opts.text(s)) }
+	eng.OnCompressing = func(s string) {
+		if s != "" && !opts.quiet {
+			fmt.Fprintln(os.Stderr, sFaint.Render(opts.mark("🗜", "[compress]")+" "+opts.text(s)))
+		}
+	}
+	eng.CheckToolLimits()
+	eng.CheckSystemPrompt()
+	if err := eng.Compression.ModelErr; err != nil {
+		eng.OnStatus(err.Error() + "; compressing with " + eng.Agent.CurrentModel)
+	}
+	eng.ResponseFormat = responseFormat
+	if opts.toolChoice != "" {
+		if err := setup.CheckToolChoice(opts.toolChoice, eng.Agent.ToolDefs); err != nil {
+			return err
+		}
+		eng.ToolChoice = opts.toolChoice
+	}
+	if message != "" && eng.ToolsOff() {
+		eng.OnStatus(eng.Agent.CurrentModel + " has no tool support; tools are off")
+	}
+
 	// non-interactive mode
 	if message != "" {
-		return runOnce(eng, sess, message, debug)
+		return runOnce(eng, cfg, sess, message, opts)
 	}
 
 	// interactive mode
 	m := initialModel(eng, cfg, reg, sess)
 	m.isNonInteractive = false // interactive mode
-	p := tea.NewProgram(m)
+	if resumed {
+		m.replay = m.replayCmd()
+	}
+	p := tea.NewProgram(m, tea.WithoutSignalHandler())
+	stopSignals := handleTermSignals(p)
 	_, err = p.Run()
-	fmt.Print("\033[0 q") // restore default cursor
+	stopSignals()
+	restoreCursor()
+	if errors.Is(err, tea.ErrProgramKilled) {
+		err = nil // killed after a signal; the terminal is restored
+	}
 
 	// save session on exit — clean up incomplete tool_call sequences
-	sess.Messages = cleanMessages(eng.Messages)
-	sess.Agent = eng.Agent.Conf.Name
-	sess.Model = eng.Agent.CurrentModel
+	gal.Record(eng, sess)
 	sess.Save()
 
 	return err
 }
 
-func runOnce(eng *engine.Engine, sess *session.Session, message string, debug bool) error {
+func runOnce(eng *engine.Engine, cfg *config.Config, sess *session.Session, message string, opts onceOptions) error {
 	// read message from various sources
 	content, err := readMessage(message)
 	if err != nil {
 		return fmt.Errorf("failed to read message: %w", err)
 	}
 
-	// simple callbacks: stdout for LLM, stderr for tools
+	// simple callbacks: stdout for LLM, stderr for tools and thinking
+	thinking := false // reasoning is being written to stderr
 	onText := func(s string) {
+		if thinking {
+			fmt.Fprintln(os.Stderr)
+			thinking = false
+		}
 		fmt.Print(s)
 	}
+	if !opts.quiet {
+		eng.OnReasoning = func(s string) {
+			if !thinking {
+				fmt.Fprintf(os.Stderr, "%s ", opts.mark("💭", "[thinking]"))
+				thinking = true
+			}
+			fmt.Fprint(os.Stderr, s)
+		}
+	}
 	onToolCall := func(name string) {
-		fmt.Fprintf(os.Stderr, "🔧 %s\n", name)
+		if thinking {
+			fmt.Fprintln(os.Stderr)
+			thinking = false
+		}
+		if !opts.quiet {
+			fmt.Fprintln(os.Stderr, toolLine(opts.mark("🔧", "[tool]"), opts.text(name)))
+		}
+	}
+	var onToolResult func(string)
+	if opts.showToolResults {
+		onToolResult = func(preview string) {
+			preview = strings.ReplaceAll(strings.TrimRight(preview, "\n"), "\n", "\n    ")
+			fmt.Fprintf(os.Stderr, "  %s %s\n", opts.mark("→", "[result]"), opts.text(preview))
+		}
+	}
+	eng.OnToolApproval = onceApproval(opts)
+	// heartbeats less often than the TUI, so CI logs show liveness without noise
+	eng.HeartbeatInterval = onceHeartbeatInterval
+	if !opts.quiet {
+		eng.OnHeartbeat = func(hb engine.Heartbeat) {
+			fmt.Fprintf(os.Stderr, "\n%s %s\n", opts.mark("⏳", "[wait]"), opts.text(hb.String()))
+		}
+		eng.OnCheckpoint = func(c engine.Checkpoint) {
+			fmt.Fprintf(os.Stderr, "%s %s\n", opts.mark("📍", "[checkpoint]"), opts.text(c.Text))
+		}
+	}
+	if opts.jsonOut {
+		var mu sync.Mutex // heartbeats arrive from another goroutine
+		enc := json.NewEncoder(os.Stdout)
+		emit := func(ev map[string]any) {
+			mu.Lock()
+			defer mu.Unlock()
+			enc.Encode(ev)
+		}
+		onText = func(s string) { emit(map[string]any{"type": "text", "content": s}) }
+		onToolCall = func(name string) { emit(map[string]any{"type": "tool_call", "name": name}) }
+		var toolMeta map[string]any
+		eng.OnToolMeta = func(_ string, meta map[string]any) { toolMeta = meta }
+		onToolResult = func(preview string) {
+			ev := map[string]any{"type": "tool_result", "result": preview}
+			if toolMeta != nil {
+				ev["meta"] = toolMeta
+				toolMeta = nil
+			}
+			emit(ev)
+		}
+		eng.OnReasoning = func(s string) { emit(map[string]any{"type": "reasoning", "content": s}) }
+		eng.OnHeartbeat = func(hb engine.Heartbeat) {
+			emit(map[string]any{"type": "heartbeat", "elapsed_ms": hb.Elapsed.Milliseconds(), "idle_ms": hb.Idle.Milliseconds()})
+		}
+		eng.OnCheckpoint = func(c engine.Checkpoint) {
+			emit(map[string]any{"type": "checkpoint", "text": c.Text, "time": c.Time})
+		}
+	}
+	if eng.ResponseFormat != nil {
+		// the answer may be retried, so it's printed once it's valid JSON
+		onText = func(string) {
+			if thinking {
+				fmt.Fprintln(os.Stderr)
+				thinking = false
+			}
+		}
 	}
 
 	ctx := context.Background()
-	err = eng.SendWithCallbacks(ctx, content, onText, onToolCall, nil)
+	var run *engine.AutoRun
+	if opts.auto != "" {
+		run, err = runAutoOnce(ctx, eng, content, opts, onText, onToolCall, onToolResult)
+	} else {
+		onInteractive, closeTTY := ttyInteractive(opts)
+		err = eng.SendWithInteractive(ctx, content, onText, onToolCall, onToolResult, onInteractive)
+		closeTTY()
+	}
 
 	// save session
-	sess.Messages = eng.Messages
-	sess.Agent = eng.Agent.Conf.Name
-	sess.Model = eng.Agent.CurrentModel
+	if len(eng.Pending) > 0 {
+		onceReview(eng, opts)
+	}
+	sess.Turns = append(sess.Turns, eng.LastTurn)
+	gal.Record(eng, sess)
 	sess.Save()
 
+	if err == nil && eng.ResponseFormat != nil {
+		answer := eng.Messages[len(eng.Messages)-1].Content
+		if opts.jsonOut {
+			json.NewEncoder(os.Stdout).Encode(map[string]any{"type": "text", "content": answer})
+		} else {
+			fmt.Print(answer)
+		}
+	}
+	if opts.jsonOut {
+		ev := map[string]any{"type": "done", "session": sess.ID, "stats": eng.LastTurn, "context": eng.ContextUsage()}
+		if run != nil {
+			ev["auto"] = run
+		}
+		if err != nil {
+			ev["type"] = "error"
+			ev["error"] = err.Error()
+			var perr *provider.Error
+			if errors.As(err, &perr) {
+				ev["kind"] = perr.Kind
+				ev["retryable"] = perr.Retryable()
+				if perr.RetryAfter > 0 {
+					ev["retry_after"] = perr.RetryAfter.Seconds()
+				}
+			}
+			var partial *engine.PartialError
+			if errors.As(err, &partial) {
+				ev["partial"] = true
+			}
+			var limit *engine.RoundLimitError
+			if errors.As(err, &limit) {
+				ev["round_limit"] = true
+			}
+		}
+		json.NewEncoder(os.Stdout).Encode(ev)
+		return err
+	}
+	if err != nil && run != nil && !opts.quiet {
+		fmt.Fprintf(os.Stderr, "\n%s\n%s\n", autoOnceLine(run, opts), run.Summary)
+	}
 	if err == nil {
 		fmt.Println() // trailing newline
-		fmt.Fprintf(os.Stderr, "\n💾 Session: %s (resume with --session %s)\n", sess.ID, sess.ID)
+		if !opts.quiet {
+			if run != nil {
+				fmt.Fprintf(os.Stderr, "\n%s\n", autoOnceLine(run, opts))
+			} else if cfg.UI.ShowTurnSummary() {
+				summary := eng.LastTurn.Summary()
+				if u := eng.Usage; u.PromptTokens > eng.LastTurn.PromptTokens {
+					summary += " (session " + record.FormatUsage(u) + ")"
+				}
+				if opts.plain {
+					summary = "[turn] " + opts.text(strings.TrimPrefix(summary, "◷ "))
+				}
+				fmt.Fprintf(os.Stderr, "\n%s\n", summary)
+			}
+			if line := costLine(eng); line != "" {
+				fmt.Fprintf(os.Stderr, "%s %s\n", opts.mark("💰", "[cost]"), opts.text(line))
+			}
+			fmt.Fprintf(os.Stderr, "%s %s\n", opts.mark("📊", "[context]"), opts.text(eng.ContextUsage().String()))
+			// scripts never want the resume hint, only people at a terminal
+			if isTerminal(os.Stderr) {
+				fmt.Fprintf(os.Stderr, "\n%s Session: %s (resume with --session %s)\n", opts.mark("💾", "[session]"), sess.ID, sess.ID)
+			}
+		}
 	}
 	return err
 }
@@ -1198,10 +2200,64 @@ func readMessage(message string) (string, error) {
 		return string(b), nil
 	}
 
+	// saved prompt
+	if spec, ok := strings.CutPrefix(message, "prompt:"); ok {
+		return expandPromptMessage(spec)
+	}
+
 	// direct string
 	return message, nil
 }
 
+// parseResponseFormat builds the ResponseFormat for --response-format and
+// --json-schema; nil means a free-form answer.
+func parseResponseFormat(format, schema string) (*provider.ResponseFormat, error) {
+	switch format {
+	case "", "text":
+		if schema == "" {
+			return nil, nil
+		}
+		if format == "text" {
+			return nil, fmt.Errorf("--json-schema needs --response-format json")
+		}
+	case "json", "json_object", "json_schema":
+	default:
+		return nil, fmt.Errorf("unknown --response-format %q (use text or json)", format)
+	}
+	rf := &provider.ResponseFormat{}
+	if schema == "" {
+		if format == "json_schema" {
+			return nil, fmt.Errorf("--response-format json_schema needs --json-schema")
+		}
+		return rf, nil
+	}
+	data := []byte(schema)
+	if strings.HasPrefix(schema, "@") {
+		var err error
+		if data, err = os.ReadFile(schema[1:]); err != nil {
+			return nil, err
+		}
+		rf.Name = strings.TrimSuffix(filepath.Base(schema[1:]), filepath.Ext(schema))
+	}
+	if err := json.Unmarshal(data, &rf.Schema); err != nil {
+		return nil, fmt.Errorf("invalid --json-schema: %w", err)
+	}
+	if title, ok := rf.Schema["title"].(string); ok && title != "" {
+		rf.Name = title
+	}
+	// OpenAI accepts only letters, digits, _ and - in schema names
+	rf.Name = strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, rf.Name)
+	if len(rf.Name) > 64 {
+		rf.Name = rf.Name[:64]
+	}
+	return rf, nil
+}
+
 // --- shell mode functions ---
 
 func (m *model) shellCompletions() []string {
@@ -1214,7 +2270,7 @@ func (m *model) shellCompletions() []string {
 	
 	// First word: complete command names
 	if len(parts) == 1 && !strings.HasSuffix(val, " ") {
-		return matchCommands(parts[0], 5)
+		return matchCommands(parts[0], m.completionLimit())
 	}
 	
 	// Other words: complete paths
@@ -1222,19 +2278,52 @@ func (m *model) shellCompletions() []string {
 	if strings.HasSuffix(val, " ") {
 		lastArg = ""
 	}
-	return matchPaths(lastArg, 5)
+	return matchPaths(lastArg, m.shellCwd, m.completionLimit())
+}
+
+// pathCommands caches the command names found on $PATH, since reading every
+// directory on it at each keystroke is slow. The scan is redone when PATH or
+// PATHEXT change, or after pathCommandsTTL so newly installed tools show up.
+var pathCommands struct {
+	sync.Mutex
+	key   string
+	at    time.Time
+	names []string
 }
 
+const pathCommandsTTL = 30 * time.Second
+
 func matchCommands(prefix string, limit int) []string {
+	return rankCandidates(prefix, commandNames(), limit)
+}
+
+// commandNames returns the commands on $PATH, from pathCommands if fresh.
+func commandNames() []string {
 	pathEnv := os.Getenv("PATH")
 	if pathEnv == "" {
 		return nil
 	}
+	key := pathEnv + "\x00" + os.Getenv("PATHEXT")
+	pathCommands.Lock()
+	defer pathCommands.Unlock()
+	if pathCommands.key == key && time.Since(pathCommands.at) < pathCommandsTTL {
+		return pathCommands.names
+	}
+	
+	// On Windows only files with an executable extension (PATHEXT) are commands
+	var pathExt []string
+	if runtime.GOOS == "windows" {
+		exts := os.Getenv("PATHEXT")
+		if exts == "" {
+			exts = ".COM;.EXE;.BAT;.CMD"
+		}
+		pathExt = filepath.SplitList(strings.ToLower(exts))
+	}
 	
 	seen := make(map[string]bool)
 	var matches []string
 	
-	for _, dir := range strings.Split(pathEnv, ":") {
+	for _, dir := range filepath.SplitList(pathEnv) {
 		entries, err := os.ReadDir(dir)
 		if err != nil {
 			continue
@@ -1244,35 +2333,30 @@ func matchCommands(prefix string, limit int) []string {
 				continue
 			}
 			name := e.Name()
-			if strings.HasPrefix(name, prefix) && !seen[name] {
+			if pathExt != nil {
+				ext := strings.ToLower(filepath.Ext(name))
+				if !slices.Contains(pathExt, ext) {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if !seen[name] {
 				seen[name] = true
 				matches = append(matches, name)
 			}
 		}
 	}
 	
-	// Sort by relevance: shorter names (better match) first
-	sort.Slice(matches, func(i, j int) bool {
-		// Calculate match score: prefix_len / total_len
-		scoreI := float64(len(prefix)) / float64(len(matches[i]))
-		scoreJ := float64(len(prefix)) / float64(len(matches[j]))
-		if scoreI != scoreJ {
-			return scoreI > scoreJ // Higher score first
-		}
-		return matches[i] < matches[j] // Alphabetical as tiebreaker
-	})
-	
-	if len(matches) > limit {
-		matches = matches[:limit]
-	}
+	pathCommands.key, pathCommands.at, pathCommands.names = key, time.Now(), matches
 	return matches
 }
 
-func matchPaths(prefix string, limit int) []string {
+// matchPaths completes a path, reading relative ones from the directory cwd.
+func matchPaths(prefix, cwd string, limit int) []string {
 	dir := "."
 	base := prefix
 	
-	if strings.Contains(prefix, "/") {
+	if strings.ContainsAny(prefix, "/"+string(filepath.Separator)) {
 		dir = filepath.Dir(prefix)
 		base = filepath.Base(prefix)
 	}
@@ -1283,7 +2367,11 @@ func matchPaths(prefix string, limit int) []string {
 		dir = strings.Replace(dir, "~", home, 1)
 	}
 	
-	entries, err := os.ReadDir(dir)
+	readDir := dir
+	if !filepath.IsAbs(dir) {
+		readDir = filepath.Join(cwd, dir)
+	}
+	entries, err := os.ReadDir(readDir)
 	if err != nil {
 		return nil
 	}
@@ -1294,11 +2382,11 @@ func matchPaths(prefix string, limit int) []string {
 		if strings.HasPrefix(name, base) {
 			fullPath := filepath.Join(dir, name)
 			if e.IsDir() {
-				fullPath += "/"
+				fullPath += string(filepath.Separator)
 			}
 			// Make path relative if it was relative
-			if !strings.HasPrefix(prefix, "/") && !strings.HasPrefix(prefix, "~") {
-				fullPath = strings.TrimPrefix(fullPath, "./")
+			if !filepath.IsAbs(prefix) && !strings.HasPrefix(prefix, "~") {
+				fullPath = strings.TrimPrefix(fullPath, "."+string(filepath.Separator))
 			}
 			matches = append(matches, fullPath)
 		}
@@ -1315,8 +2403,8 @@ func matchPaths(prefix string, limit int) []string {
 			return scoreI > scoreJ
 		}
 		// Directories first, then alphabetical
-		isDirI := strings.HasSuffix(matches[i], "/")
-		isDirJ := strings.HasSuffix(matches[j], "/")
+		isDirI := strings.HasSuffix(matches[i], string(filepath.Separator))
+		isDirJ := strings.HasSuffix(matches[j], string(filepath.Separator))
 		if isDirI != isDirJ {
 			return isDirI
 		}
@@ -1330,6 +2418,7 @@ func matchPaths(prefix string, limit int) []string {
 }
 
 func (m *model) executeShellCmd(input string) tea.Cmd {
+	maxLines, width, withContext, shellConf := m.cfg.UI.ShellLines, m.width, m.shellWithContext, m.cfg.Shell
 	return func() tea.Msg {
 		// Handle cd command specially
 		if strings.HasPrefix(input, "cd ") || input == "cd" {
@@ -1345,40 +2434,35 @@ func (m *model) executeShellCmd(input string) tea.Cmd {
 			if !filepath.IsAbs(path) {
 				path = filepath.Join(m.shellCwd, path)
 			}
-			if err := os.Chdir(path); err != nil {
+			// only shell mode moves; the file tools stay where they are (see /cd)
+			fi, err := os.Stat(path)
+			if err == nil && !fi.IsDir() {
+				err = fmt.Errorf("not a directory: %s", path)
+			}
+			if err != nil {
 				return shellOutputMsg(sErr.Render("✘ " + err.Error()))
 			}
-			// Update shellCwd
-			newCwd, _ := os.Getwd()
-			return shellCwdMsg(newCwd)
+			return shellCwdMsg(filepath.Clean(path))
 		}
 		
-		// Execute command and load aliases from .bashrc
-		// Set PS1 to trick .bashrc into thinking it's interactive
-		wrappedCmd := fmt.Sprintf(`
-			export PS1='$ '
-			shopt -s expand_aliases
-			if [ -f ~/.bashrc ]; then source ~/.bashrc; fi
-			if [ -f ~/.bash_aliases ]; then source ~/.bash_aliases; fi
-			%s
-		`, input)
-		cmd := exec.Command("bash", "-c", wrappedCmd)
+		cmd := shellModeCommand(context.Background(), input, shellConf)
 		cmd.Dir = m.shellCwd
 		out, err := cmd.CombinedOutput()
 		
-		result := string(out)
+		result := jobControlNoise.ReplaceAllString(string(out), "")
 		if err != nil && result == "" {
 			result = err.Error()
 		}
 		
 		if result == "" {
-			result = sFaint.Render("(no output)")
+			return shellResultMsg{command: input, output: sFaint.Render(i18n.T("shell.no_output")), context: "(no output)", withContext: withContext}
 		}
-		
+		so := prepareShellOutput(result, maxLines, width, withContext)
 		return shellResultMsg{
 			command:     input,
-			output:      result,
-			withContext: m.shellWithContext,
+			output:      so.display,
+			context:     so.context,
+			withContext: withContext,
 		}
 	}
 }
@@ -1391,23 +2475,21 @@ func (m *model) showInteractivePrompt() tea.Cmd {
 	
 	req := m.interactiveRequests[m.interactiveIndex]
 	var prompt string
+	m.interactiveChoice = 0
+	if req.Sensitive {
+		m.input.EchoMode = textinput.EchoPassword
+	}
 	
 	// Build prompt based on type
 	switch req.InteractiveType {
 	case "select":
+		// the options are listed under it, see optionsView
 		prompt = sInfo.Render(fmt.Sprintf("📝 %s", req.InteractiveHint))
-		if len(req.Options) > 0 {
-			prompt += "\n" + sFaint.Render("Options:")
-			for i, opt := range req.Options {
-				prompt += fmt.Sprintf("\n  %d) %s", i+1, opt)
-			}
-			prompt += "\n" + sFaint.Render("Enter number or text:")
-		}
 	case "blank":
 		fallthrough
 	default:
 		if req.Sensitive {
-			prompt = sInfo.Render(fmt.Sprintf("🔒 %s (input hidden)", req.InteractiveHint))
+			prompt = sInfo.Render(i18n.T("interactive.hidden", req.InteractiveHint))
 		} else {
 			prompt = sInfo.Render(fmt.Sprintf("📝 %s", req.InteractiveHint))
 		}
@@ -1416,6 +2498,36 @@ func (m *model) showInteractivePrompt() tea.Cmd {
 	return printAbove(prompt)
 }
 
+// interactiveOptions returns the options of the select being asked, if any.
+func (m model) interactiveOptions() []string {
+	if !m.interactiveMode || m.interactiveIndex >= len(m.interactiveRequests) {
+		return nil
+	}
+	req := m.interactiveRequests[m.interactiveIndex]
+	if req.InteractiveType != "select" {
+		return nil
+	}
+	return req.Options
+}
+
+// optionsView lists the options of a select with the highlighted one marked;
+// typing its number or some other text answers too.
+func (m model) optionsView(opts []string) string {
+	var b strings.Builder
+	for i, opt := range opts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		line := fmt.Sprintf("  %d) %s", i+1, opt)
+		if i == m.interactiveChoice {
+			b.WriteString(sInfo.Render("❯" + line[1:]))
+		} else {
+			b.WriteString(sFaint.Render(line))
+		}
+	}
+	return b.String()
+}
+
 // handleInteractiveInput processes user input during interactive mode
 func (m *model) handleInteractiveInput(input string) tea.Cmd {
 	if m.interactiveIndex >= len(m.interactiveRequests) {
@@ -1430,16 +2542,21 @@ func (m *model) handleInteractiveInput(input string) tea.Cmd {
 		if num, err := strconv.Atoi(input); err == nil && num > 0 && num <= len(req.Options) {
 			input = req.Options[num-1]
 		}
+		// Enter alone picks the highlighted option
+		if input == "" {
+			input = req.Options[m.interactiveChoice]
+		}
 	}
 	
 	// Store result
 	m.interactiveResults[req.Name] = input
+	m.input.EchoMode = textinput.EchoNormal
 	
 	// Show echo of user input (mask sensitive fields)
 	var echo string
 	if req.Sensitive {
 		if input == "" {
-			echo = sFaint.Render("  → (empty)")
+			echo = sFaint.Render(i18n.T("interactive.empty"))
 		} else {
 			echo = sFaint.Render("  → ********")
 		}
@@ -1459,7 +2576,8 @@ type shellCwdMsg string
 type shellOutputMsg string
 type shellResultMsg struct {
 	command     string
-	output      string
+	output      string // for display
+	context     string // for the conversation, when withContext
 	withContext bool
 }
 type shellModeMsg struct {
@@ -1467,66 +2585,29 @@ type shellModeMsg struct {
 	withContext bool
 }
 
+// buildEngine builds an agent's engine with the --trim-tools and
+// --temperature flags applied.
 func buildEngine(cfg *config.Config, agentName string, reg *tool.Registry) (*engine.Engine, error) {
-	agentConf, err := config.LoadAgent(agentName)
-	if err != nil {
-		return nil, err
-	}
-	a, err := agent.Build(agentConf, reg)
-	if err != nil {
-		return nil, err
-	}
-	parts := strings.SplitN(a.CurrentModel, "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid model format: %s (expected provider/model)", a.CurrentModel)
-	}
-	p, err := makeProvider(cfg, parts[0])
-	if err != nil {
-		return nil, err
-	}
-	return engine.New(a, p), nil
+	return gal.NewEngine(cfg, agentName, reg, gal.Options{TrimTools: trimTools, Temperature: temperature})
 }
 
-// cleanMessages removes trailing incomplete tool_call sequences.
-// A complete sequence ends with assistant{content}. If the tail is
-// tool results or assistant{tool_calls} without a final text response,
-// strip them back to the last clean state.
-func cleanMessages(msgs []provider.Message) []provider.Message {
-	if len(msgs) == 0 {
-		return msgs
-	}
-	last := msgs[len(msgs)-1]
-	// If last message is a complete assistant text response, nothing to clean
-	if last.Role == "assistant" && last.Content != "" && len(last.ToolCalls) == 0 {
-		return msgs
+// autosave saves the session as it stands after a turn or a compression, so
+// a crash or kill loses at most the turn in flight. It runs on the engine's
+// goroutine and saves a copy; the UI records the turn in sess itself.
+func autosave(eng *engine.Engine, sess *session.Session) {
+	s := *sess
+	if n := len(s.Turns); !eng.LastTurn.Start.IsZero() && (n == 0 || !s.Turns[n-1].Start.Equal(eng.LastTurn.Start)) {
+		s.Turns = append(s.Turns[:n:n], eng.LastTurn)
 	}
-	// If last message is user or system, nothing to clean
-	if last.Role == "user" || last.Role == "system" {
-		return msgs
+	gal.Record(eng, &s)
+	if err := s.Save(); err != nil && eng.OnStatus != nil {
+		eng.OnStatus("autosave: " + err.Error())
 	}
-	// Strip trailing tool/assistant{tool_calls} messages
-	for len(msgs) > 0 {
-		tail := msgs[len(msgs)-1]
-		if tail.Role == "tool" || (tail.Role == "assistant" && len(tail.ToolCalls) > 0) {
-			msgs = msgs[:len(msgs)-1]
-			continue
-		}
-		break
-	}
-	return msgs
 }
 
-func makeProvider(cfg *config.Config, providerName string) (provider.Provider, error) {
-	pConf, ok := cfg.Providers[providerName]
-	if !ok {
-		return nil, fmt.Errorf("unknown provider: %s", providerName)
-	}
-	timeout := time.Duration(cfg.Timeout) * time.Second
-	retries := cfg.Retries
-	switch pConf.Type {
-	case "anthropic":
-		return &provider.Anthropic{APIKey: os.ExpandEnv(pConf.APIKey), BaseURL: pConf.BaseURL, Timeout: timeout, Retries: retries}, nil
-	default:
-		return &provider.OpenAI{APIKey: os.ExpandEnv(pConf.APIKey), BaseURL: pConf.BaseURL, Timeout: timeout, Retries: retries}, nil
-	}
+// toolLine renders a tool call line; a subagent's calls come indented, and
+// the indent goes before icon.
+func toolLine(icon, name string) string {
+	trimmed := strings.TrimLeft(name, " ")
+	return name[:len(name)-len(trimmed)] + icon + " " + trimmed
 }
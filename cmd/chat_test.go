@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/record"
+	"github.com/gal-cli/gal-cli/internal/session"
+	"github.com/gal-cli/gal-cli/internal/setup"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// scriptedReply is what scriptedTurns answers one message with: tool calls,
+// each with the result "ok", then the answer in chunks, then err.
+type scriptedReply struct {
+	tools  []string
+	chunks []string
+	err    error
+}
+
+// scriptedTurns runs the chat's turns from a script, without a provider.
+type scriptedTurns struct {
+	replies []scriptedReply
+	sent    []string
+}
+
+func (s *scriptedTurns) SendWithInteractive(ctx context.Context, userMsg string, onText func(string), onToolCall func(string), onToolResult func(string), _ func([]engine.InteractiveInputRequest) (map[string]string, error)) error {
+	s.sent = append(s.sent, userMsg)
+	if len(s.replies) == 0 {
+		return errors.New("no reply scripted")
+	}
+	r := s.replies[0]
+	s.replies = s.replies[1:]
+	for _, name := range r.tools {
+		onToolCall(name)
+		onToolResult("ok")
+	}
+	for _, c := range r.chunks {
+		onText(c)
+	}
+	return r.err
+}
+
+func (s *scriptedTurns) RetryLast(ctx context.Context, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]engine.InteractiveInputRequest) (map[string]string, error)) error {
+	if len(s.sent) == 0 {
+		return errors.New("nothing to retry")
+	}
+	return s.SendWithInteractive(ctx, s.sent[len(s.sent)-1], onText, onToolCall, onToolResult, onInteractive)
+}
+
+func (s *scriptedTurns) RunAuto(context.Context, string, time.Duration, func(string), func(string), func(string)) (*engine.AutoRun, error) {
+	return nil, errors.New("no autonomous run scripted")
+}
+
+// chatHarness drives the chat's model as bubbletea would, one message at a
+// time: the commands Update returns run to completion and the messages they
+// yield are delivered in order, so a test sees the same frames every run.
+// Lines printed above the view are collected in printed.
+//
+// It stands in for teatest, which runs the program on its own goroutine
+// against a fake terminal: there the frames depend on when the spinner and
+// cursor ticks land, so golden output differs between runs, and the package
+// is in x/exp with no stable API.
+type chatHarness struct {
+	t       *testing.T
+	m       model
+	turns   *scriptedTurns
+	dir     string // config, state and home directory
+	printed []string
+}
+
+// newChatHarness returns the chat of the mock provider's agent at width
+// columns, answering with replies.
+func newChatHarness(t *testing.T, width int, replies ...scriptedReply) *chatHarness {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("GAL_CONFIG_DIR", dir)
+	t.Setenv("GAL_STATE_DIR", filepath.Join(dir, "state"))
+	t.Setenv("HOME", dir)
+	os.Mkdir(filepath.Join(dir, "agents"), 0o755)
+	os.WriteFile(filepath.Join(dir, "gal.yaml"), []byte("default_agent: echo\nproviders:\n  mock:\n    type: mock\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "agents", "echo.yaml"), []byte("name: echo\nsystem_prompt: Answer briefly.\nmodels: [mock/m]\ndefault_model: mock/m\n"), 0o644)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := tool.NewRegistry()
+	eng, err := setup.Engine(cfg, cfg.DefaultAgent, reg, setup.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(eng.Close)
+	h := &chatHarness{t: t, turns: &scriptedTurns{replies: replies}, dir: dir}
+	h.m = initialModel(eng, cfg, reg, session.New("test", "echo", "mock/m"))
+	h.m.turns = h.turns
+	h.send(tea.WindowSizeMsg{Width: width, Height: 24})
+	h.m.renderer = h.m.newRenderer() // without waiting for the resize to settle
+	return h
+}
+
+// send delivers msg to the model and runs the command it returns.
+func (h *chatHarness) send(msg tea.Msg) {
+	h.t.Helper()
+	next, cmd := h.m.Update(msg)
+	h.m = next.(model)
+	h.run(cmd)
+}
+
+// run runs cmd and delivers what it yields. Messages of other packages,
+// such as the spinner's and the cursor's ticks, are dropped: they would
+// run forever and only animate.
+func (h *chatHarness) run(cmd tea.Cmd) {
+	h.t.Helper()
+	if cmd == nil {
+		return
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("a command didn't return")
+	}
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, c := range batch {
+			h.run(c)
+		}
+		return
+	}
+	if _, ok := msg.(string); ok {
+		h.send(msg)
+		return
+	}
+	if msg == nil {
+		return
+	}
+	v := reflect.ValueOf(msg)
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem() == reflect.TypeOf(tea.Cmd(nil)): // tea.Sequence
+		for i := 0; i < v.Len(); i++ {
+			h.run(v.Index(i).Interface().(tea.Cmd))
+		}
+	case v.Type().Name() == "printLineMessage": // tea.Println
+		h.printed = append(h.printed, v.FieldByName("messageBody").String())
+	case v.Type().PkgPath() == reflect.TypeOf(model{}).PkgPath():
+		h.send(msg)
+	}
+}
+
+// typeLine types s and presses Enter.
+func (h *chatHarness) typeLine(s string) {
+	h.t.Helper()
+	h.typeText(s)
+	h.press(tea.KeyEnter)
+}
+
+func (h *chatHarness) typeText(s string) {
+	h.t.Helper()
+	h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+}
+
+func (h *chatHarness) press(k tea.KeyType) {
+	h.t.Helper()
+	h.send(tea.KeyMsg{Type: k})
+}
+
+var (
+	ansiSeq = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+	seconds = regexp.MustCompile(`\d+\.\d+s\b`)
+)
+
+// normalize makes s the same on every run: no colors, no timings and no
+// temporary directories.
+func (h *chatHarness) normalize(s string) string {
+	s = ansiSeq.ReplaceAllString(s, "")
+	s = seconds.ReplaceAllString(s, "N.NNs")
+	s = strings.ReplaceAll(s, h.dir, "$TMP")
+	if wd, err := os.Getwd(); err == nil {
+		s = strings.ReplaceAll(s, wd, "$CWD")
+	}
+	return s
+}
+
+// transcript is what the chat printed, then its view, as a terminal would
+// show it now.
+func (h *chatHarness) transcript() string {
+	var sb strings.Builder
+	for _, p := range h.printed {
+		sb.WriteString(p + "\n")
+	}
+	sb.WriteString("----\n" + h.m.View() + "\n")
+	return h.normalize(sb.String())
+}
+
+// golden compares got with testdata/chat/name.golden; go test -update
+// writes it instead.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "chat", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (go test -update writes it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s:\n%s", name, path, got)
+	}
+}
+
+func TestChatSubmit(t *testing.T) {
+	for _, width := range []int{40, 80} {
+		h := newChatHarness(t, width, scriptedReply{
+			tools:  []string{"file_read"},
+			chunks: []string{"The answer is ", "**42**, as the file says."},
+		})
+		h.typeLine("what is the answer?")
+		if want := []string{"what is the answer?"}; !reflect.DeepEqual(h.turns.sent, want) {
+			t.Errorf("width %d: sent %q, want %q", width, h.turns.sent, want)
+		}
+		if h.m.waiting || h.m.streaming != "" {
+			t.Errorf("width %d: waiting %v, streaming %q after the turn", width, h.m.waiting, h.m.streaming)
+		}
+		golden(t, fmt.Sprintf("submit_%d", width), h.transcript())
+	}
+}
+
+func TestChatStreamError(t *testing.T) {
+	h := newChatHarness(t, 60,
+		scriptedReply{chunks: []string{"partial "}, err: errors.New("connection reset")},
+		scriptedReply{chunks: []string{"second try"}},
+	)
+	h.typeLine("hello")
+	if h.m.waiting || h.m.streaming != "" {
+		t.Errorf("waiting %v, streaming %q after the error", h.m.waiting, h.m.streaming)
+	}
+	// the chat goes on after an error
+	h.typeLine("hello again")
+	if want := []string{"hello", "hello again"}; !reflect.DeepEqual(h.turns.sent, want) {
+		t.Errorf("sent %q, want %q", h.turns.sent, want)
+	}
+	golden(t, "stream_error", h.transcript())
+}
+
+func TestChatHistory(t *testing.T) {
+	h := newChatHarness(t, 60)
+	h.m.inputHist = []string{"first", "second", "third"}
+	h.typeText("draft")
+	for _, step := range []struct {
+		key  tea.KeyType
+		want string
+	}{
+		{tea.KeyUp, "third"},
+		{tea.KeyUp, "second"},
+		{tea.KeyUp, "first"},
+		{tea.KeyUp, "first"}, // the oldest stays
+		{tea.KeyDown, "second"},
+		{tea.KeyDown, "third"},
+		{tea.KeyDown, "draft"}, // back to what was being typed
+		{tea.KeyDown, "draft"},
+	} {
+		h.press(step.key)
+		if got := h.m.input.Value(); got != step.want {
+			t.Fatalf("after %s: input %q, want %q", step.key, got, step.want)
+		}
+	}
+	h.press(tea.KeyUp)
+	golden(t, "history", h.transcript())
+}
+
+func TestChatTabCompletion(t *testing.T) {
+	h := newChatHarness(t, 60)
+	h.typeText("/sh")
+	golden(t, "tab_hints", h.transcript())
+	h.press(tea.KeyTab)
+	if got := h.m.input.Value(); got != "/shell " {
+		t.Errorf("after Tab: input %q, want %q", got, "/shell ")
+	}
+
+	// a second Tab takes the next candidate of the same query
+	h = newChatHarness(t, 60)
+	h.typeText("/c")
+	comps := h.m.completions()
+	if len(comps) < 2 {
+		t.Fatalf("completions of /c: %q, want at least 2", comps)
+	}
+	h.press(tea.KeyTab)
+	first := h.m.input.Value()
+	h.press(tea.KeyTab)
+	if second := h.m.input.Value(); second == first {
+		t.Errorf("a second Tab kept %q", first)
+	}
+}
+
+func TestChatShellMode(t *testing.T) {
+	h := newChatHarness(t, 60)
+	sub := filepath.Join(h.dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h.typeLine("/shell")
+	if !h.m.shellMode {
+		t.Fatal("/shell didn't enter shell mode")
+	}
+	h.m.shellCwd = h.dir
+	for _, tc := range []struct {
+		line string
+		cwd  string
+	}{
+		{"cd sub", sub},
+		{"cd missing", sub}, // an error; the directory stays
+		{"echo hi from $(basename $PWD)", sub},
+		{"cd ..", h.dir},
+	} {
+		h.typeLine(tc.line)
+		if h.m.shellCwd != tc.cwd {
+			t.Errorf("after %q: shell directory %s, want %s", tc.line, h.m.shellCwd, tc.cwd)
+		}
+	}
+	if len(h.turns.sent) > 0 {
+		t.Errorf("shell mode sent %q to the model", h.turns.sent)
+	}
+	golden(t, "shell", h.transcript())
+	h.typeLine("/chat")
+	if h.m.shellMode {
+		t.Error("/chat didn't leave shell mode")
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	stats := record.TurnStats{DurationMs: 42000, Rounds: 6, ToolCalls: 9, TokensBefore: 18000, TokensAfter: 21000, PromptTokens: 95000, CompletionTokens: 2000, Cost: 0.31}
+	tests := []struct {
+		in, want string
+	}{
+		{strings.TrimPrefix(stats.Summary(), "◷ "), "42s - 6 rounds - 9 tools - 18k->21k ctx - 95k in/2k out - $0.31"},
+		{engine.Heartbeat{Elapsed: 45 * time.Second, Idle: 30 * time.Second}.String(), "waiting for model... 45s, last data 30s ago"},
+		{engine.ContextUse{Tokens: 25000, Limit: 60000, Ratio: 25000.0 / 60000}.String(), "ctx 42% (25k/60k)"},
+		{"line one\nline two…", "line one\nline two..."},
+	}
+	for _, tt := range tests {
+		if got := (onceOptions{plain: true}).text(tt.in); got != tt.want {
+			t.Errorf("plain text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := (onceOptions{}).text(tt.in); got != tt.in {
+			t.Errorf("text(%q) = %q, want it unchanged", tt.in, got)
+		}
+	}
+}
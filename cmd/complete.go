@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Completion match kinds, best first.
+const (
+	matchPrefix = iota
+	matchSubstring
+	matchFuzzy
+	matchTypo
+	matchNone
+)
+
+var sHintMatch = lipgloss.NewStyle().Underline(true)
+
+// matchCandidate classifies how cand matches query (case-insensitive) and
+// returns a tiebreak score within the kind (lower is better) plus the rune
+// indices in cand that matched, for highlighting. Model names such as
+// "anthropic/claude-sonnet-4" also match on the part after the provider slash.
+func matchCandidate(query, cand string) (kind, score int, pos []int) {
+	// lowercase rune by rune so indices into c are indices into cand's runes
+	q := lowerRunes(query)
+	c := lowerRunes(cand)
+	if len(q) == 0 {
+		return matchPrefix, len(c), nil
+	}
+	span := func(start int) []int {
+		var p []int
+		for i := start; i < start+len(q) && i < len(c); i++ {
+			p = append(p, i)
+		}
+		return p
+	}
+	hasPrefix := func(s []rune) bool { return len(s) >= len(q) && slices.Equal(s[:len(q)], q) }
+
+	if hasPrefix(c) {
+		return matchPrefix, len(c), span(0)
+	}
+	if slash := slices.Index(c, '/'); slash >= 0 && hasPrefix(c[slash+1:]) {
+		return matchPrefix, len(c) + 1000, span(slash + 1)
+	}
+	for i := 0; i+len(q) <= len(c); i++ {
+		if hasPrefix(c[i:]) {
+			return matchSubstring, i*1000 + len(c), span(i)
+		}
+	}
+
+	// subsequence: every query char appears in order; fewer gaps is better
+	var p []int
+	gaps, last := 0, -1
+	for i := 0; i < len(c) && len(p) < len(q); i++ {
+		if c[i] == q[len(p)] {
+			if last >= 0 {
+				gaps += i - last - 1
+			}
+			p = append(p, i)
+			last = i
+		}
+	}
+	if len(p) == len(q) {
+		return matchFuzzy, gaps*1000 + len(c), p
+	}
+
+	// typo tolerance: one edit or transposition against a same-length prefix
+	if len(q) >= 3 {
+		n := min(len(q), len(c))
+		if d := editDistance(q, c[:n]); d <= 1 {
+			return matchTypo, len(c), nil
+		}
+	}
+	return matchNone, 0, nil
+}
+
+// lowerRunes returns s lowercased one rune at a time, unlike strings.ToLower,
+// which may change the number of runes.
+func lowerRunes(s string) []rune {
+	r := []rune(s)
+	for i := range r {
+		r[i] = unicode.ToLower(r[i])
+	}
+	return r
+}
+
+// rankCandidates filters cands by query and orders them prefix matches first,
+// then substring, then fuzzy subsequence and typo matches. Exact matches are
+// dropped since there is nothing left to complete. limit <= 0 means no cap.
+func rankCandidates(query string, cands []string, limit int) []string {
+	type ranked struct {
+		cand        string
+		kind, score int
+	}
+	var rs []ranked
+	seen := make(map[string]bool)
+	for _, c := range cands {
+		if c == query || seen[c] {
+			continue
+		}
+		seen[c] = true
+		kind, score, _ := matchCandidate(query, c)
+		if kind == matchNone {
+			continue
+		}
+		rs = append(rs, ranked{c, kind, score})
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].kind != rs[j].kind {
+			return rs[i].kind < rs[j].kind
+		}
+		if rs[i].score != rs[j].score {
+			return rs[i].score < rs[j].score
+		}
+		return rs[i].cand < rs[j].cand
+	})
+	var out []string
+	for _, r := range rs {
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, r.cand)
+	}
+	return out
+}
+
+// highlightMatch renders cand with base, underlining the characters that matched query.
+func highlightMatch(cand, query string, base lipgloss.Style) string {
+	_, _, pos := matchCandidate(query, cand)
+	if len(pos) == 0 {
+		return base.Render(cand)
+	}
+	hit := make(map[int]bool, len(pos))
+	for _, p := range pos {
+		hit[p] = true
+	}
+	match := base.Inherit(sHintMatch)
+	var sb strings.Builder
+	var run strings.Builder
+	runHit := false
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if runHit {
+			sb.WriteString(match.Render(run.String()))
+		} else {
+			sb.WriteString(base.Render(run.String()))
+		}
+		run.Reset()
+	}
+	i := 0
+	for _, r := range cand {
+		if hit[i] != runHit {
+			flush()
+			runHit = hit[i]
+		}
+		run.WriteRune(r)
+		i++
+	}
+	flush()
+	return sb.String()
+}
+
+// editDistance is the optimal string alignment distance (Levenshtein plus
+// adjacent transpositions), enough to catch typos like "/agetn".
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= len(b); j++ {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
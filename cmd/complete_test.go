@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestMatchCandidate(t *testing.T) {
+	tests := []struct {
+		query, cand string
+		kind        int
+		pos         []int
+	}{
+		{"ag", "agent", matchPrefix, []int{0, 1}},
+		{"son", "anthropic/claude-sonnet-4", matchSubstring, []int{17, 18, 19}},
+		{"cla", "anthropic/claude-sonnet-4", matchPrefix, []int{10, 11, 12}},
+		{"agt", "agent", matchFuzzy, []int{0, 1, 4}},
+		{"agnet", "agent", matchTypo, nil},
+		{"xyz", "agent", matchNone, nil},
+		// positions count runes, not bytes of the lowercased text
+		{"ïc", "Ünïcode", matchSubstring, []int{2, 3}},
+		{"ÜN", "Ünïcode", matchPrefix, []int{0, 1}},
+		{"本語", "日本語のメモ", matchSubstring, []int{1, 2}},
+		{"日メ", "日本語のメモ", matchFuzzy, []int{0, 4}},
+		// 'İ' lowercases to two runes with strings.ToLower
+		{"da", "İdare", matchSubstring, []int{1, 2}},
+		{"ünicdoe", "Ünïcode", matchNone, nil},
+		{"ünïcdoe", "Ünïcode", matchTypo, nil},
+	}
+	for _, tt := range tests {
+		kind, _, pos := matchCandidate(tt.query, tt.cand)
+		if kind != tt.kind || !reflect.DeepEqual(pos, tt.pos) {
+			t.Errorf("matchCandidate(%q, %q) = %d %v, want %d %v", tt.query, tt.cand, kind, pos, tt.kind, tt.pos)
+		}
+	}
+}
+
+func TestHighlightMatch(t *testing.T) {
+	saved := sHintMatch
+	t.Cleanup(func() { sHintMatch = saved })
+	sHintMatch = lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" })
+
+	tests := []struct {
+		cand, query, want string
+	}{
+		{"agent", "ag", "[ag]ent"},
+		{"agent", "agt", "[ag]en[t]"},
+		{"agent", "", "agent"},
+		{"Ünïcode", "ïc", "Ün[ïc]ode"},
+		{"Ünïcode", "ün", "[Ün]ïcode"},
+		{"日本語のメモ", "本語", "日[本語]のメモ"},
+		{"日本語のメモ", "日メ", "[日]本語の[メ]モ"},
+		{"İdare", "da", "İ[da]re"},
+	}
+	for _, tt := range tests {
+		if got := highlightMatch(tt.cand, tt.query, lipgloss.NewStyle()); got != tt.want {
+			t.Errorf("highlightMatch(%q, %q) = %q, want %q", tt.cand, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCommandNamesCached(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"galtool", "other"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("PATHEXT", "")
+	t.Cleanup(func() { pathCommands.key = "" })
+
+	if got := matchCommands("galt", 0); !reflect.DeepEqual(got, []string{"galtool"}) {
+		t.Fatalf("matchCommands = %v, want [galtool]", got)
+	}
+	// a command installed since is not seen until the cache expires
+	if err := os.WriteFile(filepath.Join(dir, "galtwo"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := matchCommands("galt", 0); !reflect.DeepEqual(got, []string{"galtool"}) {
+		t.Errorf("second matchCommands = %v, want the cached [galtool]", got)
+	}
+	pathCommands.at = pathCommands.at.Add(-pathCommandsTTL)
+	if got := matchCommands("galt", 0); !reflect.DeepEqual(got, []string{"galtwo", "galtool"}) {
+		t.Errorf("after the TTL matchCommands = %v, want [galtwo galtool]", got)
+	}
+	// a changed PATH is scanned again at once
+	t.Setenv("PATH", t.TempDir())
+	if got := matchCommands("galt", 0); len(got) != 0 {
+		t.Errorf("with another PATH matchCommands = %v, want none", got)
+	}
+}
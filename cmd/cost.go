@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
+)
+
+// costReport renders /cost: the session's cost and tokens, the last turn's
+// cost and the price the current model is charged at.
+func (m *model) costReport() string {
+	lines := []string{"Session: " + sessionCost(m.eng)}
+	if m.eng.LastTurn.Cost > 0 {
+		lines = append(lines, "Last turn: "+record.FormatUSD(m.eng.LastTurn.Cost))
+	}
+	model := m.eng.Agent.CurrentModel
+	if p, ok := m.eng.ModelPrice(); ok {
+		line := fmt.Sprintf("Price of %s: $%g in / $%g out", model, p.Input, p.Output)
+		if p.Cached > 0 {
+			line += fmt.Sprintf(" / $%g cached", p.Cached)
+		}
+		lines = append(lines, line+" per million tokens")
+	} else {
+		lines = append(lines, "No price for "+model+": set one under pricing in gal.yaml")
+	}
+	return sInfo.Render(strings.Join(lines, "\n"))
+}
+
+// sessionCost renders the session's spending, e.g. "$0.42 (95k in/2k out)";
+// models without a price show their tokens only.
+func sessionCost(eng *engine.Engine) string {
+	u := eng.Usage
+	if u.PromptTokens+u.CompletionTokens == 0 {
+		return "no usage reported yet"
+	}
+	if eng.Cost.USD == 0 {
+		return record.FormatUsage(u)
+	}
+	return eng.Cost.String() + " (" + record.FormatUsage(u) + ")"
+}
+
+// costLine summarizes a non-interactive run's cost for stderr, or "" when
+// the provider reported no usage.
+func costLine(eng *engine.Engine) string {
+	t := eng.LastTurn
+	if t.PromptTokens+t.CompletionTokens == 0 {
+		return ""
+	}
+	if t.Cost == 0 {
+		u := provider.Usage{PromptTokens: t.PromptTokens, CompletionTokens: t.CompletionTokens, CachedTokens: t.CachedTokens}
+		return fmt.Sprintf("%s, no price for %s", record.FormatUsage(u), t.Model)
+	}
+	line := record.FormatUSD(t.Cost)
+	if eng.Cost.USD > t.Cost {
+		line += " (session " + eng.Cost.String() + ")"
+	}
+	return line
+}
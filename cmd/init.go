@@ -13,6 +13,19 @@ var defaultGalYAML = `default_agent: default
 context_limit: 60000    # token threshold for auto context compression
 timeout: 1800           # HTTP timeout in seconds for LLM API calls
 retries: 1              # retry count on 429/5xx errors
+tool_parallelism: 4     # max tool calls run concurrently when they don't conflict
+
+# search:                 # the engine web_search queries; without it the tool isn't offered
+#   provider: brave       # brave, serpapi or searxng
+#   api_key: ${BRAVE_API_KEY}
+#   endpoint: http://localhost:8080   # searxng: your instance
+
+# compression_model: openai/gpt-4o-mini   # cheaper model that writes the summaries (default: the chat's model)
+compression:              # summarize old messages when the context fills up
+  enabled: true           # false never summarizes mid-chat
+  trigger_ratio: 1.0      # compress once the context passes this share of context_limit
+  target_ratio: 0.2       # keep the newest messages up to this share of it unsummarized
+  keep_last_messages: 0   # the last N exchanges are never summarized, however large
 
 providers:
   openai:
@@ -44,8 +57,9 @@ providers:
       - glm-4-plus
       - glm-4-flash
   ollama:
-    type: openai
-    base_url: http://localhost:11434/v1
+    type: ollama
+    base_url: http://localhost:11434
+    keep_alive: 30m     # keep the model loaded between turns
     models:
       - llama3
       - qwen2
@@ -73,11 +87,18 @@ tools:
   - file_write
   - file_edit
   - file_list
+  - glob
   - grep
+  - log_read
   - bash
   - interactive
   - http
   - file_patch
+  - apply_patch
+  - file_undo
+  - git
+  - web_search
+  - fetch
   - browser
 
 skills: []
@@ -92,32 +113,41 @@ skills: []
 func init() {
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "init",
-		Short: "Initialize default config in ~/.gal/",
+		Short: "Initialize default config (~/.gal/, or $XDG_CONFIG_HOME/gal if it exists)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			dir := config.GalDir()
-			agentsDir := filepath.Join(dir, "agents")
-			skillsDir := filepath.Join(dir, "skills")
-			os.MkdirAll(agentsDir, 0755)
-			os.MkdirAll(skillsDir, 0755)
-
-			galPath := filepath.Join(dir, "gal.yaml")
-			if _, err := os.Stat(galPath); os.IsNotExist(err) {
-				os.WriteFile(galPath, []byte(defaultGalYAML), 0644)
-				fmt.Println("Created", galPath)
-			} else {
-				fmt.Println("Exists", galPath)
-			}
-
-			agentPath := filepath.Join(agentsDir, "default.yaml")
-			if _, err := os.Stat(agentPath); os.IsNotExist(err) {
-				os.WriteFile(agentPath, []byte(defaultAgentYAML), 0644)
-				fmt.Println("Created", agentPath)
-			} else {
-				fmt.Println("Exists", agentPath)
+			dir, err := writeDefaults()
+			if err != nil {
+				return err
 			}
-
 			fmt.Println("✅ GAL-CLI initialized at", dir)
 			return nil
 		},
 	})
 }
+
+// writeDefaults creates the config directory with the default gal.yaml and
+// agent, leaving files that exist alone, and returns the directory.
+func writeDefaults() (string, error) {
+	dir := config.GalDir()
+	agentsDir := filepath.Join(dir, "agents")
+	skillsDir := filepath.Join(dir, "skills")
+	if err := os.MkdirAll(agentsDir, 0755); err != nil {
+		return "", err
+	}
+	os.MkdirAll(skillsDir, 0755)
+
+	for _, f := range []struct{ path, content string }{
+		{filepath.Join(dir, "gal.yaml"), defaultGalYAML},
+		{filepath.Join(agentsDir, "default.yaml"), defaultAgentYAML},
+	} {
+		if _, err := os.Stat(f.path); !os.IsNotExist(err) {
+			fmt.Println("Exists", f.path)
+			continue
+		}
+		if err := os.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			return "", err
+		}
+		fmt.Println("Created", f.path)
+	}
+	return dir, nil
+}
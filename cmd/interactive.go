@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"golang.org/x/term"
+)
+
+// ttyInteractive answers the interactive tool in non-interactive mode by
+// asking on the terminal, /dev/tty, so it works with stdin and stdout
+// redirected. It returns nil without a terminal or with --json, whose
+// reader can't answer; the tool then tells the model to do without.
+func ttyInteractive(opts onceOptions) (func([]engine.InteractiveInputRequest) (map[string]string, error), func()) {
+	if opts.jsonOut {
+		return nil, func() {}
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, func() {}
+	}
+	in := bufio.NewReader(tty)
+	ask := func(requests []engine.InteractiveInputRequest) (map[string]string, error) {
+		results := make(map[string]string, len(requests))
+		for _, req := range requests {
+			v, err := askTTY(tty, in, req)
+			if err != nil {
+				return nil, err
+			}
+			results[req.Name] = v
+		}
+		return results, nil
+	}
+	return ask, func() { tty.Close() }
+}
+
+// askTTY asks for one field: an option by number or text, a hidden value
+// when it is sensitive, or a line of text.
+func askTTY(tty *os.File, in *bufio.Reader, req engine.InteractiveInputRequest) (string, error) {
+	switch {
+	case req.InteractiveType == "select" && len(req.Options) > 0:
+		fmt.Fprintf(tty, "📝 %s\n", req.InteractiveHint)
+		for i, opt := range req.Options {
+			fmt.Fprintf(tty, "  %d) %s\n", i+1, opt)
+		}
+		fmt.Fprint(tty, "Enter number or text: ")
+	case req.Sensitive:
+		fmt.Fprintf(tty, "🔒 %s (input hidden): ", req.InteractiveHint)
+		b, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		if err != nil {
+			return "", fmt.Errorf("interactive input: %w", err)
+		}
+		return string(b), nil
+	default:
+		fmt.Fprintf(tty, "📝 %s: ", req.InteractiveHint)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		fmt.Fprintln(tty)
+		return "", errors.New("interactive input cancelled")
+	}
+	line = strings.TrimSpace(line)
+	if n, err := strconv.Atoi(line); err == nil && n > 0 && n <= len(req.Options) {
+		line = req.Options[n-1]
+	}
+	return line, nil
+}
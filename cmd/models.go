@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/setup"
+	"github.com/spf13/cobra"
+)
+
+// listModelsTimeout bounds a whole listing, so one unreachable provider
+// can't hang it.
+const listModelsTimeout = 30 * time.Second
+
+func init() {
+	var providers []string
+	var asJSON bool
+	modelsCmd := &cobra.Command{
+		Use:   "models",
+		Short: "List the models each configured provider serves",
+		Long: `Ask each configured provider's API which models it serves and print them
+as provider/model lines, with the agents that use each one.
+
+Models that agents use but their provider doesn't list are reported on
+stderr, as are providers that can't be reached.`,
+		Example: `  gal-cli models
+  gal-cli models --provider openai --provider anthropic
+  gal-cli models --json | jq -r '.[] | .models[]?.id'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			for _, p := range providers {
+				if _, ok := cfg.Providers[p]; !ok {
+					return fmt.Errorf("unknown provider: %s", p)
+				}
+			}
+			cmd.SilenceUsage = true // failures from here on are the providers'
+			ctx, cancel := context.WithTimeout(cmd.Context(), listModelsTimeout)
+			defer cancel()
+			results := listRemoteModels(ctx, cfg, providers)
+			used := agentModels()
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+				}
+			}
+			if failed > 0 && failed == len(results) {
+				err = fmt.Errorf("no provider could list its models")
+			}
+			if asJSON {
+				if jerr := printModelsJSON(results, used); jerr != nil {
+					return jerr
+				}
+				return err
+			}
+			for _, r := range results {
+				if r.Err != nil {
+					fmt.Fprintf(os.Stderr, "✘ %s: %v\n", r.Provider, r.Err)
+					continue
+				}
+				for _, id := range r.ids() {
+					if agents := used[id]; len(agents) > 0 {
+						fmt.Printf("%-45s agents: %s\n", id, strings.Join(agents, ", "))
+					} else {
+						fmt.Println(id)
+					}
+				}
+				for _, id := range r.unlisted(used) {
+					fmt.Fprintf(os.Stderr, "⚠ %s (used by %s) is not listed by %s\n", id, strings.Join(used[id], ", "), r.Provider)
+				}
+			}
+			return err
+		},
+	}
+	modelsCmd.Flags().StringSliceVarP(&providers, "provider", "p", nil, "Only query this provider (repeatable)")
+	modelsCmd.Flags().BoolVar(&asJSON, "json", false, "Print JSON")
+	rootCmd.AddCommand(modelsCmd)
+}
+
+// providerModels is one provider's answer to a model listing.
+type providerModels struct {
+	Provider string
+	Models   []string
+	Err      error
+}
+
+// ids returns the models as sorted provider/model IDs.
+func (r providerModels) ids() []string {
+	ids := make([]string, len(r.Models))
+	for i, m := range r.Models {
+		ids[i] = r.Provider + "/" + m
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// unlisted returns the models of this provider that agents use but the
+// provider didn't list.
+func (r providerModels) unlisted(used map[string][]string) []string {
+	var out []string
+	for id := range used {
+		p, m, _ := strings.Cut(id, "/")
+		if p == r.Provider && !slices.Contains(r.Models, m) {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// listRemoteModels asks the named providers, or all configured ones, for
+// their models in parallel. A provider that fails doesn't affect the others.
+// Without names, providers that can't list models (type mock) are skipped.
+func listRemoteModels(ctx context.Context, cfg *config.Config, names []string) []providerModels {
+	if len(names) == 0 {
+		for name, pc := range cfg.Providers {
+			if pc.Type != "mock" {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	results := make([]providerModels, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		results[i].Provider = name
+		p, err := setup.Provider(cfg, name)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		lister, ok := p.(provider.ModelLister)
+		if !ok {
+			results[i].Err = fmt.Errorf("type %s can't list models", cfg.Providers[name].Type)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			models, err := lister.ListModels(ctx)
+			sort.Strings(models)
+			results[i].Models, results[i].Err = models, err
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// remoteModelsReqMsg asks the chat for /model list --remote.
+type remoteModelsReqMsg struct{}
+
+// remoteModelsCmd lists what the providers serve in the background, marking
+// the current model and the agent's other models.
+func (m *model) remoteModelsCmd() tea.Cmd {
+	cfg, current, models := m.cfg, m.eng.Agent.CurrentModel, m.eng.Agent.Conf.Models
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), listModelsTimeout)
+		defer cancel()
+		var out []string
+		for _, r := range listRemoteModels(ctx, cfg, nil) {
+			if r.Err != nil {
+				out = append(out, sErr.Render("✘ "+r.Provider+": "+r.Err.Error()))
+				continue
+			}
+			for _, id := range r.ids() {
+				switch {
+				case id == current:
+					out = append(out, sOK.Render("▶ ")+id)
+				case slices.Contains(models, id):
+					out = append(out, "• "+id)
+				default:
+					out = append(out, "  "+id)
+				}
+			}
+		}
+		if len(out) == 0 {
+			return sInfo.Render("No provider can list its models")
+		}
+		return strings.Join(out, "\n")
+	}
+}
+
+// agentModels maps each provider/model ID used by an agent to the agents
+// that use it.
+func agentModels() map[string][]string {
+	used := map[string][]string{}
+	names, _ := config.ListAgents()
+	for _, n := range names {
+		a, err := config.LoadAgent(n)
+		if err != nil {
+			continue
+		}
+		for _, m := range a.Models {
+			if !slices.Contains(used[m], n) {
+				used[m] = append(used[m], n)
+			}
+		}
+	}
+	return used
+}
+
+func printModelsJSON(results []providerModels, used map[string][]string) error {
+	type model struct {
+		ID     string   `json:"id"`
+		Agents []string `json:"agents,omitempty"`
+	}
+	type entry struct {
+		Provider string   `json:"provider"`
+		Models   []model  `json:"models,omitempty"`
+		Unlisted []string `json:"unlisted,omitempty"` // used by agents but not listed
+		Error    string   `json:"error,omitempty"`
+	}
+	out := make([]entry, 0, len(results))
+	for _, r := range results {
+		e := entry{Provider: r.Provider}
+		if r.Err != nil {
+			e.Error = r.Err.Error()
+		} else {
+			for _, id := range r.ids() {
+				e.Models = append(e.Models, model{ID: id, Agents: used[id]})
+			}
+			e.Unlisted = r.unlisted(used)
+		}
+		out = append(out, e)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/keychain"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/setup"
+	"golang.org/x/term"
+)
+
+// exitNoConfig is gal-cli's exit code when there is no configuration to run
+// with (EX_CONFIG in sysexits.h).
+const exitNoConfig = 78
+
+// errNoConfig is what a chat without a configuration fails with when there's
+// nobody to set one up with.
+var errNoConfig = &exitError{code: exitNoConfig, err: errors.New("no configuration yet. Next steps:\n" +
+	"  1. gal-cli init                  # writes gal.yaml and a default agent\n" +
+	"  2. export OPENAI_API_KEY=...     # or the key of another provider in gal.yaml\n" +
+	"  3. gal-cli chat -m \"hello\"\n" +
+	"or run gal-cli chat in a terminal to set it up step by step")}
+
+// onboardProvider is a provider of the default gal.yaml that setup offers.
+type onboardProvider struct {
+	name  string // in gal.yaml
+	label string
+	env   string // the variable its api_key refers to; "" for none
+	model string
+}
+
+var onboardProviders = []onboardProvider{
+	{"openai", "OpenAI", "OPENAI_API_KEY", "gpt-4o"},
+	{"anthropic", "Anthropic", "ANTHROPIC_API_KEY", "claude-sonnet-4-20250514"},
+	{"deepseek", "DeepSeek", "DEEPSEEK_API_KEY", "deepseek-chat"},
+	{"zhipu", "Zhipu AI", "ZHIPU_API_KEY", "glm-4-plus"},
+	{"ollama", "Ollama (runs locally, no key)", "", "llama3"},
+}
+
+// onboard sets gal-cli up for a first chat: it writes the default config,
+// asks for a provider and its API key, checks the key with a one-token
+// request and keeps it in the system keychain (in gal.yaml only if the user
+// insists), then returns the loaded config.
+func onboard() (*config.Config, error) {
+	in := bufio.NewReader(os.Stdin)
+	fmt.Printf("👋 Welcome to gal-cli! There is no configuration in %s yet.\n", config.GalDir())
+	if !askYesNo(in, "Set it up now?", true) {
+		return nil, errNoConfig
+	}
+	dir, err := writeDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("\nWhich provider do you want to start with?")
+	for i, p := range onboardProviders {
+		fmt.Printf("  %d. %s\n", i+1, p.label)
+	}
+	p := onboardProviders[0]
+	for {
+		answer := ask(in, "Provider [1]: ")
+		if answer == "" {
+			break
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(onboardProviders) {
+			p = onboardProviders[n-1]
+			break
+		}
+		fmt.Printf("Type a number from 1 to %d.\n", len(onboardProviders))
+	}
+
+	galPath := filepath.Join(dir, "gal.yaml")
+	if p.env == "" {
+		if err := checkProvider(p); err != nil {
+			fmt.Printf("⚠ %s didn't answer (%v); start it, then chat away.\n", p.label, err)
+		}
+	} else if err := setupKey(in, p, galPath); err != nil {
+		return nil, err
+	}
+
+	model := p.name + "/" + p.model
+	agentPath := filepath.Join(dir, "agents", "default.yaml")
+	if err := replaceInFile(agentPath, "default_model: openai/gpt-4o", "default_model: "+model); err != nil {
+		return nil, err
+	}
+	fmt.Printf("\n✅ Ready. Chatting with %s; switch with /model, and see %s for more.\n\n", model, galPath)
+	return config.Load()
+}
+
+// setupKey asks for p's API key, checks it, and stores it: in the keychain,
+// with gal.yaml referring to it, or else in gal.yaml itself if the user
+// insists. A key already in the environment is used as it is.
+func setupKey(in *bufio.Reader, p onboardProvider, galPath string) error {
+	if os.Getenv(p.env) != "" && askYesNo(in, fmt.Sprintf("$%s is set. Use it?", p.env), true) {
+		if err := checkProvider(p); err != nil {
+			fmt.Printf("⚠ The key in $%s doesn't work (%v); fix it before chatting.\n", p.env, err)
+		}
+		return nil
+	}
+	var key string
+	for attempt := 1; ; attempt++ {
+		fmt.Printf("%s API key (input hidden): ", p.label)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		if key = strings.TrimSpace(string(b)); key == "" {
+			continue
+		}
+		os.Setenv(p.env, key) // gal.yaml refers to it until the key is stored
+		fmt.Print("Checking the key... ")
+		err = checkProvider(p)
+		if err == nil {
+			fmt.Println("✓")
+			break
+		}
+		fmt.Printf("✘ %v\n", err)
+		if attempt >= 3 {
+			if askYesNo(in, "Keep this key anyway?", false) {
+				break
+			}
+			return &exitError{code: exitNoConfig, err: fmt.Errorf("no working %s key; export %s=<your key> and run gal-cli chat again", p.label, p.env)}
+		}
+	}
+
+	ref := "${" + p.env + "}"
+	err := keychain.Set(p.name, key)
+	if err == nil {
+		fmt.Printf("🔑 Saved the key in the system keychain; gal.yaml refers to it as ${keychain:%s}.\n", p.name)
+		return replaceInFile(galPath, ref, "${keychain:"+p.name+"}")
+	}
+	fmt.Printf("⚠ Couldn't save the key in a keychain: %v\n", err)
+	if askYesNo(in, "Write it into gal.yaml as plain text instead? Anyone who can read the file can use it.", false) {
+		if err := replaceInFile(galPath, ref, strconv.Quote(key)); err != nil {
+			return err
+		}
+		return os.Chmod(galPath, 0600)
+	}
+	fmt.Printf("The key is used for this chat only. To keep it, add this to your shell profile:\n  export %s=<your key>\n", p.env)
+	return nil
+}
+
+// checkProvider makes a one-token request to p's model.
+func checkProvider(p onboardProvider) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	prov, err := setup.Provider(cfg, p.name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	msgs := []provider.Message{{Role: "user", Content: "hi"}}
+	return prov.ChatStream(ctx, p.model, msgs, nil, provider.ChatOptions{MaxTokens: 1}, func(provider.StreamDelta) {})
+}
+
+// ask prints prompt and returns the line typed, trimmed.
+func ask(in *bufio.Reader, prompt string) string {
+	fmt.Print(prompt)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// askYesNo asks a yes/no question; Enter gives def.
+func askYesNo(in *bufio.Reader, question string, def bool) bool {
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+	switch strings.ToLower(ask(in, question+hint)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// replaceInFile replaces the first old in the file at path with new.
+func replaceInFile(path, old, new string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), fi.Mode())
+}
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/prompt"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	promptCmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Manage saved prompts",
+		Long: `Saved prompts are markdown files in <config dir>/prompts, one per name.
+{{name}} placeholders are filled in when a prompt is used, and optional
+frontmatter gives a description and defaults:
+
+  ---
+  description: Review a diff
+  vars:
+    focus: error handling
+  ---
+  Review this diff with attention to {{focus}}: {{diff}}
+
+Use them with /prompt <name> [var=value ...] in the chat, or with
+-m "prompt:<name> var=value ..." in non-interactive mode.`,
+	}
+
+	promptCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List saved prompts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prompts, err := prompt.List()
+			if err != nil {
+				return err
+			}
+			if len(prompts) == 0 {
+				fmt.Println("No prompts.")
+				return nil
+			}
+			for _, p := range prompts {
+				fmt.Printf("  %-20s %s\n", p.Name, clipText(p.Summary(), 70))
+			}
+			return nil
+		},
+	})
+
+	promptCmd.AddCommand(&cobra.Command{
+		Use:   "show [name]",
+		Short: "Print a saved prompt's file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := prompt.Load(args[0]); err != nil {
+				return err
+			}
+			data, err := os.ReadFile(prompt.Path(args[0]))
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		},
+	})
+
+	promptCmd.AddCommand(&cobra.Command{
+		Use:   "save [name] [text]",
+		Short: "Save a prompt (text, @file or - for stdin)",
+		Example: `  gal-cli prompt save review "Review this diff with attention to {{focus}}"
+  gal-cli prompt save release @release-notes.md`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			text, err := readMessage(args[1])
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(text) == "" {
+				return fmt.Errorf("prompt text is empty")
+			}
+			if err := prompt.Save(args[0], text); err != nil {
+				return err
+			}
+			fmt.Printf("Saved prompt %s (%s)\n", args[0], prompt.Path(args[0]))
+			return nil
+		},
+	})
+
+	promptCmd.AddCommand(&cobra.Command{
+		Use:   "rm [name]",
+		Short: "Delete a saved prompt",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := prompt.Remove(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted prompt %s\n", args[0])
+			return nil
+		},
+	})
+
+	rootCmd.AddCommand(promptCmd)
+}
+
+// promptFillMsg asks for the variables a /prompt was given no value for.
+type promptFillMsg struct {
+	prompt  *prompt.Prompt
+	vars    map[string]string
+	missing []string
+}
+
+// sendTextMsg sends text as the user's message, for /prompt, /retry and
+// /continue.
+type sendTextMsg string
+
+// retryMsg has the last message answered again, for /retry.
+type retryMsg struct{}
+
+// handlePrompt runs /prompt list, /prompt save <name> and
+// /prompt <name> [var=value ...].
+func (m *model) handlePrompt(input string) tea.Msg {
+	parts := strings.Fields(input)
+	if len(parts) < 2 || parts[1] == "list" {
+		prompts, err := prompt.List()
+		if err != nil {
+			return sErr.Render("✘ " + err.Error())
+		}
+		if len(prompts) == 0 {
+			return sInfo.Render("No saved prompts (/prompt save <name> saves the last message)")
+		}
+		var out []string
+		for _, p := range prompts {
+			out = append(out, fmt.Sprintf("  %-20s %s", p.Name, sFaint.Render(clipText(p.Summary(), 60))))
+		}
+		return strings.Join(out, "\n")
+	}
+	if parts[1] == "save" {
+		if len(parts) != 3 {
+			return sErr.Render("Usage: /prompt save <name>")
+		}
+		text := m.lastMessage
+		if text == "" {
+			text = lastUserMessage(m.eng.Messages)
+		}
+		if text == "" {
+			return sErr.Render("✘ Nothing to save yet: send a message first")
+		}
+		existed := prompt.Exists(parts[2])
+		if err := prompt.Save(parts[2], text); err != nil {
+			return sErr.Render("✘ " + err.Error())
+		}
+		if existed {
+			return sOK.Render("✔ Updated prompt " + parts[2])
+		}
+		return sOK.Render("✔ Saved prompt " + parts[2] + " (/prompt " + parts[2] + " sends it)")
+	}
+
+	p, err := prompt.Load(parts[1])
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	_, rest, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(input, "/prompt")), " ")
+	vars, err := prompt.ParseVars(rest)
+	if err == nil {
+		err = p.CheckVars(vars)
+	}
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	if missing := p.Missing(vars); len(missing) > 0 {
+		return promptFillMsg{prompt: p, vars: vars, missing: missing}
+	}
+	text, err := p.Expand(vars)
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	return sendTextMsg(text)
+}
+
+// askPromptVars collects a /prompt's missing variables one by one with the
+// interactive input prompts; fillPrompt sends it once they're in.
+func (m model) askPromptVars(msg promptFillMsg) (tea.Model, tea.Cmd) {
+	reqs := make([]engine.InteractiveInputRequest, len(msg.missing))
+	for i, v := range msg.missing {
+		reqs[i] = engine.InteractiveInputRequest{
+			Name:            v,
+			InteractiveType: "blank",
+			InteractiveHint: fmt.Sprintf("%s (for prompt %s):", v, msg.prompt.Name),
+		}
+	}
+	m.promptFill = &msg
+	m.interactiveMode = true
+	m.interactiveRequests = reqs
+	m.interactiveIndex = 0
+	m.interactiveResults = make(map[string]string)
+	return m, m.showInteractivePrompt()
+}
+
+func (m model) fillPrompt(values map[string]string) (tea.Model, tea.Cmd) {
+	fill := m.promptFill
+	m.promptFill = nil
+	for k, v := range values {
+		fill.vars[k] = v
+	}
+	text, err := fill.prompt.Expand(fill.vars)
+	if err != nil {
+		return m, printAbove(sErr.Render("✘ " + err.Error()))
+	}
+	return m.sendMessage(text)
+}
+
+// expandPromptMessage reads -m "prompt:<name> var=value ...".
+func expandPromptMessage(spec string) (string, error) {
+	name, rest, _ := strings.Cut(strings.TrimSpace(spec), " ")
+	p, err := prompt.Load(name)
+	if err != nil {
+		return "", err
+	}
+	vars, err := prompt.ParseVars(rest)
+	if err != nil {
+		return "", err
+	}
+	if err := p.CheckVars(vars); err != nil {
+		return "", err
+	}
+	if missing := p.Missing(vars); len(missing) > 0 {
+		return "", fmt.Errorf("prompt %s needs %s: -m \"prompt:%s %s=...\"", name, strings.Join(missing, ", "), name, missing[0])
+	}
+	return p.Expand(vars)
+}
+
+// lastUserMessage returns the content of the last user message, or "".
+func lastUserMessage(msgs []provider.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
+
+// promptNames lists saved prompt names for completion.
+func promptNames() []string {
+	prompts, _ := prompt.List()
+	names := make([]string, len(prompts))
+	for i, p := range prompts {
+		names[i] = p.Name
+	}
+	return names
+}
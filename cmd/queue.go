@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/i18n"
+)
+
+// queueInput holds a line typed while a turn (or compression) is running,
+// to be sent once it ends. Commands that don't touch the conversation run
+// at once; other commands wait their turn like messages.
+func (m model) queueInput(input string) (tea.Model, tea.Cmd) {
+	parts := strings.Fields(input)
+	switch parts[0] {
+	case "/help", "/speak", "/say", "/cost", "/quit", "/exit":
+		return m.submit(input)
+	case "/model", "/prompt":
+		if len(parts) == 1 || parts[1] == "list" || parts[0] == "/prompt" && parts[1] == "save" {
+			return m.submit(input)
+		}
+	case "/bg":
+		if len(parts) == 1 || parts[1] == "list" || parts[1] == "drop" {
+			return m.submit(input)
+		}
+	}
+	m.queue = append(m.queue, input)
+	line := sFaint.Render(i18n.T("queue.queued", input))
+	if strings.HasPrefix(input, "/") {
+		line += sDim.Render(i18n.T("queue.after_reply"))
+	}
+	return m, printAbove(line)
+}
+
+// sendQueued submits queued lines in order until one starts a turn or
+// compression; the rest wait for that to end. Background turns that finished
+// meanwhile are counted first.
+func (m model) sendQueued() (model, tea.Cmd) {
+	m.countBg()
+	var cmds []tea.Cmd
+	for len(m.queue) > 0 && !m.waiting && !m.compressing && m.stopping == nil {
+		input := m.queue[0]
+		m.queue = m.queue[1:]
+		next, cmd := m.submit(input)
+		m = next.(model)
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Sequence(cmds...)
+}
+
+// dropQueue forgets the queued lines, returning a note about them or ""
+// when there were none. They stay in the input history for ↑.
+func (m *model) dropQueue() string {
+	n := len(m.queue)
+	m.queue = nil
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return sDim.Render(i18n.T("queue.dropped_one"))
+	}
+	return sDim.Render(i18n.T("queue.dropped", n))
+}
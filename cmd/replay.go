@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Caps on the text replayed when resuming, so a huge session doesn't delay
+// startup.
+const (
+	maxReplayChars        = 12000 // all replayed messages
+	maxReplayMessageChars = 3000  // one message
+)
+
+type replayMsg string
+
+// replayCmd shows the last ui.replay_turns exchanges of a resumed session.
+// It runs after the first frame, with its own renderer.
+func (m *model) replayCmd() tea.Cmd {
+	turns := m.cfg.UI.ReplayTurns
+	if turns <= 0 || len(m.eng.Messages) <= 1 {
+		return nil
+	}
+	msgs := slices.Clone(m.eng.Messages)
+	style, width, id := m.mdStyle, m.wrapWidth(), m.sess.ID
+	return func() tea.Msg {
+		r, _ := glamour.NewTermRenderer(glamour.WithStyles(style), glamour.WithWordWrap(width))
+		return replayMsg(replayText(msgs, turns, id, r))
+	}
+}
+
+// replayText renders the messages of the last turns exchanges the way the
+// chat showed them, with tool calls as one-liners and tool results left out.
+func replayText(msgs []provider.Message, turns int, sessionID string, r *glamour.TermRenderer) string {
+	start := len(msgs)
+	for seen := 0; start > 1 && seen < turns; {
+		start--
+		if msgs[start].Role == "user" {
+			seen++
+		}
+	}
+	earlier := 0
+	for _, msg := range msgs[1:start] {
+		if msg.Role != "system" {
+			earlier++
+		}
+	}
+
+	var lines []string
+	if earlier > 0 {
+		lines = append(lines, sDim.Render(fmt.Sprintf("… %d earlier messages (gal-cli session cat %s shows all)", earlier, sessionID)))
+	}
+	budget := maxReplayChars
+	for _, msg := range msgs[start:] {
+		if budget <= 0 {
+			lines = append(lines, sDim.Render("… (replay truncated)"))
+			break
+		}
+		switch msg.Role {
+		case "user":
+			text := clipText(msg.Content, min(budget, maxReplayMessageChars))
+			budget -= len(text)
+			lines = append(lines, sPrompt.Render("▶ ")+text)
+		case "assistant":
+			if msg.Content != "" {
+				text := clipText(msg.Content, min(budget, maxReplayMessageChars))
+				budget -= len(text)
+				if r != nil {
+					if out, err := r.Render(text); err == nil {
+						text = strings.TrimRight(out, "\n")
+					}
+				}
+				lines = append(lines, text)
+			}
+			for _, tc := range msg.ToolCalls {
+				args := clipText(strings.Join(strings.Fields(tc.Function.Arguments), " "), 60)
+				lines = append(lines, sTool.Render("⚡ "+tc.Function.Name)+" "+sFaint.Render(args))
+			}
+		}
+	}
+	lines = append(lines, sDim.Render("─── resumed ───"))
+	return strings.Join(lines, "\n")
+}
+
+// clipText cuts s to at most n bytes on a rune boundary, marking the cut.
+func clipText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/i18n"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// maxReviewLines is how much of a staged change's diff the review shows;
+// editing it shows all of it.
+const maxReviewLines = 300
+
+// review is the review of the changes a turn staged in propose mode, one
+// file at a time.
+type review struct {
+	changes  []engine.PendingChange // as staged, or as the user edited them
+	i        int                    // the change asked about
+	accepted []engine.PendingChange
+	edited   map[string]bool // paths the user edited
+	rejected []string
+}
+
+type reviewStartMsg struct{}
+
+// reviewEditedMsg is sent when the editor opened on a staged change exits.
+type reviewEditedMsg struct {
+	file string // the edited copy
+	err  error
+}
+
+// handlePropose runs /propose: on and off switch propose mode, review goes
+// over the changes staged already, and no argument shows the state.
+func (m *model) handlePropose(parts []string) tea.Msg {
+	n := len(m.eng.Pending)
+	if len(parts) < 2 {
+		state := "off"
+		if m.eng.Propose {
+			state = "on"
+		}
+		if n > 0 {
+			return sInfo.Render(fmt.Sprintf("Propose mode is %s; %d staged changes wait for review (/propose review)", state, n))
+		}
+		return sInfo.Render("Propose mode is " + state)
+	}
+	switch parts[1] {
+	case "on":
+		m.eng.Propose = true
+		return sOK.Render("✔ Propose mode on: file changes are staged for review after each turn")
+	case "off":
+		m.eng.Propose = false
+		if n > 0 {
+			return sOK.Render(fmt.Sprintf("✔ Propose mode off; %d staged changes still wait for review (/propose review)", n))
+		}
+		return sOK.Render("✔ Propose mode off: file tools write directly")
+	case "review":
+		if n == 0 {
+			return sInfo.Render("No staged changes to review")
+		}
+		return reviewStartMsg{}
+	}
+	return sErr.Render("Usage: /propose [on|off|review]")
+}
+
+// startReview asks about the first staged change.
+func (m model) startReview() (model, tea.Cmd) {
+	m.review = &review{changes: slices.Clone(m.eng.Pending), edited: map[string]bool{}}
+	head := sInfo.Render(fmt.Sprintf("✎ %d staged file changes to review", len(m.review.changes)))
+	return m, printAbove(head + "\n" + m.reviewPrompt())
+}
+
+// reviewPrompt shows the change asked about.
+func (m model) reviewPrompt() string {
+	r := m.review
+	c := r.changes[r.i]
+	verb := "change"
+	if c.Created {
+		verb = "create"
+	}
+	if r.edited[c.Path] {
+		verb += " (edited)"
+	}
+	lines := strings.Split(c.Diff(), "\n")
+	if len(lines) > maxReviewLines {
+		lines = append(lines[:maxReviewLines], fmt.Sprintf(" ... (%d more lines; e shows them all)", len(lines)-maxReviewLines))
+	}
+	head := fmt.Sprintf("%s %s (%d/%d)", verb, tool.ShowPath(c.Path), r.i+1, len(r.changes))
+	return renderToolResult(head + "\n" + strings.Join(lines, "\n"))
+}
+
+// answerReview acts on a key pressed during the review.
+func (m model) answerReview(key string) (tea.Model, tea.Cmd) {
+	r := m.review
+	switch key {
+	case "a":
+		r.accepted = append(r.accepted, r.changes[r.i])
+		r.i++
+	case "r":
+		r.rejected = append(r.rejected, tool.ShowPath(r.changes[r.i].Path))
+		r.i++
+	case "A":
+		r.accepted = append(r.accepted, r.changes[r.i:]...)
+		r.i = len(r.changes)
+	case "R":
+		for _, c := range r.changes[r.i:] {
+			r.rejected = append(r.rejected, tool.ShowPath(c.Path))
+		}
+		r.i = len(r.changes)
+	case "e":
+		return m, m.editStaged()
+	default:
+		return m, nil
+	}
+	if r.i < len(r.changes) {
+		return m, printAbove(m.reviewPrompt())
+	}
+	return m.finishReview()
+}
+
+// editStaged opens the staged version of the change asked about in the
+// user's editor.
+func (m model) editStaged() tea.Cmd {
+	c := m.review.changes[m.review.i]
+	f, err := os.CreateTemp("", "gal-review-*-"+filepath.Base(c.Path))
+	if err == nil {
+		_, err = f.WriteString(c.After)
+		f.Close()
+	}
+	if err != nil {
+		return printAbove(sErr.Render("✘ edit: " + err.Error()))
+	}
+	args := strings.Fields(editorCommand())
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return reviewEditedMsg{file: f.Name(), err: err} })
+}
+
+// editorCommand is $VISUAL or $EDITOR, or else the platform's plain editor.
+func editorCommand() string {
+	for _, v := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(v)); e != "" {
+			return e
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// reviewEdited takes the edited version as the change and asks about it
+// again.
+func (m model) reviewEdited(msg reviewEditedMsg) (tea.Model, tea.Cmd) {
+	defer os.Remove(msg.file)
+	if m.review == nil {
+		return m, nil
+	}
+	c := &m.review.changes[m.review.i]
+	data, err := os.ReadFile(msg.file)
+	if msg.err != nil {
+		err = msg.err
+	}
+	if err != nil {
+		return m, tea.Batch(setIBeamCursor, printAbove(sErr.Render("✘ edit: "+err.Error())+"\n"+m.reviewPrompt()))
+	}
+	if string(data) != c.After {
+		c.After = string(data)
+		m.review.edited[c.Path] = true
+	}
+	return m, tea.Batch(setIBeamCursor, printAbove(m.reviewPrompt()))
+}
+
+// leaveReview ends the review without applying anything; the changes,
+// without the user's edits, stay staged for /propose review.
+func (m model) leaveReview() (tea.Model, tea.Cmd) {
+	m.review = nil
+	return m, printAbove(sFaint.Render(fmt.Sprintf("‣ review left: %d changes stay staged (/propose review)", len(m.eng.Pending))))
+}
+
+// finishReview applies the accepted changes, then tells the model about
+// rejected and edited ones in a follow-up message. Without one, what was
+// queued during the turn runs.
+func (m model) finishReview() (tea.Model, tea.Cmd) {
+	r := m.review
+	m.review = nil
+	var applied, edited []string
+	for _, c := range r.accepted {
+		if r.edited[c.Path] {
+			edited = append(edited, tool.ShowPath(c.Path))
+		} else {
+			applied = append(applied, tool.ShowPath(c.Path))
+		}
+	}
+	var out, feedback string
+	if err := m.eng.ApplyChanges(r.accepted); err != nil {
+		out = sErr.Render("✘ " + err.Error())
+		feedback = "None of your staged file changes were written: " + err.Error()
+	} else {
+		out = sOK.Render(fmt.Sprintf("✔ %d changes applied, %d rejected", len(r.accepted), len(r.rejected)))
+		feedback = engine.ReviewFeedback(applied, edited, r.rejected)
+	}
+	if feedback != "" {
+		next, send := m.sendMessage(feedback)
+		return next, tea.Sequence(printAbove(out), send)
+	}
+	if m.eng.NeedsCompression() {
+		m.compressing = true
+		m.startTime = time.Now()
+		return m, tea.Sequence(printAbove(out), m.compressCmd())
+	}
+	var next tea.Cmd
+	m, next = m.sendQueued()
+	return m, tea.Sequence(printAbove(out), next)
+}
+
+// reviewStatus is the status line of the review.
+func (m model) reviewStatus() string {
+	return sInfo.Render("a") + sFaint.Render(i18n.T("review.accept")) +
+		sInfo.Render("r") + sFaint.Render(i18n.T("review.reject")) +
+		sInfo.Render("e") + sFaint.Render(i18n.T("review.edit")) +
+		sInfo.Render("A") + sFaint.Render(i18n.T("review.accept_rest")) +
+		sInfo.Render("R") + sFaint.Render(i18n.T("review.reject_rest"))
+}
+
+// onceReview settles the changes a -m turn staged: --yes applies them all,
+// otherwise they are written to a patch file to apply later.
+func onceReview(eng *engine.Engine, opts onceOptions) {
+	pending := eng.Pending
+	if opts.yes {
+		err := eng.ApplyChanges(pending)
+		switch {
+		case opts.jsonOut:
+			ev := map[string]any{"type": "applied", "files": len(pending)}
+			if err != nil {
+				ev["error"] = err.Error()
+			}
+			json.NewEncoder(os.Stdout).Encode(ev)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "\n%s staged changes: %v\n", opts.mark("✘", "[error]"), err)
+		case !opts.quiet:
+			fmt.Fprintf(os.Stderr, "\n%s applied %d staged file changes\n", opts.mark("✔", "[applied]"), len(pending))
+		}
+		return
+	}
+	var sb strings.Builder
+	for _, c := range pending {
+		sb.WriteString(c.Patch())
+	}
+	f, err := os.CreateTemp("", "gal-proposed-*.patch")
+	if err == nil {
+		_, err = f.WriteString(sb.String())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "\n%s staged changes not saved: %v\n", opts.mark("✘", "[error]"), err)
+	case opts.jsonOut:
+		json.NewEncoder(os.Stdout).Encode(map[string]any{"type": "proposed", "files": len(pending), "patch": f.Name()})
+	default:
+		// even with --quiet: it's where the turn's work went
+		fmt.Fprintf(os.Stderr, "\n%s %d staged file changes written to %s (apply with patch -p1, or rerun with --yes)\n",
+			opts.mark("✎", "[proposed]"), len(pending), f.Name())
+	}
+}
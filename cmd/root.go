@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/i18n"
 	"github.com/spf13/cobra"
 )
 
@@ -36,11 +40,50 @@ Examples:
   echo "test" | gal-cli chat -m -
   gal-cli chat -m @prompt.txt > output.txt`,
 	CompletionOptions: cobra.CompletionOptions{HiddenDefaultCmd: true},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return config.CheckDirs()
+	},
 }
 
 func Execute() {
+	// the language has to be known before cobra renders any help; a missing
+	// or broken config is reported by the command that needs it
+	if cfg, err := config.Load(); err == nil {
+		if err := i18n.Set(cfg.UI.Language); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ %v\n", err)
+		}
+	}
+	localizeCommands(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		os.Exit(1)
 	}
 }
+
+// exitError is an error that ends gal-cli with an exit code other than 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// localizeCommands replaces each command's Short with its catalog text,
+// keyed "cmd." plus the command path after gal-cli ("cmd.session.rm").
+func localizeCommands(c *cobra.Command) {
+	key := "cmd.root"
+	if c != rootCmd {
+		key = "cmd." + strings.ReplaceAll(strings.TrimPrefix(c.CommandPath(), rootCmd.Name()+" "), " ", ".")
+	}
+	if i18n.Has(key) {
+		c.Short = i18n.T(key)
+	}
+	for _, sub := range c.Commands() {
+		localizeCommands(sub)
+	}
+}
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/provider"
 	"github.com/gal-cli/gal-cli/internal/session"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +25,11 @@ func init() {
 		Use:   "list",
 		Short: "List all saved sessions",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			session.Cleanup()
+			cfg, err := config.Load()
+			if err != nil {
+				cfg = &config.Config{} // session_max_age's default
+			}
+			session.Cleanup(cfg.SessionRetention())
 			sessions, err := session.List()
 			if err != nil {
 				return err
@@ -36,7 +48,8 @@ func init() {
 		},
 	})
 
-	sessionCmd.AddCommand(&cobra.Command{
+	var showMessages, showArchive bool
+	showCmd := &cobra.Command{
 		Use:   "show [id]",
 		Short: "Show session metadata",
 		Args:  cobra.ExactArgs(1),
@@ -51,9 +64,30 @@ func init() {
 			fmt.Printf("Created:    %s\n", s.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Updated:    %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("Messages:   %d\n", len(s.Messages))
+			archive, skipped, err := session.LoadArchive(s.ID)
+			if err != nil {
+				return err
+			}
+			if len(archive) > 0 {
+				fmt.Printf("Archived:   %d messages, %s (--archive lists them with their summaries)\n", archivedMessages(archive), formatBytes(int(session.ArchiveSize(s.ID))))
+			}
+			if skipped > 0 {
+				fmt.Printf("            %d unreadable archive entries skipped\n", skipped)
+			}
+			if showArchive && len(archive) > 0 {
+				fmt.Println()
+				fmt.Print(archiveList(archive))
+			}
+			if showMessages {
+				fmt.Println()
+				fmt.Print(messageList(s.Messages))
+			}
 			return nil
 		},
-	})
+	}
+	showCmd.Flags().BoolVar(&showMessages, "messages", false, "Also list the messages: role, time, model and the start of each")
+	showCmd.Flags().BoolVar(&showArchive, "archive", false, "Also list the messages compression replaced, with their summaries")
+	sessionCmd.AddCommand(showCmd)
 
 	sessionCmd.AddCommand(&cobra.Command{
 		Use:   "rm [id]",
@@ -68,5 +102,304 @@ func init() {
 		},
 	})
 
+	var revert string
+	changesCmd := &cobra.Command{
+		Use:   "changes [id]",
+		Short: "List what a session's tools changed, or revert a file change",
+		Long: `List the calls a session made to tools that may change the machine (file
+writes with their diffs, bash commands, custom and MCP tools that aren't
+read-only), oldest first. --revert puts a file back the way it was before a
+change, if the file hasn't changed again since.
+
+Examples:
+  gal-cli session changes abc123
+  gal-cli session changes abc123 --revert 4`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := session.Load(args[0])
+			if err != nil {
+				return fmt.Errorf("session not found: %s", args[0])
+			}
+			if revert == "" {
+				fmt.Println(renderChanges(s.Changes, "--revert %d"))
+				return nil
+			}
+			msg, err := revertChange(s.Changes, revert)
+			if err != nil {
+				return err
+			}
+			if err := s.Save(); err != nil {
+				return err
+			}
+			fmt.Println(msg)
+			return nil
+		},
+	}
+	changesCmd.Flags().StringVar(&revert, "revert", "", "Revert file change n")
+	sessionCmd.AddCommand(changesCmd)
+
+	var catOpts catOptions
+	catCmd := &cobra.Command{
+		Use:   "cat [id]",
+		Short: "Print session messages as plain text or JSON lines",
+		Long: `Print a session's messages for piping into other tools.
+
+Examples:
+  gal-cli session cat abc123 --role assistant --last 1 | pbcopy
+  gal-cli session cat abc123 --tool bash            # commands the agent ran, with results
+  gal-cli session cat abc123 --since 2h --jsonl | jq .`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return catSession(args[0], catOpts)
+		},
+	}
+	catCmd.Flags().StringVar(&catOpts.role, "role", "", "Only messages with this role (user, assistant, tool, system)")
+	catCmd.Flags().IntVar(&catOpts.last, "last", 0, "Only the last N entries")
+	catCmd.Flags().StringVar(&catOpts.since, "since", "", "Only turns started after this time (RFC 3339, 2006-01-02[ 15:04], or a duration like 2h)")
+	catCmd.Flags().StringVar(&catOpts.tool, "tool", "", "Only calls of this tool: their arguments and results")
+	catCmd.Flags().BoolVar(&catOpts.jsonl, "jsonl", false, "One JSON object per line")
+	sessionCmd.AddCommand(catCmd)
+
 	rootCmd.AddCommand(sessionCmd)
 }
+
+type catOptions struct {
+	role  string
+	last  int
+	since string
+	tool  string
+	jsonl bool
+}
+
+// catEntry is one printable unit: a whole message, or with --tool a single
+// call's arguments or result.
+type catEntry struct {
+	msg       *provider.Message
+	tool, id  string
+	arguments string
+	result    string
+	isResult  bool
+}
+
+func catSession(id string, opts catOptions) error {
+	var since time.Time
+	if opts.since != "" {
+		t, err := parseSince(opts.since)
+		if err != nil {
+			return err
+		}
+		since = t
+	}
+
+	mk := session.NewMasker()
+	toolNames := make(map[string]string) // tool call ID → tool name
+	var ring []catEntry
+	emit := func(e catEntry) {
+		if opts.last <= 0 {
+			printCatEntry(e, opts.jsonl)
+			return
+		}
+		ring = append(ring, e)
+		if len(ring) > opts.last {
+			ring = ring[1:]
+		}
+	}
+	visit := func(m provider.Message) error {
+		mk.Observe(m)
+		for _, tc := range m.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+		}
+		if opts.role != "" && m.Role != opts.role {
+			return nil
+		}
+		if opts.tool == "" {
+			m.Content = mk.Mask(m.Content)
+			for i := range m.ToolCalls {
+				m.ToolCalls[i].Function.Arguments = mk.Mask(m.ToolCalls[i].Function.Arguments)
+			}
+			emit(catEntry{msg: &m, tool: toolNames[m.ToolCallID]})
+			return nil
+		}
+		for _, tc := range m.ToolCalls {
+			if tc.Function.Name == opts.tool {
+				emit(catEntry{tool: tc.Function.Name, id: tc.ID, arguments: mk.Mask(tc.Function.Arguments)})
+			}
+		}
+		if m.Role == "tool" && toolNames[m.ToolCallID] == opts.tool {
+			emit(catEntry{tool: opts.tool, id: m.ToolCallID, result: mk.Mask(m.Content), isResult: true})
+		}
+		return nil
+	}
+
+	var err error
+	if since.IsZero() {
+		err = session.Stream(id, visit)
+	} else {
+		// message times come from the per-turn stats, so the whole session is needed
+		var s *session.Session
+		if s, err = session.Load(id); err == nil {
+			times := messageTimes(s)
+			for i, m := range s.Messages {
+				if times[i].IsZero() || times[i].Before(since) {
+					mk.Observe(m)
+					for _, tc := range m.ToolCalls {
+						toolNames[tc.ID] = tc.Function.Name
+					}
+					continue
+				}
+				if err = visit(m); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range ring {
+		printCatEntry(e, opts.jsonl)
+	}
+	return nil
+}
+
+func printCatEntry(e catEntry, jsonl bool) {
+	if jsonl {
+		var v any = e.msg
+		if e.msg == nil {
+			ev := map[string]any{"tool": e.tool, "id": e.id}
+			if e.isResult {
+				ev["result"] = e.result
+			} else {
+				ev["arguments"] = json.RawMessage(e.arguments)
+				if !json.Valid([]byte(e.arguments)) {
+					ev["arguments"] = e.arguments
+				}
+			}
+			v = ev
+		}
+		b, _ := json.Marshal(v)
+		fmt.Println(string(b))
+		return
+	}
+	if e.msg == nil {
+		if e.isResult {
+			fmt.Printf("[%s result]\n%s\n\n", e.tool, strings.TrimRight(e.result, "\n"))
+		} else {
+			fmt.Printf("[%s] %s\n", e.tool, e.arguments)
+		}
+		return
+	}
+	m := e.msg
+	if m.Role == "tool" && e.tool != "" {
+		fmt.Printf("[tool %s]\n", e.tool)
+	} else {
+		fmt.Printf("[%s]\n", m.Role)
+	}
+	if m.Content != "" {
+		fmt.Println(strings.TrimRight(m.Content, "\n"))
+	}
+	for _, tc := range m.ToolCalls {
+		fmt.Printf("→ %s %s\n", tc.Function.Name, tc.Function.Arguments)
+	}
+	fmt.Println()
+}
+
+// messageList has a line per message: its number, role, when it was added
+// and the model that wrote it, as far as the session recorded them, and the
+// start of its content or tool calls.
+func messageList(msgs []provider.Message) string {
+	var sb strings.Builder
+	mk := session.NewMasker()
+	toolNames := make(map[string]string) // tool call ID → tool name
+	for i, m := range msgs {
+		mk.Observe(m)
+		role := m.Role
+		for _, tc := range m.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+		}
+		if m.Role == "tool" && toolNames[m.ToolCallID] != "" {
+			role = "tool " + toolNames[m.ToolCallID]
+		}
+		when := ""
+		if m.Timestamp != nil {
+			when = m.Timestamp.Local().Format("2006-01-02 15:04:05")
+		}
+		preview := strings.Join(strings.Fields(mk.Mask(m.Content)), " ")
+		for _, tc := range m.ToolCalls {
+			if preview != "" {
+				preview += " "
+			}
+			preview += "→ " + tc.Function.Name + " " + strings.Join(strings.Fields(mk.Mask(tc.Function.Arguments)), " ")
+		}
+		fmt.Fprintf(&sb, "  #%-4d %-16s %-19s  %-28s %s\n", i+1, role, when, m.Model, clipText(preview, 80))
+	}
+	return sb.String()
+}
+
+func archivedMessages(archive []engine.Archived) int {
+	n := 0
+	for _, a := range archive {
+		n += len(a.Messages)
+	}
+	return n
+}
+
+// archiveList shows what compression replaced in a session: when, the
+// summary it was replaced with, and the messages.
+func archiveList(archive []engine.Archived) string {
+	var sb strings.Builder
+	for i, a := range archive {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "Archived %s: %d messages, summarized as\n", a.Time.Local().Format("2006-01-02 15:04:05"), len(a.Messages))
+		for _, l := range strings.Split(strings.TrimSpace(a.Summary), "\n") {
+			sb.WriteString("  │ " + l + "\n")
+		}
+		sb.WriteString(messageList(a.Messages))
+	}
+	return sb.String()
+}
+
+// messageTimes assigns each message the start time of the turn it belongs to.
+// Turns are matched to user messages from the end; before the recorded
+// turns, a user message's own timestamp starts a turn, and messages of
+// sessions with neither get the zero time.
+func messageTimes(s *session.Session) []time.Time {
+	turnStart := make(map[int]time.Time) // user message index → turn start
+	k := len(s.Turns) - 1
+	for i := len(s.Messages) - 1; i >= 0 && k >= 0; i-- {
+		if s.Messages[i].Role == "user" {
+			turnStart[i] = s.Turns[k].Start
+			k--
+		}
+	}
+	times := make([]time.Time, len(s.Messages))
+	var cur time.Time
+	for i, m := range s.Messages {
+		if m.Role == "user" {
+			cur = turnStart[i]
+			if cur.IsZero() && m.Timestamp != nil {
+				cur = *m.Timestamp
+			}
+		}
+		times[i] = cur
+	}
+	return times
+}
+
+// parseSince accepts an absolute time or a duration back from now.
+func parseSince(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use RFC 3339, 2006-01-02[ 15:04], or a duration like 2h)", v)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/tool"
+	"github.com/mattn/go-runewidth"
+)
+
+// maxShellContext caps the shell output added to the conversation in
+// /shell --context mode; longer output keeps its head and tail.
+const maxShellContext = 8 * 1024
+
+// jobControlNoise matches the warnings a shell prints when it is made
+// interactive without a terminal to control, e.g. "bash: cannot set terminal
+// process group (123): Inappropriate ioctl for device".
+var jobControlNoise = regexp.MustCompile(`(?m)^(ba|z)?sh: (cannot set terminal process group|no job control in this shell|can't set tty pgrp).*\n?`)
+
+// shellModeCommand builds the command that runs a line typed in shell mode.
+// By default the shell starts without its rc files and only sources
+// shellrc in the gal config directory, if there is one: a .bashrc can take
+// the better part of a second per command (nvm, prompt themes) and is
+// meant for terminals. With shell.interactive_rc bash sources ~/.bashrc and
+// ~/.bash_aliases as an interactive shell would.
+func shellModeCommand(ctx context.Context, input string, conf config.ShellConf) *exec.Cmd {
+	if !tool.IsPOSIXShell() {
+		return tool.ShellCommand(ctx, input)
+	}
+	var rc []string
+	if tool.ShellName() == "bash" {
+		rc = append(rc, "shopt -s expand_aliases")
+		if conf.InteractiveRC {
+			// PS1 makes .bashrc files that return early when not interactive read on
+			rc = append(rc, "export PS1='$ '",
+				`if [ -f ~/.bashrc ]; then . ~/.bashrc; fi`,
+				`if [ -f ~/.bash_aliases ]; then . ~/.bash_aliases; fi`)
+		}
+	}
+	shellrc := filepath.Join(config.GalDir(), "shellrc")
+	if _, err := os.Stat(shellrc); err == nil {
+		rc = append(rc, `. "$GAL_SHELLRC"`)
+	}
+	// aliases defined above apply from the next line on
+	cmd := tool.ShellCommand(ctx, strings.Join(append(rc, input), "\n"))
+	cmd.Env = append(os.Environ(), "GAL_SHELLRC="+shellrc)
+	return cmd
+}
+
+// shellOutput is a shell-mode command's output prepared for the screen and,
+// in --context mode, for the conversation.
+type shellOutput struct {
+	display string // the last lines, soft-wrapped to the terminal
+	context string // head and tail, pointing at the saved file when trimmed
+}
+
+// prepareShellOutput keeps the last maxLines lines for display. When output
+// is cut, for the screen or for the conversation, the whole of it is saved
+// to a temp file that the header and the context note point at.
+func prepareShellOutput(out string, maxLines, width int, withContext bool) shellOutput {
+	out = strings.TrimSuffix(out, "\n")
+	lines := strings.Split(out, "\n")
+	var path string
+	if len(lines) > maxLines || (withContext && len(out) > maxShellContext) {
+		var err error
+		if path, err = saveShellOutput(out); err != nil {
+			path = ""
+		}
+	}
+
+	var sb strings.Builder
+	shown := lines
+	if len(lines) > maxLines {
+		shown = lines[len(lines)-maxLines:]
+		header := fmt.Sprintf("…%d earlier lines", len(lines)-maxLines)
+		if path != "" {
+			header += ", saved to " + path
+		}
+		sb.WriteString(sFaint.Render(header) + "\n")
+	}
+	for i, line := range shown {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(strings.Join(softWrap(line, width), "\n"))
+	}
+
+	so := shellOutput{display: sb.String(), context: out}
+	if withContext && len(out) > maxShellContext {
+		so.context = headTail(out, maxShellContext, path)
+	}
+	return so
+}
+
+// saveShellOutput writes output to a new gal-shell-<time>-*.log in the temp
+// directory.
+func saveShellOutput(out string) (string, error) {
+	f, err := os.CreateTemp("", fmt.Sprintf("gal-shell-%s-*.log", time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(out + "\n"); err != nil {
+		return "", err
+	}
+	return filepath.Clean(f.Name()), nil
+}
+
+// headTail keeps about n bytes of s, a third from the start and the rest
+// from the end, cut at line breaks and never inside a character, noting what
+// was left out and where the full text is.
+func headTail(s string, n int, path string) string {
+	head := tool.ClipUTF8(s, n/3)
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i]
+	}
+	t := len(s) - (n - len(head))
+	for t < len(s) && !utf8.RuneStart(s[t]) {
+		t++ // not inside a character either
+	}
+	tail := s[t:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	omitted := strings.Count(s[len(head):len(s)-len(tail)], "\n") - 1
+	note := fmt.Sprintf("[… %d lines omitted", max(omitted, 0))
+	if path != "" {
+		note += "; full output in " + path + " (file_read it for details)"
+	}
+	return head + "\n" + note + " …]\n" + tail
+}
+
+// softWrap splits a line wider than width terminal columns into pieces that
+// fit, so long lines don't depend on how the terminal wraps. Escape
+// sequences take no room and are never split.
+func softWrap(line string, width int) []string {
+	if width <= 0 || runewidth.StringWidth(line) <= width {
+		return []string{line}
+	}
+	var out []string
+	var cur strings.Builder
+	w := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' && i+1 < len(line) && line[i+1] == '[' {
+			j := i + 2
+			for j < len(line) && (line[j] < 0x40 || line[j] > 0x7e) {
+				j++
+			}
+			j = min(j+1, len(line))
+			cur.WriteString(line[i:j])
+			i = j
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		rw := runewidth.RuneWidth(r)
+		if w+rw > width && w > 0 {
+			out = append(out, cur.String())
+			cur.Reset()
+			w = 0
+		}
+		cur.WriteString(line[i : i+size])
+		w += rw
+		i += size
+	}
+	return append(out, cur.String())
+}
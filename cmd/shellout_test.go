@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHeadTail(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+	}{
+		{"ascii lines", strings.Repeat("line of output\n", 2000), maxShellContext},
+		{"one long line", strings.Repeat("x", 50000), maxShellContext},
+		{"cjk without line breaks", strings.Repeat("日本語のテキスト", 3000), maxShellContext},
+		{"accents without line breaks", strings.Repeat("é", 20000), 1001},
+		{"emoji lines", strings.Repeat("build ✅ 🚀 done\n", 1000), 1000},
+		{"mixed widths", strings.Repeat("aé日🚀", 5000), 997},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := headTail(tt.s, tt.n, "/tmp/out.log")
+			if !utf8.ValidString(got) {
+				t.Fatalf("headTail split a character: %q", got)
+			}
+			i := strings.Index(got, "\n[… ")
+			j := strings.Index(got, " …]\n")
+			if i < 0 || j < i {
+				t.Fatalf("no note in %.200q", got)
+			}
+			j += len(" …]")
+			head, note, tail := got[:i], got[i+1:j], got[j+1:]
+			if !strings.HasPrefix(tt.s, head) || !strings.HasSuffix(tt.s, tail) {
+				t.Errorf("head %.40q or tail %.40q is not from the text", head, tail)
+			}
+			if len(head)+len(tail) > tt.n || len(head)+len(tail) < tt.n/2 {
+				t.Errorf("kept %d bytes of the text, want about %d", len(head)+len(tail), tt.n)
+			}
+			if !strings.Contains(note, "/tmp/out.log") {
+				t.Errorf("note %q doesn't say where the output is", note)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// terminateMsg asks the chat to quit the way /exit does, after SIGINT or
+// SIGTERM.
+type terminateMsg struct{}
+
+// terminateGrace is how long the chat gets to quit after a signal before the
+// program is killed (which still restores the terminal).
+const terminateGrace = 3 * time.Second
+
+// suspendSupported reports whether Ctrl+Z can stop the process; Windows has
+// no job control.
+var suspendSupported = runtime.GOOS != "windows"
+
+func restoreCursor() tea.Msg {
+	// \033[0 q = the terminal's default cursor
+	fmt.Print("\033[0 q")
+	return nil
+}
+
+// suspendCmd gives the terminal back in its normal state and stops the
+// process, as Ctrl+Z does for other programs. Bubble Tea re-enters the
+// program and sends tea.ResumeMsg on SIGCONT.
+func suspendCmd() tea.Cmd {
+	return tea.Sequence(restoreCursor, tea.Suspend)
+}
+
+// handleTermSignals routes SIGINT and SIGTERM through the model instead of
+// Bubble Tea's default handler, so `kill` saves the session and restores the
+// terminal like /exit. Call stop once the program has returned.
+func handleTermSignals(p *tea.Program) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sig:
+		case <-done:
+			return
+		}
+		p.Send(terminateMsg{})
+		select {
+		case <-time.After(terminateGrace):
+			p.Kill()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
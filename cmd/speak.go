@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// maxSpeakChars caps the text read aloud for one reply.
+const maxSpeakChars = 3000
+
+const noSpeakCommand = "✘ no speech command: set ui.speak in gal.yaml, e.g. speak: espeak-ng"
+
+// speakMsg asks the chat to read text aloud (from /say).
+type speakMsg string
+
+// speakErrMsg reports a failed speech command.
+type speakErrMsg struct{ err error }
+
+// defaultSpeakCommand is what /speak on uses when ui.speak isn't set.
+func defaultSpeakCommand() string {
+	if runtime.GOOS == "darwin" {
+		return "say"
+	}
+	return ""
+}
+
+// speakCmd reads text aloud with the speech command in the background,
+// stopping whatever is still being spoken. The command runs in the configured
+// shell with the text on stdin.
+func (m *model) speakCmd(text string) tea.Cmd {
+	if m.speakCommand == "" || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	m.stopSpeaking()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.speakStop = cancel
+	command := m.speakCommand
+	return func() tea.Msg {
+		defer cancel()
+		cmd := tool.ShellCommand(ctx, command)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				err = fmt.Errorf("%w: %s", err, clipText(msg, 200))
+			}
+			return speakErrMsg{err}
+		}
+		return nil
+	}
+}
+
+func (m *model) stopSpeaking() {
+	if m.speakStop != nil {
+		m.speakStop()
+		m.speakStop = nil
+	}
+}
+
+// handleSpeak runs /speak [on|off].
+func (m *model) handleSpeak(parts []string) tea.Msg {
+	if len(parts) < 2 {
+		if m.speakOn {
+			return sInfo.Render("Speaking replies with: " + m.speakCommand)
+		}
+		return sInfo.Render("Speaking replies is off")
+	}
+	switch parts[1] {
+	case "on":
+		if m.speakCommand == "" {
+			return sErr.Render(noSpeakCommand)
+		}
+		m.speakOn = true
+		return sOK.Render("✔ Speaking replies with: " + m.speakCommand)
+	case "off":
+		m.speakOn = false
+		m.stopSpeaking()
+		return sOK.Render("✔ Speaking replies off")
+	}
+	return sErr.Render("Usage: /speak [on|off]")
+}
+
+var (
+	mdFence     = regexp.MustCompile("(?m)^ {0,3}(```|~~~)")
+	mdImage     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdHTML      = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdLinePre   = regexp.MustCompile(`^\s*(#{1,6}\s+|>\s*|[-*+]\s+(\[[ xX]\]\s+)?|\d+[.)]\s+)`)
+	mdRule      = regexp.MustCompile(`^\s*([-*_]\s*){3,}$|^\s*\|?[\s:|-]+\|[\s:|-]*$`)
+	mdEmphasis  = regexp.MustCompile("(\\*\\*|__|\\*|~~|`)")
+	mdCells     = regexp.MustCompile(`\s*\|\s*`)
+	mdSpaceRuns = regexp.MustCompile(`[ \t]+`)
+	mdBlankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// speechText turns a markdown reply into plain text worth reading aloud:
+// code blocks are skipped, markup is dropped and the result is capped at
+// maxSpeakChars, cut at a sentence end when there is one.
+func speechText(md string) string {
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(md, "\n") {
+		if mdFence.MatchString(line) {
+			inCode = !inCode
+			continue
+		}
+		if inCode || mdRule.MatchString(line) {
+			continue
+		}
+		line = mdImage.ReplaceAllString(line, "")
+		line = mdLink.ReplaceAllString(line, "$1")
+		line = mdHTML.ReplaceAllString(line, "")
+		line = mdLinePre.ReplaceAllString(line, "")
+		line = mdEmphasis.ReplaceAllString(line, "")
+		line = strings.Trim(mdCells.ReplaceAllString(line, ", "), " ,")
+		line = mdSpaceRuns.ReplaceAllString(line, " ")
+		lines = append(lines, line)
+	}
+	text := strings.TrimSpace(mdBlankRuns.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+	if len(text) <= maxSpeakChars {
+		return text
+	}
+	text = clipText(text, maxSpeakChars)
+	text = strings.TrimSuffix(text, "…")
+	if i := strings.LastIndexAny(text, ".!?\n"); i > maxSpeakChars/2 {
+		text = text[:i+1]
+	}
+	return text
+}
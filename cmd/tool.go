@@ -2,25 +2,102 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 
+	"github.com/charmbracelet/glamour"
+	"github.com/gal-cli/gal-cli/internal/agent"
+	"github.com/gal-cli/gal-cli/internal/config"
 	"github.com/gal-cli/gal-cli/internal/tool"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "tool list",
-		Short: "List all built-in tools",
-		Run: func(cmd *cobra.Command, args []string) {
-			reg := tool.NewRegistry()
-			for _, d := range reg.GetDefs(nil) {
-				desc := d.Description
-				if i := strings.IndexAny(desc, ".\n"); i > 0 {
-					desc = desc[:i]
+	listTools := func(cmd *cobra.Command, args []string) {
+		reg := toolRegistry()
+		defs := reg.GetDefs(nil)
+		sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+		fmt.Println(strings.Join(toolListLines(defs, nil, reg), "\n"))
+	}
+	toolCmd := &cobra.Command{
+		Use:   "tool",
+		Short: "List and inspect tools",
+		Run:   listTools,
+	}
+	toolCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List all built-in and custom tools",
+		Run:   listTools,
+	})
+
+	var agentName string
+	showCmd := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show a tool's description and parameters",
+		Long: `Show a tool's description and parameter schema.
+
+Built-in and custom tools are always available; use --agent to also look up
+an agent's skill (skill_*) and MCP (mcp_*) tools.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reg := toolRegistry()
+			defs := reg.GetDefs(nil)
+			if agentName != "" {
+				agentConf, err := config.LoadAgent(agentName)
+				if err != nil {
+					return err
+				}
+				a, err := agent.Build(agentConf, reg)
+				if err != nil {
+					return err
 				}
-				fmt.Printf("  %-12s %s\n", d.Name, desc)
+				defer a.Close()
+				defs = append(a.ToolDefs, defs...)
 			}
+			d, ok := findToolDef(defs, args[0])
+			if !ok {
+				return fmt.Errorf("unknown tool: %s", args[0])
+			}
+			printMarkdown(toolSchemaMarkdown(d, reg.IsReadOnly(d.Name)))
+			return nil
 		},
-	})
+	}
+	showCmd.Flags().StringVarP(&agentName, "agent", "a", "", "Include this agent's skill and MCP tools")
+	toolCmd.AddCommand(showCmd)
+
+	rootCmd.AddCommand(toolCmd)
+}
+
+// toolRegistry returns the built-in tools plus the custom tools and
+// web_search from gal.yaml.
+func toolRegistry() *tool.Registry {
+	reg := tool.NewRegistry()
+	if cfg, err := config.Load(); err == nil {
+		for _, ct := range cfg.CustomTools {
+			if err := reg.RegisterCustom(ct); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ %v\n", err)
+			}
+		}
+		if cfg.Search.Provider != "" {
+			if err := reg.RegisterSearch(cfg.Search); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠ %v\n", err)
+			}
+		}
+	}
+	return reg
+}
+
+// printMarkdown renders md for the terminal, or prints it as is when stdout
+// isn't one.
+func printMarkdown(md string) {
+	if isTerminal(os.Stdout) {
+		if r, err := glamour.NewTermRenderer(glamour.WithStyles(markdownStyle()), glamour.WithWordWrap(100)); err == nil {
+			if out, err := r.Render(md); err == nil {
+				fmt.Print(out)
+				return
+			}
+		}
+	}
+	fmt.Print(md)
 }
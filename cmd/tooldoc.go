@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// toolListLines renders one line per tool: name, "ro" for read-only tools,
+// and the first sentence of the description. The agent's native tools,
+// which the provider runs, come last.
+func toolListLines(defs []provider.ToolDef, native []string, reg *tool.Registry) []string {
+	width := 12
+	for _, d := range defs {
+		width = max(width, len(d.Name))
+	}
+	for _, name := range native {
+		width = max(width, len(name))
+	}
+	lines := make([]string, 0, len(defs)+len(native))
+	for _, d := range defs {
+		flag := "  "
+		if reg != nil && reg.IsReadOnly(d.Name) {
+			flag = "ro"
+		}
+		lines = append(lines, fmt.Sprintf("  %-*s %s  %s", width, d.Name, flag, firstSentence(d.Description)))
+	}
+	for _, name := range native {
+		api, _, _ := strings.Cut(name, ":")
+		lines = append(lines, fmt.Sprintf("  %-*s     provider-native, for the %s API", width, name, api))
+	}
+	return lines
+}
+
+func firstSentence(s string) string {
+	if i := strings.IndexAny(s, ".\n"); i > 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func findToolDef(defs []provider.ToolDef, name string) (provider.ToolDef, bool) {
+	for _, d := range defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return provider.ToolDef{}, false
+}
+
+func toolNames(defs []provider.ToolDef) []string {
+	names := make([]string, len(defs))
+	for i, d := range defs {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// toolSchemaMarkdown documents a tool as markdown: its description and a
+// table of parameters. Nested object properties are listed with dotted names,
+// those of array items as name[].field.
+func toolSchemaMarkdown(d provider.ToolDef, readonly bool) string {
+	var sb strings.Builder
+	sb.WriteString("## " + d.Name + "\n\n")
+	if readonly {
+		sb.WriteString("*read-only*\n\n")
+	}
+	if d.Description != "" {
+		sb.WriteString(d.Description + "\n\n")
+	}
+	var rows [][4]string
+	schemaRows(d.Parameters, "", &rows)
+	if len(rows) == 0 {
+		sb.WriteString("No parameters.\n")
+		return sb.String()
+	}
+	sb.WriteString("| Parameter | Type | Required | Description |\n|---|---|---|---|\n")
+	for _, r := range rows {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", r[0], r[1], r[2], r[3]))
+	}
+	return sb.String()
+}
+
+func schemaRows(schema map[string]any, prefix string, rows *[][4]string) {
+	props, _ := schema["properties"].(map[string]any)
+	required := map[string]bool{}
+	switch req := schema["required"].(type) {
+	case []string:
+		for _, n := range req {
+			required[n] = true
+		}
+	case []any:
+		for _, n := range req {
+			if s, ok := n.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(props))
+	for n := range props {
+		names = append(names, n)
+	}
+	// required parameters first, then alphabetical
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	for _, n := range names {
+		p, _ := props[n].(map[string]any)
+		req := ""
+		if required[n] {
+			req = "yes"
+		}
+		desc, _ := p["description"].(string)
+		*rows = append(*rows, [4]string{"`" + prefix + n + "`", schemaType(p), req, tableCell(desc)})
+		if _, ok := p["properties"].(map[string]any); ok {
+			schemaRows(p, prefix+n+".", rows)
+		} else if items, ok := p["items"].(map[string]any); ok {
+			if _, ok := items["properties"].(map[string]any); ok {
+				schemaRows(items, prefix+n+"[].", rows)
+			}
+		}
+	}
+}
+
+// schemaType describes a property's type, e.g. "string", "array of string",
+// "string: get | post".
+func schemaType(p map[string]any) string {
+	t, _ := p["type"].(string)
+	if t == "" {
+		t = "any"
+	}
+	if t == "array" {
+		if items, ok := p["items"].(map[string]any); ok {
+			t = "array of " + schemaType(items)
+		}
+	}
+	var vals []string
+	switch enum := p["enum"].(type) {
+	case []string:
+		vals = enum
+	case []any:
+		for _, v := range enum {
+			vals = append(vals, fmt.Sprint(v))
+		}
+	}
+	if len(vals) > 0 {
+		t += ": " + strings.Join(vals, " \\| ")
+	}
+	return t
+}
+
+// tableCell makes text safe for a single markdown table cell.
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
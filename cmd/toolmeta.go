@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffLines caps the diff shown under a file tool's result.
+const maxDiffLines = 40
+
+// renderToolResultMeta renders a tool result preview with what its metadata
+// adds: the whole diff of a file change (the preview cuts it short), a
+// failed exit code or the signal that killed a command, an HTTP status line or where a cut result was saved.
+func renderToolResultMeta(preview string, meta map[string]any) string {
+	if diff, ok := meta["diff"].(string); ok && diff != "" {
+		first, _, _ := strings.Cut(preview, "\n")
+		lines := strings.Split(diff, "\n")
+		if len(lines) > maxDiffLines {
+			lines = append(lines[:maxDiffLines], fmt.Sprintf(" ... (%d more diff lines)", len(lines)-maxDiffLines))
+		}
+		preview = first + "\n" + strings.Join(lines, "\n")
+	}
+	out := renderToolResult(preview)
+	if line := toolMetaLine(meta); line != "" {
+		out += "\n    " + line
+	}
+	return out
+}
+
+// toolMetaLine summarizes metadata worth a glance, or "" when there's none.
+func toolMetaLine(meta map[string]any) string {
+	if sig, _ := meta["signal"].(string); sig != "" {
+		return sErr.Render("✘ " + sig)
+	}
+	if code, ok := meta["exit_code"].(int); ok && code != 0 {
+		return sErr.Render(fmt.Sprintf("✘ exit %d", code))
+	}
+	if status, ok := meta["status"].(int); ok {
+		parts := []string{fmt.Sprintf("HTTP %d", status)}
+		if n, ok := meta["bytes"].(int); ok {
+			parts = append(parts, formatBytes(n))
+		}
+		if ct, _ := meta["content_type"].(string); ct != "" {
+			ct, _, _ = strings.Cut(ct, ";")
+			parts = append(parts, ct)
+		}
+		line := strings.Join(parts, " · ")
+		if status >= 400 {
+			return sErr.Render(line)
+		}
+		return sFaint.Render(line)
+	}
+	if path, _ := meta["full_output"].(string); path != "" {
+		return sFaint.Render("✂ cut to fit the context; full output in " + path)
+	}
+	return ""
+}
+
+// formatBytes renders a size the way people read it: 512 B, 3.4 KB, 1.2 MB.
+func formatBytes(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// handleUndo runs /undo: take the last turn out of the conversation and
+// save the session without it. Each /undo takes one more.
+func (m *model) handleUndo() string {
+	removed := m.eng.RemoveLastTurn()
+	if len(removed) == 0 {
+		return sErr.Render("✘ Nothing to undo")
+	}
+	autosave(m.eng, m.sess)
+	return sFaint.Render(m.undoSummary(removed))
+}
+
+// undoSummary describes the turn RemoveLastTurn took out: the start of the
+// message, its answers and tool calls, and whether tools changed things
+// that stay changed.
+func (m *model) undoSummary(removed []provider.Message) string {
+	text, _, _ := strings.Cut(removed[0].Content, "\n")
+	if r := []rune(text); len(r) > 60 {
+		text = string(r[:60]) + "…"
+	}
+	answers, calls := 0, 0
+	changed := false
+	for _, msg := range removed[1:] {
+		if msg.Role == "assistant" && msg.Content != "" {
+			answers++
+		}
+		for _, tc := range msg.ToolCalls {
+			calls++
+			var args map[string]any
+			json.Unmarshal([]byte(tc.Function.Arguments), &args)
+			if tc.Function.Name != "interactive" && !m.eng.Agent.Registry.IsReadOnlyCall(tc.Function.Name, args) {
+				changed = true
+			}
+		}
+	}
+	out := fmt.Sprintf("↶ Undid %q: %s, %s · %s left", text,
+		plural(answers, "answer"), plural(calls, "tool call"), plural(len(m.eng.Messages), "message"))
+	if changed {
+		out += "\n  what its tools changed stays changed (see /changes)"
+	}
+	return out
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
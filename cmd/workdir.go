@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The file tools, bash and @file mentions work in gal-cli's working
+// directory, where the chat started; only /cd moves it. Shell mode keeps its
+// own directory in shellCwd, which its cd changes.
+
+// handleCd runs /cd: move the directory the file tools work in, or show it
+// and shell mode's. Shell mode follows when it was in the same directory.
+func (m *model) handleCd(input string) tea.Msg {
+	base, err := os.Getwd()
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	arg := strings.TrimSpace(strings.TrimPrefix(input, "/cd"))
+	if arg == "" {
+		out := sInfo.Render("File tools work in " + shortDir(base))
+		if m.shellCwd != base {
+			out += "\n" + sInfo.Render("Shell mode is in "+shortDir(m.shellCwd))
+		}
+		return out
+	}
+	if arg == "~" || strings.HasPrefix(arg, "~/") {
+		home, _ := os.UserHomeDir()
+		arg = filepath.Join(home, arg[1:])
+	}
+	if !filepath.IsAbs(arg) {
+		arg = filepath.Join(base, arg)
+	}
+	fi, err := os.Stat(arg)
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("not a directory: %s", arg)
+	}
+	if err == nil {
+		err = os.Chdir(arg)
+	}
+	if err != nil {
+		return sErr.Render("✘ " + err.Error())
+	}
+	dir, _ := os.Getwd()
+	if m.shellCwd == base {
+		m.shellCwd = dir
+	}
+	return sOK.Render("✔ File tools now work in " + shortDir(dir))
+}
+
+// workdirStatus names the shell mode and file tool directories for the
+// status bar while they differ, or returns "".
+func (m *model) workdirStatus() string {
+	base, err := os.Getwd()
+	if err != nil || base == m.shellCwd {
+		return ""
+	}
+	return "files " + shortDir(base) + " · shell " + shortDir(m.shellCwd)
+}
+
+// shortDir shows a directory under the home directory as ~/...
+func shortDir(dir string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return dir
+	}
+	if dir == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(dir, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+	return dir
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gal-cli/gal-cli/internal/config"
 	"github.com/gal-cli/gal-cli/internal/mcp"
@@ -13,15 +15,27 @@ import (
 	"github.com/gal-cli/gal-cli/internal/tool"
 )
 
-const lazyThreshold = 1024 // bytes
+// defaultLazyThreshold is the skill size (bytes) from which a skill is only
+// listed in the prompt and loaded on demand; agents can set lazy_threshold.
+const defaultLazyThreshold = 1024
 
 type Agent struct {
 	Conf         *config.AgentConf
 	CurrentModel string
-	SystemPrompt string // assembled prompt (base + skills)
+	SystemPrompt string       // assembled prompt (base + skills)
+	PromptParts  []PromptPart // what SystemPrompt is made of, in order
 	ToolDefs     []provider.ToolDef
+	Params       provider.ChatOptions // generation parameters from the agent config
 	Registry     *tool.Registry
 	mcpClients   []*mcp.Client
+	mcpTools     map[string]bool
+}
+
+// PromptPart is one contributor to the system prompt: the agent's own
+// system_prompt, an eager skill or the list of lazy skills.
+type PromptPart struct {
+	Name string
+	Size int // bytes
 }
 
 func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
@@ -29,10 +43,26 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 		Conf:         conf,
 		CurrentModel: conf.DefaultModel,
 		Registry:     reg,
+		mcpTools:     make(map[string]bool),
+	}
+	a.Params = provider.ChatOptions{
+		Temperature:    conf.Params.Temperature,
+		TopP:           conf.Params.TopP,
+		MaxTokens:      conf.Params.MaxTokens,
+		Stop:           conf.Params.Stop,
+		ThinkingBudget: conf.Params.ThinkingBudget,
+		NativeTools:    conf.NativeTools,
 	}
 
 	var sb strings.Builder
 	sb.WriteString(conf.SystemPrompt)
+	if conf.SystemPrompt != "" {
+		a.PromptParts = append(a.PromptParts, PromptPart{"system_prompt", len(conf.SystemPrompt)})
+	}
+	threshold := conf.LazyThreshold
+	if threshold <= 0 {
+		threshold = defaultLazyThreshold
+	}
 
 	// load all skills, split into eager/lazy
 	type loadedSkill struct {
@@ -51,10 +81,12 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 			return nil, fmt.Errorf("agent %s: %w", conf.Name, err)
 		}
 
-		if len(s.Prompt) < lazyThreshold {
+		if len(s.Prompt) < threshold && !slices.Contains(conf.LazySkills, sName) && !slices.Contains(conf.LazySkills, s.Name) {
 			// eager: inject full content
+			n := sb.Len()
 			sb.WriteString("\n\n## Skill: " + s.Name + "\n")
 			sb.WriteString(s.Prompt)
+			a.PromptParts = append(a.PromptParts, PromptPart{"skill " + s.Name, sb.Len() - n})
 		} else {
 			// lazy: inject name + first line only
 			lazySkills = append(lazySkills, loadedSkill{s: s, dir: dir})
@@ -66,6 +98,7 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 
 	// add lazy skill summaries + register load_skills tool
 	if len(lazySkills) > 0 {
+		n := sb.Len()
 		sb.WriteString("\n\n## Available Skills (use load_skills tool to read full documentation before using these skills)\n")
 		skillMap := make(map[string]*skill.Skill)
 		for _, ls := range lazySkills {
@@ -81,8 +114,9 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 			sb.WriteString(fmt.Sprintf("- %s: %s [requires load_skills to view full documentation]\n", name, desc))
 			skillMap[ls.s.Name] = ls.s
 		}
+		a.PromptParts = append(a.PromptParts, PromptPart{"lazy skill list", sb.Len() - n})
 
-		reg.Register(provider.ToolDef{
+		reg.RegisterReadOnly(provider.ToolDef{
 			Name:        "load_skills",
 			Description: "Load full SKILL.md documentation for one or more skills. Use this when you need detailed instructions for a skill.",
 			Parameters: map[string]any{
@@ -125,9 +159,21 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 		a.ToolDefs = append(a.ToolDefs, reg.GetDefs([]string{"load_skills"})...)
 	}
 
+	// agent-level custom tools are always enabled
+	for _, ct := range conf.CustomTools {
+		if err := reg.RegisterCustom(ct); err != nil {
+			return nil, fmt.Errorf("agent %s: %w", conf.Name, err)
+		}
+		a.ToolDefs = append(a.ToolDefs, reg.GetDefs([]string{ct.Name})...)
+	}
+
 	// MCP servers (best-effort: skip unavailable servers)
 	for mcpName, mcpConf := range conf.MCPs {
-		client := mcp.NewClient(mcpConf)
+		client, err := mcp.NewClient(mcpConf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ mcp %s: %v (skipped)\n", mcpName, err)
+			continue
+		}
 		if err := client.Initialize(); err != nil {
 			fmt.Fprintf(os.Stderr, "⚠ mcp %s: %v (skipped)\n", mcpName, err)
 			continue
@@ -142,10 +188,13 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 			t.Name = fmt.Sprintf("mcp_%s_%s", mcpName, origName)
 			cl := client // capture
 			on := origName
-			reg.Register(t, func(_ context.Context, args map[string]any) (string, error) {
+			reg.RegisterWithOptions(t, func(_ context.Context, args map[string]any) (string, error) {
 				return cl.CallTool(on, args)
-			})
+			}, tool.ToolOptions{Timeout: time.Duration(mcpConf.Timeout) * time.Second})
+			reg.SetConflictGroup(t.Name, "mcp:"+mcpName)
+			reg.SetUntrusted(t.Name)
 			a.ToolDefs = append(a.ToolDefs, t)
+			a.mcpTools[t.Name] = true
 		}
 		a.mcpClients = append(a.mcpClients, client)
 	}
@@ -153,6 +202,11 @@ func Build(conf *config.AgentConf, reg *tool.Registry) (*Agent, error) {
 	return a, nil
 }
 
+// IsMCPTool reports whether name was registered from an MCP server.
+func (a *Agent) IsMCPTool(name string) bool {
+	return a.mcpTools[name]
+}
+
 func (a *Agent) Close() {
 	// MCP clients are HTTP-based, no cleanup needed for now
 	a.mcpClients = nil
@@ -5,40 +5,351 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/gal-cli/gal-cli/internal/keychain"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	DefaultAgent string                  `yaml:"default_agent"`
-	ContextLimit int                     `yaml:"context_limit"`
-	Timeout      int                     `yaml:"timeout"`      // HTTP timeout in seconds, default 1800
-	Retries      int                     `yaml:"retries"`      // retry count on 429/5xx, default 1
-	Providers    map[string]ProviderConf `yaml:"providers"`
+	DefaultAgent        string                  `yaml:"default_agent"`
+	ContextLimit        int                     `yaml:"context_limit"`
+	Compress            CompressConf            `yaml:",inline"`
+	Compression         CompressionConf         `yaml:"compression"`
+	ClearKeepSummary    bool                    `yaml:"clear_keep_summary"`     // /clear carries a summary into the fresh context; /clear --no-summary overrides
+	Timeout             int                     `yaml:"timeout"`                // seconds to wait for a provider's response to start, default 1800; streams then run as long as data flows
+	Retries             int                     `yaml:"retries"`                // retry count on 429/5xx, default 1
+	ToolParallelism     int                     `yaml:"tool_parallelism"`       // max concurrent tool groups per round, default 4
+	MaxToolResultTokens int                     `yaml:"max_tool_result_tokens"` // longer tool results are cut and saved to a temp file, default 4000; -1 = never
+	BackgroundTasks     int                     `yaml:"background_tasks"`       // max concurrent /bg turns, default 2
+	SubagentDepth       int                     `yaml:"subagent_depth"`         // how deep spawn_agent calls may nest, default 2
+	InjectionGuard      bool                    `yaml:"injection_guard"`        // wrap and scan web/MCP tool results for all agents
+	RequireCleanGit     bool                    `yaml:"require_clean_git"`      // refuse file writes in git repositories with uncommitted changes, for all agents
+	AutoStash           bool                    `yaml:"auto_stash"`             // checkpoint such repositories instead, for all agents
+	MetricsPort         int                     `yaml:"metrics_port"`           // serve Prometheus metrics on this port, 0 = off
+	DebugDir            string                  `yaml:"debug_dir"`              // where --debug writes its logs, default <state dir>/logs
+	DebugKeep           int                     `yaml:"debug_keep"`             // debug logs kept in debug_dir, the oldest removed first; default 20, -1 keeps all
+	CollapseReplays     *bool                   `yaml:"collapse_replays"`       // drop an answer's ending when it comes twice in a row (a gateway replaying the stream), default true
+	SessionMaxAge       *int                    `yaml:"session_max_age"`        // days a session is kept after its last use, default 7; 0 keeps them all
+	Providers           map[string]ProviderConf `yaml:"providers"`
+	Shell               ShellConf               `yaml:"shell"`
+	Browser             BrowserConf             `yaml:"browser"`
+	Search              SearchConf              `yaml:"search"` // the web_search tool's backend; without a provider there is no web_search
+	Tools               ToolsConf               `yaml:"tools"`
+	UI                  UIConf                  `yaml:"ui"`
+	CustomTools         []CustomToolConf        `yaml:"custom_tools"` // enabled per agent by listing them in tools
+	Pricing             map[string]ModelPrice   `yaml:"pricing"`      // by "provider/model" or model name; overrides the built-in prices
+}
+
+// ModelPrice is what a model costs in US dollars per million tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+	Cached float64 `yaml:"cached"` // prompt tokens read from the cache; 0 = input price
+}
+
+// CompressConf customizes context compression. In gal.yaml it sets the
+// defaults; an agent's non-empty fields override them.
+type CompressConf struct {
+	Prompt   string `yaml:"compress_prompt"`   // summarizer system prompt, or @file
+	Language string `yaml:"compress_language"` // e.g. "English"; default is the conversation's language; ignored with compress_prompt
+	Header   string `yaml:"compress_header"`   // first line of the injected summary
+	Model    string `yaml:"compression_model"` // "provider/model" that writes the summaries, e.g. openai/gpt-4o-mini; default the conversation's model
+}
+
+// Merge returns c with the non-empty fields of o applied on top.
+func (c CompressConf) Merge(o CompressConf) CompressConf {
+	if o.Prompt != "" {
+		c.Prompt = o.Prompt
+	}
+	if o.Language != "" {
+		c.Language = o.Language
+	}
+	if o.Header != "" {
+		c.Header = o.Header
+	}
+	if o.Model != "" {
+		c.Model = o.Model
+	}
+	return c
+}
+
+// CompressionConf sets when context compression runs and how much of the
+// conversation it keeps. In gal.yaml it sets the defaults; an agent's set
+// fields override them.
+type CompressionConf struct {
+	Enabled          *bool   `yaml:"enabled"`            // default true; false never summarizes mid-chat
+	TriggerRatio     float64 `yaml:"trigger_ratio"`      // compress when the context passes this share of the limit, default 1.0
+	TargetRatio      float64 `yaml:"target_ratio"`       // recent messages kept as they are, as a share of the limit, default 0.2
+	KeepLastMessages int     `yaml:"keep_last_messages"` // most recent exchanges never summarized, however large, default 0
+}
+
+// Merge returns c with the set fields of o applied on top.
+func (c CompressionConf) Merge(o CompressionConf) CompressionConf {
+	if o.Enabled != nil {
+		c.Enabled = o.Enabled
+	}
+	if o.TriggerRatio != 0 {
+		c.TriggerRatio = o.TriggerRatio
+	}
+	if o.TargetRatio != 0 {
+		c.TargetRatio = o.TargetRatio
+	}
+	if o.KeepLastMessages != 0 {
+		c.KeepLastMessages = o.KeepLastMessages
+	}
+	return c
+}
+
+// Check reports settings that can't work: ratios outside (0, 1], a target
+// that isn't below the trigger, or a negative count.
+func (c CompressionConf) Check() error {
+	trigger, target := c.TriggerRatio, c.TargetRatio
+	if trigger == 0 {
+		trigger = 1
+	}
+	if target == 0 {
+		target = 0.2
+	}
+	switch {
+	case trigger < 0 || trigger > 1:
+		return fmt.Errorf("compression.trigger_ratio must be between 0 and 1, got %g", c.TriggerRatio)
+	case target < 0 || target > 1:
+		return fmt.Errorf("compression.target_ratio must be between 0 and 1, got %g", c.TargetRatio)
+	case target >= trigger:
+		return fmt.Errorf("compression.target_ratio (%g) must be below trigger_ratio (%g), or compression never frees anything", target, trigger)
+	case c.KeepLastMessages < 0:
+		return fmt.Errorf("compression.keep_last_messages must not be negative, got %d", c.KeepLastMessages)
+	}
+	return nil
+}
+
+// ReadPrompt resolves a prompt setting: "@path" reads the file (relative
+// paths are under the config directory), anything else is used as is.
+func ReadPrompt(v string) (string, error) {
+	if !strings.HasPrefix(v, "@") {
+		return v, nil
+	}
+	path := v[1:]
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(GalDir(), path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read prompt: %w", err)
+	}
+	return string(b), nil
+}
+
+type UIConf struct {
+	MaxCompletions int    `yaml:"max_completions"` // Tab completion candidates shown, default 5
+	MaxWidth       int    `yaml:"max_width"`       // markdown wrap width cap, default 100
+	TurnSummary    *bool  `yaml:"turn_summary"`    // timing/rounds/tools line after each reply, default true
+	ReplayTurns    int    `yaml:"replay_turns"`    // exchanges shown when resuming a session, default 3; -1 = none
+	Speak          string `yaml:"speak"`           // command that reads replies aloud from stdin, e.g. "say"; off by default
+	ShellLines     int    `yaml:"shell_lines"`     // shell-mode output lines shown, default 200; the rest goes to a temp file
+	Language       string `yaml:"language"`        // en or zh; default from $LC_ALL/$LC_MESSAGES/$LANG, else en
+}
+
+// SpeakCommand returns the speech command, or "" when ui.speak is off.
+func (u UIConf) SpeakCommand() string {
+	switch c := strings.TrimSpace(u.Speak); strings.ToLower(c) {
+	case "", "off", "false", "no":
+		return ""
+	default:
+		return c
+	}
+}
+
+// defaultSessionMaxAge is session_max_age's default, in days.
+const defaultSessionMaxAge = 7
+
+// SessionRetention returns how long sessions are kept after their last use,
+// or 0 if they are kept until removed.
+func (c *Config) SessionRetention() time.Duration {
+	days := defaultSessionMaxAge
+	if c.SessionMaxAge != nil {
+		days = max(*c.SessionMaxAge, 0)
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ShowTurnSummary reports whether the per-turn summary line is enabled.
+func (u UIConf) ShowTurnSummary() bool {
+	return u.TurnSummary == nil || *u.TurnSummary
+}
+
+// ToolsConf holds tool settings shared by all agents.
+type ToolsConf struct {
+	// Concurrency caps simultaneous calls by tool name, or by "mcp:<server>"
+	// for all tools of an MCP server; -1 = unlimited. Default 3 for http,
+	// web_search, fetch, browser and MCP tools, unlimited for the rest.
+	Concurrency map[string]int `yaml:"concurrency"`
+	// Approve lists tools that change things but run without asking the
+	// user first; "*" is all of them. Read-only tools never ask.
+	Approve []string `yaml:"approve"`
+	// ResultTokens overrides max_tool_result_tokens by tool name; -1 = never
+	// cut. grep and log_read limit their own output and aren't cut.
+	ResultTokens map[string]int `yaml:"result_tokens"`
+	// Timeouts bounds calls by tool name, in seconds; -1 = never. Default
+	// 30 for bash, a custom tool's own timeout, an MCP server's timeout, and
+	// 120 for the rest.
+	Timeouts map[string]int `yaml:"timeouts"`
+}
+
+type BrowserConf struct {
+	IdleTimeout int `yaml:"idle_timeout"` // seconds without calls before the browser is closed, default 600; -1 keeps it open
+}
+
+// SearchConf is the search engine web_search queries.
+type SearchConf struct {
+	Provider string `yaml:"provider"` // brave, serpapi or searxng
+	APIKey   string `yaml:"api_key"`  // brave and serpapi
+	Endpoint string `yaml:"endpoint"` // the API's URL; required for searxng (the instance), the others have their public one
+}
+
+type ShellConf struct {
+	Program       string `yaml:"program"`        // bash, sh, zsh, powershell, pwsh or cmd; default bash (powershell on Windows)
+	InteractiveRC bool   `yaml:"interactive_rc"` // shell mode: source ~/.bashrc and ~/.bash_aliases for every command
 }
 
 type ProviderConf struct {
-	Type    string   `yaml:"type"`     // "openai" (default) or "anthropic"
-	APIKey  string   `yaml:"api_key"`
-	BaseURL string   `yaml:"base_url"`
-	Models  []string `yaml:"models"`   // available models for this provider
+	Type         string            `yaml:"type"` // "openai" (default), "azure", "anthropic", "ollama" or "mock"
+	APIKey       string            `yaml:"api_key"`
+	BaseURL      string            `yaml:"base_url"`
+	Models       []ModelConf       `yaml:"models"`         // available models for this provider
+	Script       string            `yaml:"script"`         // reply script for type "mock"
+	KeepAlive    string            `yaml:"keep_alive"`     // type "ollama": how long the model stays loaded, e.g. "30m"
+	Options      map[string]any    `yaml:"options"`        // type "ollama": model options such as num_ctx
+	APIVersion   string            `yaml:"api_version"`    // type "azure": api-version query parameter, default 2024-06-01
+	Deployment   string            `yaml:"deployment"`     // type "azure": fixed deployment; default is the model name
+	Stream       *bool             `yaml:"stream"`         // OpenAI-compatible and "anthropic": false for one non-streaming request per round, default true
+	StreamUsage  *bool             `yaml:"stream_usage"`   // OpenAI-compatible: request token usage in the stream, default true
+	MaxTokens    int               `yaml:"max_tokens"`     // type "anthropic": output limit unless the agent sets params.max_tokens; default by model
+	PromptCache  bool              `yaml:"prompt_cache"`   // type "anthropic": cache the system prompt and tool definitions
+	MaxTools     int               `yaml:"max_tools"`      // tool definitions per request, default 128
+	MaxToolBytes int               `yaml:"max_tool_bytes"` // serialized tool definitions per request, default 100KB
+	Headers      map[string]string `yaml:"headers"`        // extra request headers, ${ENV} expanded; they win over the defaults except Content-Type
+	// ModelParams overrides request body fields per model ID or pattern
+	// ("o3*"); a null value leaves the field out.
+	ModelParams map[string]map[string]any `yaml:"model_params"`
+	// MaxStreamLine is the longest line of a streamed response in bytes,
+	// default 16MB; gateways that send a whole response as one event may
+	// need more.
+	MaxStreamLine int `yaml:"max_stream_line"`
+}
+
+// ModelConf is a provider model entry: either a plain name or an object
+// with a context window override (`{name: llama3, context: 8192}`).
+type ModelConf struct {
+	Name         string       `yaml:"name"`
+	Context      int          `yaml:"context"`      // context window in tokens; default from the built-in table
+	Capabilities Capabilities `yaml:"capabilities"` // unset fields default from the built-in table
+}
+
+// Capabilities override what a model supports, e.g.
+// `capabilities: {tools: false}` for a model without function calling.
+type Capabilities struct {
+	Tools     *bool `yaml:"tools"`     // function calling; without it tools are left out of requests
+	Vision    *bool `yaml:"vision"`    // image input
+	Reasoning *bool `yaml:"reasoning"` // streams its thinking
+}
+
+func (m *ModelConf) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&m.Name)
+	}
+	type plain ModelConf
+	return value.Decode((*plain)(m))
 }
 
 type MCPConf struct {
 	URL     string            `yaml:"url"`
 	Headers map[string]string `yaml:"headers"`
 	Timeout int               `yaml:"timeout"` // seconds, default 30
+	Auth    MCPAuth           `yaml:"auth"`    // per-request authentication on top of headers
+}
+
+// MCPAuth is how requests to an MCP server are authenticated: "header" sets
+// one header to a fixed value, "hmac" signs every request body.
+type MCPAuth struct {
+	Type            string `yaml:"type"`             // "header" or "hmac"; "" = none
+	Header          string `yaml:"header"`           // header to set; for hmac default X-Signature
+	Value           string `yaml:"value"`            // type header: the header value
+	Secret          string `yaml:"secret"`           // type hmac: signing key
+	Algo            string `yaml:"algo"`             // type hmac: sha256 (default), sha512 or sha1
+	TimestampHeader string `yaml:"timestamp_header"` // type hmac: header carrying the signed timestamp, default X-Timestamp
+}
+
+// String describes the auth without its secret or header value, so printing
+// an agent config doesn't leak them.
+func (a MCPAuth) String() string {
+	switch a.Type {
+	case "":
+		return "none"
+	case "hmac":
+		return "hmac(secret ********)"
+	}
+	return fmt.Sprintf("%s(%s: ********)", a.Type, a.Header)
 }
 
 type AgentConf struct {
-	Name         string   `yaml:"name"`
-	Description  string   `yaml:"description"`
-	SystemPrompt string   `yaml:"system_prompt"`
-	Models       []string `yaml:"models"`
-	DefaultModel string   `yaml:"default_model"`
-	Tools        []string `yaml:"tools"`
-	Skills       []string              `yaml:"skills"`
-	MCPs         MCPMap                `yaml:"mcps"`
+	Name            string           `yaml:"name"`
+	Description     string           `yaml:"description"`
+	SystemPrompt    string           `yaml:"system_prompt"`
+	Models          []string         `yaml:"models"`
+	DefaultModel    string           `yaml:"default_model"`
+	Tools           []string         `yaml:"tools"`
+	Skills          []string         `yaml:"skills"`
+	LazySkills      []string         `yaml:"lazy_skills"`    // skills only listed in the prompt and loaded on demand, whatever their size
+	LazyThreshold   int              `yaml:"lazy_threshold"` // skill size in bytes from which skills are lazy, default 1024
+	MCPs            MCPMap           `yaml:"mcps"`
+	TrimTools       bool             `yaml:"trim_tools"`        // drop least-recently-used MCP tools when over provider limits
+	InjectionGuard  bool             `yaml:"injection_guard"`   // wrap and scan web/MCP tool results
+	RequireCleanGit bool             `yaml:"require_clean_git"` // refuse file writes in git repositories with uncommitted changes until they're committed or checkpointed
+	AutoStash       bool             `yaml:"auto_stash"`        // checkpoint a dirty repository before the turn's first file write instead (implies the check)
+	Propose         bool             `yaml:"propose"`           // stage file writes for review at the end of each turn instead of writing them (/propose)
+	ToolChoice      string           `yaml:"tool_choice"`       // auto (default), none, required or a tool name; forced only in a turn's first round
+	AutoContinue    bool             `yaml:"auto_continue"`     // ask for the rest of answers cut off by max_tokens
+	KeepPartial     bool             `yaml:"keep_partial"`      // keep an answer cut off by a stream error instead of rolling the turn back
+	StrictToolArgs  bool             `yaml:"strict_tool_args"`  // also refuse tool calls with fields their schema doesn't declare
+	MaxRounds       int              `yaml:"max_rounds"`        // model requests per turn before it stops with a progress report, default 50
+	Subagents       []string         `yaml:"subagents"`         // agents this one may delegate tasks to with spawn_agent
+	NativeTools     []string         `yaml:"native_tools"`      // tools the provider runs itself, e.g. anthropic:web_search; entries for other providers' APIs are skipped
+	Auto            AutoConf         `yaml:"auto"`              // autonomous runs (/auto, --auto)
+	CustomTools     []CustomToolConf `yaml:"custom_tools"`      // always available to this agent
+	Compress        CompressConf     `yaml:",inline"`           // overrides the gal.yaml compression settings
+	Compression     CompressionConf  `yaml:"compression"`       // overrides gal.yaml's compression block
+	Params          Params           `yaml:"params"`            // generation parameters; zero values use the API default
+}
+
+// AutoConf limits autonomous runs, which work without asking the user.
+type AutoConf struct {
+	Approve   []string `yaml:"approve"`    // tools that run without confirmation; default: the read-only built-ins
+	MaxRounds int      `yaml:"max_rounds"` // model requests per run, default 200
+	MaxTokens int      `yaml:"max_tokens"` // prompt and completion tokens per run, default 2M
+}
+
+// Params are generation parameters sent with every request of an agent.
+type Params struct {
+	Temperature    float64  `yaml:"temperature"`
+	TopP           float64  `yaml:"top_p"`
+	MaxTokens      int      `yaml:"max_tokens"`
+	Stop           []string `yaml:"stop"`
+	ThinkingBudget int      `yaml:"thinking_budget"` // Anthropic extended thinking budget in tokens (at least 1024); 0 = off
+}
+
+// CustomToolConf declares an external command as a tool.
+type CustomToolConf struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Parameters  map[string]any `yaml:"parameters"` // JSON schema, must be type: object
+	Command     []string       `yaml:"command"`    // argv; elements are templates when args is "argv"
+	Args        string         `yaml:"args"`       // "stdin" (JSON on stdin, default) or "argv"
+	Dir         string         `yaml:"dir"`        // working directory, default current
+	Timeout     int            `yaml:"timeout"`    // seconds, default 30
+	MaxOutput   int            `yaml:"max_output"` // bytes of output returned, default 64KB
+	ReadOnly    bool           `yaml:"readonly"`   // no side effects: may run alongside other tools
 }
 
 // MCPMap is a map that tolerates being set to an empty YAML sequence ([]).
@@ -55,17 +366,12 @@ func (m *MCPMap) UnmarshalYAML(unmarshal func(any) error) error {
 	return nil
 }
 
-func GalDir() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".gal")
-}
-
 func Load() (*Config, error) {
 	data, err := os.ReadFile(filepath.Join(GalDir(), "gal.yaml"))
 	if err != nil {
 		return nil, fmt.Errorf("load config: %w", err)
 	}
-	data = []byte(os.ExpandEnv(string(data)))
+	data = []byte(expandVars(string(data)))
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
@@ -79,16 +385,62 @@ func Load() (*Config, error) {
 	if cfg.Retries < 0 {
 		cfg.Retries = 1
 	}
+	if cfg.ToolParallelism <= 0 {
+		cfg.ToolParallelism = 4
+	}
+	if cfg.BackgroundTasks <= 0 {
+		cfg.BackgroundTasks = 2
+	}
+	if cfg.SubagentDepth <= 0 {
+		cfg.SubagentDepth = 2
+	}
+	if cfg.Browser.IdleTimeout == 0 {
+		cfg.Browser.IdleTimeout = 600
+	}
+	if cfg.UI.MaxCompletions <= 0 {
+		cfg.UI.MaxCompletions = 5
+	}
+	if cfg.UI.MaxWidth <= 0 {
+		cfg.UI.MaxWidth = 100
+	}
+	if cfg.UI.ShellLines <= 0 {
+		cfg.UI.ShellLines = 200
+	}
+	if cfg.UI.ReplayTurns == 0 {
+		cfg.UI.ReplayTurns = 3
+	}
 	return &cfg, nil
 }
 
+// expandVars replaces ${VAR} and $VAR in config files with the environment
+// variable, and ${keychain:NAME} with the secret stored in the system
+// keychain as NAME (see the keychain package). A secret that can't be read
+// expands to "", like an unset variable, and is reported once on stderr.
+func expandVars(s string) string {
+	return os.Expand(s, func(name string) string {
+		key, ok := strings.CutPrefix(name, "keychain:")
+		if !ok {
+			return os.Getenv(name)
+		}
+		secret, err := keychain.Get(key)
+		if err != nil && !keychainWarned[key] {
+			keychainWarned[key] = true
+			fmt.Fprintf(os.Stderr, "⚠ %v\n", err)
+		}
+		return secret
+	})
+}
+
+// keychainWarned holds the keychain secrets expandVars reported it can't read.
+var keychainWarned = map[string]bool{}
+
 func LoadAgent(name string) (*AgentConf, error) {
 	path := filepath.Join(GalDir(), "agents", name+".yaml")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("load agent %s: %w", name, err)
 	}
-	data = []byte(os.ExpandEnv(string(data)))
+	data = []byte(expandVars(string(data)))
 	var agent AgentConf
 	if err := yaml.Unmarshal(data, &agent); err != nil {
 		return nil, fmt.Errorf("parse agent %s: %w", name, err)
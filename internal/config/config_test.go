@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSessionRetention(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want time.Duration
+	}{
+		{"", 7 * 24 * time.Hour},
+		{"session_max_age: 30", 30 * 24 * time.Hour},
+		{"session_max_age: 0", 0},
+		{"session_max_age: -1", 0},
+	}
+	for _, tt := range tests {
+		var c Config
+		if err := yaml.Unmarshal([]byte(tt.yaml), &c); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.SessionRetention(); got != tt.want {
+			t.Errorf("%q: SessionRetention() = %s, want %s", tt.yaml, got, tt.want)
+		}
+	}
+}
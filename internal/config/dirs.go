@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// legacySessionDir is where sessions lived before the state dir existed.
+const legacySessionDir = "/tmp/gal-sessions"
+
+// homeDir returns the user's home directory, falling back to the passwd
+// entry when HOME is unset (common in containers and systemd services).
+func homeDir() string {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return home
+	}
+	if u, err := user.Current(); err == nil && u.HomeDir != "" && u.HomeDir != "/" {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// xdgConfigDir returns $XDG_CONFIG_HOME/gal when that directory exists.
+func xdgConfigDir() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		if home := homeDir(); home != "" {
+			base = filepath.Join(home, ".config")
+		}
+	}
+	if base == "" {
+		return ""
+	}
+	dir := filepath.Join(base, "gal")
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+	return ""
+}
+
+// GalDir returns the config directory: $GAL_CONFIG_DIR, then $XDG_CONFIG_HOME/gal
+// (or ~/.config/gal) if it exists, then the legacy ~/.gal. It returns "" when no
+// location can be determined; CheckDirs reports that case as an error.
+func GalDir() string {
+	if dir := os.Getenv("GAL_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := xdgConfigDir(); dir != "" {
+		return dir
+	}
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".gal")
+	}
+	return ""
+}
+
+// StateDir holds sessions and input history. With the XDG layout it is
+// $XDG_STATE_HOME/gal (or ~/.local/state/gal); with the legacy layout it is
+// the config dir itself.
+func StateDir() string {
+	if dir := os.Getenv("GAL_STATE_DIR"); dir != "" {
+		return dir
+	}
+	if os.Getenv("GAL_CONFIG_DIR") == "" && xdgConfigDir() != "" {
+		if base := os.Getenv("XDG_STATE_HOME"); base != "" {
+			return filepath.Join(base, "gal")
+		}
+		if home := homeDir(); home != "" {
+			return filepath.Join(home, ".local", "state", "gal")
+		}
+	}
+	return GalDir()
+}
+
+// CacheDir holds disposable data. With the XDG layout it is $XDG_CACHE_HOME/gal
+// (or ~/.cache/gal); with the legacy layout it is <config dir>/cache.
+func CacheDir() string {
+	if dir := os.Getenv("GAL_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	if os.Getenv("GAL_CONFIG_DIR") == "" && xdgConfigDir() != "" {
+		if base, err := os.UserCacheDir(); err == nil {
+			return filepath.Join(base, "gal")
+		}
+	}
+	if dir := GalDir(); dir != "" {
+		return filepath.Join(dir, "cache")
+	}
+	return ""
+}
+
+// LogDir holds the --debug logs unless debug_dir says otherwise:
+// <state dir>/logs.
+func LogDir() string {
+	if dir := StateDir(); dir != "" {
+		return filepath.Join(dir, "logs")
+	}
+	return ""
+}
+
+// CheckDirs returns an error naming the problem when no config location can
+// be determined, instead of letting every command fail on "/.gal".
+func CheckDirs() error {
+	if GalDir() == "" {
+		return errors.New("cannot determine config directory: HOME is not set and no home directory was found for the current user; set HOME, GAL_CONFIG_DIR or XDG_CONFIG_HOME")
+	}
+	return nil
+}
+
+// MigrationNote returns a one-time note about data left in old locations, or ""
+// if there is nothing to say or the note was already shown.
+func MigrationNote() string {
+	state := StateDir()
+	marker := filepath.Join(state, ".migration-noted")
+	if _, err := os.Stat(marker); err == nil {
+		return ""
+	}
+	var note string
+	if entries, err := os.ReadDir(legacySessionDir); err == nil && len(entries) > 0 {
+		note = fmt.Sprintf("sessions are now stored in %s; old sessions remain in %s", filepath.Join(state, "sessions"), legacySessionDir)
+	}
+	if home := homeDir(); home != "" && GalDir() != filepath.Join(home, ".gal") {
+		if _, err := os.Stat(filepath.Join(home, ".gal")); err == nil {
+			if note != "" {
+				note += "; "
+			}
+			note += fmt.Sprintf("config is read from %s, ~/.gal is no longer used (move agents/ and skills/ over)", GalDir())
+		}
+	}
+	if note != "" {
+		os.MkdirAll(state, 0755)
+		os.WriteFile(marker, nil, 0644)
+	}
+	return note
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationNote(t *testing.T) {
+	if entries, _ := os.ReadDir(legacySessionDir); len(entries) > 0 {
+		t.Skip("this machine has sessions in", legacySessionDir)
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GAL_CONFIG_DIR", "")
+	t.Setenv("GAL_STATE_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	marker := filepath.Join(StateDir(), ".migration-noted")
+
+	// nothing to say yet: no marker, so a later note still shows
+	if note := MigrationNote(); note != "" {
+		t.Fatalf("note %q with nothing to migrate", note)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the marker was written without a note")
+	}
+
+	// the XDG config dir appears while ~/.gal is still there
+	os.MkdirAll(filepath.Join(home, ".gal"), 0o755)
+	os.MkdirAll(filepath.Join(home, ".config", "gal"), 0o755)
+	marker = filepath.Join(StateDir(), ".migration-noted")
+	if note := MigrationNote(); !strings.Contains(note, "~/.gal is no longer used") {
+		t.Fatalf("note %q, want one about ~/.gal", note)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("no marker after the note: %v", err)
+	}
+	if note := MigrationNote(); note != "" {
+		t.Errorf("the note came twice: %q", note)
+	}
+	if want := filepath.Join(home, ".local", "state", "gal", "logs"); LogDir() != want {
+		t.Errorf("LogDir %s, want %s", LogDir(), want)
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Decision is the user's answer when a tool call needs approval.
+type Decision int
+
+const (
+	Deny      Decision = iota
+	Allow              // this call
+	AllowTool          // this call and the tool's later ones this session
+	AllowAll           // every call for the rest of the session
+)
+
+// Approver decides whether a call to a tool that isn't read-only may run,
+// usually by asking the user. An error ends the turn.
+type Approver func(name string, args map[string]any) (Decision, error)
+
+// approvals are the AllowTool and AllowAll decisions of a session.
+type approvals struct {
+	tools map[string]bool
+	all   bool
+}
+
+const deniedResult = "error: the user denied this operation (%s was not run). Don't retry it as is: ask the user what they want instead, or find another way that doesn't need it."
+
+// needsApproval reports whether a call to name waits for OnToolApproval:
+// read-only calls, tools in ApprovedTools and the ones the user allowed for
+// the session don't, nor does spawn_agent, whose agent asks for its own.
+func (e *Engine) needsApproval(name string, args map[string]any) bool {
+	switch {
+	case e.OnToolApproval == nil || e.auto != nil || e.allowed.all:
+		return false
+	case name == "interactive" || name == SpawnAgentTool || e.Agent.Registry.IsReadOnlyCall(name, args):
+		return false
+	case e.allowed.tools[name], slices.Contains(e.ApprovedTools, name), slices.Contains(e.ApprovedTools, "*"):
+		return false
+	}
+	return true
+}
+
+// approveToolCalls asks OnToolApproval about each call that needs it, one
+// at a time in call order, before any of them runs. Denied calls get
+// deniedResult in refused; calls refused already aren't asked about. An
+// error from OnToolApproval (the user cancelled) ends the turn.
+func (e *Engine) approveToolCalls(calls []provider.ToolCall, args []map[string]any, refused []string) error {
+	for i, tc := range calls {
+		name := tc.Function.Name
+		if refused[i] != "" || !e.needsApproval(name, args[i]) || e.stages(name) {
+			continue // staged calls are reviewed after the turn
+		}
+		d, err := e.OnToolApproval(name, args[i])
+		if err != nil {
+			return err
+		}
+		e.debugLog("APPROVAL: %s %s", name, d)
+		switch d {
+		case Deny:
+			refused[i] = fmt.Sprintf(deniedResult, name)
+		case AllowTool:
+			if e.allowed.tools == nil {
+				e.allowed.tools = make(map[string]bool)
+			}
+			e.allowed.tools[name] = true
+		case AllowAll:
+			e.allowed.all = true
+		}
+	}
+	return nil
+}
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allowed"
+	case AllowTool:
+		return "allowed for the session"
+	case AllowAll:
+		return "all tools allowed for the session"
+	}
+	return "denied"
+}
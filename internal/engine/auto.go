@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
+)
+
+// CheckpointTool is the tool the model reports progress with during an
+// autonomous run. The engine handles it; it is offered only during runs.
+const CheckpointTool = "checkpoint"
+
+// AutoStoppedNote closes the work an autonomous run kept when it stopped
+// before the model was done.
+const AutoStoppedNote = "[autonomous run stopped]"
+
+const (
+	defaultAutoRounds = 200
+	defaultAutoTokens = 2_000_000
+	maxWrapUpRounds   = 2 // the summary round, and one more if it calls tools anyway
+)
+
+// DefaultAutoApprove are the tools an autonomous run may use when the agent
+// doesn't list any: the ones that only read.
+var DefaultAutoApprove = []string{"file_read", "file_list", "glob", "grep", "log_read"}
+
+// AutoLimits bound an autonomous run besides its time budget.
+type AutoLimits struct {
+	Approve   []string // tools that run without confirmation; other calls are declined
+	MaxRounds int      // model requests in the whole run; 0 means defaultAutoRounds
+	MaxTokens int      // prompt and completion tokens in the whole run; 0 means defaultAutoTokens
+}
+
+func (l AutoLimits) withDefaults() AutoLimits {
+	if l.Approve == nil {
+		l.Approve = DefaultAutoApprove
+	}
+	if l.MaxRounds <= 0 {
+		l.MaxRounds = defaultAutoRounds
+	}
+	if l.MaxTokens <= 0 {
+		l.MaxTokens = defaultAutoTokens
+	}
+	return l
+}
+
+// autoState is the engine's view of the running autonomous run.
+type autoState struct {
+	run    *AutoRun
+	limits AutoLimits
+	wrapUp bool       // the budget is spent; only the summary is left
+	mu     sync.Mutex // checkpoints come from parallel tool calls
+}
+
+var (
+	errAutoRounds = errors.New("autonomous run reached its round limit")
+	errAutoTokens = errors.New("autonomous run reached its token limit")
+)
+
+const autoPrompt = `Work on this task on your own for up to %s (until %s):
+
+%s
+
+The user is away: don't ask questions or for confirmation, decide for yourself. These tools run without asking: %s. Calls of other tools are declined. After each meaningful step, call the %s tool with a short note of what is done and what is next, so the work can be picked up if time runs out. When the task is done, end with a summary of what you did and anything left to do.`
+
+const autoWrapUp = `Stop working: %s. Don't call any more tools. Summarize the state of the task for the user: what is done, what is in progress and what remains, with enough detail to pick it up later.`
+
+var checkpointDef = provider.ToolDef{
+	Name:        CheckpointTool,
+	Description: "Record progress during an autonomous run: what is done and what is next, in a sentence or two. The user reads these later.",
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"progress": map[string]any{"type": "string", "description": "What is done and what is next"},
+		},
+		"required": []string{"progress"},
+	},
+}
+
+// RunAuto works on task without the user for up to budget: the model runs
+// tools from the approved list, records checkpoints with OnCheckpoint, and
+// stops at the deadline or at the round or token limit, after which one more
+// round asks it to sum up. Cancelling ctx stops at once. The rounds that
+// finished stay in the conversation either way, and the run is appended to
+// AutoRuns.
+func (e *Engine) RunAuto(ctx context.Context, task string, budget time.Duration, onText func(string), onToolCall func(string), onToolResult func(string)) (*AutoRun, error) {
+	start := time.Now()
+	e.AutoRuns = append(e.AutoRuns, AutoRun{Task: task, Start: start, BudgetMs: budget.Milliseconds()})
+	run := &e.AutoRuns[len(e.AutoRuns)-1]
+	limits := e.AutoLimits.withDefaults()
+	e.auto = &autoState{run: run, limits: limits}
+	defer func() {
+		e.auto = nil
+		run.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	workCtx, cancel := context.WithDeadline(ctx, start.Add(budget))
+	defer cancel()
+	approved := strings.Join(e.AutoApproved(), ", ")
+	if approved == "" {
+		approved = "none"
+	}
+	msg := fmt.Sprintf(autoPrompt, budget, start.Add(budget).Format("15:04"), task, approved, CheckpointTool)
+	err := e.SendWithInteractive(workCtx, msg, onText, onToolCall, onToolResult, nil)
+
+	var reason string
+	switch {
+	case err == nil:
+		run.Stopped = "done"
+		run.Summary = e.lastAnswer()
+		return run, nil
+	case ctx.Err() != nil:
+		run.Stopped = "cancelled"
+	case workCtx.Err() != nil:
+		run.Stopped, reason = "time", fmt.Sprintf("the %s time budget ran out", budget)
+	case errors.Is(err, errAutoRounds):
+		run.Stopped, reason = "rounds", fmt.Sprintf("the limit of %d rounds was reached", limits.MaxRounds)
+	case errors.Is(err, errAutoTokens):
+		run.Stopped, reason = "tokens", fmt.Sprintf("the limit of %s tokens was reached", record.FormatTokens(limits.MaxTokens))
+	default:
+		run.Stopped = "error"
+	}
+	if reason == "" {
+		run.Summary = run.CheckpointSummary()
+		return run, err
+	}
+
+	e.debugLog("AUTO STOP: %s", reason)
+	e.auto.wrapUp = true
+	if err := e.SendWithInteractive(ctx, fmt.Sprintf(autoWrapUp, reason), onText, onToolCall, onToolResult, nil); err != nil {
+		run.Summary = run.CheckpointSummary()
+		return run, err
+	}
+	run.Summary = e.lastAnswer()
+	return run, nil
+}
+
+// AutoApproved returns the agent's tools an autonomous run may call.
+func (e *Engine) AutoApproved() []string {
+	approve := e.AutoLimits.withDefaults().Approve
+	var names []string
+	for _, d := range e.Agent.ToolDefs {
+		if slices.Contains(approve, d.Name) {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// AutoRunning reports whether an autonomous run is in progress.
+func (e *Engine) AutoRunning() bool {
+	return e.auto != nil
+}
+
+// autoLimit counts a round of the autonomous run, or returns why there may
+// be no more.
+func (e *Engine) autoLimit(round int) error {
+	a := e.auto
+	if a.wrapUp {
+		if round > maxWrapUpRounds {
+			return errors.New("autonomous run: no summary after it stopped")
+		}
+		return nil
+	}
+	if a.run.Rounds >= a.limits.MaxRounds {
+		return errAutoRounds
+	}
+	if a.run.Tokens >= a.limits.MaxTokens {
+		return errAutoTokens
+	}
+	a.run.Rounds++
+	return nil
+}
+
+// autoToolResult handles a tool call the autonomous run doesn't pass on to
+// the registry: checkpoints, questions for the user, tools that aren't
+// approved and anything after the run stopped.
+func (e *Engine) autoToolResult(tc provider.ToolCall) (string, bool) {
+	a := e.auto
+	name := tc.Function.Name
+	switch {
+	case a.wrapUp:
+		return "error: the run has stopped; write the summary instead of calling tools", true
+	case name == CheckpointTool:
+		var args struct {
+			Progress string `json:"progress"`
+		}
+		json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		text := strings.TrimSpace(args.Progress)
+		if text == "" {
+			return "error: progress is empty", true
+		}
+		c := Checkpoint{Time: time.Now(), Text: text}
+		a.mu.Lock()
+		a.run.Checkpoints = append(a.run.Checkpoints, c)
+		a.mu.Unlock()
+		e.debugLog("CHECKPOINT: %s", text)
+		if e.OnCheckpoint != nil {
+			e.OnCheckpoint(c)
+		}
+		return "checkpoint saved", true
+	case name == "interactive":
+		return "error: the user is away during this autonomous run; decide for yourself, or list the question under the remaining work", true
+	case !slices.Contains(a.limits.Approve, name):
+		return fmt.Sprintf("error: %s isn't approved for autonomous runs; don't retry it, list what it was for under the remaining work", name), true
+	}
+	return "", false
+}
+
+// lastAnswer returns the content of the last assistant message.
+func (e *Engine) lastAnswer() string {
+	for i := len(e.Messages) - 1; i >= 0; i-- {
+		if m := e.Messages[i]; m.Role == "assistant" && m.Content != "" {
+			return m.Content
+		}
+	}
+	return ""
+}
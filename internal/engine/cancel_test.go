@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/agent"
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// newMockEngine returns an engine whose model is the mock provider playing
+// replies, with the tools of reg, or none.
+func newMockEngine(t *testing.T, reg *tool.Registry, replies ...provider.MockReply) *Engine {
+	t.Helper()
+	if reg == nil {
+		reg = tool.NewRegistry()
+	}
+	a := &agent.Agent{
+		Conf:         &config.AgentConf{Name: "test"},
+		CurrentModel: "mock/m",
+		Registry:     reg,
+		ToolDefs:     reg.GetDefs(nil),
+	}
+	return New(a, &provider.Mock{Replies: replies})
+}
+
+// checkPairs fails t unless every tool call in msgs is answered right after
+// its message and every tool result answers such a call.
+func checkPairs(t *testing.T, msgs []provider.Message) {
+	t.Helper()
+	for i := 0; i < len(msgs); i++ {
+		m := msgs[i]
+		if m.Role == "tool" {
+			t.Errorf("message %d: result for %s without its call", i, m.ToolCallID)
+			continue
+		}
+		if m.Role != "assistant" || len(m.ToolCalls) == 0 {
+			continue
+		}
+		j := i + 1
+		for j < len(msgs) && msgs[j].Role == "tool" {
+			j++
+		}
+		if !toolResultsComplete(m.ToolCalls, msgs[i+1:j]) {
+			t.Errorf("message %d: %d tool calls with %d results", i, len(m.ToolCalls), j-i-1)
+		}
+		i = j - 1
+	}
+}
+
+// roles returns the roles of msgs, for comparing histories.
+func roles(msgs []provider.Message) []string {
+	var r []string
+	for _, m := range msgs {
+		r = append(r, m.Role)
+	}
+	return r
+}
+
+// blockingTool registers "slow", which reports on started when it runs and
+// returns only when its call is cancelled.
+func blockingTool(started chan<- struct{}) *tool.Registry {
+	reg := tool.NewRegistry()
+	reg.RegisterReadOnlyV2(provider.ToolDef{Name: "slow", Parameters: map[string]any{"type": "object"}}, func(ctx context.Context, _ map[string]any) (tool.ToolResult, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return tool.ToolResult{}, ctx.Err()
+	})
+	return reg
+}
+
+func TestCancelTurn(t *testing.T) {
+	slow := provider.MockToolCall{Name: "slow"}
+	tests := []struct {
+		name    string
+		replies []provider.MockReply
+		// cancel is when the turn is cancelled: in the first text of the
+		// answer, when the tools are announced, or once a tool runs
+		cancel string
+	}{
+		{"mid-stream", []provider.MockReply{{Content: "one two three"}}, "text"},
+		{"before tools", []provider.MockReply{{Content: "looking", ToolCalls: []provider.MockToolCall{slow, slow}}}, "tool call"},
+		{"mid-tool", []provider.MockReply{{ToolCalls: []provider.MockToolCall{slow}}}, "tool run"},
+		{"mid-stream after a tool round", []provider.MockReply{
+			{ToolCalls: []provider.MockToolCall{{Name: "quick"}}},
+			{Content: "the answer is"},
+		}, "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{}, 4)
+			reg := blockingTool(started)
+			reg.RegisterReadOnlyV2(provider.ToolDef{Name: "quick", Parameters: map[string]any{"type": "object"}}, func(context.Context, map[string]any) (tool.ToolResult, error) {
+				return tool.ToolResult{Text: "done"}, nil
+			})
+			// a finished turn with a tool exchange, which must survive
+			first := []provider.MockReply{{ToolCalls: []provider.MockToolCall{{Name: "quick"}}}, {Content: "first answer"}}
+			e := newMockEngine(t, reg, append(first, tt.replies...)...)
+			if err := e.SendWithInteractive(context.Background(), "first", nil, nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			before := append([]provider.Message(nil), e.Messages...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			onText := func(string) {
+				if tt.cancel == "text" {
+					cancel()
+				}
+			}
+			onToolCall := func(string) {
+				if tt.cancel == "tool call" {
+					cancel()
+				}
+			}
+			if tt.cancel == "tool run" {
+				go func() {
+					<-started
+					cancel()
+				}()
+			}
+			err := e.SendWithInteractive(ctx, "second", onText, onToolCall, nil, nil)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("cancelled turn returned %v, want context.Canceled", err)
+			}
+			if !reflect.DeepEqual(roles(e.Messages), roles(before)) {
+				t.Errorf("history after the cancel: %v, want it as before the turn: %v", roles(e.Messages), roles(before))
+			}
+			checkPairs(t, e.Messages)
+
+			// the next turn goes on from there
+			if err := e.SendWithInteractive(context.Background(), "third", nil, nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			checkPairs(t, e.Messages)
+			if last := e.Messages[len(e.Messages)-1]; last.Content != "echo: third" {
+				t.Errorf("last message %q, want the answer to the next turn", last.Content)
+			}
+		})
+	}
+}
+
+func TestCleanIncompleteToolCalls(t *testing.T) {
+	user := provider.Message{Role: "user", Content: "q"}
+	answer := provider.Message{Role: "assistant", Content: "a"}
+	calls := func(ids ...string) provider.Message {
+		m := provider.Message{Role: "assistant"}
+		for _, id := range ids {
+			m.ToolCalls = append(m.ToolCalls, provider.ToolCall{ID: id, Type: "function"})
+		}
+		return m
+	}
+	result := func(id string) provider.Message {
+		return provider.Message{Role: "tool", ToolCallID: id, Content: "r"}
+	}
+	tests := []struct {
+		name string
+		in   []provider.Message
+		keep int // messages kept from the start of in
+	}{
+		{"cancelled before any result", []provider.Message{user, calls("a", "b")}, 1},
+		{"cancelled mid-tool", []provider.Message{user, calls("a", "b"), result("a")}, 1},
+		{"cancelled after all results", []provider.Message{user, calls("a", "b"), result("b"), result("a")}, 4},
+		{"back-to-back rounds, the last complete", []provider.Message{user, calls("a"), result("a"), calls("b"), result("b")}, 5},
+		{"back-to-back rounds, the last incomplete", []provider.Message{user, calls("a"), result("a"), calls("b", "c"), result("c")}, 3},
+		{"results without their call", []provider.Message{user, answer, result("x")}, 2},
+		{"a finished turn", []provider.Message{user, calls("a"), result("a"), answer}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newMockEngine(t, nil)
+			e.Messages = append([]provider.Message(nil), tt.in...)
+			e.cleanIncompleteToolCalls()
+			if want := tt.in[:tt.keep]; !reflect.DeepEqual(e.Messages, want) {
+				t.Errorf("kept %v, want %v", roles(e.Messages), roles(want))
+			}
+		})
+	}
+}
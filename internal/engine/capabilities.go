@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Capabilities are what a model supports beyond plain chat.
+type Capabilities struct {
+	Tools     bool // function calling
+	Vision    bool // image input
+	Reasoning bool // streams its thinking
+}
+
+// ToolsOffNote is added to the system prompt of requests to a model without
+// tool support, so it doesn't imitate tool calls in its answer.
+const ToolsOffNote = `
+
+## Tools
+No tools are available with the current model. Answer directly from the conversation; do not write tool calls or function-call syntax.`
+
+var (
+	capsAll       = Capabilities{Tools: true, Vision: true, Reasoning: true}
+	capsTools     = Capabilities{Tools: true}
+	capsVision    = Capabilities{Tools: true, Vision: true}
+	capsReasoning = Capabilities{Tools: true, Reasoning: true}
+)
+
+// modelCapabilities lists known capabilities, matched like modelWindows.
+// Ollama tags (llama3:8b) are matched without the tag. Unknown models are
+// assumed to support tools, which most current ones do.
+var modelCapabilities = []struct {
+	pattern string
+	caps    Capabilities
+}{
+	{"claude-3-5*", capsVision},
+	{"claude-3-7*", capsAll},
+	{"claude-3-*", capsVision},
+	{"claude-*", capsAll},
+	{"gpt-4o*", capsVision},
+	{"gpt-4.1*", capsVision},
+	{"gpt-4-turbo*", capsVision},
+	{"gpt-5*", capsAll},
+	{"o1-mini*", Capabilities{Reasoning: true}},
+	{"o1-preview*", Capabilities{Reasoning: true}},
+	{"o1*", capsAll},
+	{"o3*", capsAll},
+	{"o4*", capsAll},
+	{"gemini-*", capsAll},
+	{"deepseek-reasoner*", capsReasoning},
+	{"deepseek-r1*", Capabilities{Reasoning: true}},
+	{"qwen3*", capsReasoning},
+	{"llama3.2-vision*", Capabilities{Vision: true}},
+	{"llama3.1*", capsTools},
+	{"llama3.2*", capsTools},
+	{"llama3.3*", capsTools},
+	{"llama4*", capsVision},
+	{"llama3-groq-tool-use*", capsTools},
+	{"llama3*", Capabilities{}},
+	{"llama2*", Capabilities{}},
+	{"codellama*", Capabilities{}},
+	{"gemma3*", Capabilities{Vision: true}},
+	{"gemma*", Capabilities{}},
+	{"phi3*", Capabilities{}},
+	{"llava*", Capabilities{Vision: true}},
+}
+
+// ModelCapabilities returns the built-in capabilities for a model ID.
+func ModelCapabilities(modelID string) Capabilities {
+	name := strings.ToLower(modelID)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name, _, _ = strings.Cut(name, ":")
+	for _, c := range modelCapabilities {
+		if ok, _ := path.Match(c.pattern, name); ok {
+			return c.caps
+		}
+	}
+	return capsTools
+}
+
+// Capabilities returns the current model's capabilities: the configured
+// ones if any, else the built-in table.
+func (e *Engine) Capabilities() Capabilities {
+	if c, ok := e.ModelCaps[e.Agent.CurrentModel]; ok {
+		return c
+	}
+	return ModelCapabilities(e.ModelID())
+}
+
+// ToolsOff reports whether the agent has tools the current model can't use.
+func (e *Engine) ToolsOff() bool {
+	return len(e.Agent.ToolDefs) > 0 && !e.Capabilities().Tools
+}
+
+// requestMessages returns the messages to send this round: the conversation,
+// with ToolsOffNote added to the system prompt when tools are off.
+func (e *Engine) requestMessages() []provider.Message {
+	if !e.ToolsOff() || len(e.Messages) == 0 || e.Messages[0].Role != "system" {
+		return e.Messages
+	}
+	msgs := slices.Clone(e.Messages)
+	msgs[0].Content += ToolsOffNote
+	return msgs
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// maxChangeImage caps the file content kept before and after a change. Larger
+// changes keep their diff only and can't be reverted.
+const maxChangeImage = 256 * 1024
+
+// maxChangeDiff and maxChangeArgs cap a change's diff and call arguments.
+const (
+	maxChangeDiff = 16 * 1024
+	maxChangeArgs = 2 * 1024
+)
+
+// toolChange is what a call to a tool that isn't read-only returned, until
+// recordChange adds it to Changes in call order.
+type toolChange struct {
+	res tool.ToolResult
+	err error
+}
+
+// recordChange adds a call to a tool that isn't read-only to Changes, a
+// call that changed several files once for each.
+func (e *Engine) recordChange(name, args string, res tool.ToolResult, err error) {
+	if len(res.Changes) > 0 {
+		for _, fc := range res.Changes {
+			e.recordChange(name, args, tool.ToolResult{Change: fc}, err)
+		}
+		return
+	}
+	c := Change{Seq: len(e.Changes) + 1, Time: time.Now(), Tool: name, Args: e.maskSensitive(args)}
+	if len(c.Args) > maxChangeArgs {
+		c.Args = tool.ClipUTF8(c.Args, maxChangeArgs) + "…"
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	if fc := res.Change; fc != nil {
+		c.Path, c.Created, c.Deleted, c.Mode = fc.Path, fc.Created, fc.Deleted, fc.Mode
+		if c.Mode == 0 {
+			if fi, err := os.Stat(fc.Path); err == nil {
+				c.Mode = fi.Mode().Perm()
+			}
+		}
+		if len(e.GitCheckpoints) > 0 {
+			if cp, ok := e.checkpointFor(gitTop(filepath.Dir(fc.Path))); ok {
+				c.Checkpoint = cp.Ref
+			}
+		}
+		switch {
+		case fc.Created:
+			c.Diff = "+ " + strings.ReplaceAll(strings.TrimSuffix(fc.After, "\n"), "\n", "\n+ ")
+		case fc.Deleted:
+			c.Diff = "- " + strings.ReplaceAll(strings.TrimSuffix(fc.Before, "\n"), "\n", "\n- ")
+		default:
+			c.Diff = tool.FormatDiff(fc.Before, fc.After)
+		}
+		if len(c.Diff) > maxChangeDiff {
+			c.Diff = tool.ClipUTF8(c.Diff, maxChangeDiff) + "\n … (diff cut)"
+		}
+		if len(fc.Before) <= maxChangeImage && len(fc.After) <= maxChangeImage {
+			c.Before, c.After = &fc.Before, &fc.After
+		}
+	}
+	e.Changes = append(e.Changes, c)
+	e.debugLog("CHANGE #%d: %s %s", c.Seq, name, c.Path)
+}
+
+// minSecretLen is the length below which AddSensitive ignores a value: a
+// placeholder key such as "none" or "ollama" would mask ordinary words.
+const minSecretLen = 8
+
+// AddSensitive adds secrets, such as the providers' API keys, to the values
+// masked in the debug log and recorded changes, as the values the user
+// enters in sensitive interactive fields are.
+func (e *Engine) AddSensitive(values ...string) {
+	for _, v := range values {
+		if len(v) >= minSecretLen && !slices.Contains(e.sensitiveValues, v) {
+			e.sensitiveValues = append(e.sensitiveValues, v)
+		}
+	}
+}
+
+// maskSensitive replaces the values the user entered in sensitive
+// interactive fields and those given to AddSensitive.
+func (e *Engine) maskSensitive(s string) string {
+	for _, sv := range e.sensitiveValues {
+		s = strings.ReplaceAll(s, sv, "********")
+	}
+	return s
+}
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+const (
+	defaultCompressPrompt = "Summarize the following conversation concisely, preserving key decisions, code changes, file paths, and technical details."
+	defaultCompressHeader = "[Compressed context from earlier conversation]"
+	defaultTriggerRatio   = 1.0 // compress once the context passes the limit
+	defaultTargetRatio    = 0.2 // and keep recent messages up to a fifth of it
+
+	// carriedHeader marks a summary seeded by ClearWithSummary, so a later
+	// clear keeps it as is instead of summarizing it again.
+	carriedHeader = "[Summary carried over from a cleared conversation]"
+)
+
+// archive hands msgs, about to be replaced with summary, to OnArchive.
+func (e *Engine) archive(msgs []provider.Message, summary string) {
+	if e.OnArchive != nil {
+		e.OnArchive(Archived{Time: time.Now(), Summary: summary, Messages: slices.Clone(msgs)})
+	}
+}
+
+// CompressSettings customizes when and how Compress summarizes old messages.
+// Empty fields fall back to the built-in defaults.
+type CompressSettings struct {
+	Prompt       string  // system prompt for the summarizer
+	Language     string  // language of the summary with the built-in Prompt, default same as the conversation
+	Header       string  // first line of the injected summary message
+	Disabled     bool    // never compress; the context may outgrow the limit
+	TriggerRatio float64 // share of the limit the context may reach before compression, default 1.0
+	TargetRatio  float64 // share of the limit the newest messages keep unsummarized, default 0.2
+	KeepLast     int     // most recent exchanges (a user message and what follows) never summarized
+
+	// Model ("provider/model") writes the summaries instead of the
+	// conversation's model, with Provider. If it couldn't be set up, ModelErr
+	// says why and the conversation's model is used.
+	Model    string
+	Provider provider.Provider
+	ModelErr error
+}
+
+func (c CompressSettings) triggerRatio() float64 {
+	if c.TriggerRatio <= 0 {
+		return defaultTriggerRatio
+	}
+	return c.TriggerRatio
+}
+
+func (c CompressSettings) targetRatio() float64 {
+	if c.TargetRatio <= 0 {
+		return defaultTargetRatio
+	}
+	return c.TargetRatio
+}
+
+// keepFrom returns the index in msgs of the first message of the KeepLast
+// most recent exchanges, or len(msgs) if none are kept.
+func (c CompressSettings) keepFrom(msgs []provider.Message) int {
+	n := c.KeepLast
+	for i := len(msgs) - 1; i >= 0 && n > 0; i-- {
+		if msgs[i].Role == "user" {
+			n--
+			if n == 0 {
+				return i
+			}
+		}
+	}
+	if n > 0 && c.KeepLast > 0 {
+		return 0 // fewer exchanges than that: keep them all
+	}
+	return len(msgs)
+}
+
+// systemPrompt returns the summarizer's instructions: a custom Prompt as it
+// is, or the built-in one with the summary's language.
+func (c CompressSettings) systemPrompt() string {
+	if p := strings.TrimSpace(c.Prompt); p != "" {
+		return p
+	}
+	p := defaultCompressPrompt
+	if c.Language != "" {
+		return p + " Write the summary in " + c.Language + "."
+	}
+	return p + " Output in the same language as the conversation."
+}
+
+func (c CompressSettings) header() string {
+	if c.Header == "" {
+		return defaultCompressHeader
+	}
+	return strings.TrimSpace(c.Header)
+}
+
+// summarize asks the model for a summary of msgs, in a request isolated from
+// the conversation. Its usage counts toward the totals but not toward the
+// context size.
+func (e *Engine) summarize(ctx context.Context, msgs []provider.Message) (string, error) {
+	// pack the messages as a single user message
+	var sb strings.Builder
+	for _, m := range msgs {
+		switch {
+		case m.Role == "system":
+			sb.WriteString("Earlier summary: " + m.Content + "\n\n")
+		case m.Role == "user":
+			sb.WriteString("User: " + m.Content + "\n\n")
+		case m.Role == "assistant" && m.Content != "":
+			sb.WriteString("Assistant: " + m.Content + "\n\n")
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			for _, tc := range m.ToolCalls {
+				sb.WriteString(fmt.Sprintf("Assistant called tool %s(%s)\n", tc.Function.Name, tc.Function.Arguments))
+			}
+			sb.WriteString("\n")
+		case m.Role == "tool":
+			preview := m.Content
+			if len(preview) > 500 {
+				preview = preview[:500] + "...(truncated)"
+			}
+			sb.WriteString("Tool result: " + preview + "\n\n")
+		}
+	}
+	request := []provider.Message{
+		{Role: "system", Content: e.Compression.systemPrompt()},
+		{Role: "user", Content: sb.String()},
+	}
+
+	model, p := e.Agent.CurrentModel, e.Provider
+	if c := e.Compression; c.Model != "" && c.Provider != nil {
+		model, p = c.Model, c.Provider
+	} else if c.ModelErr != nil {
+		e.debugLog("COMPRESS MODEL: %v; using %s", c.ModelErr, model)
+	}
+	start := time.Now()
+	summary, err := e.summarizeWith(ctx, p, model, request)
+	if err != nil && model != e.Agent.CurrentModel && ctx.Err() == nil {
+		e.debugLog("COMPRESS MODEL: %s failed (%v), retrying with %s", model, err, e.Agent.CurrentModel)
+		model, p, start = e.Agent.CurrentModel, e.Provider, time.Now()
+		summary, err = e.summarizeWith(ctx, p, model, request)
+	}
+	e.debugLog("COMPRESS MODEL: %s in %s", model, time.Since(start).Round(time.Millisecond))
+	return summary, err
+}
+
+// summarizeWith sends the summary request to model ("provider/model") and
+// counts its usage at that model's price.
+func (e *Engine) summarizeWith(ctx context.Context, p provider.Provider, model string, request []provider.Message) (string, error) {
+	var summary string
+	var usage *provider.Usage
+	_, id, _ := strings.Cut(model, "/")
+	err := p.ChatStream(ctx, id, request, nil, provider.ChatOptions{}, func(d provider.StreamDelta) {
+		summary += d.Content
+		if d.Usage != nil {
+			usage = d.Usage
+		}
+	})
+	if usage != nil {
+		e.Usage.Add(*usage)
+		e.recordTokens(model, *usage)
+		e.Cost.Add(e.usageCostOf(model, *usage))
+	}
+	return summary, err
+}
+
+func isCarried(m provider.Message) bool {
+	return m.Role == "system" && strings.HasPrefix(m.Content, carriedHeader)
+}
+
+// ClearWithSummary clears the conversation like Clear, but first summarizes
+// it and seeds the fresh context with the summary. Summaries carried over by
+// an earlier clear are kept verbatim rather than summarized again. It returns
+// the context size before and after.
+func (e *Engine) ClearWithSummary(ctx context.Context) (before, after int, err error) {
+	before = e.contextTokens()
+	var carried, rest []provider.Message
+	for _, m := range e.Messages[1:] {
+		if isCarried(m) {
+			carried = append(carried, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+
+	var summary string
+	if len(rest) > 0 {
+		e.debugLog("CLEAR WITH SUMMARY: summarizing %d msgs, keeping %d carried", len(rest), len(carried))
+		if summary, err = e.summarize(ctx, rest); err != nil {
+			e.debugLog("CLEAR WITH SUMMARY ERROR: %v", err)
+			return before, before, err
+		}
+	}
+
+	if len(rest) > 0 {
+		e.archive(rest, summary)
+	}
+	e.Clear()
+	e.Messages = append(e.Messages, carried...)
+	if summary = strings.TrimSpace(summary); summary != "" {
+		e.Messages = append(e.Messages, provider.Message{Role: "system", Content: carriedHeader + "\n" + summary})
+	}
+	return before, e.contextTokens(), nil
+}
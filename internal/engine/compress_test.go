@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// recorder passes requests on to a provider and keeps their messages.
+type recorder struct {
+	provider.Provider
+	requests [][]provider.Message
+}
+
+func (r *recorder) ChatStream(ctx context.Context, model string, messages []provider.Message, tools []provider.ToolDef, opts provider.ChatOptions, onDelta func(provider.StreamDelta)) error {
+	r.requests = append(r.requests, slices.Clone(messages))
+	return r.Provider.ChatStream(ctx, model, messages, tools, opts, onDelta)
+}
+
+func TestCompressMidTurn(t *testing.T) {
+	reg := tool.NewRegistry()
+	reg.RegisterReadOnlyV2(provider.ToolDef{Name: "quick", Parameters: map[string]any{"type": "object"}}, func(context.Context, map[string]any) (tool.ToolResult, error) {
+		return tool.ToolResult{Text: "done"}, nil
+	})
+	// each read fills most of the context on its own
+	reg.RegisterReadOnlyV2(provider.ToolDef{Name: "read", Parameters: map[string]any{"type": "object"}}, func(context.Context, map[string]any) (tool.ToolResult, error) {
+		return tool.ToolResult{Text: strings.Repeat("the file goes on and on ", 300)}, nil
+	})
+	read := provider.MockToolCall{Name: "read"}
+	e := newMockEngine(t, reg,
+		// a finished turn with a tool exchange, to be summarized
+		provider.MockReply{ToolCalls: []provider.MockToolCall{{Name: "quick"}}},
+		provider.MockReply{Content: "first answer"},
+		// a turn whose reads outgrow the context
+		provider.MockReply{ToolCalls: []provider.MockToolCall{read}},
+		provider.MockReply{ToolCalls: []provider.MockToolCall{read, read}},
+		provider.MockReply{ToolCalls: []provider.MockToolCall{read}},
+		provider.MockReply{Content: "all read"},
+	)
+	rec := &recorder{Provider: e.Provider}
+	e.Provider = rec
+	e.ContextLimit = 2000
+	e.Compression = CompressSettings{Model: "mock/s", Provider: &provider.Mock{Replies: []provider.MockReply{{Content: "they said hello"}}}}
+
+	if err := e.SendWithInteractive(context.Background(), "first", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if e.NeedsCompression() {
+		t.Fatalf("the first turn already needs compression: %d tokens", e.contextTokens())
+	}
+	var compressedAt []int // requests sent before each compression
+	e.OnCompressing = func(s string) {
+		if s != "" {
+			compressedAt = append(compressedAt, len(rec.requests))
+		}
+	}
+	if err := e.SendWithInteractive(context.Background(), "read them all", nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first turn's requests are 2, the second's 4
+	if len(rec.requests) != 6 {
+		t.Fatalf("%d requests, want 6", len(rec.requests))
+	}
+	if len(compressedAt) != 1 || compressedAt[0] <= 2 || compressedAt[0] >= 6 {
+		t.Fatalf("compressed after requests %v, want once between the rounds of the second turn", compressedAt)
+	}
+	for i, req := range rec.requests {
+		checkPairs(t, req)
+		if i >= compressedAt[0] && slices.ContainsFunc(req, func(m provider.Message) bool { return m.Content == "first" }) {
+			t.Errorf("request %d still has the summarized turn", i+1)
+		}
+	}
+
+	// the summary replaced the first turn; the second is whole
+	checkPairs(t, e.Messages)
+	want := []string{"system", "system", "user", "assistant", "tool", "assistant", "tool", "tool", "assistant", "tool", "assistant"}
+	if got := roles(e.Messages); !slices.Equal(got, want) {
+		t.Fatalf("history %v, want %v", got, want)
+	}
+	if s := e.Messages[1].Content; !strings.HasPrefix(s, defaultCompressHeader) || !strings.Contains(s, "they said hello") {
+		t.Errorf("summary message %q", s)
+	}
+	if u := e.Messages[2]; u.Content != "read them all" {
+		t.Errorf("the turn starts with %q, want its user message", u.Content)
+	}
+}
+
+func TestCompressSystemPrompt(t *testing.T) {
+	tests := []struct {
+		c    CompressSettings
+		want string
+	}{
+		{CompressSettings{}, defaultCompressPrompt + " Output in the same language as the conversation."},
+		{CompressSettings{Language: "English"}, defaultCompressPrompt + " Write the summary in English."},
+		// a custom prompt is the user's own words, language and all
+		{CompressSettings{Prompt: "Résume en français.\n"}, "Résume en français."},
+		{CompressSettings{Prompt: "List the decisions.", Language: "English"}, "List the decisions."},
+		{CompressSettings{Prompt: "  \n", Language: "English"}, defaultCompressPrompt + " Write the summary in English."},
+	}
+	for _, tt := range tests {
+		if got := tt.c.systemPrompt(); got != tt.want {
+			t.Errorf("%+v: systemPrompt() = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
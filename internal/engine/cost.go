@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Price is what a model costs in US dollars per million tokens. Cached is
+// the price of prompt tokens read from the cache; 0 means Input.
+type Price struct {
+	Input, Output, Cached float64
+}
+
+// defaultPrices are list prices of common models by model name. A name also
+// matches its dated and -latest versions, e.g. gpt-4o-2024-08-06.
+var defaultPrices = map[string]Price{
+	"gpt-4o":            {2.5, 10, 1.25},
+	"gpt-4o-mini":       {0.15, 0.6, 0.075},
+	"gpt-4.1":           {2, 8, 0.5},
+	"gpt-4.1-mini":      {0.4, 1.6, 0.1},
+	"gpt-4.1-nano":      {0.1, 0.4, 0.025},
+	"gpt-5":             {1.25, 10, 0.125},
+	"gpt-5-mini":        {0.25, 2, 0.025},
+	"gpt-5-nano":        {0.05, 0.4, 0.005},
+	"o3":                {2, 8, 0.5},
+	"o3-mini":           {1.1, 4.4, 0.55},
+	"o4-mini":           {1.1, 4.4, 0.275},
+	"claude-opus-4":     {15, 75, 1.5},
+	"claude-opus-4-5":   {5, 25, 0.5},
+	"claude-sonnet-4":   {3, 15, 0.3},
+	"claude-3-7-sonnet": {3, 15, 0.3},
+	"claude-3-5-sonnet": {3, 15, 0.3},
+	"claude-haiku-4-5":  {1, 5, 0.1},
+	"claude-3-5-haiku":  {0.8, 4, 0.08},
+	"deepseek-chat":     {0.27, 1.1, 0.07},
+	"deepseek-reasoner": {0.55, 2.19, 0.14},
+	"gemini-2.5-pro":    {1.25, 10, 0.31},
+	"gemini-2.5-flash":  {0.3, 2.5, 0.075},
+}
+
+// ModelPrice looks up the current model's price: Pricing by "provider/model",
+// then by model name, then the built-in prices.
+func (e *Engine) ModelPrice() (Price, bool) {
+	return e.priceOf(e.Agent.CurrentModel)
+}
+
+// priceOf looks up the price of a "provider/model" like ModelPrice.
+func (e *Engine) priceOf(full string) (Price, bool) {
+	if p, ok := e.Pricing[full]; ok {
+		return p, true
+	}
+	model := full
+	if i := strings.Index(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if p, ok := e.Pricing[model]; ok {
+		return p, true
+	}
+	// gateways name models like anthropic/claude-sonnet-4
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return defaultPrice(model)
+}
+
+// defaultPrice finds the longest built-in name that is model itself or a
+// prefix of it followed by a version (a digit or "latest").
+func defaultPrice(model string) (Price, bool) {
+	var best string
+	for name := range defaultPrices {
+		if len(name) <= len(best) {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(model, name); ok && (rest == "" || isVersionSuffix(rest)) {
+			best = name
+		}
+	}
+	p, ok := defaultPrices[best]
+	return p, ok
+}
+
+func isVersionSuffix(s string) bool {
+	rest, ok := strings.CutPrefix(s, "-")
+	return ok && rest != "" && (rest[0] >= '0' && rest[0] <= '9' || rest == "latest")
+}
+
+// usageCost prices a request's usage with the current model's price.
+func (e *Engine) usageCost(u provider.Usage) Cost {
+	return e.usageCostOf(e.Agent.CurrentModel, u)
+}
+
+// usageCostOf prices usage of the "provider/model" full.
+func (e *Engine) usageCostOf(full string, u provider.Usage) Cost {
+	p, ok := e.priceOf(full)
+	if !ok {
+		return Cost{UnpricedTokens: u.PromptTokens + u.CompletionTokens}
+	}
+	cached := p.Cached
+	if cached == 0 {
+		cached = p.Input
+	}
+	usd := float64(u.PromptTokens-u.CachedTokens)*p.Input + float64(u.CachedTokens)*cached + float64(u.CompletionTokens)*p.Output
+	return Cost{USD: usd / 1e6}
+}
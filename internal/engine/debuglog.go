@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// defaultDebugKeep is how many debug logs InitDebug leaves in DebugDir
+// unless DebugKeep says otherwise.
+const defaultDebugKeep = 20
+
+// debugSink is the debug log of an engine, shared with its forks and
+// subagents.
+type debugSink struct {
+	f     *os.File
+	jsonl bool
+	mu    sync.Mutex // one line at a time; provider and tool goroutines log too
+}
+
+// debugEntry is a line of a DebugFormat "jsonl" log. Kind sorts lines into
+// request, response, tool_call, tool_result, error and event; Label is the
+// line's tag in the text log, such as "TOOL_RESULT" or "COMPRESS DONE".
+type debugEntry struct {
+	TS      string `json:"ts"`
+	Tag     string `json:"tag,omitempty"` // the fork or subagent that logged it
+	Turn    int    `json:"turn"`
+	Round   int    `json:"round"`
+	Kind    string `json:"kind"`
+	Label   string `json:"label,omitempty"`
+	Detail  string `json:"detail,omitempty"` // the rest of a JSON payload's label, e.g. the tool of a TOOL_META
+	Payload any    `json:"payload"`          // the message, or the value debugJSON logs
+}
+
+// InitDebug opens a new debug log in DebugDir and wires the provider and the
+// tools to it. Older logs beyond DebugKeep are removed.
+func (e *Engine) InitDebug() {
+	if e.debugOut != nil {
+		return
+	}
+	dir := os.TempDir()
+	if e.DebugDir != "" {
+		d, err := tool.ResolvePath(e.DebugDir)
+		if err == nil {
+			err = os.MkdirAll(d, 0700)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "🐛 Debug log: debug_dir: %v\n", err)
+			return
+		}
+		dir = d
+	}
+	jsonl := e.DebugFormat == "jsonl"
+	ext := ".log"
+	if jsonl {
+		ext = ".jsonl"
+	}
+	name := filepath.Join(dir, "gal-debug-"+time.Now().Format("20060102-150405")+ext)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) // it holds the conversation
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "🐛 Debug log: %v\n", err)
+		return
+	}
+	e.debugOut = &debugSink{f: f, jsonl: jsonl}
+	fmt.Fprintf(os.Stderr, "🐛 Debug log: %s\n", name)
+	rotateDebugLogs(dir, e.DebugKeep)
+
+	// wire debug logger to provider
+	dbg := provider.DebugFunc(e.debugLog)
+	switch p := e.Provider.(type) {
+	case *provider.OpenAI:
+		p.Debug = dbg
+	case *provider.Anthropic:
+		p.Debug = dbg
+	case *provider.Ollama:
+		p.Debug = dbg
+	}
+	tool.SetDebug(dbg)
+}
+
+// rotateDebugLogs removes the oldest debug logs in dir until keep are left
+// (defaultDebugKeep if 0, all if negative). Their names sort by time.
+func rotateDebugLogs(dir string, keep int) {
+	if keep == 0 {
+		keep = defaultDebugKeep
+	}
+	if keep < 0 {
+		return
+	}
+	var logs []string
+	for _, ext := range []string{".log", ".jsonl"} {
+		m, _ := filepath.Glob(filepath.Join(dir, "gal-debug-*"+ext))
+		logs = append(logs, m...)
+	}
+	if len(logs) <= keep {
+		return
+	}
+	slices.SortFunc(logs, func(a, b string) int { return strings.Compare(filepath.Base(a), filepath.Base(b)) })
+	for _, old := range logs[:len(logs)-keep] {
+		os.Remove(old)
+	}
+}
+
+func (e *Engine) debugLog(format string, args ...any) {
+	if e.debugOut == nil {
+		return
+	}
+	msg := e.maskSensitive(fmt.Sprintf(format, args...))
+	if !e.debugOut.jsonl {
+		e.debugOut.write(fmt.Sprintf("[%s] %s%s\n", time.Now().Format("15:04:05.000"), e.logTag, msg))
+		return
+	}
+	label, rest := splitDebugLabel(msg)
+	e.debugOut.writeEntry(e.debugEntry(label, rest))
+}
+
+func (e *Engine) debugJSON(label string, v any) {
+	if e.debugOut == nil {
+		return
+	}
+	b, _ := json.Marshal(v)
+	if !e.debugOut.jsonl {
+		go e.debugLog("%s:\n%s", label, b)
+		return
+	}
+	l, detail := splitDebugLabel(label)
+	entry := e.debugEntry(l, "")
+	entry.Detail = detail
+	go func() {
+		entry.Payload = json.RawMessage(e.maskSensitive(string(b)))
+		e.debugOut.writeEntry(entry)
+	}()
+}
+
+// debugEntry makes the JSONL line of a message with the given label.
+func (e *Engine) debugEntry(label, payload string) debugEntry {
+	return debugEntry{
+		TS:      time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
+		Tag:     strings.TrimSpace(e.logTag),
+		Turn:    e.debugTurn,
+		Round:   e.debugRound,
+		Kind:    debugKind(label),
+		Label:   label,
+		Payload: payload,
+	}
+}
+
+// debugTurnRound is the "turn 3 / round 2" many messages repeat after their
+// label; JSONL lines have fields for it.
+var debugTurnRound = regexp.MustCompile(`^ turn \d+( / round \d+)?`)
+
+// splitDebugLabel splits a debug message such as "TOOL_RESULT: ..." or
+// "ERROR turn 3 / round 2: ..." into its upper-case label and the rest, or
+// returns "" for a message without one.
+func splitDebugLabel(msg string) (label, rest string) {
+	end := strings.IndexFunc(msg, func(r rune) bool { return !(r >= 'A' && r <= 'Z' || r == '_' || r == ' ') })
+	if end < 0 {
+		end = len(msg)
+	}
+	label = strings.TrimSpace(msg[:end])
+	if label == "" || end < len(msg) && msg[end] != ':' && msg[end-1] != ' ' {
+		return "", msg // not a label but the start of a word
+	}
+	rest = debugTurnRound.ReplaceAllString(msg[len(strings.TrimRight(msg[:end], " ")):], "")
+	rest = strings.TrimPrefix(rest, ":")
+	return label, strings.TrimLeft(rest, " \n")
+}
+
+// debugKind sorts a debug message by its label.
+func debugKind(label string) string {
+	switch {
+	case strings.Contains(label, "ERROR") || strings.HasPrefix(label, "INVALID") || label == "TOOL_PANIC" || label == "TOOL_TIMEOUT":
+		return "error"
+	case strings.HasPrefix(label, "REQUEST"):
+		return "request"
+	case strings.HasPrefix(label, "RESPONSE") || strings.HasPrefix(label, "STREAM") ||
+		label == "REASONING" || label == "STOP REASON" || label == "SSE RAW" || label == "USAGE":
+		return "response"
+	case label == "TOOL_CALL":
+		return "tool_call"
+	case label == "TOOL_RESULT" || label == "TOOL_META":
+		return "tool_result"
+	}
+	return "event"
+}
+
+func (s *debugSink) write(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.f.WriteString(line)
+}
+
+func (s *debugSink) writeEntry(entry debugEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	s.write(string(b) + "\n")
+}
@@ -3,76 +3,126 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gal-cli/gal-cli/internal/agent"
+	"github.com/gal-cli/gal-cli/internal/i18n"
 	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
 )
 
+// ErrMaxTokens is returned when a response is cut off by the output token
+// limit in a way that can't be shown as a partial answer.
+var ErrMaxTokens = errors.New("response truncated: hit max_tokens")
+
+// TruncatedNote is appended to answers cut off by the output token limit.
+const TruncatedNote = "(truncated: hit max_tokens)"
+
+// maxContinuations caps the follow-up rounds AutoContinue asks for in a turn.
+const maxContinuations = 3
+
+// continueNudge asks for the rest of an answer cut off by max_tokens.
+const continueNudge = "Your reply was cut off by the output token limit. Continue exactly where it stopped, without repeating anything or adding a preamble."
+
 type Engine struct {
-	Agent           *agent.Agent
-	Provider        provider.Provider
-	Messages        []provider.Message
-	ContextLimit    int
-	Debug           bool
-	debugFile       *os.File
-	debugTurn       int
-	sensitiveValues []string // values to mask in display/logs
+	Agent              *agent.Agent
+	Provider           provider.Provider
+	Messages           []provider.Message
+	ContextLimit       int
+	Compression        CompressSettings             // summarizer prompt, language and header
+	ModelWindows       map[string]int               // context window overrides by "provider/model"
+	ModelCaps          map[string]Capabilities      // capabilities by "provider/model", see Capabilities
+	ToolParallelism    int                          // max concurrent tool groups per round, default 4
+	ToolLimits         map[string]ToolLimit         // per provider name; see CheckToolLimits
+	TrimTools          bool                         // drop least-recently-used MCP tools when over the limit
+	InjectionGuard     bool                         // wrap and scan results of untrusted tools, see guardResult
+	ResponseFormat     *provider.ResponseFormat     // ask for JSON answers; invalid JSON is retried once
+	ToolChoice         string                       // tool_choice of a turn's first round; later rounds are left to the model
+	AutoContinue       bool                         // ask for the rest of answers cut off by max_tokens, up to maxContinuations times
+	KeepPartialOnError bool                         // keep an answer cut off by a stream error, ending in InterruptedNote, instead of rolling the turn back
+	KeepReplays        bool                         // keep an answer's repeated ending as it is, see collapseReplay
+	MaxRounds          int                          // model requests per turn, default 50; see RoundLimitError
+	ToolResultTokens   int                          // most of a tool result the model sees, default 4000; -1 = all; see limitToolResult
+	StrictToolArgs     bool                         // also refuse tool calls with fields their schema doesn't declare, see checkToolCalls
+	AutoLimits         AutoLimits                   // tools and round/token caps of autonomous runs, see RunAuto
+	OnStatus           func(string)                 // warnings that aren't errors, e.g. tool limits
+	OnReasoning        func(string)                 // the model's thinking as it streams; never added to Messages
+	OnHeartbeat        func(Heartbeat)              // called from another goroutine while a request is idle
+	OnToolMeta         func(string, map[string]any) // a tool's metadata (exit code, path, ...), just before its onToolResult
+	OnCheckpoint       func(Checkpoint)             // progress the model reported during an autonomous run
+	OnTurnComplete     func()                       // after a turn that ended in an answer, and after Compress; Messages end without open tool calls
+	OnCompressing      func(string)                 // "compressing context..." and "" around compression within a turn, as Compress's onStatus
+	OnArchive          func(Archived)               // the messages compression or ClearWithSummary replaces, with their summary
+	OnToolApproval     Approver                     // asked before a tool that isn't read-only runs; nil runs them all
+	ApprovedTools      []string                     // tools that run without OnToolApproval; "*" is all
+	HeartbeatInterval  time.Duration                // idle time between heartbeats, default 15s
+	LastTurn           TurnStats                    // stats of the most recent Send, set when it returns
+	Usage              provider.Usage               // API-reported tokens across all turns, including compression
+	Cost               Cost                         // what Usage cost, as far as models have prices
+	Pricing            map[string]Price             // by "provider/model" or model name, over defaultPrices
+	AutoRuns           []AutoRun                    // autonomous runs of the session, oldest first
+	Changes            []Change                     // calls to tools that may change the machine, oldest first
+	RequireCleanGit    bool                         // refuse file writes in a git repository with uncommitted changes, see guardGit
+	AutoStash          bool                         // checkpoint such a repository instead, see SaveCheckpoint
+	GitCheckpoints     []GitCheckpoint              // checkpoints made this session, oldest first
+	GitTrees           map[string]string            // by repository, its work tree after the agent's last writes there, see checkRepo
+	SessionID          string                       // names checkpoints
+	NewSubagent        SubagentBuilder              // builds the engine of a subagent for spawn_agent; nil: the agent has none
+	SubagentDepth      int                          // how deep this engine runs in spawn_agent calls; 0 for the user's agent
+	MaxSubagentDepth   int                          // deepest SubagentDepth spawn_agent may reach, default 2
+	Propose            bool                         // stage file writes in Pending for the user's review instead of writing them, see stageCall
+	Pending            []PendingChange              // file changes staged for review, see ApplyChanges
+	Debug              bool
+	DebugDir           string // where InitDebug writes the log, default the temp directory
+	DebugKeep          int    // debug logs kept in DebugDir, the oldest removed first; default 20, -1 keeps all
+	DebugFormat        string // "text" (default) or "jsonl", one JSON object per line, see debugEntry
+	debugOut           *debugSink
+	debugTurn          int
+	debugRound         int
+	retrying           bool     // the turn answers its message again, see RetryLast
+	sensitiveValues    []string // values to mask in display/logs, see AddSensitive
+	toolLimitSig       string   // last reported tool-limit state
+	sysPromptChecked   string   // model CheckSystemPrompt last ran for
+	toolLastUsed       map[string]int
+	toolUseSeq         int
+	usageBase          usageBaseline // last real prompt size, see contextTokens
+	auto               *autoState    // the autonomous run in progress, if any
+	tokens             *tokenCounts  // the current model's token counter, see estimateTokens
+	invalidToolArgs    int           // tool calls refused for invalid arguments this session
+	rate               *rateGate     // holds requests back after a rate limit error; shared with forks
+	fork               *forkPoint    // where a fork left the conversation, see Exchange
+	logTag             string        // starts a fork's debug log lines
+	allowed            approvals     // what the user allowed for the rest of the session
+	stageMu            *sync.Mutex   // guards Pending while file tools run in parallel
 }
 
 func New(a *agent.Agent, p provider.Provider) *Engine {
 	return &Engine{
 		Agent:    a,
 		Provider: p,
+		tokens:   &tokenCounts{},
+		rate:     &rateGate{},
+		stageMu:  &sync.Mutex{},
 		Messages: []provider.Message{
 			{Role: "system", Content: a.SystemPrompt},
 		},
 	}
 }
 
-func (e *Engine) InitDebug() {
-	if e.debugFile != nil {
-		return
-	}
-	name := fmt.Sprintf("/tmp/gal-debug-%s.log", time.Now().Format("20060102-150405"))
-	f, err := os.Create(name)
-	if err != nil {
-		return
-	}
-	e.debugFile = f
-	fmt.Fprintf(os.Stderr, "🐛 Debug log: %s\n", name)
-
-	// wire debug logger to provider
-	dbg := provider.DebugFunc(e.debugLog)
-	switch p := e.Provider.(type) {
-	case *provider.OpenAI:
-		p.Debug = dbg
-	case *provider.Anthropic:
-		p.Debug = dbg
+// stamp sets when a user or assistant message was added to the
+// conversation and, on assistant messages, the model that wrote it.
+func (e *Engine) stamp(m provider.Message) provider.Message {
+	now := time.Now()
+	m.Timestamp = &now
+	if m.Role == "assistant" {
+		m.Model = e.Agent.CurrentModel
 	}
-}
-
-func (e *Engine) debugLog(format string, args ...any) {
-	if e.debugFile == nil {
-		return
-	}
-	ts := time.Now().Format("15:04:05.000")
-	fmt.Fprintf(e.debugFile, "[%s] %s\n", ts, fmt.Sprintf(format, args...))
-}
-
-func (e *Engine) debugJSON(label string, v any) {
-	if e.debugFile == nil {
-		return
-	}
-	b, _ := json.Marshal(v)
-	s := string(b)
-	for _, sv := range e.sensitiveValues {
-		s = strings.ReplaceAll(s, sv, "********")
-	}
-	go e.debugLog("%s:\n%s", label, s)
+	return m
 }
 
 func (e *Engine) ModelID() string {
@@ -86,6 +136,10 @@ func (e *Engine) Send(ctx context.Context, userMsg string, onText func(string))
 	return e.SendWithCallbacks(ctx, userMsg, onText, nil, nil)
 }
 
+// SendWithCallbacks sends userMsg and runs the tool calls it leads to.
+// onToolCall is called as each call starts running, from several goroutines
+// at once when calls run in parallel (see runToolCalls); onText and
+// onToolResult are called in order.
 func (e *Engine) SendWithCallbacks(ctx context.Context, userMsg string, onText func(string), onToolCall func(string), onToolResult func(string)) error {
 	return e.SendWithInteractive(ctx, userMsg, onText, onToolCall, onToolResult, nil)
 }
@@ -100,75 +154,285 @@ type InteractiveInputRequest struct {
 }
 
 // SendWithInteractive adds support for interactive input collection
-func (e *Engine) SendWithInteractive(ctx context.Context, userMsg string, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]InteractiveInputRequest) (map[string]string, error)) error {
+func (e *Engine) SendWithInteractive(ctx context.Context, userMsg string, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]InteractiveInputRequest) (map[string]string, error)) (err error) {
 	// Clean up any incomplete tool_call sequences from previous cancelled requests
 	e.cleanIncompleteToolCalls()
 
 	e.debugTurn++
 	turn := e.debugTurn
 	round := 0
+	e.debugRound = 0
 
+	ctx, endTurn := e.observeTurn(ctx)
 	snapshot := len(e.Messages) // rollback point on failure
-	e.Messages = append(e.Messages, provider.Message{Role: "user", Content: userMsg})
-	e.debugLog("========== TURN %d ==========", turn)
+	stats := TurnStats{Start: time.Now(), Model: e.Agent.CurrentModel, TokensBefore: e.contextTokens(), Retry: e.retrying}
+	defer func() {
+		stats.DurationMs = time.Since(stats.Start).Milliseconds()
+		stats.Rounds = round
+		stats.TokensAfter = e.contextTokens()
+		if err != nil {
+			stats.Error = err.Error()
+		}
+		e.LastTurn = stats
+		e.debugLog("TURN_STATS: %s", stats.Summary())
+		recordTurn(stats)
+		endTurn(err)
+		if err == nil && e.OnTurnComplete != nil {
+			e.OnTurnComplete()
+		}
+	}()
+	if err := e.checkMessageFits(userMsg); err != nil {
+		return err
+	}
+	e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "user", Content: userMsg}))
+	if e.fork != nil && e.fork.first == nil {
+		e.fork.first = e.Messages[len(e.Messages)-1].Timestamp
+	}
+	if e.retrying {
+		e.debugLog("========== TURN %d (retry) ==========", turn)
+	} else {
+		e.debugLog("========== TURN %d ==========", turn)
+	}
 	e.debugLog("USER: %s", userMsg)
 
+	limitHit := false // MaxRounds was reached; the round running asks for a progress report
+	// repositories guardGit checked this turn
+	gitChecked := map[string]string{}
 	rollback := func() {
+		if limitHit {
+			e.keepAtRoundLimit("", nil) // the rounds before the limit did their work
+			return
+		}
+		if e.auto != nil && len(e.Messages) > snapshot+1 {
+			// the tools of an autonomous run's finished rounds have run; keep them
+			e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", Content: AutoStoppedNote}))
+			e.debugLog("AUTO KEPT: %d messages", len(e.Messages)-snapshot)
+			return
+		}
 		e.Messages = e.Messages[:snapshot]
 		e.debugLog("ROLLBACK: messages restored to %d", snapshot)
 	}
 
-	const maxRounds = 50
+	nudge := ""     // the message that asked once for valid JSON
+	continued := "" // answer so far, when it was cut off and AutoContinue asked for the rest
+	contStart := 0  // index of the first cut-off piece in e.Messages
+	continuations := 0
+	compressFailed := false // summarizing failed this turn; it isn't retried every round
+	opts := e.Agent.Params
+	opts.ResponseFormat = e.ResponseFormat
+	if e.ToolsOff() {
+		opts.NativeTools = nil
+	}
 
 	for {
 		round++
-		if round > maxRounds {
-			rollback()
-			return fmt.Errorf("agentic loop exceeded %d rounds, stopping", maxRounds)
+		if e.auto != nil {
+			if err := e.autoLimit(round); err != nil {
+				rollback()
+				return err
+			}
+		} else if round > e.maxRounds() && !limitHit {
+			// keep the work and ask for a report instead of throwing it away
+			limitHit = true
+			e.debugLog("ROUND LIMIT turn %d: %d rounds, asking for a progress report", turn, e.maxRounds())
+			e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "user", Content: fmt.Sprintf(roundLimitPrompt, e.maxRounds())}))
 		}
 		if ctx.Err() != nil {
 			rollback()
 			return ctx.Err()
 		}
+		// tool results can outgrow the context within a turn; summarize what
+		// came before it, while the turn itself stays whole so the model
+		// keeps track of its task
+		if !compressFailed {
+			shift, err := e.compress(ctx, e.OnCompressing, snapshot)
+			if err != nil && ctx.Err() != nil {
+				rollback()
+				return ctx.Err()
+			} else if err != nil {
+				compressFailed = true
+				if e.OnStatus != nil {
+					e.OnStatus("compress: " + err.Error())
+				}
+			} else if shift != 0 {
+				e.debugLog("COMPRESS turn %d / round %d: earlier messages summarized, turn moved by %d", turn, round, shift)
+				snapshot += shift
+				if continued != "" {
+					contStart += shift
+				}
+			}
+		}
 		var fullContent string
 		var toolCalls []provider.ToolCall
+		var thinking []provider.ThinkingBlock
 
+		e.debugRound = round
 		e.debugLog("--- turn %d / round %d --- model=%s messages=%d", turn, round, e.Agent.CurrentModel, len(e.Messages))
+		toolDefs := e.activeToolDefs()
+		reqMsgs := e.requestMessages()
+		if fixed, notes := pairToolResults(reqMsgs); len(notes) > 0 {
+			e.debugLog("REPAIR turn %d / round %d: %s", turn, round, strings.Join(notes, "; "))
+			reqMsgs = fixed
+		}
+		opts.ToolChoice = e.ToolChoice
+		if round > 1 && provider.ForcesTool(e.ToolChoice) {
+			opts.ToolChoice = "" // forcing every round would call tools forever
+		}
+		if e.auto != nil && !e.ToolsOff() {
+			toolDefs = append(slices.Clip(toolDefs), checkpointDef)
+			if e.auto.wrapUp {
+				opts.ToolChoice = "none"
+			}
+		}
+		if limitHit {
+			opts.ToolChoice = "none" // the tools stay defined: the history has calls to them
+		}
 		e.debugJSON(fmt.Sprintf("REQUEST turn %d / round %d", turn, round), map[string]any{
-			"model":    e.ModelID(),
-			"messages": e.Messages,
-			"tools":    e.Agent.ToolDefs,
+			"model":       e.ModelID(),
+			"messages":    reqMsgs,
+			"tools":       toolDefs,
+			"tool_choice": opts.ToolChoice,
 		})
 
-		err := e.Provider.ChatStream(ctx, e.ModelID(), e.Messages, e.Agent.ToolDefs, func(d provider.StreamDelta) {
+		if err := e.rate.wait(ctx); err != nil {
+			rollback()
+			return err
+		}
+		touch, stopWatch := e.watchStream()
+		reqCtx, reqDone := e.observeRequest(ctx)
+		var usage *provider.Usage
+		var stop, reasoning string
+		err := e.Provider.ChatStream(reqCtx, e.ModelID(), reqMsgs, toolDefs, opts, func(d provider.StreamDelta) {
+			touch()
+			if d.Usage != nil {
+				usage = d.Usage
+			}
+			if d.Stop != "" {
+				stop = d.Stop
+			}
+			if d.Reasoning != "" {
+				reasoning += d.Reasoning
+				if e.OnReasoning != nil {
+					e.OnReasoning(d.Reasoning)
+				}
+			}
 			if d.Content != "" {
 				fullContent += d.Content
 				if onText != nil {
 					onText(d.Content)
 				}
 			}
+			if d.Thinking != nil {
+				thinking = append(thinking, *d.Thinking)
+			}
 			if len(d.ToolCalls) > 0 {
 				toolCalls = append(toolCalls, d.ToolCalls...)
 			}
 		})
+		stopWatch()
+		reqDone(err)
+		if reasoning != "" {
+			e.debugLog("REASONING turn %d / round %d:\n%s", turn, round, reasoning)
+		}
+		if usage != nil {
+			stats.Cost += e.recordUsage(*usage, len(e.Messages)).USD
+			stats.PromptTokens += usage.PromptTokens
+			stats.CompletionTokens += usage.CompletionTokens
+			stats.CachedTokens += usage.CachedTokens
+			if e.auto != nil {
+				e.auto.run.Tokens += usage.PromptTokens + usage.CompletionTokens
+			}
+		}
 		if err != nil {
 			e.debugLog("ERROR turn %d / round %d: %v", turn, round, err)
+			if d := e.rate.hold(err); d > 0 {
+				e.debugLog("RATE HOLD: requests wait %s", d)
+			}
+			if limitHit {
+				return e.keepAtRoundLimit("", err)
+			}
+			if partial := continued + fullContent; e.KeepPartialOnError && partial != "" && ctx.Err() == nil && e.ResponseFormat == nil {
+				if continued != "" {
+					e.Messages = e.Messages[:contStart]
+				}
+				e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", Content: partial + "\n\n" + InterruptedNote}))
+				e.debugLog("PARTIAL KEPT turn %d / round %d: %d chars", turn, round, len(partial))
+				return &PartialError{Err: err, Text: partial}
+			}
 			rollback()
 			return err
 		}
+		if stop != "" {
+			e.debugLog("STOP REASON turn %d / round %d: %s", turn, round, stop)
+		}
+		if text, ok := e.collapseReplay(fullContent); ok {
+			fullContent = text
+			stats.Replays++
+		}
+		truncated := stop == provider.StopMaxTokens
+		if limitHit {
+			// tool calls made anyway don't run: the turn is over
+			return e.keepAtRoundLimit(fullContent, nil)
+		}
 
 		if len(toolCalls) == 0 {
-			e.Messages = append(e.Messages, provider.Message{Role: "assistant", Content: fullContent})
+			if truncated && e.AutoContinue && e.ResponseFormat == nil && continuations < maxContinuations && fullContent != "" {
+				if continued == "" {
+					contStart = len(e.Messages)
+				}
+				continuations++
+				continued += fullContent
+				e.debugLog("AUTO CONTINUE turn %d / round %d: %d/%d, %d chars so far", turn, round, continuations, maxContinuations, len(continued))
+				e.Messages = append(e.Messages,
+					e.stamp(provider.Message{Role: "assistant", Content: fullContent, Thinking: thinking}),
+					e.stamp(provider.Message{Role: "user", Content: continueNudge}))
+				continue
+			}
+			if continued != "" {
+				// stitch the pieces into one answer
+				fullContent = continued + fullContent
+				e.Messages = e.Messages[:contStart]
+			}
+			if e.ResponseFormat != nil && fullContent != "" {
+				answer, jerr := jsonAnswer(fullContent)
+				if jerr != nil && nudge == "" {
+					nudge = fmt.Sprintf(jsonNudge, jerr)
+					e.debugLog("INVALID JSON turn %d / round %d: %v", turn, round, jerr)
+					e.Messages = append(e.Messages,
+						e.stamp(provider.Message{Role: "assistant", Content: fullContent, Thinking: thinking}),
+						e.stamp(provider.Message{Role: "user", Content: nudge}))
+					continue
+				}
+				if jerr != nil {
+					rollback()
+					return fmt.Errorf("%w after a retry: %v", ErrInvalidJSON, jerr)
+				}
+				if nudge != "" {
+					e.dropJSONNudge(nudge)
+				}
+				fullContent = answer
+			}
+			e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", Content: fullContent, Thinking: thinking}))
 			e.debugLog("RESPONSE turn %d / round %d: text (%d chars)", turn, round, len(fullContent))
 			if fullContent == "" {
 				rollback()
-				return fmt.Errorf("empty response from %s (no content, no tool calls, round %d)", e.Agent.CurrentModel, round)
+				return errors.New(i18n.T("engine.empty_response", e.Agent.CurrentModel, round))
+			}
+			if truncated && onText != nil {
+				onText("\n\n" + TruncatedNote)
 			}
 			return nil
 		}
+		if truncated {
+			// the last call's arguments are cut off mid-JSON; running it would do the wrong thing
+			rollback()
+			return fmt.Errorf("%w while writing a call to %s; raise max_tokens", ErrMaxTokens, toolCalls[len(toolCalls)-1].Function.Name)
+		}
 
-		e.Messages = append(e.Messages, provider.Message{Role: "assistant", ToolCalls: toolCalls})
+		continued = "" // the pieces stay in the history as they are
+		e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", ToolCalls: toolCalls, Thinking: thinking}))
 		e.debugLog("RESPONSE turn %d / round %d: %d tool calls", turn, round, len(toolCalls))
+		stats.ToolCalls += len(toolCalls)
 
 		// Check if any tool calls are 'interactive' tool
 		var interactiveRequests []InteractiveInputRequest
@@ -240,77 +504,72 @@ func (e *Engine) SendWithInteractive(ctx context.Context, userMsg string, onText
 			}
 		}
 
-		// Process all tool calls — readonly tools run in parallel, others serial
-		type toolResult struct {
-			index   int
-			result  string
-			elapsed time.Duration
+		// Check every call's arguments against its tool's schema first; calls
+		// with invalid ones get what's wrong as their result and don't run
+		toolArgs, refused := e.checkToolCalls(toolCalls, interactiveToolIndex)
+		// File writes in a repository with uncommitted work wait for a
+		// checkpoint of it (require_clean_git, auto_stash)
+		e.guardGit(toolCalls, toolArgs, refused, gitChecked)
+		// With Propose, file changes that can't be staged don't run
+		e.refuseUnstageable(toolCalls, refused)
+		// Then ask the user about calls to tools that change things
+		if err := e.approveToolCalls(toolCalls, toolArgs, refused); err != nil {
+			rollback()
+			return err
 		}
 
-		// Identify readonly batch (only if ALL are readonly and no interactive)
-		allReadOnly := interactiveToolIndex < 0
-		if allReadOnly {
-			for _, tc := range toolCalls {
-				if !e.Agent.Registry.IsReadOnly(tc.Function.Name) {
-					allReadOnly = false
-					break
-				}
+		// Process all tool calls — calls that don't conflict run in parallel,
+		// calls on the same path/backend (and exclusive tools like bash) run in order
+		changed := make([]*toolChange, len(toolCalls)) // calls to tools that aren't read-only, for Changes
+		results := e.runToolCalls(ctx, toolCalls, func(i int) toolResult {
+			tc := toolCalls[i]
+			if onToolCall != nil {
+				onToolCall(tc.Function.Name)
 			}
-		}
-
-		results := make([]toolResult, len(toolCalls))
+			e.debugLog("TOOL_CALL: %s args=%s", tc.Function.Name, tc.Function.Arguments)
 
-		if allReadOnly && len(toolCalls) > 1 {
-			// parallel execution
-			ch := make(chan toolResult, len(toolCalls))
-			for i, tc := range toolCalls {
-				if onToolCall != nil {
-					onToolCall(tc.Function.Name)
-				}
-				go func(idx int, tc provider.ToolCall) {
-					var args map[string]any
-					json.Unmarshal([]byte(tc.Function.Arguments), &args)
-					e.debugLog("TOOL_CALL[parallel]: %s args=%s", tc.Function.Name, tc.Function.Arguments)
-					start := time.Now()
-					res, err := e.Agent.Registry.Execute(ctx, tc.Function.Name, args)
-					elapsed := time.Since(start)
-					if err != nil {
-						res = "error: " + err.Error()
-					}
-					ch <- toolResult{idx, res, elapsed}
-				}(i, tc)
-			}
-			for range toolCalls {
-				tr := <-ch
-				results[tr.index] = tr
-			}
-		} else {
-			// serial execution
-			for i, tc := range toolCalls {
-				if onToolCall != nil {
-					onToolCall(tc.Function.Name)
+			start := time.Now()
+			if i == interactiveToolIndex && interactiveResults != nil {
+				resultJSON, _ := json.Marshal(interactiveResults)
+				return toolResult{index: i, result: string(resultJSON), elapsed: time.Since(start)}
+			}
+			if e.auto != nil {
+				if res, ok := e.autoToolResult(tc); ok {
+					return toolResult{index: i, result: res, elapsed: time.Since(start)}
 				}
-
-				var args map[string]any
-				json.Unmarshal([]byte(tc.Function.Arguments), &args)
-
-				e.debugLog("TOOL_CALL: %s args=%s", tc.Function.Name, tc.Function.Arguments)
-
-				start := time.Now()
-				var res string
-				if i == interactiveToolIndex && interactiveResults != nil {
-					resultJSON, _ := json.Marshal(interactiveResults)
-					res = string(resultJSON)
-				} else {
-					var err error
-					res, err = e.Agent.Registry.Execute(ctx, tc.Function.Name, args)
-					if err != nil {
-						res = "error: " + err.Error()
-					}
+			}
+			if refused[i] != "" {
+				return toolResult{index: i, result: refused[i], elapsed: time.Since(start)}
+			}
+			if tc.Function.Name == SpawnAgentTool {
+				return toolResult{index: i, result: e.spawnAgent(ctx, toolArgs[i], onToolCall), elapsed: time.Since(start)}
+			}
+			toolCtx, toolDone := observeTool(ctx, tc.Function.Name)
+			var res tool.ToolResult
+			var err error
+			staged := e.stages(tc.Function.Name)
+			if staged {
+				res, err = e.stageCall(toolCtx, tc.Function.Name, toolArgs[i])
+			} else {
+				res, err = e.Agent.Registry.ExecuteV2(toolCtx, tc.Function.Name, toolArgs[i])
+			}
+			toolDone(err)
+			if !staged && tc.Function.Name != "interactive" && !e.Agent.Registry.IsReadOnlyCall(tc.Function.Name, toolArgs[i]) {
+				changed[i] = &toolChange{res: res, err: err}
+			}
+			if err != nil {
+				res.Text = "error: " + err.Error()
+			}
+			var full string
+			if res.Text, full = e.limitToolResult(tc.Function.Name, res.Text); full != "" {
+				if res.Meta == nil {
+					res.Meta = map[string]any{}
 				}
-				results[i] = toolResult{i, res, time.Since(start)}
+				res.Meta["full_output"] = full
 			}
-		}
+			return toolResult{index: i, result: res.Text, elapsed: time.Since(start), meta: res.Meta}
+		})
+		e.noteGitWrites(toolCalls, toolArgs, refused)
 
 		// Emit results and append messages
 		for i, tc := range toolCalls {
@@ -333,7 +592,17 @@ func (e *Engine) SendWithInteractive(ctx context.Context, userMsg string, onText
 			}
 
 			e.debugLog("TOOL_RESULT: %s (%d chars, %v) %s", tc.Function.Name, len(tr.result), tr.elapsed, displayResult)
+			if c := changed[i]; c != nil {
+				e.recordChange(tc.Function.Name, tc.Function.Arguments, c.res, c.err)
+			}
+			e.markToolUsed(tc.Function.Name)
 
+			if len(tr.meta) > 0 {
+				e.debugJSON("TOOL_META: "+tc.Function.Name, tr.meta)
+				if e.OnToolMeta != nil {
+					e.OnToolMeta(tc.Function.Name, tr.meta)
+				}
+			}
 			if onToolResult != nil {
 				preview := displayResult
 				if len(preview) > 200 {
@@ -344,7 +613,7 @@ func (e *Engine) SendWithInteractive(ctx context.Context, userMsg string, onText
 
 			e.Messages = append(e.Messages, provider.Message{
 				Role:       "tool",
-				Content:    tr.result,
+				Content:    e.guardResult(tc.Function.Name, tr.result),
 				ToolCallID: tc.ID,
 			})
 		}
@@ -359,156 +628,150 @@ func (e *Engine) Clear() {
 
 func (e *Engine) SwitchModel(model string) {
 	e.Agent.CurrentModel = model
+	e.usageBase = usageBaseline{} // token counts differ between models
 }
 
-// cleanIncompleteToolCalls strips trailing incomplete tool_call sequences
-// (assistant with tool_calls not followed by matching tool results).
+// cleanIncompleteToolCalls strips a trailing tool exchange left incomplete
+// by a cancelled request: an assistant message whose tool_calls don't all
+// have results, with the results it has, and tool results with no assistant
+// message before them. A complete exchange stays, even at the end.
 func (e *Engine) cleanIncompleteToolCalls() {
-	for len(e.Messages) > 0 {
-		last := e.Messages[len(e.Messages)-1]
-		if last.Role == "assistant" && last.Content != "" && len(last.ToolCalls) == 0 {
-			break
+	for {
+		end := len(e.Messages)
+		start := end // the trailing tool results are e.Messages[start:end]
+		for start > 0 && e.Messages[start-1].Role == "tool" {
+			start--
 		}
-		if last.Role == "user" || last.Role == "system" {
+		if start == 0 {
 			break
 		}
-		if last.Role == "tool" || (last.Role == "assistant" && len(last.ToolCalls) > 0) {
-			e.debugLog("CLEAN: removing trailing %s message", last.Role)
-			e.Messages = e.Messages[:len(e.Messages)-1]
+		parent := e.Messages[start-1]
+		if parent.Role != "assistant" || len(parent.ToolCalls) == 0 {
+			if start == end {
+				break
+			}
+			e.debugLog("CLEAN: removing %d tool results without tool calls", end-start)
+			e.Messages = e.Messages[:start]
 			continue
 		}
-		break
+		if toolResultsComplete(parent.ToolCalls, e.Messages[start:end]) {
+			break
+		}
+		e.debugLog("CLEAN: removing %d tool calls with %d of their results", len(parent.ToolCalls), end-start)
+		e.Messages = e.Messages[:start-1]
 	}
 }
 
-func (e *Engine) Close() {
-	if e.debugFile != nil {
-		e.debugFile.Close()
+// toolResultsComplete reports whether results answer every one of calls.
+func toolResultsComplete(calls []provider.ToolCall, results []provider.Message) bool {
+	answered := make(map[string]bool, len(results))
+	for _, m := range results {
+		answered[m.ToolCallID] = true
+	}
+	for _, tc := range calls {
+		if !answered[tc.ID] {
+			return false
+		}
 	}
+	return true
 }
 
-// estimateTokens estimates token count from character length.
-func estimateTokens(msgs []provider.Message) int {
-	total := 0
-	for _, m := range msgs {
-		total += len(m.Content)
-		for _, tc := range m.ToolCalls {
-			total += len(tc.Function.Name) + len(tc.Function.Arguments)
-		}
+func (e *Engine) Close() {
+	tool.CloseBrowser()
+	if e.debugOut != nil {
+		e.debugOut.f.Close()
 	}
-	return int(float64(total) / 2.5)
 }
 
-// NeedsCompression returns true if estimated tokens exceed the context limit.
+// NeedsCompression returns true if estimated tokens exceed the effective limit.
 func (e *Engine) NeedsCompression() bool {
-	if e.ContextLimit <= 0 {
+	limit := e.EffectiveLimit()
+	if limit <= 0 || e.Compression.Disabled {
 		return false
 	}
-	return estimateTokens(e.Messages) > e.ContextLimit
+	return float64(e.contextTokens()) > float64(limit)*e.Compression.triggerRatio()
 }
 
 // Compress summarizes old messages to reduce context size.
 // onStatus is called with status text (e.g. for TUI display).
 func (e *Engine) Compress(ctx context.Context, onStatus func(string)) error {
-	if !e.NeedsCompression() {
-		return nil
+	if _, err := e.compress(ctx, onStatus, len(e.Messages)); err != nil {
+		return err
 	}
-	if onStatus != nil {
-		onStatus("compressing context...")
+	if e.OnTurnComplete != nil {
+		e.OnTurnComplete()
+	}
+	return nil
+}
+
+// compress summarizes old messages when the context needs it, leaving
+// e.Messages[keep:] as they are, and returns by how much that moved them.
+func (e *Engine) compress(ctx context.Context, onStatus func(string), keep int) (int, error) {
+	if !e.NeedsCompression() {
+		return 0, nil
 	}
-	defer func() {
-		if onStatus != nil {
-			onStatus("")
-		}
-	}()
 
 	// skip system message at index 0
 	msgs := e.Messages[1:]
-	targetTokens := int(float64(e.ContextLimit) * 0.8)
+	keepTokens := int(float64(e.EffectiveLimit()) * e.Compression.targetRatio())
+	maxCut := min(e.Compression.keepFrom(msgs), keep-1) // the last KeepLast exchanges stay whatever their size
 
-	// find compress boundary: accumulate from oldest, respect tool_call groups
+	// find compress boundary: summarize from oldest until the rest fits in
+	// keepTokens, respect tool_call groups
+	remaining := e.estimateTokens(msgs)
 	accum := 0
 	cutIdx := 0 // index in msgs (not e.Messages)
-	for cutIdx < len(msgs) {
+	for cutIdx < maxCut && remaining > keepTokens {
 		m := msgs[cutIdx]
-		mtokens := int(float64(len(m.Content)) / 2.5)
-		for _, tc := range m.ToolCalls {
-			mtokens += int(float64(len(tc.Function.Name)+len(tc.Function.Arguments)) / 2.5)
-		}
-
-		if accum+mtokens > targetTokens {
-			break
-		}
+		mtokens := e.messageTokens(m)
 		accum += mtokens
+		remaining -= mtokens
 		cutIdx++
 
 		// if this was an assistant with tool_calls, include all following tool results
 		if m.Role == "assistant" && len(m.ToolCalls) > 0 {
 			for cutIdx < len(msgs) && msgs[cutIdx].Role == "tool" {
 				tm := msgs[cutIdx]
-				accum += int(float64(len(tm.Content)) / 2.5)
+				accum += e.messageTokens(tm)
+				remaining -= e.messageTokens(tm)
 				cutIdx++
 			}
 		}
 	}
 
-	if cutIdx == 0 {
-		return nil
+	if cutIdx == 0 || cutIdx == 1 && msgs[0].Role == "system" {
+		return 0, nil // nothing, or only the last summary, to summarize
 	}
 
 	compressZone := msgs[:cutIdx]
 	keepZone := msgs[cutIdx:]
 
-	// build compression request (isolated from conversation)
-	compressMessages := []provider.Message{
-		{Role: "system", Content: "Summarize the following conversation concisely, preserving key decisions, code changes, file paths, and technical details. Output in the same language as the conversation."},
-	}
-	// pack compress zone as a single user message
-	var sb strings.Builder
-	for _, m := range compressZone {
-		switch {
-		case m.Role == "user":
-			sb.WriteString("User: " + m.Content + "\n\n")
-		case m.Role == "assistant" && m.Content != "":
-			sb.WriteString("Assistant: " + m.Content + "\n\n")
-		case m.Role == "assistant" && len(m.ToolCalls) > 0:
-			for _, tc := range m.ToolCalls {
-				sb.WriteString(fmt.Sprintf("Assistant called tool %s(%s)\n", tc.Function.Name, tc.Function.Arguments))
-			}
-			sb.WriteString("\n")
-		case m.Role == "tool":
-			preview := m.Content
-			if len(preview) > 500 {
-				preview = preview[:500] + "...(truncated)"
-			}
-			sb.WriteString("Tool result: " + preview + "\n\n")
-		}
-	}
-	compressMessages = append(compressMessages, provider.Message{Role: "user", Content: sb.String()})
-
-	e.debugLog("COMPRESS: zone=%d msgs, keep=%d msgs, estimated_tokens=%d", len(compressZone), len(keepZone), accum)
+	e.debugLog("COMPRESS: zone=%d msgs, keep=%d msgs, estimated_tokens=%d, kept_tokens=%d", len(compressZone), len(keepZone), accum, remaining)
 
-	// call LLM for summary
-	var summary string
-	err := e.Provider.ChatStream(ctx, e.ModelID(), compressMessages, nil, func(d provider.StreamDelta) {
-		summary += d.Content
-	})
+	if onStatus != nil {
+		onStatus("compressing context...")
+		defer onStatus("")
+	}
+	summary, err := e.summarize(ctx, compressZone)
+	recordCompression(err)
 	if err != nil {
 		e.debugLog("COMPRESS ERROR: %v", err)
-		return err
+		return 0, err
 	}
 
 	e.debugLog("COMPRESS DONE: summary=%d chars", len(summary))
+	e.archive(compressZone, summary)
 
 	// rebuild messages: system + compressed summary + keep zone
 	newMessages := []provider.Message{
 		e.Messages[0], // original system prompt
-		{Role: "system", Content: "[Compressed context from earlier conversation]\n" + summary},
+		{Role: "system", Content: e.Compression.header() + "\n" + summary},
 	}
 	newMessages = append(newMessages, keepZone...)
+	shift := len(newMessages) - len(e.Messages)
 	e.Messages = newMessages
 
-	return nil
+	return shift, nil
 }
 
 // Helper functions for extracting fields from map[string]any
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// defaultRateHold is how long requests wait after a rate limit error that
+// didn't say when to retry.
+const defaultRateHold = 10 * time.Second
+
+// Fork returns an engine for a background turn (/bg): it starts from the
+// conversation so far but keeps its own history, may only call read-only
+// tools, and shares the provider, its rate limit and the debug log, where
+// its lines start with tag. Its Usage and Cost start at zero; AddForkUsage
+// counts them in. Don't Close a fork: the debug log is the parent's.
+func (e *Engine) Fork(tag string) *Engine {
+	f := *e
+	a := *e.Agent
+	a.ToolDefs = nil
+	for _, d := range e.Agent.ToolDefs {
+		if d.Name != "interactive" && e.Agent.Registry.IsReadOnly(d.Name) {
+			a.ToolDefs = append(a.ToolDefs, d)
+		}
+	}
+	f.Agent = &a
+	f.Messages = slices.Clone(e.Messages)
+	f.fork = &forkPoint{}
+	f.sensitiveValues = slices.Clip(e.sensitiveValues)
+	f.toolLastUsed = maps.Clone(e.toolLastUsed)
+	if provider.ForcesTool(e.ToolChoice) {
+		f.ToolChoice = "" // the forced tool may not be read-only
+	}
+	f.Usage, f.Cost, f.LastTurn = provider.Usage{}, Cost{}, TurnStats{}
+	f.AutoRuns, f.auto = nil, nil
+	f.OnStatus, f.OnReasoning, f.OnHeartbeat, f.OnToolMeta, f.OnCheckpoint, f.OnToolApproval = nil, nil, nil, nil, nil, nil
+	f.OnCompressing = nil
+	// The fork's messages aren't the session's, and the hooks that save the
+	// session read it on the chat's goroutine only.
+	f.OnTurnComplete, f.OnArchive = nil, nil
+	f.logTag = tag + " "
+	return &f
+}
+
+// forkPoint marks where a fork's own messages start. Compression inside the
+// fork replaces the messages before them, so it is the Timestamp of the
+// fork's first user message, which stays the same pointer however the
+// history moves, rather than an index.
+type forkPoint struct {
+	first *time.Time
+}
+
+// Exchange returns what a fork added to the conversation it started from:
+// the user message, tool calls and results, and the answer.
+func (e *Engine) Exchange() []provider.Message {
+	if e.fork == nil || e.fork.first == nil {
+		return nil
+	}
+	for i, m := range e.Messages {
+		if m.Timestamp == e.fork.first {
+			return e.Messages[i:]
+		}
+	}
+	return nil
+}
+
+// AddForkUsage counts the tokens and cost of a fork's turn in e's totals.
+func (e *Engine) AddForkUsage(f *Engine) {
+	e.Usage.Add(f.Usage)
+	e.Cost.Add(f.Cost)
+}
+
+// rateGate holds back the requests of an engine and its forks after the
+// provider answered one of them with a rate limit error, so they don't all
+// run into it again.
+type rateGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// wait blocks until the rate limit has passed, or ctx is done.
+func (g *rateGate) wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	d := time.Until(g.until)
+	g.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// hold closes the gate for as long as a rate limit error asks, and returns
+// that time; other errors return 0.
+func (g *rateGate) hold(err error) time.Duration {
+	var perr *provider.Error
+	if g == nil || !errors.As(err, &perr) || perr.Kind != provider.ErrRateLimited {
+		return 0
+	}
+	d := perr.RetryAfter
+	if d <= 0 {
+		d = defaultRateHold
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until := time.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+	return d
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+func TestForkExchange(t *testing.T) {
+	read := provider.MockToolCall{Name: "read"}
+	tests := []struct {
+		name     string
+		limit    int // the fork's context limit; 0 for none
+		replies  []provider.MockReply
+		want     []string // roles of the exchange
+		compress bool     // the fork compressed its history mid-turn
+	}{
+		{"a plain answer", 0, []provider.MockReply{{Content: "found it"}}, []string{"user", "assistant"}, false},
+		{"a tool round", 0, []provider.MockReply{{ToolCalls: []provider.MockToolCall{read}}, {Content: "found it"}}, []string{"user", "assistant", "tool", "assistant"}, false},
+		{"compressed mid-turn", 2000, []provider.MockReply{
+			{ToolCalls: []provider.MockToolCall{read}},
+			{ToolCalls: []provider.MockToolCall{read, read}},
+			{ToolCalls: []provider.MockToolCall{read}},
+			{Content: "found it"},
+		}, []string{"user", "assistant", "tool", "assistant", "tool", "tool", "assistant", "tool", "assistant"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := tool.NewRegistry()
+			reg.RegisterReadOnlyV2(provider.ToolDef{Name: "read", Parameters: map[string]any{"type": "object"}}, func(context.Context, map[string]any) (tool.ToolResult, error) {
+				return tool.ToolResult{Text: strings.Repeat("the file goes on and on ", 300)}, nil
+			})
+			replies := append([]provider.MockReply{{Content: "first answer"}, {Content: "second answer"}}, tt.replies...)
+			e := newMockEngine(t, reg, replies...)
+			e.Compression = CompressSettings{Model: "mock/s", Provider: &provider.Mock{Replies: []provider.MockReply{{Content: "they talked"}}}}
+			for _, msg := range []string{"first", "second"} {
+				if err := e.SendWithInteractive(context.Background(), msg, nil, nil, nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			parent := slices.Clone(e.Messages)
+
+			f := e.Fork("[bg 1]")
+			if ex := f.Exchange(); len(ex) != 0 {
+				t.Errorf("exchange before the fork's turn: %v", roles(ex))
+			}
+			f.ContextLimit = tt.limit
+			compressed := false
+			f.OnCompressing = func(s string) { compressed = compressed || s != "" }
+			if err := f.SendWithInteractive(context.Background(), "look it up", nil, nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if compressed != tt.compress {
+				t.Fatalf("fork compressed: %v, want %v", compressed, tt.compress)
+			}
+
+			ex := f.Exchange()
+			if got := roles(ex); !slices.Equal(got, tt.want) {
+				t.Fatalf("exchange %v, want %v", got, tt.want)
+			}
+			if ex[0].Content != "look it up" || ex[len(ex)-1].Content != "found it" {
+				t.Errorf("exchange from %q to %q, want the fork's message to its answer", ex[0].Content, ex[len(ex)-1].Content)
+			}
+			checkPairs(t, ex)
+			if !slices.EqualFunc(e.Messages, parent, func(a, b provider.Message) bool { return a.Content == b.Content }) {
+				t.Errorf("the fork changed the parent's history: %v", roles(e.Messages))
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// gitWriteTools are the file tools RequireCleanGit guards.
+var gitWriteTools = map[string]bool{"file_write": true, "file_edit": true, "file_patch": true}
+
+// applyPatchTool writes the files its patch names, which RequireCleanGit
+// guards too, but Propose can't stage.
+const applyPatchTool = "apply_patch"
+
+// checkpointRefs is where checkpoint commits are kept, by session and time.
+const checkpointRefs = "refs/gal-cli/checkpoints/"
+
+const dirtyRepoResult = "error: %s has uncommitted changes and require_clean_git is on, so this file was not changed. Don't work around it: tell the user, and ask them to commit or stash their changes, or to run /checkpoint so the current state can be recovered."
+
+const changedRepoResult = "error: %s has uncommitted changes made since checkpoint %s, and require_clean_git is on, so this file was not changed. Don't work around it: tell the user, and ask them to commit or stash their changes, or to run /checkpoint again so the current state can be recovered."
+
+// GitCheckpoint is a recoverable point of a repository: a commit of its work
+// tree as it was, untracked files included (ignored ones aren't), made
+// without touching the work tree, the index or the branch.
+type GitCheckpoint struct {
+	Time   time.Time `json:"time"`
+	Repo   string    `json:"repo"`   // the work tree's top directory
+	Ref    string    `json:"ref"`    // refs/gal-cli/checkpoints/<session>-<time>
+	Commit string    `json:"commit"` // what Ref points to
+	Tree   string    `json:"tree"`   // Commit's tree: the work tree as it was
+	Label  string    `json:"label"`  // the commit message
+}
+
+// Short is the checkpoint's abbreviated commit.
+func (c GitCheckpoint) Short() string {
+	return c.Commit[:min(len(c.Commit), 10)]
+}
+
+// SaveCheckpoint commits the state of the repository dir is in to a
+// checkpoint ref (/checkpoint, and auto_stash before the agent's edits).
+func (e *Engine) SaveCheckpoint(dir string) (GitCheckpoint, error) {
+	repo := gitTop(dir)
+	if repo == "" {
+		return GitCheckpoint{}, fmt.Errorf("%s isn't in a git repository", dir)
+	}
+	now := time.Now()
+	session := e.SessionID
+	if session == "" {
+		session = "nosession"
+	}
+	cp := GitCheckpoint{
+		Time:  now,
+		Repo:  repo,
+		Ref:   checkpointRefs + session + "-" + now.Format("20060102-150405"),
+		Label: fmt.Sprintf("gal-cli checkpoint %s %s", session, now.Format(time.RFC3339)),
+	}
+
+	tree, head, err := workTree(repo)
+	if err != nil {
+		return GitCheckpoint{}, err
+	}
+	cp.Tree = tree
+	args := []string{"commit-tree", tree, "-m", cp.Label}
+	if head != "" {
+		args = append(args, "-p", head)
+	}
+	// checkpoints are gal-cli's commits, and work without a git identity
+	author := append(os.Environ(), "GIT_AUTHOR_NAME=gal-cli", "GIT_AUTHOR_EMAIL=gal-cli@localhost",
+		"GIT_COMMITTER_NAME=gal-cli", "GIT_COMMITTER_EMAIL=gal-cli@localhost")
+	if cp.Commit, err = git(repo, author, args...); err != nil {
+		return GitCheckpoint{}, err
+	}
+	if _, err := git(repo, nil, "update-ref", "-m", cp.Label, cp.Ref, cp.Commit); err != nil {
+		return GitCheckpoint{}, err
+	}
+	e.GitCheckpoints = append(e.GitCheckpoints, cp)
+	e.debugLog("CHECKPOINT: %s %s at %s", repo, cp.Short(), cp.Ref)
+	return cp, nil
+}
+
+// workTree writes the tree of repo's work tree as it is, untracked files
+// included, to the object store without touching the index, and returns it
+// with the HEAD commit ("" before the first commit).
+func workTree(repo string) (tree, head string, err error) {
+	// stage everything in a scratch index, so the real one stays as it is
+	index, err := os.CreateTemp("", "gal-index-*")
+	if err != nil {
+		return "", "", err
+	}
+	index.Close()
+	os.Remove(index.Name()) // git wants to create it
+	defer os.Remove(index.Name())
+	env := append(os.Environ(), "GIT_INDEX_FILE="+index.Name())
+
+	head, err = git(repo, nil, "rev-parse", "--verify", "-q", "HEAD")
+	if err != nil {
+		head = ""
+	} else if _, err := git(repo, env, "read-tree", head); err != nil {
+		return "", "", err
+	}
+	if _, err := git(repo, env, "add", "-A"); err != nil {
+		return "", "", err
+	}
+	tree, err = git(repo, env, "write-tree")
+	return tree, head, err
+}
+
+// checkpointFor returns the latest checkpoint of this session for repo.
+func (e *Engine) checkpointFor(repo string) (GitCheckpoint, bool) {
+	for i := len(e.GitCheckpoints) - 1; i >= 0; i-- {
+		if e.GitCheckpoints[i].Repo == repo {
+			return e.GitCheckpoints[i], true
+		}
+	}
+	return GitCheckpoint{}, false
+}
+
+// guardGit applies RequireCleanGit and AutoStash to a round's file writes,
+// once per repository and turn: checked holds the outcome for each
+// repository seen (the result refusing the call, or "" to go ahead). A clean
+// work tree goes ahead. A dirty one is checkpointed first with AutoStash;
+// without it, its writes are refused unless the work tree is as the session's
+// last checkpoint of the repository saved it, or as the agent's last writes
+// left it. Writes Propose stages leave the work tree alone
+// until they are reviewed, and aren't guarded.
+func (e *Engine) guardGit(calls []provider.ToolCall, args []map[string]any, refused []string, checked map[string]string) {
+	if !e.RequireCleanGit && !e.AutoStash {
+		return
+	}
+	for i, tc := range calls {
+		name := tc.Function.Name
+		if refused[i] != "" || !gitWriteTools[name] && name != applyPatchTool || e.stages(name) {
+			continue
+		}
+		for _, abs := range writePaths(name, args[i]) {
+			repo := gitTop(filepath.Dir(abs))
+			if repo == "" {
+				continue
+			}
+			msg, ok := checked[repo]
+			if !ok {
+				msg = e.checkRepo(repo)
+				checked[repo] = msg
+			}
+			if refused[i] = msg; msg != "" {
+				break
+			}
+		}
+	}
+}
+
+// writePaths returns the files a call to a file tool that writes would
+// change; those it can't make out are the tool's to report.
+func writePaths(name string, args map[string]any) []string {
+	if name == applyPatchTool {
+		patch, _ := args["patch"].(string)
+		return tool.PatchFiles(patch)
+	}
+	p, _ := args["path"].(string)
+	abs, err := tool.ResolvePath(p)
+	if err != nil {
+		return nil
+	}
+	return []string{abs}
+}
+
+// checkRepo decides whether a turn may write files in repo; see guardGit.
+func (e *Engine) checkRepo(repo string) string {
+	status, err := git(repo, nil, "status", "--porcelain")
+	if err != nil {
+		return fmt.Sprintf("error: can't check %s for uncommitted changes (require_clean_git): %v", repo, err)
+	}
+	if status == "" {
+		return ""
+	}
+	if e.AutoStash {
+		cp, err := e.SaveCheckpoint(repo)
+		if err != nil {
+			return fmt.Sprintf("error: %s has uncommitted changes and saving a checkpoint of them failed, so this file was not changed: %v", repo, err)
+		}
+		if e.OnStatus != nil {
+			e.OnStatus(fmt.Sprintf("uncommitted changes in %s saved as checkpoint %s (%s)", repo, cp.Short(), cp.Ref))
+		}
+		return ""
+	}
+	// a checkpoint covers the work tree as it was then, and the agent's
+	// writes it as they left it; changes made since by someone else aren't
+	// recoverable
+	tree, _, err := workTree(repo)
+	if err != nil {
+		return fmt.Sprintf("error: can't check %s for uncommitted changes (require_clean_git): %v", repo, err)
+	}
+	if tree == e.GitTrees[repo] {
+		return ""
+	}
+	cp, ok := e.checkpointFor(repo)
+	if !ok {
+		e.debugLog("CHECKPOINT: %s is dirty, refusing file writes", repo)
+		return fmt.Sprintf(dirtyRepoResult, repo)
+	}
+	if tree == cp.Tree {
+		return ""
+	}
+	e.debugLog("CHECKPOINT: %s changed since %s, refusing file writes", repo, cp.Short())
+	return fmt.Sprintf(changedRepoResult, repo, cp.Short())
+}
+
+// noteGitWrites records, for checkRepo, the work tree of each repository a
+// round's file writes went to as they left it.
+func (e *Engine) noteGitWrites(calls []provider.ToolCall, args []map[string]any, refused []string) {
+	if !e.RequireCleanGit || e.AutoStash {
+		return // only checkRepo's checkpoint comparison needs them
+	}
+	seen := map[string]bool{}
+	for i, tc := range calls {
+		name := tc.Function.Name
+		if refused[i] != "" || !gitWriteTools[name] && name != applyPatchTool || e.stages(name) {
+			continue
+		}
+		for _, abs := range writePaths(name, args[i]) {
+			repo := gitTop(filepath.Dir(abs))
+			if repo == "" || seen[repo] {
+				continue
+			}
+			seen[repo] = true
+			tree, _, err := workTree(repo)
+			if err != nil {
+				e.debugLog("CHECKPOINT: can't note the work tree of %s: %v", repo, err)
+				continue
+			}
+			if e.GitTrees == nil {
+				e.GitTrees = map[string]string{}
+			}
+			e.GitTrees[repo] = tree
+		}
+	}
+}
+
+// gitTop returns the top directory of the work tree dir is in, or "" when it
+// isn't in one. dir needn't exist yet: its nearest existing parent counts.
+func gitTop(dir string) string {
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	top, err := git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return ""
+	}
+	return filepath.Clean(top)
+}
+
+// git runs a git command in dir and returns its trimmed output; env, when
+// set, replaces the environment.
+func git(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
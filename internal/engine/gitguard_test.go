@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// testRepo returns a repository with one commit of a.txt.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "a.txt"), "a\n")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=t", "-c", "user.email=t@localhost", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckRepo(t *testing.T) {
+	repo := testRepo(t)
+	e := &Engine{RequireCleanGit: true, SessionID: "test"}
+	if msg := e.checkRepo(repo); msg != "" {
+		t.Fatalf("clean repo refused: %s", msg)
+	}
+
+	// the agent's write to a clean repository; the next turn goes on from it
+	var write provider.ToolCall
+	write.Function.Name = "file_write"
+	agentWrite := func(content string) {
+		writeFile(t, filepath.Join(repo, "a.txt"), content)
+		e.noteGitWrites([]provider.ToolCall{write}, []map[string]any{{"path": filepath.Join(repo, "a.txt")}}, []string{""})
+	}
+	agentWrite("agent\n")
+	if msg := e.checkRepo(repo); msg != "" {
+		t.Fatalf("repo as the agent left it refused: %s", msg)
+	}
+
+	// someone else's change without a checkpoint
+	writeFile(t, filepath.Join(repo, "b.txt"), "user\n")
+	if msg := e.checkRepo(repo); !strings.Contains(msg, "has uncommitted changes and") {
+		t.Fatalf("dirty repo without a checkpoint: got %q", msg)
+	}
+
+	cp, err := e.SaveCheckpoint(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Tree == "" {
+		t.Fatal("checkpoint without a tree")
+	}
+	if msg := e.checkRepo(repo); msg != "" {
+		t.Fatalf("repo as checkpointed refused: %s", msg)
+	}
+	agentWrite("agent again\n")
+	if msg := e.checkRepo(repo); msg != "" {
+		t.Fatalf("checkpointed repo as the agent left it refused: %s", msg)
+	}
+
+	// changed since both the checkpoint and the agent's writes
+	writeFile(t, filepath.Join(repo, "b.txt"), "user again\n")
+	if msg := e.checkRepo(repo); !strings.Contains(msg, "since checkpoint "+cp.Short()) {
+		t.Fatalf("repo changed since the checkpoint: got %q", msg)
+	}
+	if _, err := e.SaveCheckpoint(repo); err != nil {
+		t.Fatal(err)
+	}
+	if msg := e.checkRepo(repo); msg != "" {
+		t.Fatalf("repo as checkpointed again refused: %s", msg)
+	}
+}
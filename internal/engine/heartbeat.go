@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
+)
+
+const defaultHeartbeatInterval = 15 * time.Second
+
+// Heartbeat reports on a model request that has gone quiet: how long the
+// request has been running, how long since any data arrived (keep-alives
+// count), and the idle timeout after which the stream is abandoned.
+type Heartbeat struct {
+	Elapsed     time.Duration
+	Idle        time.Duration
+	IdleTimeout time.Duration
+}
+
+// Stalling reports how close the stream is to the idle timeout, from 0 to 1.
+func (h Heartbeat) Stalling() float64 {
+	if h.IdleTimeout <= 0 {
+		return 0
+	}
+	return min(h.Idle.Seconds()/h.IdleTimeout.Seconds(), 1)
+}
+
+func (h Heartbeat) String() string {
+	return "waiting for model… " + record.FormatDuration(h.Elapsed) + ", last data " + record.FormatDuration(h.Idle) + " ago"
+}
+
+// watchStream emits heartbeats through OnHeartbeat while a request is idle for
+// at least one HeartbeatInterval. Call touch on every delta; call stop when
+// the request returns. Once stop returns, OnHeartbeat isn't called again.
+func (e *Engine) watchStream() (touch func(), stop func()) {
+	var last atomic.Int64
+	last.Store(time.Now().UnixNano())
+	touch = func() { last.Store(time.Now().UnixNano()) }
+	if e.OnHeartbeat == nil {
+		return touch, func() {}
+	}
+
+	interval := e.HeartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	start := time.Now()
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				idle := now.Sub(time.Unix(0, last.Load()))
+				if idle < interval {
+					continue
+				}
+				hb := Heartbeat{Elapsed: now.Sub(start), Idle: idle, IdleTimeout: provider.StreamIdleTimeout}
+				e.debugLog("HEARTBEAT: %s", hb)
+				e.OnHeartbeat(hb)
+			}
+		}
+	}()
+	return touch, func() {
+		close(done)
+		<-stopped
+	}
+}
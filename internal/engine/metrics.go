@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/gal-cli/gal-cli/internal/engine"
+
+// tel holds the OpenTelemetry instruments. It stays nil unless
+// EnableTelemetry turned something on, so with telemetry off every hook
+// below is a nil check.
+var tel *telemetry
+
+type telemetry struct {
+	tracer       trace.Tracer
+	requestTime  metric.Float64Histogram // ChatStream duration by provider, model and outcome
+	tokens       metric.Int64Counter     // API-reported tokens by model and type
+	turnTokens   metric.Int64Histogram   // prompt + completion tokens of each turn
+	toolCalls    metric.Int64Counter     // tool executions by tool and outcome
+	toolTime     metric.Float64Histogram // tool execution duration
+	retries      metric.Int64Counter     // HTTP retries by host and status
+	compressions metric.Int64Counter     // context compressions by outcome
+}
+
+// EnableTelemetry turns on metrics and traces for the rest of the process.
+// With metricsAddr set, Prometheus metrics are served at /metrics on that
+// address; traces are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set. With neither, nothing is
+// enabled. The returned function flushes pending spans and stops the server.
+func EnableTelemetry(ctx context.Context, metricsAddr string) (shutdown func(context.Context) error, err error) {
+	traces := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+	if metricsAddr == "" && !traces {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "gal-cli")),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("telemetry resource: %w", err)
+	}
+
+	var closers []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	t := &telemetry{tracer: tracenoop.NewTracerProvider().Tracer("")}
+	if traces {
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("OTLP trace exporter: %w", err)
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+		closers = append(closers, tp.Shutdown)
+		t.tracer = tp.Tracer(instrumentationName)
+	}
+
+	meter := metricnoop.NewMeterProvider().Meter("")
+	if metricsAddr != "" {
+		exp, err := otelprom.New()
+		if err != nil {
+			shutdown(ctx)
+			return nil, fmt.Errorf("prometheus exporter: %w", err)
+		}
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exp), sdkmetric.WithResource(res))
+		closers = append(closers, mp.Shutdown)
+		meter = mp.Meter(instrumentationName)
+
+		ln, err := net.Listen("tcp", metricsAddr)
+		if err != nil {
+			shutdown(ctx)
+			return nil, fmt.Errorf("metrics endpoint: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		closers = append(closers, srv.Shutdown)
+	}
+
+	if err := t.instruments(meter); err != nil {
+		shutdown(ctx)
+		return nil, err
+	}
+	tel = t
+	provider.OnRetry = func(host string, status int) {
+		tel.retries.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("host", host), attribute.Int("status", status)))
+	}
+	return shutdown, nil
+}
+
+// secondBuckets spans quick tool calls to long reasoning requests.
+var secondBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+func (t *telemetry) instruments(m metric.Meter) error {
+	var errs [7]error
+	t.requestTime, errs[0] = m.Float64Histogram("gal.provider.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of model requests, from sending to the end of the stream"),
+		metric.WithExplicitBucketBoundaries(secondBuckets...))
+	t.tokens, errs[1] = m.Int64Counter("gal.tokens",
+		metric.WithUnit("{token}"), metric.WithDescription("Tokens reported by the API, by type (prompt, completion, cached)"))
+	t.turnTokens, errs[2] = m.Int64Histogram("gal.turn.tokens",
+		metric.WithUnit("{token}"), metric.WithDescription("Prompt plus completion tokens used by one turn"),
+		metric.WithExplicitBucketBoundaries(1000, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000))
+	t.toolCalls, errs[3] = m.Int64Counter("gal.tool.calls",
+		metric.WithUnit("{call}"), metric.WithDescription("Tool executions"))
+	t.toolTime, errs[4] = m.Float64Histogram("gal.tool.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of tool executions"),
+		metric.WithExplicitBucketBoundaries(secondBuckets...))
+	t.retries, errs[5] = m.Int64Counter("gal.provider.retries",
+		metric.WithUnit("{retry}"), metric.WithDescription("HTTP retries after 429 or 5xx responses"))
+	t.compressions, errs[6] = m.Int64Counter("gal.compressions",
+		metric.WithUnit("{compression}"), metric.WithDescription("Context compressions"))
+	return errors.Join(errs[:]...)
+}
+
+// noopDone is returned by the observe functions when telemetry is off.
+func noopDone(error) {}
+
+// observeTurn starts the span that parents a turn's requests and tool calls.
+func (e *Engine) observeTurn(ctx context.Context) (context.Context, func(error)) {
+	if tel == nil {
+		return ctx, noopDone
+	}
+	attrs := []attribute.KeyValue{attribute.String("gal.model", e.Agent.CurrentModel)}
+	if e.Agent.Conf != nil {
+		attrs = append(attrs, attribute.String("gal.agent", e.Agent.Conf.Name))
+	}
+	ctx, span := tel.tracer.Start(ctx, "turn", trace.WithAttributes(attrs...))
+	return ctx, func(err error) { endSpan(span, err) }
+}
+
+// observeRequest times one ChatStream call.
+func (e *Engine) observeRequest(ctx context.Context) (context.Context, func(error)) {
+	if tel == nil {
+		return ctx, noopDone
+	}
+	attrs := []attribute.KeyValue{attribute.String("provider", e.providerName()), attribute.String("model", e.ModelID())}
+	start := time.Now()
+	ctx, span := tel.tracer.Start(ctx, "chat "+e.ModelID(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		tel.requestTime.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(append(attrs, attribute.String("outcome", outcome(err)))...))
+		endSpan(span, err)
+	}
+}
+
+// observeTool times one Registry.Execute call.
+func observeTool(ctx context.Context, name string) (context.Context, func(error)) {
+	if tel == nil {
+		return ctx, noopDone
+	}
+	start := time.Now()
+	ctx, span := tel.tracer.Start(ctx, "tool "+name, trace.WithAttributes(attribute.String("gal.tool", name)))
+	return ctx, func(err error) {
+		attrs := metric.WithAttributes(attribute.String("tool", name), attribute.String("outcome", outcome(err)))
+		tel.toolCalls.Add(ctx, 1, attrs)
+		tel.toolTime.Record(ctx, time.Since(start).Seconds(), attrs)
+		endSpan(span, err)
+	}
+}
+
+func (e *Engine) recordTokens(full string, u provider.Usage) {
+	if tel == nil {
+		return
+	}
+	ctx := context.Background()
+	model := attribute.String("model", full)
+	tel.tokens.Add(ctx, int64(u.PromptTokens), metric.WithAttributes(model, attribute.String("type", "prompt")))
+	tel.tokens.Add(ctx, int64(u.CompletionTokens), metric.WithAttributes(model, attribute.String("type", "completion")))
+	tel.tokens.Add(ctx, int64(u.CachedTokens), metric.WithAttributes(model, attribute.String("type", "cached")))
+}
+
+func recordTurn(stats TurnStats) {
+	if tel == nil || stats.PromptTokens+stats.CompletionTokens == 0 {
+		return
+	}
+	tel.turnTokens.Record(context.Background(), int64(stats.PromptTokens+stats.CompletionTokens),
+		metric.WithAttributes(attribute.String("model", stats.Model)))
+}
+
+func recordCompression(err error) {
+	if tel == nil {
+		return
+	}
+	tel.compressions.Add(context.Background(), 1, metric.WithAttributes(attribute.String("outcome", outcome(err))))
+}
+
+// outcome labels a result: "ok", "cancelled", the provider.ErrorKind of an
+// API error, or "error".
+func outcome(err error) string {
+	var perr *provider.Error
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &perr):
+		return string(perr.Kind)
+	}
+	return "error"
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
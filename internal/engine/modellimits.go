@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/i18n"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
+)
+
+// modelWindows lists known context windows in tokens, matched in order
+// against the lowercased model name (without any org/ prefix), so more
+// specific patterns come first.
+var modelWindows = []struct {
+	pattern string
+	window  int
+}{
+	{"claude-*", 200000},
+	{"gpt-4.1*", 1047576},
+	{"gpt-5*", 400000},
+	{"gpt-4o*", 128000},
+	{"gpt-4-turbo*", 128000},
+	{"gpt-4-32k*", 32768},
+	{"gpt-4", 8192},
+	{"gpt-4-0*", 8192},
+	{"gpt-3.5-turbo*", 16385},
+	{"o1-mini*", 128000},
+	{"o1*", 200000},
+	{"o3*", 200000},
+	{"o4*", 200000},
+	{"gemini-*", 1048576},
+	{"deepseek-*", 65536},
+	{"glm-4*", 128000},
+	{"qwen3*", 32768},
+	{"qwen2.5*", 32768},
+	{"llama3.1*", 131072},
+	{"llama3.2*", 131072},
+	{"llama3.3*", 131072},
+	{"llama3*", 8192},
+	{"mistral-large*", 131072},
+	{"mistral*", 32768},
+}
+
+// ModelWindow returns the built-in context window for a model ID, or 0 if
+// the model is unknown.
+func ModelWindow(modelID string) int {
+	name := strings.ToLower(modelID)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, w := range modelWindows {
+		if ok, _ := path.Match(w.pattern, name); ok {
+			return w.window
+		}
+	}
+	return 0
+}
+
+// responseReserve is the part of the window kept free for the reply.
+func responseReserve(window int) int {
+	return min(window/4, 16384)
+}
+
+// contextWindow returns the current model's window: the configured override
+// if any, else the built-in table, else 0 (unknown).
+func (e *Engine) contextWindow() int {
+	if w := e.ModelWindows[e.Agent.CurrentModel]; w > 0 {
+		return w
+	}
+	return ModelWindow(e.ModelID())
+}
+
+// EffectiveLimit is the context size that triggers compression: the
+// configured ContextLimit, lowered to the model's window minus the response
+// reserve when that is smaller. Zero disables compression.
+func (e *Engine) EffectiveLimit() int {
+	limit := e.ContextLimit
+	if w := e.contextWindow(); w > 0 {
+		usable := w - responseReserve(w)
+		if limit <= 0 || usable < limit {
+			limit = usable
+		}
+	}
+	return limit
+}
+
+// ContextUse is how full the context is, as ContextUsage reports it.
+type ContextUse struct {
+	Tokens int     `json:"tokens"` // the last reported prompt size plus an estimate of what came since
+	Limit  int     `json:"limit"`  // EffectiveLimit; 0 if there is none
+	Ratio  float64 `json:"ratio"`  // Tokens / Limit, 0 without a limit
+}
+
+// String renders the use for status lines: "ctx 42% (25k/60k)", or
+// "ctx 25k" without a limit.
+func (c ContextUse) String() string {
+	if c.Limit <= 0 {
+		return "ctx " + record.FormatTokens(c.Tokens)
+	}
+	return fmt.Sprintf("ctx %d%% (%s/%s)", int(c.Ratio*100+0.5), record.FormatTokens(c.Tokens), record.FormatTokens(c.Limit))
+}
+
+// ContextUsage returns the context size against the effective limit.
+func (e *Engine) ContextUsage() ContextUse {
+	c := ContextUse{Tokens: e.contextTokens(), Limit: e.EffectiveLimit()}
+	if c.Limit > 0 {
+		c.Ratio = float64(c.Tokens) / float64(c.Limit)
+	}
+	return c
+}
+
+// checkMessageFits fails when a single user message can't fit in the model's
+// window on its own, so no amount of compression would help.
+func (e *Engine) checkMessageFits(userMsg string) error {
+	w := e.contextWindow()
+	if w <= 0 {
+		return nil
+	}
+	usable := w - responseReserve(w)
+	size := e.estimateTokens([]provider.Message{{Content: userMsg}})
+	if size <= usable {
+		return nil
+	}
+	return errors.New(i18n.T("engine.message_too_large",
+		record.FormatTokens(size), e.Agent.CurrentModel, record.FormatTokens(usable), record.FormatTokens(w), record.FormatTokens(responseReserve(w))))
+}
+
+// systemPromptShare is the part of the effective limit the system prompt may
+// take before SystemPromptWarning complains: past it, compression can free
+// too little room for the conversation.
+const systemPromptShare = 0.5
+
+// SystemPromptWarning returns a warning when the system prompt alone takes
+// more than systemPromptShare of the current model's effective limit, naming
+// its largest parts; "" when it fits or the limit is unknown.
+func (e *Engine) SystemPromptWarning() string {
+	limit := e.EffectiveLimit()
+	if limit <= 0 {
+		return ""
+	}
+	size := e.estimateTokens([]provider.Message{{Content: e.Agent.SystemPrompt}})
+	if float64(size) <= float64(limit)*systemPromptShare {
+		return ""
+	}
+	parts := slices.Clone(e.Agent.PromptParts)
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Size > parts[j].Size })
+	var largest []string
+	for _, p := range parts[:min(3, len(parts))] {
+		// parts are sized in bytes; they share the prompt's tokens pro rata
+		tokens := p.Size * size / max(len(e.Agent.SystemPrompt), 1)
+		largest = append(largest, fmt.Sprintf("%s ~%s", p.Name, record.FormatTokens(tokens)))
+	}
+	msg := fmt.Sprintf("system prompt is ~%s tokens, %d%% of the %s context limit of %s",
+		record.FormatTokens(size), size*100/limit, record.FormatTokens(limit), e.Agent.CurrentModel)
+	if size > limit {
+		msg += "; requests will fail or leave no room for the conversation"
+	}
+	if len(largest) > 0 {
+		msg += "; largest: " + strings.Join(largest, ", ")
+	}
+	return msg + "; list big skills under lazy_skills or lower lazy_threshold in the agent config"
+}
+
+// CheckSystemPrompt reports SystemPromptWarning through OnStatus and the
+// debug log. It only reports again after the model changes.
+func (e *Engine) CheckSystemPrompt() {
+	if e.sysPromptChecked == e.Agent.CurrentModel {
+		return
+	}
+	e.sysPromptChecked = e.Agent.CurrentModel
+	msg := e.SystemPromptWarning()
+	if msg == "" {
+		return
+	}
+	e.debugLog("SYSTEM_PROMPT: %s", msg)
+	if e.OnStatus != nil {
+		e.OnStatus(msg)
+	}
+}
@@ -0,0 +1,30 @@
+package engine
+
+import "strings"
+
+// InterruptedNote ends an answer that was kept after its stream failed, see
+// Engine.KeepPartialOnError.
+const InterruptedNote = "[response interrupted]"
+
+// ResumeMessage asks the model to finish an interrupted answer.
+const ResumeMessage = "Your previous reply was interrupted by a connection error. Continue exactly where it stopped, without repeating anything or adding a preamble."
+
+// PartialError is returned when a stream failed part way through an answer
+// and KeepPartialOnError kept what had arrived in the conversation.
+type PartialError struct {
+	Err  error
+	Text string // the answer so far, without InterruptedNote
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error() + " (partial answer kept)"
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// Interrupted reports whether the last message is an answer kept after its
+// stream failed.
+func (e *Engine) Interrupted() bool {
+	last := e.Messages[len(e.Messages)-1]
+	return last.Role == "assistant" && strings.HasSuffix(last.Content, InterruptedNote)
+}
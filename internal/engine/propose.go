@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// stagedNote ends the result of a file tool call Propose staged.
+const stagedNote = "\n[staged for review: %s is not written until the user accepts the change after this turn; file_read shows the staged version]"
+
+// PendingChange is a file change the model proposed with Propose on, waiting
+// for the user's review. Calls to the same file add up to one change, and
+// Pending holds them by path.
+type PendingChange struct {
+	Path    string // absolute
+	Before  string // the file when the change was first staged
+	After   string
+	Created bool // the file doesn't exist yet
+}
+
+// Diff renders the change as FormatDiff does, or as added lines for a new file.
+func (c PendingChange) Diff() string {
+	if c.Created {
+		return "+ " + strings.ReplaceAll(strings.TrimSuffix(c.After, "\n"), "\n", "\n+ ")
+	}
+	return tool.FormatDiff(c.Before, c.After)
+}
+
+// patchContext is how many unchanged lines around a change Patch shows.
+const patchContext = 3
+
+// Patch renders the change as a unified diff that git apply and patch take,
+// with names relative to the working directory when the file is inside it.
+func (c PendingChange) Patch() string {
+	split := func(s string) []string {
+		l := strings.SplitAfter(s, "\n")
+		if l[len(l)-1] == "" {
+			l = l[:len(l)-1]
+		}
+		return l
+	}
+	before, after := split(c.Before), split(c.After)
+	// one hunk from the first changed line to the last, as FormatDiff does
+	n := min(len(before), len(after))
+	pre := 0
+	for pre < n && before[pre] == after[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < n-pre && before[len(before)-1-suf] == after[len(after)-1-suf] {
+		suf++
+	}
+	start := max(0, pre-patchContext)
+	end := min(suf, patchContext) // context lines after the change
+
+	name := filepath.ToSlash(tool.ShowPath(c.Path))
+	var sb strings.Builder
+	from := "a/" + name
+	if c.Created {
+		from = "/dev/null"
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ b/%s\n", from, name)
+	oldN := len(before) - suf - start + end
+	newN := len(after) - suf - start + end
+	fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(start, oldN), hunkRange(start, newN))
+	line := func(mark, l string) {
+		sb.WriteString(mark + l)
+		if !strings.HasSuffix(l, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	for _, l := range before[start:pre] {
+		line(" ", l)
+	}
+	for _, l := range before[pre : len(before)-suf] {
+		line("-", l)
+	}
+	for _, l := range after[pre : len(after)-suf] {
+		line("+", l)
+	}
+	for _, l := range before[len(before)-suf : len(before)-suf+end] {
+		line(" ", l)
+	}
+	return sb.String()
+}
+
+// hunkRange is one side of a unified diff hunk header: its first line and
+// line count, where an empty side names the line before it.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// stages reports whether Propose stages calls to the tool name: the file
+// tools that write, and file_read, which reads what they staged.
+func (e *Engine) stages(name string) bool {
+	return e.Propose && (gitWriteTools[name] || name == "file_read")
+}
+
+// refuseUnstageable refuses apply_patch calls while Propose is on: they
+// can't be staged, and nothing may be written before the user's review.
+func (e *Engine) refuseUnstageable(calls []provider.ToolCall, refused []string) {
+	if !e.Propose {
+		return
+	}
+	for i, tc := range calls {
+		if refused[i] == "" && tc.Function.Name == applyPatchTool {
+			refused[i] = "error: file changes are proposed for review in this session, and apply_patch can't be staged; make the changes with file_patch, file_edit or file_write instead"
+		}
+	}
+}
+
+// stageCall runs a file tool call against a temp copy of its file, holding
+// the staged version if there is one, and keeps what a write made of it in
+// Pending instead of on disk. A file_read of a file without a staged change
+// just runs.
+func (e *Engine) stageCall(ctx context.Context, name string, args map[string]any) (tool.ToolResult, error) {
+	p, _ := args["path"].(string)
+	abs, err := tool.ResolvePath(p)
+	if err != nil {
+		return tool.ToolResult{}, err
+	}
+	e.stageMu.Lock()
+	var staged *PendingChange
+	if i, ok := e.pendingIndex(abs); ok {
+		c := e.Pending[i]
+		staged = &c
+	}
+	e.stageMu.Unlock()
+	if staged == nil && name == "file_read" {
+		return e.Agent.Registry.ExecuteV2(ctx, name, args)
+	}
+
+	base, exists := "", true
+	if staged != nil {
+		base = staged.After
+	} else if data, err := os.ReadFile(abs); errors.Is(err, os.ErrNotExist) {
+		exists = false
+	} else if err != nil {
+		return tool.ToolResult{}, err
+	} else {
+		base = string(data)
+	}
+	dir, err := os.MkdirTemp("", "gal-staged-*")
+	if err != nil {
+		return tool.ToolResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, filepath.Base(abs))
+	if exists {
+		if err := os.WriteFile(tmp, []byte(base), 0644); err != nil {
+			return tool.ToolResult{}, err
+		}
+	}
+	targs := maps.Clone(args)
+	targs["path"] = tmp
+	res, err := e.Agent.Registry.ExecuteV2(tool.WithoutBackup(ctx), name, targs)
+	// the tool reports on the temp copy; it's about the real file
+	show := tool.ShowPath(abs)
+	res.Text = strings.ReplaceAll(res.Text, tmp, show)
+	if _, ok := res.Meta["path"]; ok {
+		res.Meta["path"] = show
+	}
+	res.Change = nil
+	if err != nil {
+		return res, errors.New(strings.ReplaceAll(err.Error(), tmp, show))
+	}
+	if name == "file_read" {
+		return res, nil
+	}
+	after, err := os.ReadFile(tmp)
+	if err != nil {
+		return res, nil // nothing written
+	}
+
+	e.stageMu.Lock()
+	defer e.stageMu.Unlock()
+	switch i, ok := e.pendingIndex(abs); {
+	case !ok:
+		e.Pending = slices.Insert(e.Pending, i, PendingChange{Path: abs, Before: base, After: string(after), Created: !exists})
+	case !e.Pending[i].Created && e.Pending[i].Before == string(after):
+		e.Pending = slices.Delete(e.Pending, i, i+1) // back to what's on disk
+	default:
+		e.Pending[i].After = string(after)
+	}
+	e.debugLog("STAGED: %s %s", name, abs)
+	res.Text += fmt.Sprintf(stagedNote, show)
+	return res, nil
+}
+
+// pendingIndex finds the change to path in Pending, which is sorted by
+// path, or where it would go.
+func (e *Engine) pendingIndex(path string) (int, bool) {
+	return slices.BinarySearchFunc(e.Pending, path, func(c PendingChange, p string) int { return strings.Compare(c.Path, p) })
+}
+
+// ApplyChanges writes accepted changes to disk, all or none: when a file
+// changed on disk since its change was staged, or a write fails, no file is
+// left changed. Applied changes go to Changes, as calls of the "review"
+// tool. Pending is cleared either way; the caller decided about all of it.
+func (e *Engine) ApplyChanges(accepted []PendingChange) error {
+	e.stageMu.Lock()
+	e.Pending = nil
+	e.stageMu.Unlock()
+	for _, c := range accepted {
+		cur, err := os.ReadFile(c.Path)
+		switch {
+		case c.Created && err == nil:
+			return fmt.Errorf("%s was created since the change was staged; nothing applied", c.Path)
+		case !c.Created && err != nil:
+			return fmt.Errorf("%s: %w; nothing applied", c.Path, err)
+		case !c.Created && string(cur) != c.Before:
+			return fmt.Errorf("%s changed on disk since the change was staged; nothing applied", c.Path)
+		}
+	}
+
+	// write every file next to its target first, then rename them all
+	tmps := make([]string, len(accepted))
+	removeTmps := func() {
+		for _, t := range tmps {
+			if t != "" {
+				os.Remove(t)
+			}
+		}
+	}
+	for i, c := range accepted {
+		mode := os.FileMode(0644)
+		if fi, err := os.Stat(c.Path); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+			removeTmps()
+			return fmt.Errorf("%s: %w; nothing applied", c.Path, err)
+		}
+		f, err := os.CreateTemp(filepath.Dir(c.Path), "."+filepath.Base(c.Path)+".gal-*")
+		if err != nil {
+			removeTmps()
+			return fmt.Errorf("%s: %w; nothing applied", c.Path, err)
+		}
+		tmps[i] = f.Name()
+		_, err = f.WriteString(c.After)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), mode)
+		}
+		if err != nil {
+			removeTmps()
+			return fmt.Errorf("%s: %w; nothing applied", c.Path, err)
+		}
+	}
+	for i, c := range accepted {
+		if err := os.Rename(tmps[i], c.Path); err != nil {
+			// put back the files renamed so far
+			for _, done := range accepted[:i] {
+				if done.Created {
+					os.Remove(done.Path)
+				} else {
+					os.WriteFile(done.Path, []byte(done.Before), 0644)
+				}
+			}
+			removeTmps()
+			return fmt.Errorf("%s: %w; nothing applied", c.Path, err)
+		}
+		tmps[i] = ""
+	}
+	for _, c := range accepted {
+		fc := &tool.FileChange{Path: c.Path, Before: c.Before, After: c.After, Created: c.Created}
+		e.recordChange("review", fmt.Sprintf(`{"path":%q}`, c.Path), tool.ToolResult{Change: fc}, nil)
+	}
+	return nil
+}
+
+// ReviewFeedback tells the model how the user reviewed its staged changes,
+// for a follow-up message: which were applied, applied after the user
+// edited them, or rejected. It returns "" when all were applied as staged,
+// which the model expects.
+func ReviewFeedback(applied, edited, rejected []string) string {
+	if len(edited) == 0 && len(rejected) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("I reviewed your staged file changes.")
+	if len(applied) > 0 {
+		sb.WriteString("\nApplied as you staged them: " + strings.Join(applied, ", "))
+	}
+	if len(edited) > 0 {
+		sb.WriteString("\nApplied after I edited them (read them again before changing them further): " + strings.Join(edited, ", "))
+	}
+	if len(rejected) > 0 {
+		sb.WriteString("\nRejected, not written: " + strings.Join(rejected, ", ") + ". Adjust your approach for these.")
+	}
+	return sb.String()
+}
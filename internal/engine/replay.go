@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// minReplayLen is the shortest repeated ending collapseReplay drops; shorter
+// repeats are too likely to be meant.
+const minReplayLen = 80
+
+// collapseReplay undoes a gateway bug: after a transparent reconnect, some
+// OpenAI-compatible gateways replay the last chunks of the stream, so the
+// answer ends with the same passage twice in a row, or nearly the same when
+// it holds timestamps or counters. It returns text with the second copy
+// dropped and whether it dropped one; with KeepReplays, or when the repeat
+// may be meant, text is returned as it is. The answer was already streamed
+// with the copy: the fix is for what is stored, and OnStatus says so.
+func (e *Engine) collapseReplay(text string) (string, bool) {
+	if e.KeepReplays {
+		return text, false
+	}
+	body := text
+	n := replayLen(body)
+	if n == 0 {
+		// a copy may have lost or gained the line break or space it ended with
+		body = strings.TrimRightFunc(text, unicode.IsSpace)
+		if n = replayLen(body); n == 0 {
+			return text, false
+		}
+	}
+	e.debugLog("REPLAY: the answer ends with a repeated %d-byte passage, likely replayed by the gateway; dropped the copy: %q", n, body[len(body)-n:])
+	if e.OnStatus != nil {
+		e.OnStatus(fmt.Sprintf("the answer ended with a passage twice (%d bytes), likely replayed by the API gateway; the copy was dropped from the conversation (collapse_replays: false keeps it)", n))
+	}
+	return body[:len(body)-n] + text[len(body):], true
+}
+
+// replayLen returns the length of the passage body ends with twice in a
+// row, or 0 when there is none or it looks meant: shorter than minReplayLen,
+// a repeating pattern itself (a rule, "ha ha"), a single line repeated, or
+// rows of a table or list. The copies may differ in their numbers and
+// spacing, as a replay's timestamps and counters do.
+func replayLen(body string) int {
+	norm, pos := normalizeReplay(body)
+	if len(norm) < 2*minReplayLen {
+		return 0
+	}
+	// z[l] of the reversed text is how far its first l bytes repeat at l;
+	// the longest l with z[l] >= l is the longest passage it ends with twice
+	rev := slices.Clone(norm)
+	slices.Reverse(rev)
+	z := zArray(rev)
+	n := 0
+	for l := len(norm) / 2; l >= minReplayLen; l-- {
+		if z[l] >= l {
+			n = l
+			break
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	passage := string(norm[len(norm)-n:])
+	if strings.Contains((passage + passage)[1:2*n-1], passage) {
+		return 0 // periodic
+	}
+	first, second := pos[len(norm)-2*n], pos[len(norm)-n]
+	if lineAligned(body, first, second) {
+		lines := strings.Split(strings.Trim(body[second:], "\n"), "\n")
+		if len(lines) == 1 || allRows(lines) {
+			return 0
+		}
+	}
+	return len(body) - second
+}
+
+// normalizeReplay returns s with each run of digits as one 0 and each run of
+// spaces, tabs and line breaks as one space, and where each of its bytes
+// starts in s.
+func normalizeReplay(s string) (norm []byte, pos []int) {
+	norm, pos = make([]byte, 0, len(s)), make([]int, 0, len(s))
+	var last byte // class of the last byte: '0', ' ' or 0 for any other
+	for i := 0; i < len(s); i++ {
+		c, class := s[i], byte(0)
+		switch {
+		case c >= '0' && c <= '9':
+			c, class = '0', '0'
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			c, class = ' ', ' '
+		}
+		if class != 0 && class == last {
+			continue
+		}
+		norm, pos = append(norm, c), append(pos, i)
+		last = class
+	}
+	return norm, pos
+}
+
+// lineAligned reports whether both copies start at the start of a line.
+func lineAligned(body string, first, second int) bool {
+	startsLine := func(i int) bool { return i == 0 || body[i-1] == '\n' || body[i] == '\n' }
+	return startsLine(first) && startsLine(second)
+}
+
+// allRows reports whether every non-blank line is a table row or a list
+// item, which a table or list may well repeat.
+func allRows(lines []string) bool {
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "|"), strings.HasPrefix(l, "- "), strings.HasPrefix(l, "* "), strings.HasPrefix(l, "+ "):
+		case len(l) > 1 && l[0] >= '0' && l[0] <= '9' && strings.ContainsAny(l[:min(len(l), 4)], ".)"):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// zArray returns the Z-array of s: z[i] is the length of the longest common
+// prefix of s and s[i:] (z[0] is len(s)).
+func zArray(s []byte) []int {
+	z := make([]int, len(s))
+	if len(s) == 0 {
+		return z
+	}
+	z[0] = len(s)
+	for i, l, r := 1, 0, 0; i < len(s); i++ {
+		if i < r {
+			z[i] = min(r-i, z[i-l])
+		}
+		for i+z[i] < len(s) && s[z[i]] == s[i+z[i]] {
+			z[i]++
+		}
+		if i+z[i] > r {
+			l, r = i, i+z[i]
+		}
+	}
+	return z
+}
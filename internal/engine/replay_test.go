@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseReplay(t *testing.T) {
+	// replays repeat whole passages; a single line repeated is taken as meant
+	para := "The build failed because the linker could not find libssl.\nInstall the dev package and run make again.\n"
+	deployed := func(ts string, n string) string {
+		return "Deployed at " + ts + ".\nAll " + n + " services answered their health checks and the rollout is complete.\n"
+	}
+	line := func(ts string) string {
+		return "Deployed at " + ts + ": all services answered their health checks and the rollout is complete.\n"
+	}
+	table := "| name | size |\n|------|------|\n| a.go | 1200 |\n| b.go | 3400 |\n| c.go | 5600 |\n"
+	list := "- read the config file and check its version\n- migrate the sessions to the new directory\n"
+	tests := []struct {
+		name string
+		in   string
+		want string // "" if in stays as it is
+	}{
+		{"an exact replay", "Here is why.\n" + para + para, "Here is why.\n" + para},
+		{"a replay that lost its line break", "Here is why.\n" + para + strings.TrimSuffix(para, "\n"), "Here is why.\n" + strings.TrimSuffix(para, "\n")},
+		{"a replay with other timestamps", "Done.\n" + deployed("12:03:55.120", "3") + deployed("12:03:58.904", "3"), "Done.\n" + deployed("12:03:55.120", "3")},
+		{"a replay with other counters", "Done.\n" + deployed("12:03", "9") + deployed("12:03", "10"), "Done.\n" + deployed("12:03", "9")},
+		{"a replay with other spacing", "Here is why.\n" + para + strings.ReplaceAll(para, ".\n", ".  \n\n"), "Here is why.\n" + para},
+		{"a table repeated", "Before:\n" + table + table, ""},
+		{"a table with other numbers", "Before:\n" + table + strings.ReplaceAll(table, "1200", "1300"), ""},
+		{"a list repeated", "Steps:\n" + list + list, ""},
+		{"a line repeated", "Log:\n" + line("10:00:00") + line("10:00:00"), ""},
+		{"a line repeated with other timestamps", "Log:\n" + line("10:00:00") + line("10:00:05"), ""},
+		{"a rule", strings.Repeat("=", 300), ""},
+		{"a short repeat", "Yes, it is. Yes, it is.", ""},
+		{"no repeat", para + "And then the tests passed on the second run, after the cache was cleared.\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = tt.in
+			}
+			got, ok := (&Engine{}).collapseReplay(tt.in)
+			if got != want || ok != (tt.want != "") {
+				t.Errorf("collapseReplay = %q, %v\nwant %q, %v", got, ok, want, tt.want != "")
+			}
+			// collapse_replays: false keeps every answer as it is
+			if got, ok := (&Engine{KeepReplays: true}).collapseReplay(tt.in); got != tt.in || ok {
+				t.Errorf("with KeepReplays: %q, %v", got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidJSON is returned when a ResponseFormat is set and the answer is
+// still not valid JSON after one retry.
+var ErrInvalidJSON = errors.New("response is not valid JSON")
+
+// jsonNudge asks the model to answer again after invalid JSON.
+const jsonNudge = "Your reply was not valid JSON (%v). Reply again with only the JSON document: no prose, no code fences."
+
+// jsonAnswer returns the JSON document in an answer, without the code fence
+// some models wrap it in, or why it doesn't parse.
+func jsonAnswer(content string) (string, error) {
+	s := strings.TrimSpace(content)
+	if strings.HasPrefix(s, "```") && strings.HasSuffix(s, "```") && len(s) >= 6 {
+		s = strings.TrimSuffix(s, "```")
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = strings.TrimSpace(s[i+1:]) // drop ```json
+		} else {
+			s = strings.TrimSpace(s[3:])
+		}
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// dropJSONNudge removes the invalid answer and the nudge message from the
+// history once the retry came back valid, so later turns don't see them. They
+// are found by content, since compression may have moved them.
+func (e *Engine) dropJSONNudge(nudge string) {
+	for i := len(e.Messages) - 1; i > 0; i-- {
+		m, prev := e.Messages[i], e.Messages[i-1]
+		if m.Role == "user" && m.Content == nudge && prev.Role == "assistant" && len(prev.ToolCalls) == 0 {
+			e.Messages = slices.Delete(e.Messages, i-1, i+1)
+			return
+		}
+	}
+}
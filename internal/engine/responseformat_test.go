@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+func TestJSONRetry(t *testing.T) {
+	quick := provider.MockReply{ToolCalls: []provider.MockToolCall{{Name: "quick"}}}
+	tests := []struct {
+		name    string
+		replies []provider.MockReply
+		want    []string // roles of the history after the turn
+		wantErr error
+	}{
+		{"valid at once", []provider.MockReply{{Content: `{"ok":true}`}}, []string{"system", "user", "assistant"}, nil},
+		{"valid after the retry", []provider.MockReply{{Content: "Sure! ok"}, {Content: `{"ok":true}`}}, []string{"system", "user", "assistant"}, nil},
+		{"a tool round before the retry answers", []provider.MockReply{{Content: "Sure! ok"}, quick, {Content: `{"ok":true}`}}, []string{"system", "user", "assistant", "tool", "assistant"}, nil},
+		{"invalid twice", []provider.MockReply{{Content: "Sure! ok"}, {Content: "still not"}}, []string{"system"}, ErrInvalidJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := tool.NewRegistry()
+			reg.RegisterReadOnlyV2(provider.ToolDef{Name: "quick", Parameters: map[string]any{"type": "object"}}, func(context.Context, map[string]any) (tool.ToolResult, error) {
+				return tool.ToolResult{Text: "done"}, nil
+			})
+			e := newMockEngine(t, reg, tt.replies...)
+			e.ResponseFormat = &provider.ResponseFormat{}
+			err := e.SendWithInteractive(context.Background(), "answer in JSON", nil, nil, nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if got := roles(e.Messages); !slices.Equal(got, tt.want) {
+				t.Errorf("history %v, want %v", got, tt.want)
+			}
+			for _, m := range e.Messages {
+				if m.Role == "assistant" && m.Content != "" && m.Content != `{"ok":true}` {
+					t.Errorf("the history kept the answer %q", m.Content)
+				}
+				if m.Role == "user" && m.Content != "answer in JSON" {
+					t.Errorf("the history kept the message %q", m.Content)
+				}
+			}
+		})
+	}
+}
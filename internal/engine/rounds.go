@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/i18n"
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// defaultMaxRounds is MaxRounds when the agent doesn't set max_rounds.
+const defaultMaxRounds = 50
+
+// RoundLimitNote ends the progress report of a turn that reached MaxRounds.
+const RoundLimitNote = "[stopped at the round limit]"
+
+// ContinueMessage asks the model to go on with a task it stopped at the round
+// limit (/continue).
+const ContinueMessage = "Continue the task from where you stopped, picking up the remaining work from your progress report."
+
+const roundLimitPrompt = "You have used all %d rounds this turn may take. Don't call any more tools. Report your progress to the user: what is done, what is in progress and what remains, so the task can be continued in the next turn."
+
+// RoundLimitError is returned when a turn reached MaxRounds. Unlike other
+// errors it doesn't roll the turn back: the tool work stays in the
+// conversation, followed by the model's progress report, and ContinueMessage
+// resumes the task.
+type RoundLimitError struct {
+	Rounds int
+	Report string // the model's progress report, "" if it gave none
+	Err    error  // why the report round failed, if it did
+}
+
+func (e *RoundLimitError) Error() string {
+	msg := i18n.T("engine.round_limit", e.Rounds)
+	if e.Err != nil {
+		msg = i18n.T("engine.round_limit_no_report", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *RoundLimitError) Unwrap() error { return e.Err }
+
+func (e *Engine) maxRounds() int {
+	if e.MaxRounds <= 0 {
+		return defaultMaxRounds
+	}
+	return e.MaxRounds
+}
+
+// RoundLimited reports whether the last message is the report of a turn
+// stopped at the round limit.
+func (e *Engine) RoundLimited() bool {
+	last := e.Messages[len(e.Messages)-1]
+	return last.Role == "assistant" && strings.HasSuffix(last.Content, RoundLimitNote)
+}
+
+// keepAtRoundLimit ends a turn that reached the round limit: the report, if
+// any, becomes the turn's answer, marked with RoundLimitNote. Without one the
+// request for it is taken back out of the conversation.
+func (e *Engine) keepAtRoundLimit(report string, err error) error {
+	report = strings.TrimSpace(report)
+	if report == "" {
+		if last := e.Messages[len(e.Messages)-1]; last.Role == "user" && strings.HasPrefix(last.Content, fmt.Sprintf(roundLimitPrompt, e.maxRounds())) {
+			e.Messages = e.Messages[:len(e.Messages)-1]
+		}
+		e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", Content: RoundLimitNote}))
+	} else {
+		e.Messages = append(e.Messages, e.stamp(provider.Message{Role: "assistant", Content: report + "\n\n" + RoundLimitNote}))
+	}
+	e.debugLog("ROUND LIMIT KEPT: %d messages, report %d chars", len(e.Messages), len(report))
+	return &RoundLimitError{Rounds: e.maxRounds(), Report: report, Err: err}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+const defaultToolParallelism = 4
+
+// notRunResult is the result of a call that was still queued when the turn
+// was cancelled.
+const notRunResult = "error: not run: the turn was cancelled before this call started"
+
+type toolResult struct {
+	index   int
+	result  string
+	elapsed time.Duration
+	meta    map[string]any // see tool.ToolResult
+}
+
+// scheduleGroups splits a batch of tool calls into phases that run one after
+// another. Within a phase, each group holds call indices that conflict (see
+// tool.Conflicts) and must run in order; different groups may run
+// concurrently. A call conflicting with calls of two groups starts a new
+// phase, as do exclusive calls (bash, unknown mutating tools), which get a
+// phase of their own.
+func (e *Engine) scheduleGroups(toolCalls []provider.ToolCall) [][][]int {
+	var phases [][][]int
+	var groups [][]int
+	var keys [][]string // conflict keys of each group's calls
+	flush := func() {
+		if len(groups) > 0 {
+			phases = append(phases, groups)
+		}
+		groups, keys = nil, nil
+	}
+	for i, tc := range toolCalls {
+		var args map[string]any
+		json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		key := e.Agent.Registry.ConflictKey(tc.Function.Name, args)
+		if key == tool.ExclusiveKey {
+			flush()
+			phases = append(phases, [][]int{{i}})
+			continue
+		}
+		var hit []int // groups key conflicts with
+		for g, ks := range keys {
+			for _, k := range ks {
+				if tool.Conflicts(key, k) {
+					hit = append(hit, g)
+					break
+				}
+			}
+		}
+		switch len(hit) {
+		case 0:
+			groups = append(groups, []int{i})
+			keys = append(keys, []string{key})
+		case 1:
+			groups[hit[0]] = append(groups[hit[0]], i)
+			keys[hit[0]] = append(keys[hit[0]], key)
+		default:
+			flush()
+			groups, keys = [][]int{{i}}, [][]string{{key}}
+		}
+	}
+	flush()
+	return phases
+}
+
+// runToolCalls executes a batch of tool calls, running non-conflicting groups
+// concurrently on at most ToolParallelism workers. Results are indexed by the
+// original call position so callers can append them in order. exec is only
+// called once a call gets a worker, so its onToolCall shows when the call
+// starts rather than when it's queued; calls still queued when ctx is done
+// don't run at all.
+func (e *Engine) runToolCalls(ctx context.Context, toolCalls []provider.ToolCall, exec func(i int) toolResult) []toolResult {
+	results := make([]toolResult, len(toolCalls))
+	workers := e.ToolParallelism
+	if workers <= 0 {
+		workers = defaultToolParallelism
+	}
+	run := func(i int) {
+		if ctx.Err() != nil {
+			results[i] = toolResult{index: i, result: notRunResult}
+			return
+		}
+		results[i] = e.safeExec(i, toolCalls[i].Function.Name, exec)
+	}
+
+	for _, groups := range e.scheduleGroups(toolCalls) {
+		if len(groups) == 1 || workers == 1 {
+			for _, g := range groups {
+				for _, i := range g {
+					run(i)
+				}
+			}
+			continue
+		}
+		e.debugLog("TOOL_SCHEDULE: %d groups, %d workers", len(groups), min(workers, len(groups)))
+		sem := make(chan struct{}, workers)
+		var wg sync.WaitGroup
+		for _, g := range groups {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				for _, i := range g {
+					run(i) // not run: cancelled while queued
+				}
+				continue
+			}
+			wg.Add(1)
+			go func(g []int) {
+				defer func() { <-sem; wg.Done() }()
+				for _, i := range g {
+					run(i)
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+	return results
+}
+
+// safeExec runs one call, turning a panicking tool into an error result so a
+// single misbehaving worker cannot take down the whole batch.
+func (e *Engine) safeExec(i int, name string, exec func(i int) toolResult) (tr toolResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.debugLog("TOOL_PANIC: %s: %v", name, r)
+			tr = toolResult{index: i, result: fmt.Sprintf("error: tool %s panicked: %v", name, r)}
+		}
+	}()
+	return exec(i)
+}
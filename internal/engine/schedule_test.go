@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/agent"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// call is a tool call of name with args.
+func call(name string, args map[string]any) provider.ToolCall {
+	var tc provider.ToolCall
+	tc.Function.Name = name
+	b, _ := json.Marshal(args)
+	tc.Function.Arguments = string(b)
+	return tc
+}
+
+func TestScheduleGroups(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/a.txt"
+	read := call("file_read", map[string]any{"path": file})
+	write := call("file_write", map[string]any{"path": file, "content": "x"})
+	other := call("file_write", map[string]any{"path": dir + "/b.txt", "content": "x"})
+	list := call("file_list", map[string]any{"path": dir})
+	fetch := call("http", map[string]any{"url": "http://example.com"})
+	bash := call("bash", map[string]any{"command": "ls"})
+	tests := []struct {
+		name  string
+		calls []provider.ToolCall
+		want  [][][]int
+	}{
+		{"readers of a path run together", []provider.ToolCall{read, read, list}, [][][]int{{{0}, {1}, {2}}}},
+		{"a read waits for the write before it", []provider.ToolCall{write, read}, [][][]int{{{0, 1}}}},
+		{"a write waits for the read before it", []provider.ToolCall{read, write}, [][][]int{{{0, 1}}}},
+		{"listing a directory waits for a write in it", []provider.ToolCall{other, list, fetch}, [][][]int{{{0, 1}, {2}}}},
+		{"writes to other files run together", []provider.ToolCall{write, other}, [][][]int{{{0}, {1}}}},
+		{"a write after two readers starts a phase", []provider.ToolCall{read, list, write}, [][][]int{{{0}, {1}}, {{2}}}},
+		{"bash runs alone", []provider.ToolCall{read, bash, read}, [][][]int{{{0}}, {{1}}, {{2}}}},
+		{"no path is exclusive", []provider.ToolCall{fetch, call("file_write", nil)}, [][][]int{{{0}}, {{1}}}},
+	}
+	e := &Engine{Agent: &agent.Agent{Registry: tool.NewRegistry()}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.scheduleGroups(tt.calls); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scheduleGroups = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunToolCallsCancel(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/a.txt"
+	fetch := func(url string) provider.ToolCall { return call("http", map[string]any{"url": url}) }
+	tests := []struct {
+		name    string
+		calls   []provider.ToolCall
+		workers int
+		ran     []int // calls that start before the cancel; the rest never do
+	}{
+		{"queued groups", []provider.ToolCall{fetch("http://a"), fetch("http://b"), fetch("http://c"), fetch("http://d"), fetch("http://e")}, 2, []int{0, 1}},
+		{"the rest of a group", []provider.ToolCall{call("file_write", map[string]any{"path": file, "content": "x"}), call("file_read", map[string]any{"path": file}), fetch("http://a")}, 4, []int{0, 2}},
+		{"later phases", []provider.ToolCall{fetch("http://a"), call("bash", map[string]any{"command": "ls"}), fetch("http://b")}, 4, []int{0}},
+		{"one worker", []provider.ToolCall{fetch("http://a"), fetch("http://b"), fetch("http://c")}, 1, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Engine{Agent: &agent.Agent{Registry: tool.NewRegistry()}, ToolParallelism: tt.workers}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var mu sync.Mutex
+			var ran []int
+			started := make(chan struct{}, len(tt.calls))
+			go func() {
+				for range tt.ran {
+					<-started
+				}
+				cancel()
+			}()
+			// a slow tool: it runs until the turn is cancelled
+			exec := func(i int) toolResult {
+				mu.Lock()
+				ran = append(ran, i)
+				mu.Unlock()
+				started <- struct{}{}
+				<-ctx.Done()
+				return toolResult{index: i, result: "error: cancelled"}
+			}
+			done := make(chan []toolResult)
+			go func() { done <- e.runToolCalls(ctx, tt.calls, exec) }()
+			var results []toolResult
+			select {
+			case results = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("runToolCalls didn't return after the cancel")
+			}
+
+			if slices.Sort(ran); !reflect.DeepEqual(ran, tt.ran) {
+				t.Errorf("ran %v, want %v", ran, tt.ran)
+			}
+			for i, r := range results {
+				want := notRunResult
+				for _, j := range tt.ran {
+					if i == j {
+						want = "error: cancelled"
+					}
+				}
+				if r.result != want || r.index != i {
+					t.Errorf("result %d: %d %q, want %q", i, r.index, r.result, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunToolCallsErrors(t *testing.T) {
+	urls := []string{"http://a", "http://b", "http://c", "http://d", "http://e"}
+	var calls []provider.ToolCall
+	for _, u := range urls {
+		calls = append(calls, call("http", map[string]any{"url": u}))
+	}
+	want := []string{
+		"fetched http://a",
+		"error: http://b: connection refused",
+		"fetched http://c",
+		"error: tool http panicked: lost http://d",
+		"fetched http://e",
+	}
+	e := &Engine{Agent: &agent.Agent{Registry: tool.NewRegistry()}, ToolParallelism: len(calls)}
+	var mu sync.Mutex
+	var finished []int
+	// each call is its own group, and waits for the call after it to finish
+	done := make([]chan struct{}, len(calls)+1)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	close(done[len(calls)])
+	exec := func(i int) toolResult {
+		<-done[i+1]
+		mu.Lock()
+		finished = append(finished, i)
+		mu.Unlock()
+		defer close(done[i])
+		switch i {
+		case 1:
+			return toolResult{index: i, result: "error: " + urls[i] + ": connection refused"}
+		case 3:
+			panic("lost " + urls[i])
+		}
+		return toolResult{index: i, result: "fetched " + urls[i]}
+	}
+	ch := make(chan []toolResult)
+	go func() { ch <- e.runToolCalls(context.Background(), calls, exec) }()
+	var results []toolResult
+	select {
+	case results = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the calls didn't run concurrently")
+	}
+
+	if !reflect.DeepEqual(finished, []int{4, 3, 2, 1, 0}) {
+		t.Fatalf("calls finished in order %v, want the last first", finished)
+	}
+	if len(results) != len(calls) {
+		t.Fatalf("%d results for %d calls", len(results), len(calls))
+	}
+	for i, r := range results {
+		if r.index != i || r.result != want[i] {
+			t.Errorf("result %d: %d %q, want %q", i, r.index, r.result, want[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// SpawnAgentTool is the tool an agent delegates a task to one of its
+// subagents with.
+const SpawnAgentTool = "spawn_agent"
+
+// SubagentBuilder builds a fresh engine for the named agent.
+type SubagentBuilder func(agent string) (*Engine, error)
+
+// defaultSubagentDepth is MaxSubagentDepth when it isn't set.
+const defaultSubagentDepth = 2
+
+// SpawnAgentDef is the spawn_agent tool of an agent whose subagents are
+// agents.
+func SpawnAgentDef(agents []string) provider.ToolDef {
+	return provider.ToolDef{
+		Name: SpawnAgentTool,
+		Description: "Delegate a task to another agent and get its final answer. The agent starts with an empty conversation: " +
+			"the task must say everything it needs to know. Its tool calls are shown to the user; its answer is the result.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"agent": map[string]any{"type": "string", "enum": agents, "description": "The agent to run: " + strings.Join(agents, ", ")},
+				"task":  map[string]any{"type": "string", "description": "The task, with the context and files it needs, and what to answer with"},
+			},
+			"required": []string{"agent", "task"},
+		},
+	}
+}
+
+// spawnAgent runs a spawn_agent call: one turn of a fresh engine for the
+// subagent, whose messages aren't shared with e's. Its tool calls go to
+// onToolCall indented under its name, its tools that change things are
+// approved through e's OnToolApproval, and its usage and changes are
+// counted in e's. It returns the subagent's answer, or the error for the
+// model.
+func (e *Engine) spawnAgent(ctx context.Context, args map[string]any, onToolCall func(string)) string {
+	name, _ := args["agent"].(string)
+	task, _ := args["task"].(string)
+	maxDepth := e.MaxSubagentDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultSubagentDepth
+	}
+	switch {
+	case e.NewSubagent == nil || !slices.Contains(e.Agent.Conf.Subagents, name):
+		return fmt.Sprintf("error: %q isn't one of your subagents (%s)", name, strings.Join(e.Agent.Conf.Subagents, ", "))
+	case e.SubagentDepth >= maxDepth:
+		return fmt.Sprintf("error: agents are already nested %d deep, the limit (subagent_depth); do this task yourself", e.SubagentDepth)
+	case strings.TrimSpace(task) == "":
+		return "error: task is empty"
+	}
+	sub, err := e.NewSubagent(name)
+	if err != nil {
+		return fmt.Sprintf("error: can't start %s: %v", name, err)
+	}
+	defer sub.Agent.Close()
+	sub.SubagentDepth, sub.MaxSubagentDepth = e.SubagentDepth+1, maxDepth
+	sub.OnToolApproval, sub.OnStatus, sub.SessionID = e.OnToolApproval, e.OnStatus, e.SessionID
+	sub.allowed = e.allowed
+	sub.debugOut, sub.logTag = e.debugOut, e.logTag+name+" "
+	sub.sensitiveValues = slices.Clip(e.sensitiveValues)
+
+	e.debugLog("SPAWN_AGENT: %s (depth %d) task=%s", name, sub.SubagentDepth, task)
+	err = sub.SendWithCallbacks(ctx, task, nil, func(tool string) {
+		if onToolCall != nil {
+			// a nested subagent's calls come indented already
+			trimmed := strings.TrimLeft(tool, " ")
+			onToolCall("  " + tool[:len(tool)-len(trimmed)] + name + " › " + trimmed)
+		}
+	}, nil)
+	e.allowed = sub.allowed // "always allow" answers given for the subagent hold here too
+	e.AddForkUsage(sub)
+	for _, c := range sub.Changes {
+		c.Seq = len(e.Changes) + 1
+		c.Tool = name + " › " + c.Tool
+		e.Changes = append(e.Changes, c)
+	}
+	if err != nil {
+		return fmt.Sprintf("error: %s failed: %v", name, err)
+	}
+	answer := sub.Messages[len(sub.Messages)-1].Content
+	e.debugLog("SPAWN_AGENT: %s answered (%d chars, %s)", name, len(answer), sub.LastTurn.Summary())
+	return answer
+}
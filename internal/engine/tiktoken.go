@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// tiktokenCounter counts with an OpenAI model's own tokenizer, and with the
+// family's heuristic until its encoding is loaded or when it can't be.
+type tiktokenCounter struct {
+	enc      *encoding
+	fallback heuristicCounter
+}
+
+func (c tiktokenCounter) Count(text string) int {
+	if t := c.enc.get(); t != nil {
+		return len(t.EncodeOrdinary(text))
+	}
+	return c.fallback.Count(text)
+}
+
+// encoding is a tiktoken encoding, loaded the first time it's asked for.
+type encoding struct {
+	name string
+	once sync.Once
+	enc  atomic.Pointer[tiktoken.Tiktoken]
+	err  error // why it couldn't be loaded; set before done is closed
+	done chan struct{}
+}
+
+// encodings are the encodings of tokenFamilies by name.
+var encodings = map[string]*encoding{
+	"o200k_base":  {name: "o200k_base", done: make(chan struct{})},
+	"cl100k_base": {name: "cl100k_base", done: make(chan struct{})},
+}
+
+// get returns the encoding, or nil while it loads or if it can't be. The
+// first call starts loading it in the background, so counting never waits
+// for the ranks to be parsed.
+func (e *encoding) get() *tiktoken.Tiktoken {
+	e.once.Do(func() { go e.load() })
+	return e.enc.Load()
+}
+
+// wait loads the encoding if it isn't yet and returns it.
+func (e *encoding) wait() (*tiktoken.Tiktoken, error) {
+	e.get()
+	<-e.done
+	return e.enc.Load(), e.err
+}
+
+func (e *encoding) load() {
+	defer close(e.done)
+	t, err := tiktoken.GetEncoding(e.name)
+	if err != nil {
+		e.err = fmt.Errorf("tokenizer %s: %w", e.name, err)
+		return
+	}
+	e.enc.Store(t)
+}
+
+func init() {
+	// the ranks are built in: counting never goes to the network, and there
+	// is no downloaded file to go stale or corrupt
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
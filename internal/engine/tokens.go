@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// TokenCounter counts the tokens a model's tokenizer makes of text.
+type TokenCounter interface {
+	Count(text string) int
+}
+
+// messageOverhead is what a message costs besides its text: the role and the
+// separators around it.
+const messageOverhead = 4
+
+// tokenCacheMin is the content size from which a message's count is cached;
+// shorter ones are cheaper to count again than to look up.
+const tokenCacheMin = 256
+
+// tokenCacheMax bounds the cache; it starts over when full.
+const tokenCacheMax = 4096
+
+// heuristicCounter approximates a BPE tokenizer the way it splits text: a
+// word of ASCII letters (with the space before it) is a token, or more when
+// it's long; digits go in threes; runs of punctuation pair up; and scripts
+// without spaces cost a family-specific number of tokens per character.
+//
+// Calibrated on known tokenizations, for example with cl100k (GPT-4):
+// "The quick brown fox jumps over the lazy dog." is 10 tokens, "Hello,
+// world!" 4, `func main() {` 4; a Chinese sentence takes about 0.9 tokens per
+// character there, 0.6 with o200k (GPT-4o), and 0.6-0.7 with DeepSeek and
+// Qwen, whose vocabularies are built for it.
+type heuristicCounter struct {
+	cjk   float64 // tokens per Han, kana or Hangul character
+	other float64 // tokens per letter of other scripts (Cyrillic, Greek, accented Latin, ...)
+	scale float64 // tokenizer-wide factor; Claude's makes about 15% more tokens than cl100k
+}
+
+// tokenFamilies picks a counter by model name prefix; the first match wins,
+// so gpt-4o comes before gpt-4. OpenAI's families name their tiktoken
+// encoding, which counts exactly once it is loaded (see tiktoken.go).
+var tokenFamilies = []struct {
+	prefixes []string
+	counter  heuristicCounter
+	encoding string
+}{
+	{[]string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt", "o1", "o3", "o4"}, heuristicCounter{cjk: 0.6, other: 0.3, scale: 1}, "o200k_base"},
+	{[]string{"gpt-4", "gpt-3.5", "text-embedding"}, heuristicCounter{cjk: 0.9, other: 0.4, scale: 1}, "cl100k_base"},
+	{[]string{"claude"}, heuristicCounter{cjk: 1.4, other: 0.45, scale: 1.15}, ""},
+	{[]string{"deepseek", "qwen", "qwq", "glm", "chatglm", "yi-", "moonshot", "kimi", "minimax", "baichuan", "ernie", "doubao"}, heuristicCounter{cjk: 0.65, other: 0.4, scale: 1.05}, ""},
+	{[]string{"gemini", "gemma"}, heuristicCounter{cjk: 0.8, other: 0.3, scale: 1}, ""},
+	{[]string{"llama", "mistral", "mixtral", "codestral"}, heuristicCounter{cjk: 1.1, other: 0.4, scale: 1.05}, ""},
+}
+
+// genericCounter is used for models of no known family; it leans to
+// counting high, so compression starts early rather than late.
+var genericCounter = heuristicCounter{cjk: 1.2, other: 0.45, scale: 1.1}
+
+// CounterFor returns the token counter for a model, given as "model",
+// "provider/model" or a gateway's "vendor/model".
+func CounterFor(model string) TokenCounter {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	model = strings.ToLower(model)
+	for _, f := range tokenFamilies {
+		for _, p := range f.prefixes {
+			if !strings.HasPrefix(model, p) {
+				continue
+			}
+			if f.encoding != "" {
+				return tiktokenCounter{encodings[f.encoding], f.counter}
+			}
+			return f.counter
+		}
+	}
+	return genericCounter
+}
+
+func (c heuristicCounter) Count(text string) int {
+	var n, cjk, other float64
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		j := i + size
+		switch {
+		case isASCIILetter(r):
+			for j < len(text) && isASCIILetter(rune(text[j])) {
+				j++
+			}
+			n += float64(1 + (j-i-1)/8)
+		case r >= '0' && r <= '9':
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			n += float64((j - i + 2) / 3)
+		case r == ' ' && j < len(text) && !isSpace(text[j]):
+			// a single space goes with what follows it
+		case r < utf8.RuneSelf && isSpace(byte(r)):
+			for j < len(text) && isSpace(text[j]) {
+				j++
+			}
+			n += float64(1 + (j-i)/16)
+		case r < utf8.RuneSelf:
+			for j < len(text) && text[j] < utf8.RuneSelf && isASCIIPunct(rune(text[j])) {
+				j++
+			}
+			n += float64((j - i + 1) / 2)
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			other++
+		default: // CJK and other punctuation, symbols, emoji
+			n++
+		}
+		i = j
+	}
+	total := (n+other*c.other)*c.scale + cjk*c.cjk
+	if total > 0 && total < 1 {
+		return 1
+	}
+	return int(total + 0.5)
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
+
+func isASCIIPunct(r rune) bool {
+	return r > ' ' && r < utf8.RuneSelf && !isASCIILetter(r) && !(r >= '0' && r <= '9')
+}
+
+// tokenCounts is the engine's counter for its current model, with the counts
+// of long message contents. The status bar reads the context size while a
+// turn runs, hence the lock.
+type tokenCounts struct {
+	mu      sync.Mutex
+	model   string
+	counter TokenCounter
+	sizes   map[string]int
+}
+
+// countText counts the tokens of a text with the current model's counter.
+func (e *Engine) countText(text string) int {
+	t := e.tokens
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counter == nil || t.model != e.Agent.CurrentModel {
+		t.model, t.counter, t.sizes = e.Agent.CurrentModel, CounterFor(e.ModelID()), map[string]int{}
+	}
+	if len(text) < tokenCacheMin {
+		return t.counter.Count(text)
+	}
+	if n, ok := t.sizes[text]; ok {
+		return n
+	}
+	if len(t.sizes) >= tokenCacheMax {
+		clear(t.sizes)
+	}
+	n := t.counter.Count(text)
+	t.sizes[text] = n
+	return n
+}
+
+// messageTokens estimates what a message takes of the context.
+func (e *Engine) messageTokens(m provider.Message) int {
+	n := messageOverhead + e.countText(m.Content)
+	for _, tc := range m.ToolCalls {
+		n += e.countText(tc.Function.Name) + e.countText(tc.Function.Arguments)
+	}
+	return n
+}
+
+// estimateTokens estimates the tokens of messages with the current model's
+// counter.
+func (e *Engine) estimateTokens(msgs []provider.Message) int {
+	total := 0
+	for _, m := range msgs {
+		total += e.messageTokens(m)
+	}
+	return total
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"math"
+	"os"
+	"testing"
+	"unicode/utf8"
+)
+
+// Samples of the kinds of text conversations hold.
+const (
+	englishSample = "The quick brown fox jumps over the lazy dog."
+	chineseSample = "我们今天讨论一下这个项目的架构设计和实现细节。"
+	codeSample    = "for i := 0; i < len(xs); i++ {\n\tsum += xs[i]\n}"
+)
+
+// fallback returns the heuristic behind c.
+func fallback(c TokenCounter) TokenCounter {
+	if tc, ok := c.(tiktokenCounter); ok {
+		return tc.fallback
+	}
+	return c
+}
+
+func TestHeuristicCounter(t *testing.T) {
+	// English and code by cl100k's known tokenizations; Chinese by
+	// tokens per character, which is what differs most between families.
+	chars := float64(utf8.RuneCountInString(chineseSample))
+	tests := []struct {
+		model    string
+		text     string
+		min, max float64
+	}{
+		{"gpt-4", englishSample, 10, 10},
+		{"gpt-4", "Hello, world!", 4, 4},
+		{"gpt-4", "func main() {", 4, 4},
+		{"gpt-4o", englishSample, 10, 10},
+		{"gpt-4", chineseSample, 0.8 * chars, 1 * chars},
+		{"openai/gpt-4o", chineseSample, 0.5 * chars, 0.7 * chars},
+		{"deepseek-chat", chineseSample, 0.55 * chars, 0.75 * chars},
+		{"qwen-max", chineseSample, 0.55 * chars, 0.75 * chars},
+		{"claude-sonnet-4", englishSample, 11, 13},
+		{"some-new-model", chineseSample, 1.1 * chars, 1.3 * chars},
+	}
+	for _, tt := range tests {
+		got := float64(fallback(CounterFor(tt.model)).Count(tt.text))
+		if got < math.Floor(tt.min) || got > math.Ceil(tt.max) {
+			t.Errorf("%s: %q is %v tokens, want %.0f-%.0f", tt.model, tt.text, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestTiktokenCounter(t *testing.T) {
+	// the ranks are built in: nothing is downloaded or cached
+	cache := t.TempDir()
+	t.Setenv("GAL_CACHE_DIR", cache)
+	for _, name := range []string{"cl100k_base", "o200k_base"} {
+		enc, err := encodings[name].wait()
+		if err != nil {
+			t.Fatalf("no %s: %v", name, err)
+		}
+		c := tiktokenCounter{encodings[name], heuristicCounter{}}
+		for text, want := range map[string]int{englishSample: 10, "Hello, world!": 4, "": 0} {
+			if got := c.Count(text); got != want {
+				t.Errorf("%s: %q is %d tokens, want %d", name, text, got, want)
+			}
+		}
+		// the heuristic stays close to the tokenizer it stands in for
+		h := fallback(CounterFor(map[string]string{"cl100k_base": "gpt-4", "o200k_base": "gpt-4o"}[name]))
+		for _, text := range []string{englishSample, chineseSample, codeSample} {
+			exact, guess := len(enc.EncodeOrdinary(text)), h.Count(text)
+			if math.Abs(float64(guess-exact)) > 0.35*float64(exact) {
+				t.Errorf("%s: the heuristic counts %q as %d tokens, the tokenizer %d", name, text, guess, exact)
+			}
+		}
+	}
+	if entries, _ := os.ReadDir(cache); len(entries) > 0 {
+		t.Errorf("loading the encodings wrote %s to the cache", entries[0].Name())
+	}
+}
+
+func TestTiktokenCounterFallback(t *testing.T) {
+	// an encoding that can't be loaded counts with the heuristic
+	broken := &encoding{name: "no_such_encoding", done: make(chan struct{})}
+	if _, err := broken.wait(); err == nil {
+		t.Fatal("loading an unknown encoding succeeded")
+	}
+	h := heuristicCounter{cjk: 1.3, other: 0.4, scale: 1}
+	c := tiktokenCounter{broken, h}
+	for _, text := range []string{englishSample, chineseSample, codeSample} {
+		if got, want := c.Count(text), h.Count(text); got != want {
+			t.Errorf("%q: %d tokens, want the heuristic's %d", text, got, want)
+		}
+	}
+}
+
+func TestCounterFor(t *testing.T) {
+	for model, encoding := range map[string]string{
+		"gpt-4o-mini":         "o200k_base",
+		"openai/gpt-4.1":      "o200k_base",
+		"o3-mini":             "o200k_base",
+		"gpt-4-turbo":         "cl100k_base",
+		"azure/gpt-3.5-turbo": "cl100k_base",
+		"claude-sonnet-4":     "",
+		"deepseek-chat":       "",
+		"llama3":              "",
+	} {
+		tc, ok := CounterFor(model).(tiktokenCounter)
+		switch {
+		case encoding == "" && ok:
+			t.Errorf("%s counts with %s, want a heuristic", model, tc.enc.name)
+		case encoding != "" && !ok:
+			t.Errorf("%s counts with a heuristic, want %s", model, encoding)
+		case ok && tc.enc.name != encoding:
+			t.Errorf("%s counts with %s, want %s", model, tc.enc.name, encoding)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// argProblems is what is wrong with a tool call's arguments. It is returned
+// to the model as the call's result, so it can fix them in the next round.
+type argProblems struct {
+	Error      string            `json:"error"`
+	Missing    []string          `json:"missing,omitempty"`    // required fields that weren't given
+	Invalid    map[string]string `json:"invalid,omitempty"`    // field: what was expected
+	Unexpected []string          `json:"unexpected,omitempty"` // fields the schema doesn't have (StrictToolArgs)
+}
+
+func (p *argProblems) empty() bool {
+	return p.Error == "" && len(p.Missing) == 0 && len(p.Invalid) == 0 && len(p.Unexpected) == 0
+}
+
+// result renders the problems as the tool result of the refused call.
+func (p *argProblems) result(tool string) string {
+	if p.Error == "" {
+		p.Error = fmt.Sprintf("invalid arguments; %s was not run. Call it again with arguments that match its parameters", tool)
+	}
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// summary is a one-line account of the problems for the debug log.
+func (p *argProblems) summary() string {
+	var parts []string
+	if len(p.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(p.Missing, ", "))
+	}
+	for _, f := range sortedKeys(p.Invalid) {
+		parts = append(parts, f+": "+p.Invalid[f])
+	}
+	if len(p.Unexpected) > 0 {
+		parts = append(parts, "unexpected "+strings.Join(p.Unexpected, ", "))
+	}
+	if len(parts) == 0 {
+		return p.Error
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseToolArgs decodes a tool call's arguments and checks them against the
+// tool's parameter schema: required fields, basic types and enums, and with
+// strict also fields the schema doesn't declare. A nil schema only checks
+// that the arguments are a JSON object. Empty arguments are an empty object.
+func parseToolArgs(schema map[string]any, raw string, strict bool) (map[string]any, *argProblems) {
+	if strings.TrimSpace(raw) == "" {
+		raw = "{}"
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, &argProblems{Error: "arguments are not valid JSON: " + err.Error()}
+	}
+	args, ok := v.(map[string]any)
+	if !ok {
+		return nil, &argProblems{Error: "arguments must be a JSON object, got " + jsonType(v)}
+	}
+	if schema == nil {
+		return args, nil
+	}
+	p := &argProblems{}
+	p.check("", schema, args, strict)
+	if p.empty() {
+		return args, nil
+	}
+	return nil, p
+}
+
+// check adds what is wrong with the value v at path to p.
+func (p *argProblems) check(path string, schema map[string]any, v any, strict bool) {
+	if types := schemaTypeNames(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(v, t) }) {
+		p.invalid(path, fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), jsonType(v)))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		var opts []string
+		for _, e := range enum {
+			opts = append(opts, fmt.Sprint(e))
+		}
+		p.invalid(path, "expected one of "+strings.Join(opts, ", "))
+		return
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range requiredFields(schema["required"]) {
+			if x, ok := val[name]; !ok || x == nil {
+				p.Missing = append(p.Missing, joinPath(path, name))
+			}
+		}
+		open := schema["additionalProperties"] == true || props == nil
+		for _, name := range sortedKeys(val) {
+			if ps, ok := props[name].(map[string]any); ok {
+				if val[name] != nil {
+					p.check(joinPath(path, name), ps, val[name], strict)
+				}
+			} else if strict && !open {
+				p.Unexpected = append(p.Unexpected, joinPath(path, name))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, x := range val {
+				p.check(fmt.Sprintf("%s[%d]", path, i), items, x, strict)
+			}
+		}
+	}
+}
+
+func (p *argProblems) invalid(path, msg string) {
+	if path == "" {
+		path = "arguments"
+	}
+	if p.Invalid == nil {
+		p.Invalid = map[string]string{}
+	}
+	p.Invalid[path] = msg
+}
+
+// hasType reports whether v is of JSON schema type t. Numbers in strings
+// pass as numbers: the tools convert them, and models often quote them.
+func hasType(v any, t string) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "null":
+		return v == nil
+	case "number", "integer":
+		f, ok := v.(float64)
+		if s, isStr := v.(string); isStr {
+			var err error
+			f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			ok = err == nil
+		}
+		return ok && (t == "number" || f == math.Trunc(f))
+	}
+	return true // a type this check doesn't know
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if x == math.Trunc(x) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// schemaTypeNames reads a schema's type, a name or a list of names.
+func schemaTypeNames(t any) []string {
+	switch x := t.(type) {
+	case string:
+		return []string{x}
+	case []any:
+		var names []string
+		for _, n := range x {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	case []string:
+		return x
+	}
+	return nil
+}
+
+// requiredFields reads a schema's required list: []string in built-in
+// tools, []any when it came from JSON or YAML.
+func requiredFields(r any) []string {
+	switch x := r.(type) {
+	case []string:
+		return x
+	case []any:
+		var names []string
+		for _, n := range x {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// toolSchema returns the parameter schema of one of the agent's tools, or
+// nil when it has none or the tool is unknown.
+func (e *Engine) toolSchema(name string) map[string]any {
+	for _, d := range e.Agent.ToolDefs {
+		if d.Name == name {
+			return d.Parameters
+		}
+	}
+	return nil
+}
+
+// checkToolCalls parses the arguments of a round's tool calls before any of
+// them runs. It returns the arguments of each call, and for calls whose
+// arguments are invalid the result to give the model instead of running it.
+// The interactive call at index skip was already answered by the user.
+func (e *Engine) checkToolCalls(calls []provider.ToolCall, skip int) (args []map[string]any, refused []string) {
+	args = make([]map[string]any, len(calls))
+	refused = make([]string, len(calls))
+	for i, tc := range calls {
+		if i == skip {
+			continue
+		}
+		a, bad := parseToolArgs(e.toolSchema(tc.Function.Name), tc.Function.Arguments, e.StrictToolArgs)
+		if bad == nil {
+			args[i] = a
+			continue
+		}
+		e.invalidToolArgs++
+		e.debugLog("INVALID ARGS #%d: %s: %s (args=%s)", e.invalidToolArgs, tc.Function.Name, bad.summary(), tc.Function.Arguments)
+		refused[i] = bad.result(tc.Function.Name)
+	}
+	return args, refused
+}
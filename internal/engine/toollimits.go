@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Default tool limits, used for providers that don't configure their own.
+// Most OpenAI-compatible APIs reject more than 128 tools, and very large
+// tool schemas tend to get truncated or rejected with an opaque 400.
+const (
+	defaultMaxTools     = 128
+	defaultMaxToolBytes = 100 * 1024
+)
+
+// ToolLimit caps the tool definitions sent to one provider. Zero means default.
+type ToolLimit struct {
+	MaxTools int
+	MaxBytes int
+}
+
+func (l ToolLimit) withDefaults() ToolLimit {
+	if l.MaxTools <= 0 {
+		l.MaxTools = defaultMaxTools
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = defaultMaxToolBytes
+	}
+	return l
+}
+
+func (e *Engine) providerName() string {
+	if i := strings.Index(e.Agent.CurrentModel, "/"); i >= 0 {
+		return e.Agent.CurrentModel[:i]
+	}
+	return ""
+}
+
+func toolDefsSize(defs []provider.ToolDef) int {
+	b, _ := json.Marshal(defs)
+	return len(b)
+}
+
+// CheckToolLimits compares the agent's tool definitions against the current
+// provider's limits and reports through OnStatus and the debug log when they
+// are exceeded. It only reports again after the provider or tool set changes.
+func (e *Engine) CheckToolLimits() {
+	e.activeToolDefs()
+}
+
+// activeToolDefs returns the tool definitions to send this round: none when
+// the model lacks tool support. When the limits are exceeded and TrimTools
+// is set, the least-recently-used MCP tools are dropped until the rest fits;
+// built-in and skill tools are always kept.
+func (e *Engine) activeToolDefs() []provider.ToolDef {
+	if e.ToolsOff() {
+		return nil
+	}
+	defs := e.Agent.ToolDefs
+	limit := e.ToolLimits[e.providerName()].withDefaults()
+	size := toolDefsSize(defs)
+	if len(defs) <= limit.MaxTools && size <= limit.MaxBytes {
+		return defs
+	}
+
+	if e.TrimTools {
+		defs = e.trimTools(defs, limit)
+	}
+
+	sig := fmt.Sprintf("%s/%d/%d/%d", e.providerName(), len(e.Agent.ToolDefs), size, len(defs))
+	if sig == e.toolLimitSig {
+		return defs
+	}
+	e.toolLimitSig = sig
+
+	msg := fmt.Sprintf("%d tool definitions (%d KB) exceed the %s limit of %d tools / %d KB",
+		len(e.Agent.ToolDefs), size/1024, e.providerName(), limit.MaxTools, limit.MaxBytes/1024)
+	if len(defs) < len(e.Agent.ToolDefs) {
+		msg += fmt.Sprintf("; trimmed %d MCP tools", len(e.Agent.ToolDefs)-len(defs))
+		if len(defs) > limit.MaxTools || toolDefsSize(defs) > limit.MaxBytes {
+			msg += ", still over the limit"
+		}
+	} else {
+		msg += "; the provider may truncate or reject the request (try --trim-tools)"
+	}
+	msg += "; largest: " + strings.Join(largestTools(e.Agent.ToolDefs, 3), ", ")
+	e.debugLog("TOOL_LIMITS: %s", msg)
+	if e.OnStatus != nil {
+		e.OnStatus(msg)
+	}
+	return defs
+}
+
+// trimTools drops MCP tools, least recently used first, until defs fit limit.
+func (e *Engine) trimTools(defs []provider.ToolDef, limit ToolLimit) []provider.ToolDef {
+	var candidates []string
+	for _, d := range defs {
+		if e.Agent.IsMCPTool(d.Name) {
+			candidates = append(candidates, d.Name)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return e.toolLastUsed[candidates[i]] < e.toolLastUsed[candidates[j]]
+	})
+
+	drop := make(map[string]bool)
+	count, size := len(defs), toolDefsSize(defs)
+	sizes := make(map[string]int, len(defs))
+	for _, d := range defs {
+		sizes[d.Name] = toolDefsSize([]provider.ToolDef{d})
+	}
+	for _, name := range candidates {
+		if count <= limit.MaxTools && size <= limit.MaxBytes {
+			break
+		}
+		drop[name] = true
+		count--
+		size -= sizes[name]
+	}
+
+	kept := make([]provider.ToolDef, 0, count)
+	for _, d := range defs {
+		if !drop[d.Name] {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// markToolUsed records a call for least-recently-used trimming.
+func (e *Engine) markToolUsed(name string) {
+	if e.toolLastUsed == nil {
+		e.toolLastUsed = make(map[string]int)
+	}
+	e.toolUseSeq++
+	e.toolLastUsed[name] = e.toolUseSeq
+}
+
+// largestTools returns the n biggest tool definitions as "name (size)".
+func largestTools(defs []provider.ToolDef, n int) []string {
+	type sized struct {
+		name string
+		size int
+	}
+	var ss []sized
+	for _, d := range defs {
+		ss = append(ss, sized{d.Name, toolDefsSize([]provider.ToolDef{d})})
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].size > ss[j].size })
+	var out []string
+	for i := 0; i < n && i < len(ss); i++ {
+		out = append(out, fmt.Sprintf("%s (%.1f KB)", ss[i].name, float64(ss[i].size)/1024))
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// missingResultNote is the result of a tool call whose result isn't in the
+// conversation, so the request stays valid.
+const missingResultNote = "error: no result was recorded for this call"
+
+// pairToolResults checks that every tool call in msgs has exactly one result
+// right after its assistant message, which the APIs insist on, and returns
+// a repaired copy if not, with notes on what was fixed: results without a
+// call or answering a call twice are dropped, calls without one get
+// missingResultNote, and calls sharing an ID get their own, as do their
+// results in order. Left as they are, such conversations fail every
+// request with "tool_call_id not found" or the like. msgs is returned
+// unchanged when it is fine.
+func pairToolResults(msgs []provider.Message) ([]provider.Message, []string) {
+	var out []provider.Message
+	var notes []string
+	for i := 0; i < len(msgs); i++ {
+		m := msgs[i]
+		if m.Role == "tool" {
+			notes = append(notes, fmt.Sprintf("dropped result %q without a call", m.ToolCallID))
+			continue
+		}
+		out = append(out, m)
+		if m.Role != "assistant" || len(m.ToolCalls) == 0 {
+			continue
+		}
+		end := i + 1
+		for end < len(msgs) && msgs[end].Role == "tool" {
+			end++
+		}
+		calls, results, fixed := pairExchange(m.ToolCalls, msgs[i+1:end])
+		notes = append(notes, fixed...)
+		if len(fixed) > 0 {
+			out[len(out)-1].ToolCalls = calls
+		}
+		out = append(out, results...)
+		i = end - 1
+	}
+	if len(notes) == 0 {
+		return msgs, nil
+	}
+	return out, notes
+}
+
+// pairExchange matches one assistant message's calls with the results after
+// it, in the calls' order.
+func pairExchange(calls []provider.ToolCall, results []provider.Message) ([]provider.ToolCall, []provider.Message, []string) {
+	var notes []string
+	calls = slices.Clone(calls)
+	used := make([]bool, len(results))
+	seen := make(map[string]bool, len(calls))
+	paired := make([]provider.Message, 0, len(calls))
+	for i := range calls {
+		id := calls[i].ID
+		// the first unused result for this ID; with a shared ID, the results
+		// go to the calls in order
+		var res *provider.Message
+		for j := range results {
+			if !used[j] && results[j].ToolCallID == id {
+				used[j] = true
+				r := results[j]
+				res = &r
+				break
+			}
+		}
+		if id == "" || seen[id] {
+			newID := fmt.Sprintf("%s_dup%d", id, i)
+			if id == "" {
+				newID = fmt.Sprintf("call_missing_%d", i)
+			}
+			notes = append(notes, fmt.Sprintf("call %s id %q is now %s", calls[i].Function.Name, id, newID))
+			calls[i].ID = newID
+		}
+		seen[id] = true
+		if res == nil {
+			notes = append(notes, fmt.Sprintf("call %s %s had no result", calls[i].Function.Name, calls[i].ID))
+			res = &provider.Message{Role: "tool", Content: missingResultNote}
+		}
+		res.ToolCallID = calls[i].ID
+		paired = append(paired, *res)
+	}
+	for j, r := range results {
+		if !used[j] {
+			notes = append(notes, fmt.Sprintf("dropped result %q without a call", r.ToolCallID))
+		}
+	}
+	return calls, paired, notes
+}
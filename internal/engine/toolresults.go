@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultToolResultTokens is ToolResultTokens when it isn't set.
+const defaultToolResultTokens = 4000
+
+// minCutField is the shortest a JSON string field is cut to; shorter fields
+// are left alone.
+const minCutField = 256
+
+// toolResultLimit is how many tokens of a tool's result the model sees: the
+// registry's limit for the tool, else ToolResultTokens; 0 means all.
+func (e *Engine) toolResultLimit(name string) int {
+	n, ok := e.Agent.Registry.ResultTokens(name)
+	if !ok {
+		n = e.ToolResultTokens
+	}
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		return defaultToolResultTokens
+	}
+	return n
+}
+
+// limitToolResult keeps a tool result within toolResultLimit. A longer one is
+// saved whole to a temp file, whose path is returned, and cut to its start
+// and end around a note saying where the rest is, so the model can file_read
+// the part it needs. A JSON object stays valid JSON: its longest string
+// fields (an HTTP body, say) are cut instead, and "full_output" names the
+// file.
+func (e *Engine) limitToolResult(name, text string) (string, string) {
+	limit := e.toolResultLimit(name)
+	if limit == 0 || len(text) <= limit {
+		return text, ""
+	}
+	tokens := CounterFor(e.ModelID()).Count(text)
+	if tokens <= limit {
+		return text, ""
+	}
+	path, err := saveToolResult(name, text)
+	if err != nil {
+		e.debugLog("TOOL_RESULT: can't save the full %s result: %v", name, err)
+	}
+	// the bytes that make up about limit tokens of this text
+	budget := int(int64(len(text)) * int64(limit) / int64(tokens))
+	if cut, ok := cutJSONFields(text, budget, path); ok {
+		e.debugLog("TOOL_RESULT: %s cut from %d to %d bytes (JSON fields), full result in %s", name, len(text), len(cut), path)
+		return cut, path
+	}
+	cut := headTail(text, budget, cutNote(len(text), budget, path))
+	e.debugLog("TOOL_RESULT: %s cut from %d to %d bytes, full result in %s", name, len(text), len(cut), path)
+	return cut, path
+}
+
+// saveToolResult writes a tool's whole result to a new gal-tool-<name>-*.txt
+// in the temp directory.
+func saveToolResult(name, text string) (string, error) {
+	safe := strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+	f, err := os.CreateTemp("", fmt.Sprintf("gal-tool-%s-%s-*.txt", safe, time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		return "", err
+	}
+	return filepath.Clean(f.Name()), nil
+}
+
+// cutNote says how much of a result was left out and where the rest is.
+func cutNote(size, kept int, path string) string {
+	note := fmt.Sprintf("[truncated %s of %s", formatSize(size-kept), formatSize(size))
+	if path == "" {
+		return note + "]"
+	}
+	return note + "; full output saved to " + path + ", file_read it with offset and limit to see more]"
+}
+
+// cutJSONFields fits a JSON object into about budget bytes by cutting its
+// longest string fields, top level first, then one level down (e.g. a
+// "data" object). It reports false for other JSON and when cutting strings
+// isn't enough.
+func cutJSONFields(text string, budget int, path string) (string, bool) {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") {
+		return "", false
+	}
+	var obj map[string]any
+	if json.Unmarshal([]byte(trimmed), &obj) != nil {
+		return "", false
+	}
+	type field struct {
+		obj map[string]any
+		key string
+		val string
+	}
+	var fields []field
+	collect := func(m map[string]any) {
+		for k, v := range m {
+			if s, ok := v.(string); ok && len(s) > minCutField {
+				fields = append(fields, field{m, k, s})
+			}
+		}
+	}
+	collect(obj)
+	for _, v := range obj {
+		if m, ok := v.(map[string]any); ok {
+			collect(m)
+		}
+	}
+	excess := len(text) - budget
+	for excess > 0 && len(fields) > 0 {
+		longest := 0
+		for i, f := range fields {
+			if len(f.val) > len(fields[longest].val) {
+				longest = i
+			}
+		}
+		f := fields[longest]
+		fields = append(fields[:longest], fields[longest+1:]...)
+		keep := max(len(f.val)-excess, minCutField)
+		f.obj[f.key] = headTail(f.val, keep, cutNote(len(f.val), keep, ""))
+		excess -= len(f.val) - keep
+	}
+	if excess > 0 {
+		return "", false
+	}
+	obj["truncated"] = true
+	if path != "" {
+		obj["full_output"] = path
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(obj) != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// headTail keeps about n bytes of s, a third from the start and the rest
+// from the end, with note in between. It cuts at line breaks when there are
+// some near the cut, and never inside a character.
+func headTail(s string, n int, note string) string {
+	if n >= len(s) {
+		return s
+	}
+	h := n / 3
+	for h > 0 && !utf8.RuneStart(s[h]) {
+		h--
+	}
+	head := s[:h]
+	if i := strings.LastIndexByte(head, '\n'); i > len(head)/2 {
+		head = head[:i]
+	}
+	t := len(s) - (n - len(head))
+	for t < len(s) && !utf8.RuneStart(s[t]) {
+		t++
+	}
+	tail := s[t:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+	return head + "\n" + note + "\n" + tail
+}
+
+// formatSize renders a byte count: 512 B, 3.4 KB, 1.2 MB.
+func formatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
@@ -0,0 +1,14 @@
+package engine
+
+import "github.com/gal-cli/gal-cli/internal/record"
+
+// What the engine records of a session's work, kept in package record so
+// sessions can store it without depending on the engine.
+type (
+	TurnStats  = record.TurnStats
+	Cost       = record.Cost
+	AutoRun    = record.AutoRun
+	Checkpoint = record.Checkpoint
+	Change     = record.Change
+	Archived   = record.Archived
+)
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// RemoveLastTurn takes the last turn out of the conversation: the user's
+// most recent message and everything after it, its answers, tool calls and
+// tool results, as if it had never been sent. It returns the messages
+// removed, oldest first, or nil when only system messages are left. What the
+// turn's tools did, such as file writes, stays done.
+func (e *Engine) RemoveLastTurn() []provider.Message {
+	i := e.lastTurnStart()
+	if i < 0 {
+		return nil
+	}
+	all := e.Messages
+	e.Messages = e.Messages[:i]
+	// the turn before is complete, unless compression cut into it
+	e.cleanIncompleteToolCalls()
+	removed := slices.Clone(all[len(e.Messages):])
+	e.debugLog("UNDO: removed %d messages, %d left", len(removed), len(e.Messages))
+	return removed
+}
+
+// LastUserMessage returns the message the last turn answered, or "" when
+// there is none.
+func (e *Engine) LastUserMessage() string {
+	if i := e.lastTurnStart(); i >= 0 {
+		return e.Messages[i].Content
+	}
+	return ""
+}
+
+// RetryLast answers the last user message again with the current model: it
+// takes the turn out with RemoveLastTurn and sends the message anew. If the
+// new turn fails and is rolled back, the old answer is put back.
+func (e *Engine) RetryLast(ctx context.Context, onText func(string), onToolCall func(string), onToolResult func(string), onInteractive func([]InteractiveInputRequest) (map[string]string, error)) error {
+	removed := e.RemoveLastTurn()
+	if len(removed) == 0 {
+		return errors.New("nothing to retry: no message has been sent")
+	}
+	n := len(e.Messages)
+	e.retrying = true
+	err := e.SendWithInteractive(ctx, removed[0].Content, onText, onToolCall, onToolResult, onInteractive)
+	e.retrying = false
+	if err != nil && len(e.Messages) == n {
+		e.Messages = append(e.Messages, removed...)
+	}
+	return err
+}
+
+// lastTurnStart returns the index of the user message the last turn
+// started with, or -1.
+func (e *Engine) lastTurnStart() int {
+	i := len(e.Messages) - 1
+	for i >= 0 && (e.Messages[i].Role != "user" || engineNudge(e.Messages[i].Content)) {
+		i--
+	}
+	return i
+}
+
+// engineNudge reports whether content is a message the engine added to a
+// turn in the user's name: asking for the rest of a cut-off answer, for
+// valid JSON or for a report at the round limit.
+func engineNudge(content string) bool {
+	for _, p := range []string{continueNudge, jsonNudge, roundLimitPrompt} {
+		prefix, _, _ := strings.Cut(p, "%")
+		if strings.HasPrefix(content, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package engine
+
+import "github.com/gal-cli/gal-cli/internal/provider"
+
+// usageBaseline remembers the real prompt size of the last request so the
+// context size can be computed from it instead of estimated.
+type usageBaseline struct {
+	tokens   int // prompt tokens reported by the API
+	messages int // number of messages that prompt contained
+	estimate int // their estimated size, to detect that they were replaced
+}
+
+// recordUsage adds a request's usage and cost to the totals and returns the
+// cost. sent is the number of messages in that request.
+func (e *Engine) recordUsage(u provider.Usage, sent int) Cost {
+	e.Usage.Add(u)
+	e.recordTokens(e.Agent.CurrentModel, u)
+	if u.PromptTokens > 0 {
+		e.usageBase = usageBaseline{tokens: u.PromptTokens, messages: sent, estimate: e.estimateTokens(e.Messages[:sent])}
+	}
+	c := e.usageCost(u)
+	e.Cost.Add(c)
+	return c
+}
+
+// contextTokens returns the size of the conversation: the last reported
+// prompt size plus an estimate for the messages added since, or a plain
+// estimate when there is no usage data or the messages have been replaced
+// (compression, /clear, session switch).
+func (e *Engine) contextTokens() int {
+	b := e.usageBase
+	if b.tokens > 0 && b.messages <= len(e.Messages) && e.estimateTokens(e.Messages[:b.messages]) == b.estimate {
+		return b.tokens + e.estimateTokens(e.Messages[b.messages:])
+	}
+	return e.estimateTokens(e.Messages)
+}
@@ -0,0 +1,117 @@
+// Package i18n translates what gal-cli shows people: command descriptions,
+// /help, status lines and user-facing errors. Debug logs, tool results and
+// anything the model reads stay in English.
+//
+// Catalogs are locales/<lang>.yaml, flat maps from key to a fmt format.
+// English is the reference: a key missing from another catalog falls back
+// to it.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed locales/*.yaml
+var locales embed.FS
+
+// catalogs by language, loaded at init.
+var catalogs = map[string]map[string]string{}
+
+var current = "en"
+
+func init() {
+	entries, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		data, err := locales.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var c map[string]string
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), ".yaml")] = c
+	}
+	current = envLanguage()
+}
+
+// Languages lists the languages there are catalogs for.
+func Languages() []string {
+	var langs []string
+	for l := range catalogs {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Set selects the language (ui.language). "" takes it from the environment
+// ($LC_ALL, $LC_MESSAGES, $LANG); an unknown language is an error and
+// leaves English.
+func Set(lang string) error {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		current = envLanguage()
+		return nil
+	}
+	if _, ok := catalogs[lang]; !ok {
+		current = "en"
+		return fmt.Errorf("ui.language %q isn't supported (%s); using en", lang, strings.Join(Languages(), ", "))
+	}
+	current = lang
+	return nil
+}
+
+// Lang returns the selected language.
+func Lang() string {
+	return current
+}
+
+// T returns the text for key in the selected language, formatted with args.
+// Keys missing there come from English; unknown keys come back as they are,
+// so a typo shows up instead of an empty string.
+func T(key string, args ...any) string {
+	s, ok := catalogs[current][key]
+	if !ok {
+		if s, ok = catalogs["en"][key]; !ok {
+			s = key
+		}
+	}
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// Has reports whether key is in the English catalog.
+func Has(key string) bool {
+	_, ok := catalogs["en"][key]
+	return ok
+}
+
+// envLanguage picks the catalog matching the locale environment, else en.
+func envLanguage() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		loc := os.Getenv(v)
+		if loc == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(loc), "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		if _, ok := catalogs[lang]; ok {
+			return lang
+		}
+		return "en"
+	}
+	return "en"
+}
@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// verb matches a fmt verb, not %%.
+var verb = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z]`)
+
+func TestCatalogsMatchEnglish(t *testing.T) {
+	en := catalogs["en"]
+	if len(en) == 0 {
+		t.Fatal("no English catalog")
+	}
+	for _, lang := range Languages() {
+		if lang == "en" {
+			continue
+		}
+		c := catalogs[lang]
+		var missing, extra []string
+		for key := range en {
+			if _, ok := c[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		for key, s := range c {
+			e, ok := en[key]
+			if !ok {
+				extra = append(extra, key)
+				continue
+			}
+			if got, want := len(verb.FindAllString(s, -1)), len(verb.FindAllString(e, -1)); got != want {
+				t.Errorf("%s: %s has %d format verbs, en has %d", lang, key, got, want)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(extra)
+		if len(missing) > 0 {
+			t.Errorf("%s lacks %v", lang, missing)
+		}
+		if len(extra) > 0 {
+			t.Errorf("%s has keys en doesn't: %v", lang, extra)
+		}
+	}
+}
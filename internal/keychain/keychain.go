@@ -0,0 +1,113 @@
+// Package keychain keeps secrets such as API keys in the system keychain:
+// the macOS Keychain through security(1), elsewhere the Secret Service
+// (GNOME Keyring, KWallet) through secret-tool(1). gal.yaml refers to them as
+// ${keychain:<name>}.
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// service is what gal-cli's secrets are stored under.
+const service = "gal-cli"
+
+// ErrUnavailable is returned when there is no keychain to use.
+var ErrUnavailable = errors.New("no system keychain: it needs the macOS Keychain or secret-tool (libsecret)")
+
+var (
+	mu    sync.Mutex
+	cache = map[string]string{} // secrets looked up so far; each Load would otherwise ask again
+)
+
+// Available reports whether there is a keychain command to use. Storing can
+// still fail, e.g. without a running Secret Service.
+func Available() bool {
+	c := command()
+	if c == "" {
+		return false
+	}
+	_, err := exec.LookPath(c)
+	return err == nil
+}
+
+// Get returns the secret stored under name.
+func Get(name string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := cache[name]; ok {
+		return s, nil
+	}
+	if !Available() {
+		return "", ErrUnavailable
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", name, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", name)
+	}
+	out, err := run(cmd, "")
+	if err != nil {
+		return "", fmt.Errorf("keychain: %s: %w", name, err)
+	}
+	s := strings.TrimRight(out, "\r\n")
+	cache[name] = s
+	return s, nil
+}
+
+// Set stores secret under name, replacing what was there. The secret goes to
+// the command on stdin, never in its arguments, where other users' ps could
+// see it.
+func Set(name, secret string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !Available() {
+		return ErrUnavailable
+	}
+	var err error
+	if runtime.GOOS == "darwin" {
+		// security -i reads commands from stdin; quote the secret for its parser
+		quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(secret) + `"`
+		_, err = run(exec.Command("security", "-i"), fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", service, name, quoted))
+	} else {
+		_, err = run(exec.Command("secret-tool", "store", "--label", service+" "+name, "service", service, "account", name), secret)
+	}
+	if err != nil {
+		return fmt.Errorf("keychain: %s: %w", name, err)
+	}
+	cache[name] = secret
+	return nil
+}
+
+// command is the keychain command of this system, or "" without one.
+func command() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "security"
+	case "windows":
+		return ""
+	}
+	return "secret-tool"
+}
+
+// run runs cmd with stdin as its input and returns its output, or an error
+// with what it wrote to stderr.
+func run(cmd *exec.Cmd, stdin string) (string, error) {
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,91 @@
+package mcp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+)
+
+// Signer authenticates a request just before it is sent. It runs again for
+// every retry, so time-based signatures stay fresh.
+type Signer interface {
+	Sign(req *http.Request, body []byte)
+}
+
+// newSigner builds the Signer for a server's auth settings; nil when there
+// are none.
+func newSigner(conf config.MCPAuth) (Signer, error) {
+	switch conf.Type {
+	case "":
+		return nil, nil
+	case "header":
+		if conf.Header == "" {
+			return nil, fmt.Errorf("auth type header needs header")
+		}
+		return headerAuth{name: conf.Header, value: conf.Value}, nil
+	case "hmac":
+		if conf.Secret == "" {
+			return nil, fmt.Errorf("auth type hmac needs secret")
+		}
+		var h func() hash.Hash
+		switch conf.Algo {
+		case "", "sha256":
+			h = sha256.New
+		case "sha512":
+			h = sha512.New
+		case "sha1":
+			h = sha1.New
+		default:
+			return nil, fmt.Errorf("auth algo must be sha256, sha512 or sha1, got %q", conf.Algo)
+		}
+		a := &hmacAuth{secret: []byte(conf.Secret), hash: h, header: conf.Header, tsHeader: conf.TimestampHeader, now: time.Now}
+		if a.header == "" {
+			a.header = "X-Signature"
+		}
+		if a.tsHeader == "" {
+			a.tsHeader = "X-Timestamp"
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("auth type must be header or hmac, got %q", conf.Type)
+}
+
+// headerAuth sets one header to a fixed value.
+type headerAuth struct{ name, value string }
+
+func (a headerAuth) Sign(req *http.Request, _ []byte) {
+	req.Header.Set(a.name, a.value)
+}
+
+// hmacAuth signs "<timestamp>.<body>" with a shared secret. The timestamp
+// (Unix seconds) goes in tsHeader and the lowercase hex signature in header,
+// so the server can reject both tampered and replayed requests.
+type hmacAuth struct {
+	secret           []byte
+	hash             func() hash.Hash
+	header, tsHeader string
+	now              func() time.Time
+}
+
+func (a *hmacAuth) Sign(req *http.Request, body []byte) {
+	ts := strconv.FormatInt(a.now().Unix(), 10)
+	req.Header.Set(a.tsHeader, ts)
+	req.Header.Set(a.header, hmacSignature(a.hash, a.secret, ts, body))
+}
+
+func hmacSignature(h func() hash.Hash, secret []byte, ts string, body []byte) string {
+	mac := hmac.New(h, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
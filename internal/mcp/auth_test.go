@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+)
+
+// The signatures are those of a reference implementation, e.g.
+//
+//	printf '%s' '1700000000.{"jsonrpc":...}' | openssl dgst -sha256 -hmac s3cret
+//
+// or Python's hmac.new(secret, ts + b"." + body, hashlib.sha256).hexdigest().
+func TestHMACSignature(t *testing.T) {
+	listBody := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	tests := []struct {
+		algo, secret, ts, body string
+		want                   string
+	}{
+		{"sha256", "s3cret", "1700000000", listBody, "07b1995b1dc807d2dc1231074485e1d74c37cf42bd80c82741bdd4f9f76f5ce3"},
+		{"", "s3cret", "1700000000", listBody, "07b1995b1dc807d2dc1231074485e1d74c37cf42bd80c82741bdd4f9f76f5ce3"},
+		{"sha256", "Jefe", "1", "what do ya want for nothing?", "a9049465ce89cfb8e9aac62333abda66f80e3403f51f57f6bae3c3a4485eca38"},
+		{"sha256", "s3cret", "1700000000", "", "21948100f1d7a89f3338f6b1106fc4f7a702fbe1493b833a3382f80193bde3fe"},
+		{"sha512", "s3cret", "1700000000", listBody, "10bba0fbf335b8a81ce19bdcb16da61ad2527d0015c07b4efa9c348bff5d1ee86b0320cdba63a53ac6bf1320af5e05cff95c2df51b0ad8e971365f5ce3852207"},
+		{"sha512", "Jefe", "1", "what do ya want for nothing?", "1499f193dd3ed59931f98e16760103e974fa3926d4aa312624d2fff8f5efa66291271288130308fd36e5ead3c34228bcb0a23164d887c08229e00aa2bb4accc2"},
+		{"sha512", "s3cret", "1700000000", "", "2fb90e1f8489765b4222966a0fcf9e0f6aa50dde4e34535ac6fdbffdb3fc6cf85e388e00ad096dc928a46a94a5ac665b8c05ca18657de0619c7dcc2aabfdbf2c"},
+		{"sha1", "s3cret", "1700000000", listBody, "b17eb87b8d7e1c815cbb750ba811441f4c679f3a"},
+		{"sha1", "Jefe", "1", "what do ya want for nothing?", "316f1490d66fa5c50ae97c5746f86d2b36ca973b"},
+		{"sha1", "s3cret", "1700000000", "", "b457810c091209b43b0a9e045ae2d185e05bdfc9"},
+	}
+	for _, tt := range tests {
+		s, err := newSigner(config.MCPAuth{Type: "hmac", Secret: tt.secret, Algo: tt.algo})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := s.(*hmacAuth)
+		sec, _ := strconv.ParseInt(tt.ts, 10, 64)
+		a.now = func() time.Time { return time.Unix(sec, 0) }
+		req, _ := http.NewRequest("POST", "http://mcp.example/", strings.NewReader(tt.body))
+		a.Sign(req, []byte(tt.body))
+		if got := req.Header.Get("X-Timestamp"); got != tt.ts {
+			t.Errorf("%s %q: X-Timestamp %q, want %q", tt.algo, tt.body, got, tt.ts)
+		}
+		if got := req.Header.Get("X-Signature"); got != tt.want {
+			t.Errorf("%s %q: X-Signature %s, want %s", tt.algo, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHMACSignRetry(t *testing.T) {
+	s, err := newSigner(config.MCPAuth{Type: "hmac", Secret: "s3cret", Header: "X-Sig", TimestampHeader: "X-Ts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := s.(*hmacAuth)
+	now := time.Unix(1700000000, 0)
+	a.now = func() time.Time { return now }
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	first, _ := http.NewRequest("POST", "http://mcp.example/", nil)
+	a.Sign(first, body)
+	if got := first.Header.Get("X-Sig"); got != "07b1995b1dc807d2dc1231074485e1d74c37cf42bd80c82741bdd4f9f76f5ce3" {
+		t.Errorf("X-Sig %s", got)
+	}
+	// a retry a few seconds later is signed anew
+	now = now.Add(3 * time.Second)
+	retry, _ := http.NewRequest("POST", "http://mcp.example/", nil)
+	a.Sign(retry, body)
+	if got := retry.Header.Get("X-Ts"); got != "1700000003" {
+		t.Errorf("retry X-Ts %s, want 1700000003", got)
+	}
+	if retry.Header.Get("X-Sig") == first.Header.Get("X-Sig") {
+		t.Error("the retry kept the first signature")
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		conf    config.MCPAuth
+		wantErr string
+	}{
+		{config.MCPAuth{}, ""},
+		{config.MCPAuth{Type: "header", Header: "Authorization", Value: "Bearer x"}, ""},
+		{config.MCPAuth{Type: "header"}, "needs header"},
+		{config.MCPAuth{Type: "hmac", Secret: "s"}, ""},
+		{config.MCPAuth{Type: "hmac"}, "needs secret"},
+		{config.MCPAuth{Type: "hmac", Secret: "s", Algo: "md5"}, "auth algo must be"},
+		{config.MCPAuth{Type: "oauth"}, "auth type must be"},
+	}
+	for _, tt := range tests {
+		_, err := newSigner(tt.conf)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: error %v, want %q", tt.conf, err, tt.wantErr)
+		}
+	}
+	s, _ := newSigner(config.MCPAuth{Type: "header", Header: "Authorization", Value: "Bearer x"})
+	req, _ := http.NewRequest("POST", "http://mcp.example/", nil)
+	s.Sign(req, nil)
+	if got := req.Header.Get("Authorization"); got != "Bearer x" {
+		t.Errorf("header auth set %q", got)
+	}
+}
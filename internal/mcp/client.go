@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,10 +17,18 @@ import (
 type Client struct {
 	url     string
 	headers map[string]string
+	auth    Signer // nil without auth settings
 	id      int
 	http    *http.Client
 }
 
+// Requests answered with 429 or 503, which the server didn't process, are
+// retried up to maxRetries times, waiting Retry-After up to maxRetryWait.
+const (
+	maxRetries   = 2
+	maxRetryWait = 10 * time.Second
+)
+
 type jsonRPCRequest struct {
 	JSONRPC string `json:"jsonrpc"`
 	ID      int    `json:"id"`
@@ -36,16 +45,21 @@ type jsonRPCResponse struct {
 	} `json:"error"`
 }
 
-func NewClient(conf config.MCPConf) *Client {
+func NewClient(conf config.MCPConf) (*Client, error) {
 	timeout := conf.Timeout
 	if timeout <= 0 {
 		timeout = 30
 	}
+	auth, err := newSigner(conf.Auth)
+	if err != nil {
+		return nil, err
+	}
 	return &Client{
 		url:     conf.URL,
 		headers: conf.Headers,
+		auth:    auth,
 		http:    &http.Client{Timeout: time.Duration(timeout) * time.Second},
-	}
+	}, nil
 }
 
 func (c *Client) Initialize() error {
@@ -114,22 +128,39 @@ func (c *Client) call(method string, params any) (json.RawMessage, error) {
 	req := jsonRPCRequest{JSONRPC: "2.0", ID: c.id, Method: method, Params: params}
 	body, _ := json.Marshal(req)
 
-	httpReq, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	for k, v := range c.headers {
-		httpReq.Header.Set(k, v)
-	}
+	var resp *http.Response
+	var respBody []byte
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		for k, v := range c.headers {
+			httpReq.Header.Set(k, v)
+		}
+		if c.auth != nil {
+			c.auth.Sign(httpReq, body)
+		}
 
-	resp, err := c.http.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("mcp request failed: %w", err)
+		resp, err = c.http.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("mcp request failed: %w", err)
+		}
+		respBody, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if attempt == maxRetries || (resp.StatusCode != 429 && resp.StatusCode != 503) {
+			break
+		}
+		wait := time.Second
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(secs) * time.Second
+		}
+		if wait > maxRetryWait {
+			break
+		}
+		time.Sleep(wait)
 	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("mcp HTTP %d: %s", resp.StatusCode, string(respBody))
 	}
@@ -0,0 +1,266 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Prompt is a saved prompt: <config dir>/prompts/<name>.md, with optional
+// YAML frontmatter giving a description and variable defaults:
+//
+//	---
+//	description: Review a diff
+//	vars:
+//	  focus: error handling
+//	---
+//	Review this diff with attention to {{focus}}: {{diff}}
+type Prompt struct {
+	Name        string
+	Description string            `yaml:"description"`
+	Defaults    map[string]string `yaml:"vars"` // variables with a default; others must be given
+	Body        string            `yaml:"-"`
+}
+
+var (
+	validName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+)
+
+// Dir returns the directory prompts are stored in.
+func Dir() string {
+	return filepath.Join(config.GalDir(), "prompts")
+}
+
+// Path returns the file of the prompt name.
+func Path(name string) string {
+	return filepath.Join(Dir(), name+".md")
+}
+
+// CheckName rejects names that can't be a file name in Dir, and list and
+// save, which are /prompt subcommands.
+func CheckName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid prompt name %q (letters, digits, '.', '_' and '-')", name)
+	}
+	if name == "list" || name == "save" {
+		return fmt.Errorf("prompt name %q is reserved", name)
+	}
+	return nil
+}
+
+func Load(name string) (*Prompt, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(Path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("prompt not found: %s", name)
+		}
+		return nil, err
+	}
+	return parse(name, string(data))
+}
+
+// parse splits off the frontmatter, if any, and reads it.
+func parse(name, text string) (*Prompt, error) {
+	p := &Prompt{Name: name, Body: text}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		front, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			front, found = strings.CutSuffix(rest, "\n---")
+		}
+		if found {
+			if err := yaml.Unmarshal([]byte(front), p); err != nil {
+				return nil, fmt.Errorf("prompt %s: frontmatter: %w", name, err)
+			}
+			p.Body = body
+		}
+	}
+	p.Body = strings.TrimSpace(p.Body)
+	return p, nil
+}
+
+// Save stores body as the prompt name, replacing any prompt of that name.
+func Save(name, body string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(Path(name), []byte(strings.TrimSpace(body)+"\n"), 0644)
+}
+
+// Exists reports whether a prompt of that name is saved.
+func Exists(name string) bool {
+	_, err := os.Stat(Path(name))
+	return err == nil
+}
+
+func Remove(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(Path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("prompt not found: %s", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns the saved prompts sorted by name. Prompts that fail to parse
+// are skipped.
+func List() ([]*Prompt, error) {
+	entries, err := os.ReadDir(Dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var prompts []*Prompt
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".md")
+		if e.IsDir() || !ok {
+			continue
+		}
+		p, err := Load(name)
+		if err != nil {
+			continue
+		}
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts, nil
+}
+
+// Summary is the description, or the first line of the body.
+func (p *Prompt) Summary() string {
+	if p.Description != "" {
+		return p.Description
+	}
+	first, _, _ := strings.Cut(p.Body, "\n")
+	return first
+}
+
+// Vars returns the variables the body uses, in order of appearance.
+func (p *Prompt) Vars() []string {
+	var vars []string
+	seen := map[string]bool{}
+	for _, m := range placeholder.FindAllStringSubmatch(p.Body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			vars = append(vars, m[1])
+		}
+	}
+	return vars
+}
+
+// Missing returns the variables that have neither a value in vars nor a
+// default.
+func (p *Prompt) Missing(vars map[string]string) []string {
+	var missing []string
+	for _, v := range p.Vars() {
+		if _, ok := vars[v]; ok {
+			continue
+		}
+		if _, ok := p.Defaults[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// CheckVars rejects values for variables the prompt doesn't use.
+func (p *Prompt) CheckVars(vars map[string]string) error {
+	used := p.Vars()
+	for name := range vars {
+		if !slices.Contains(used, name) {
+			return fmt.Errorf("prompt %s has no variable %s (it has: %s)", p.Name, name, listOrNone(used))
+		}
+	}
+	return nil
+}
+
+// Expand fills in the variables from vars, then the defaults. Variables the
+// prompt doesn't use and ones left without a value are errors.
+func (p *Prompt) Expand(vars map[string]string) (string, error) {
+	if err := p.CheckVars(vars); err != nil {
+		return "", err
+	}
+	if missing := p.Missing(vars); len(missing) > 0 {
+		return "", fmt.Errorf("prompt %s needs %s", p.Name, strings.Join(missing, ", "))
+	}
+	return placeholder.ReplaceAllStringFunc(p.Body, func(s string) string {
+		name := placeholder.FindStringSubmatch(s)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return p.Defaults[name]
+	}), nil
+}
+
+// ParseVars reads space-separated name=value arguments; values with spaces
+// are quoted: focus="error handling".
+func ParseVars(s string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, a := range splitArgs(s) {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected name=value, got %q", a)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// splitArgs splits at spaces outside single or double quotes, dropping the
+// quotes.
+func splitArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
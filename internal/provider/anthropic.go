@@ -1,37 +1,72 @@
 package provider
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 type Anthropic struct {
-	APIKey  string
-	BaseURL string
-	Timeout time.Duration
-	Retries int
-	Debug   DebugFunc
+	APIKey      string
+	BaseURL     string
+	MaxTokens   int               // output limit when the request doesn't set one; default depends on the model
+	PromptCache bool              // mark the system prompt and tool definitions as cacheable
+	NoStream    bool              // one non-streaming request, replayed as deltas
+	Headers     map[string]string // extra request headers; they win over the defaults except Content-Type
+	ModelParams ModelParams       // request body overrides per model
+	Timeout     time.Duration
+	Retries     int
+	Debug       DebugFunc
+	// MaxStreamLine is the longest stream line accepted, in bytes; default
+	// the package's MaxStreamLine.
+	MaxStreamLine int
+	conns         conns // its HTTP connections, kept across requests
 }
 
-func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, onDelta func(StreamDelta)) error {
-	var system string
+// anthropicMaxTokens is the default output limit for a model: the API
+// requires one, and too low a value silently truncates long answers.
+func anthropicMaxTokens(model string) int {
+	switch {
+	case strings.HasPrefix(model, "claude-3-5"):
+		return 8192
+	case strings.HasPrefix(model, "claude-3-"):
+		return 4096
+	default: // claude-3-7 and claude 4 models allow 32k or more
+		return 16384
+	}
+}
+
+// jsonAnswerTool carries the answer when a ResponseFormat is set: Anthropic
+// has no JSON mode, so the model must call this tool and its input, which
+// the API validates against the schema, is streamed back as content.
+const jsonAnswerTool = "json_answer"
+
+func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, opts ChatOptions, onDelta func(StreamDelta)) error {
+	var system []string // agent prompt first, then e.g. a compressed-context summary
 	var msgs []map[string]any
 
 	for _, m := range messages {
 		if m.Role == "system" {
-			system = m.Content
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
 			continue
 		}
 
-		if m.Role == "assistant" && len(m.ToolCalls) > 0 {
-			var content []map[string]any
+		// thinking blocks are only accepted back while thinking is enabled
+		if opts.ThinkingBudget <= 0 {
+			m.Thinking = nil
+		}
+		if m.Role == "assistant" && (len(m.ToolCalls) > 0 || len(m.Thinking) > 0) {
+			content := thinkingContent(m.Thinking)
 			if m.Content != "" {
 				content = append(content, map[string]any{"type": "text", "text": m.Content})
 			}
@@ -73,27 +108,109 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 		}
 	}
 
+	maxTokens := a.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens(model)
+	}
 	body := map[string]any{
 		"model":      model,
-		"max_tokens": 4096,
-		"stream":     true,
+		"max_tokens": maxTokens,
+		"stream":     !a.NoStream,
 		"messages":   msgs,
 	}
-	if system != "" {
-		body["system"] = system
+	if len(system) > 0 && a.PromptCache {
+		// The cache covers everything up to a marked block, in the order
+		// tools, system, messages. Marking the agent prompt (not the summary
+		// after it, which changes on every compression) keeps the prefix
+		// stable; the messages themselves are never marked.
+		blocks := make([]map[string]any, len(system))
+		for i, text := range system {
+			blocks[i] = map[string]any{"type": "text", "text": text}
+		}
+		blocks[0]["cache_control"] = map[string]any{"type": "ephemeral"}
+		body["system"] = blocks
+	} else if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+	if opts.MaxTokens != 0 {
+		body["max_tokens"] = opts.MaxTokens
+	}
+	if opts.Temperature != 0 {
+		body["temperature"] = opts.Temperature
+	}
+	if opts.TopP != 0 {
+		body["top_p"] = opts.TopP
+	}
+	if len(opts.Stop) > 0 {
+		body["stop_sequences"] = opts.Stop
+	}
+	var defs []map[string]any
+	for _, t := range tools {
+		defs = append(defs, map[string]any{
+			"name":         t.Name,
+			"description":  t.Description,
+			"input_schema": t.Parameters,
+		})
 	}
 	if len(tools) > 0 {
-		var defs []map[string]any
-		for _, t := range tools {
-			defs = append(defs, map[string]any{
-				"name":         t.Name,
-				"description":  t.Description,
-				"input_schema": t.Parameters,
-			})
+		switch opts.ToolChoice {
+		case "":
+		case ToolChoiceAuto, ToolChoiceNone:
+			body["tool_choice"] = map[string]any{"type": opts.ToolChoice}
+		case ToolChoiceRequired:
+			body["tool_choice"] = map[string]any{"type": "any"}
+		default:
+			body["tool_choice"] = map[string]any{"type": "tool", "name": opts.ToolChoice}
+		}
+	}
+	if f := opts.ResponseFormat; f != nil {
+		schema := f.Schema
+		if schema == nil {
+			schema = map[string]any{"type": "object"}
+		}
+		defs = append(defs, map[string]any{
+			"name":         jsonAnswerTool,
+			"description":  "Give your final answer. The input is the answer itself.",
+			"input_schema": schema,
+		})
+		// other tools stay usable, but the turn can only end through this one
+		switch {
+		case len(tools) == 0 || opts.ToolChoice == ToolChoiceNone:
+			body["tool_choice"] = map[string]any{"type": "tool", "name": jsonAnswerTool}
+		case !ForcesTool(opts.ToolChoice) || opts.ToolChoice == ToolChoiceRequired:
+			body["tool_choice"] = map[string]any{"type": "any"}
+		}
+	}
+	var betas []string
+	for _, t := range nativeToolsFor("anthropic", opts.NativeTools) {
+		defs = append(defs, maps.Clone(t.def))
+		if t.beta != "" {
+			betas = append(betas, t.beta)
+		}
+	}
+	if len(defs) > 0 {
+		if a.PromptCache {
+			defs[len(defs)-1]["cache_control"] = map[string]any{"type": "ephemeral"}
 		}
 		body["tools"] = defs
 	}
+	if opts.ThinkingBudget > 0 {
+		// Extended thinking needs max_tokens above the budget and rejects a
+		// temperature, top_p below 0.95 and forced tool use.
+		body["thinking"] = map[string]any{"type": "enabled", "budget_tokens": opts.ThinkingBudget}
+		if mt, _ := body["max_tokens"].(int); mt <= opts.ThinkingBudget {
+			body["max_tokens"] = opts.ThinkingBudget + anthropicMaxTokens(model)
+		}
+		delete(body, "temperature")
+		if opts.TopP < 0.95 {
+			delete(body, "top_p")
+		}
+		if tc, ok := body["tool_choice"].(map[string]any); ok && tc["type"] != "auto" && tc["type"] != "none" {
+			body["tool_choice"] = map[string]any{"type": "auto"}
+		}
+	}
 
+	a.ModelParams.apply(body, model, a.Debug)
 	payload, _ := json.Marshal(body)
 	req, err := http.NewRequestWithContext(ctx, "POST", a.BaseURL+"/v1/messages", bytes.NewReader(payload))
 	if err != nil {
@@ -102,32 +219,80 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", a.APIKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := doWithRetry(req, payload, a.Debug, a.Timeout, a.Retries)
-	if err != nil {
-		return err
+	setHeaders(req, a.Headers)
+	if len(betas) > 0 {
+		if h := req.Header.Get("anthropic-beta"); h != "" {
+			betas = append(betas, h) // configured in headers
+		}
+		req.Header.Set("anthropic-beta", strings.Join(betas, ","))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
+	for attempt := 0; ; attempt++ {
+		resp, err := doWithRetry(a.conns.get(), req, payload, a.Debug, a.Timeout, a.Retries, a.APIKey)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != 200 {
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if a.Debug != nil {
+				a.Debug("API ERROR BODY: %s", string(b))
+			}
+			return newError("Anthropic", resp, b)
+		}
+		if a.NoStream {
+			defer resp.Body.Close()
+			return a.readResponse(resp.Body, onDelta)
+		}
+		err = a.readStream(resp.Body, onDelta)
+		resp.Body.Close()
+
+		// An error event before any of the reply was passed on is retried
+		// like the status it stands for.
+		var perr *Error
+		if attempt >= a.Retries || !errors.As(err, &perr) || !perr.Retryable() || perr.Partial > 0 {
+			return err
+		}
+		wait := max(perr.RetryAfter, minRetryWait)
 		if a.Debug != nil {
-			a.Debug("API ERROR BODY: %s", string(b))
+			a.Debug("STREAM RETRY %d/%d: %s, waiting %s then retrying...", attempt+1, a.Retries, perr.Code, wait)
+		}
+		if OnRetry != nil {
+			OnRetry(req.URL.Host, perr.Status)
 		}
-		return fmt.Errorf("Anthropic API error %d: %s", resp.StatusCode, string(b))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		req.Body = io.NopCloser(bytes.NewReader(payload))
 	}
+}
 
-	scanner := bufio.NewScanner(&idleTimeoutReader{r: resp.Body, timeout: 300 * time.Second})
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // up to 1MB lines
+// readStream passes an SSE stream on as deltas. An error event, or a reply
+// stopped as a refusal, is returned as an *Error noting how much of the
+// reply had already been passed on.
+func (a *Anthropic) readStream(body io.ReadCloser, onDelta func(StreamDelta)) error {
+	scanner := newLineReader(&idleTimeoutReader{r: body, timeout: StreamIdleTimeout}, a.MaxStreamLine)
 	var currentToolID, currentToolName, currentToolArgs string
+	var promptTokens, cachedTokens int // from message_start; output tokens come with message_delta
+	inAnswer := false                  // inside the jsonAnswerTool block
+	var thinking *ThinkingBlock        // the thinking block being streamed
+	var text string                    // the text block being streamed, for its citations
+	var citations [][2]string          // its sources, url and title
 	chunkCount := 0
 	hasContent := false
+	streamed := 0 // bytes of text, thinking and tool input passed on
+	var stopReason string
 
 	for scanner.Scan() {
 		line := scanner.Text()
 		if a.Debug != nil {
 			a.Debug("SSE RAW: %s", line)
 		}
+		if line != "" {
+			onDelta(StreamDelta{Ping: true})
+		}
 		if !strings.HasPrefix(line, "data:") {
 			continue
 		}
@@ -140,13 +305,31 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 			Delta struct {
 				Type        string `json:"type"`
 				Text        string `json:"text"`
+				Thinking    string `json:"thinking"`
+				Signature   string `json:"signature"`
 				PartialJSON string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+				Citation    struct {
+					URL   string `json:"url"`
+					Title string `json:"title"`
+				} `json:"citation"`
 			} `json:"delta"`
 			ContentBlock struct {
-				Type string `json:"type"`
-				ID   string `json:"id"`
-				Name string `json:"name"`
+				Type     string          `json:"type"`
+				ID       string          `json:"id"`
+				Name     string          `json:"name"`
+				Thinking string          `json:"thinking"`
+				Data     string          `json:"data"`
+				Content  json.RawMessage `json:"content"` // of a server tool's result
 			} `json:"content_block"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Usage anthropicUsage `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
 		}
 		if err := json.Unmarshal([]byte(data), &event); err != nil {
 			continue
@@ -154,21 +337,94 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 		chunkCount++
 
 		switch event.Type {
+		case "message_start":
+			u := event.Message.Usage
+			promptTokens = u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+			cachedTokens = u.CacheReadInputTokens
+			if a.Debug != nil {
+				a.Debug("USAGE: input=%d cache_creation=%d cache_read=%d", u.InputTokens, u.CacheCreationInputTokens, u.CacheReadInputTokens)
+			}
+		case "error":
+			status := anthropicErrorStatus(event.Error.Type)
+			if a.Debug != nil {
+				a.Debug("STREAM ERROR after %d chunks, %d bytes: %s: %s", chunkCount, streamed, event.Error.Type, event.Error.Message)
+			}
+			return &Error{
+				Provider: "Anthropic",
+				Status:   status,
+				Kind:     classify(status, event.Error.Type, event.Error.Message),
+				Code:     event.Error.Type,
+				Message:  event.Error.Message,
+				Partial:  streamed,
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+			}
+			onDelta(StreamDelta{Stop: event.Delta.StopReason, Usage: &Usage{PromptTokens: promptTokens, CompletionTokens: event.Usage.OutputTokens, CachedTokens: cachedTokens}})
 		case "content_block_start":
-			if event.ContentBlock.Type == "tool_use" {
+			switch event.ContentBlock.Type {
+			case "tool_use":
+				if event.ContentBlock.Name == jsonAnswerTool {
+					inAnswer = true
+					break
+				}
 				currentToolID = event.ContentBlock.ID
 				currentToolName = event.ContentBlock.Name
 				currentToolArgs = ""
+			case "thinking":
+				thinking = &ThinkingBlock{Type: "thinking", Thinking: event.ContentBlock.Thinking}
+				if event.ContentBlock.Thinking != "" {
+					onDelta(StreamDelta{Reasoning: event.ContentBlock.Thinking})
+				}
+			case "redacted_thinking":
+				thinking = &ThinkingBlock{Type: "redacted_thinking", Data: event.ContentBlock.Data}
+			case "text":
+				text, citations = "", nil
+			case "server_tool_use":
+				// a native tool the API runs; its input streams as for a tool_use
+				if a.Debug != nil {
+					a.Debug("SERVER TOOL: %s %s", event.ContentBlock.Name, event.ContentBlock.ID)
+				}
+			default:
+				if out := serverToolResult(event.ContentBlock.Type, event.ContentBlock.Content); out != "" {
+					hasContent = true
+					streamed += len(out)
+					onDelta(StreamDelta{Content: out})
+				}
 			}
 		case "content_block_delta":
+			streamed += len(event.Delta.Text) + len(event.Delta.Thinking) + len(event.Delta.PartialJSON)
 			if event.Delta.Type == "text_delta" {
 				hasContent = true
+				text += event.Delta.Text
 				onDelta(StreamDelta{Content: event.Delta.Text})
-			} else if event.Delta.Type == "input_json_delta" {
+			} else if event.Delta.Type == "citations_delta" {
+				citations = append(citations, [2]string{event.Delta.Citation.URL, event.Delta.Citation.Title})
+			} else if event.Delta.Type == "thinking_delta" {
+				if thinking != nil {
+					thinking.Thinking += event.Delta.Thinking
+				}
+				onDelta(StreamDelta{Reasoning: event.Delta.Thinking})
+			} else if event.Delta.Type == "signature_delta" && thinking != nil {
+				thinking.Signature += event.Delta.Signature
+			} else if event.Delta.Type == "input_json_delta" && inAnswer {
+				hasContent = true
+				onDelta(StreamDelta{Content: event.Delta.PartialJSON})
+			} else if event.Delta.Type == "input_json_delta" && currentToolID != "" {
 				hasContent = true
 				currentToolArgs += event.Delta.PartialJSON
 			}
 		case "content_block_stop":
+			inAnswer = false
+			if links := citationLinks(text, citations); links != "" {
+				onDelta(StreamDelta{Content: links})
+			}
+			text, citations = "", nil
+			if thinking != nil {
+				onDelta(StreamDelta{Thinking: thinking})
+				thinking = nil
+			}
 			if currentToolID != "" {
 				tc := ToolCall{ID: currentToolID, Type: "function"}
 				tc.Function.Name = currentToolName
@@ -180,6 +436,9 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 			if a.Debug != nil {
 				a.Debug("STREAM DONE: %d chunks received", chunkCount)
 			}
+			if stopReason == "refusal" {
+				return &Error{Provider: "Anthropic", Status: 200, Kind: ErrRefused, Code: stopReason, Partial: streamed}
+			}
 			onDelta(StreamDelta{Done: true})
 			return nil
 		}
@@ -198,3 +457,203 @@ func (a *Anthropic) ChatStream(ctx context.Context, model string, messages []Mes
 	}
 	return nil
 }
+
+// anthropicErrorStatus maps the type of an error event sent mid-stream to
+// the HTTP status the API uses for it before a stream starts.
+func anthropicErrorStatus(typ string) int {
+	switch typ {
+	case "invalid_request_error":
+		return 400
+	case "authentication_error":
+		return 401
+	case "permission_error":
+		return 403
+	case "not_found_error":
+		return 404
+	case "request_too_large":
+		return 413
+	case "rate_limit_error":
+		return 429
+	case "overloaded_error":
+		return 529
+	}
+	return 500 // api_error and anything new
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) usage() *Usage {
+	return &Usage{
+		PromptTokens:     u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		CompletionTokens: u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	}
+}
+
+// readResponse replays a non-streaming message as the deltas a stream would
+// have produced.
+func (a *Anthropic) readResponse(body io.Reader, onDelta func(StreamDelta)) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if a.Debug != nil {
+		a.Debug("RESPONSE BODY: %s", string(b))
+	}
+	var r struct {
+		Content []struct {
+			Type      string          `json:"type"`
+			Text      string          `json:"text"`
+			Thinking  string          `json:"thinking"`
+			Signature string          `json:"signature"`
+			Data      string          `json:"data"`
+			ID        string          `json:"id"`
+			Name      string          `json:"name"`
+			Input     json.RawMessage `json:"input"`
+			Content   json.RawMessage `json:"content"` // of a server tool's result
+			Citations []struct {
+				URL   string `json:"url"`
+				Title string `json:"title"`
+			} `json:"citations"`
+		} `json:"content"`
+		StopReason string         `json:"stop_reason"`
+		Usage      anthropicUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("invalid response from Anthropic API: %w", err)
+	}
+	var toolCalls []ToolCall
+	hasContent := false
+	for _, block := range r.Content {
+		switch block.Type {
+		case "thinking":
+			onDelta(StreamDelta{Reasoning: block.Thinking, Thinking: &ThinkingBlock{Type: "thinking", Thinking: block.Thinking, Signature: block.Signature}})
+		case "redacted_thinking":
+			onDelta(StreamDelta{Thinking: &ThinkingBlock{Type: "redacted_thinking", Data: block.Data}})
+		case "text":
+			hasContent = hasContent || block.Text != ""
+			var sources [][2]string
+			for _, c := range block.Citations {
+				sources = append(sources, [2]string{c.URL, c.Title})
+			}
+			onDelta(StreamDelta{Content: block.Text + citationLinks(block.Text, sources)})
+		case "server_tool_use":
+			if a.Debug != nil {
+				a.Debug("SERVER TOOL: %s %s", block.Name, block.ID)
+			}
+		case "tool_use":
+			hasContent = true
+			if block.Name == jsonAnswerTool {
+				onDelta(StreamDelta{Content: string(block.Input)})
+				continue
+			}
+			tc := ToolCall{ID: block.ID, Type: "function"}
+			tc.Function.Name = block.Name
+			tc.Function.Arguments = string(block.Input)
+			toolCalls = append(toolCalls, tc)
+		default:
+			if out := serverToolResult(block.Type, block.Content); out != "" {
+				hasContent = true
+				onDelta(StreamDelta{Content: out})
+			}
+		}
+	}
+	if r.StopReason == "refusal" {
+		onDelta(StreamDelta{Usage: r.Usage.usage()})
+		return &Error{Provider: "Anthropic", Status: 200, Kind: ErrRefused, Code: r.StopReason}
+	}
+	if !hasContent {
+		return fmt.Errorf("empty response from Anthropic API (%d content blocks)", len(r.Content))
+	}
+	onDelta(StreamDelta{Stop: r.StopReason, Usage: r.Usage.usage()})
+	onDelta(StreamDelta{ToolCalls: toolCalls, Done: true})
+	return nil
+}
+
+// serverToolResult renders the result block of a native tool for the
+// answer: code execution output as a code block, and a failure as a note.
+// Search and fetch results aren't repeated; the answer cites what it uses.
+func serverToolResult(typ string, content json.RawMessage) string {
+	if !strings.HasSuffix(typ, "_tool_result") {
+		return ""
+	}
+	var r struct {
+		ErrorCode  string `json:"error_code"`
+		Stdout     string `json:"stdout"`
+		Stderr     string `json:"stderr"`
+		ReturnCode int    `json:"return_code"`
+	}
+	if json.Unmarshal(content, &r) != nil {
+		return "" // a list of search results
+	}
+	tool := strings.TrimSuffix(typ, "_tool_result")
+	if r.ErrorCode != "" {
+		return fmt.Sprintf("\n\n_(%s failed: %s)_\n\n", tool, r.ErrorCode)
+	}
+	if tool != "code_execution" {
+		return ""
+	}
+	out := r.Stdout + r.Stderr
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	note := ""
+	if r.ReturnCode != 0 {
+		note = fmt.Sprintf("_(exit code %d)_\n", r.ReturnCode)
+	}
+	return "\n\n```\n" + out + "```\n" + note + "\n"
+}
+
+// thinkingContent converts kept thinking blocks back to content blocks.
+func thinkingContent(blocks []ThinkingBlock) []map[string]any {
+	var content []map[string]any
+	for _, b := range blocks {
+		if b.Type == "redacted_thinking" {
+			content = append(content, map[string]any{"type": b.Type, "data": b.Data})
+		} else {
+			content = append(content, map[string]any{"type": "thinking", "thinking": b.Thinking, "signature": b.Signature})
+		}
+	}
+	return content
+}
+
+// ListModels returns the model IDs from /v1/models, following its pages.
+func (a *Anthropic) ListModels(ctx context.Context) ([]string, error) {
+	var ids []string
+	after := ""
+	for {
+		u := a.BaseURL + "/v1/models?limit=1000"
+		if after != "" {
+			u += "&after_id=" + url.QueryEscape(after)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-api-key", a.APIKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+		setHeaders(req, a.Headers)
+		var page struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := getJSON(a.conns.get(), req, "Anthropic", a.Debug, a.Timeout, a.Retries, a.APIKey, &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Data {
+			ids = append(ids, m.ID)
+		}
+		if !page.HasMore || page.LastID == "" || page.LastID == after {
+			return ids, nil
+		}
+		after = page.LastID
+	}
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies a failed API response.
+type ErrorKind string
+
+const (
+	ErrRateLimited    ErrorKind = "rate_limited"    // too many requests; retry later
+	ErrQuotaExhausted ErrorKind = "quota_exhausted" // out of credits or quota; retrying won't help
+	ErrOverloaded     ErrorKind = "overloaded"      // provider-side capacity or server errors
+	ErrRefused        ErrorKind = "refused"         // the model declined to continue; retrying won't help
+	ErrAPI            ErrorKind = "api_error"       // anything else (bad request, auth, ...)
+)
+
+// Error is a non-200 API response, classified so callers can show an
+// actionable message and decide whether trying again (or elsewhere) helps.
+type Error struct {
+	Provider   string // "OpenAI", "Anthropic" or "Ollama"
+	Status     int
+	Kind       ErrorKind
+	Code       string        // the API's error code or type, e.g. "insufficient_quota"
+	Message    string        // the API's error message, or the raw body
+	RetryAfter time.Duration // from the Retry-After header, 0 if not given
+	Partial    int           // bytes of the reply streamed before a mid-stream error
+}
+
+func (e *Error) Error() string {
+	msg := e.message()
+	if e.Partial > 0 {
+		msg += fmt.Sprintf(" (mid-stream, after %d bytes of the reply)", e.Partial)
+	}
+	return msg
+}
+
+func (e *Error) message() string {
+	switch e.Kind {
+	case ErrRateLimited:
+		if e.RetryAfter > 0 {
+			return fmt.Sprintf("rate limited by %s — retry in ~%s", e.Provider, e.RetryAfter.Round(time.Second))
+		}
+		return fmt.Sprintf("rate limited by %s — retry in a little while", e.Provider)
+	case ErrQuotaExhausted:
+		return fmt.Sprintf("quota exhausted for this %s key — check billing or use another provider (%s)", e.Provider, e.Message)
+	case ErrOverloaded:
+		return fmt.Sprintf("%s is overloaded (%d) — retry in a minute or switch models", e.Provider, e.Status)
+	case ErrRefused:
+		return fmt.Sprintf("%s declined to continue this reply (stop reason %s) — rephrase the request or switch models", e.Provider, e.Code)
+	}
+	return fmt.Sprintf("%s API error %d: %s", e.Provider, e.Status, e.Message)
+}
+
+// Retryable reports whether the same request may succeed later.
+func (e *Error) Retryable() bool {
+	return e.Kind == ErrRateLimited || e.Kind == ErrOverloaded
+}
+
+// newError classifies an error response from the given provider.
+func newError(provider string, resp *http.Response, body []byte) *Error {
+	e := &Error{
+		Provider:   provider,
+		Status:     resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RetryAfter: retryAfter(resp.Header),
+	}
+	// OpenAI: {"error": {"message", "type", "code"}}
+	// Anthropic: {"type": "error", "error": {"type", "message"}}
+	// Ollama: {"error": "message"}
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		}
+		var msg string
+		if json.Unmarshal(parsed.Error, &detail) == nil {
+			e.Message = detail.Message
+			e.Code = detail.Type
+			if code, ok := detail.Code.(string); ok && code != "" {
+				e.Code = code
+			}
+		} else if json.Unmarshal(parsed.Error, &msg) == nil {
+			e.Message = msg
+		}
+	}
+	e.Kind = classify(e.Status, e.Code, e.Message)
+	return e
+}
+
+func classify(status int, code, message string) ErrorKind {
+	lower := strings.ToLower(message)
+	switch {
+	case code == "insufficient_quota" || code == "billing_error" ||
+		strings.Contains(lower, "credit balance") || strings.Contains(lower, "exceeded your current quota"):
+		return ErrQuotaExhausted
+	case status == 429 || code == "rate_limit_error" || code == "rate_limit_exceeded":
+		return ErrRateLimited
+	case status == 529 || code == "overloaded_error" || status >= 500:
+		return ErrOverloaded
+	}
+	return ErrAPI
+}
+
+// retryAfter reads the Retry-After header (seconds or an HTTP date).
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
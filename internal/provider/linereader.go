@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxStreamLine is the longest stream line accepted unless a provider sets
+// its own. Gateways that batch a whole response into one event send lines
+// of several megabytes.
+const MaxStreamLine = 16 << 20
+
+// lineReader reads the lines of a stream like bufio.Scanner, but without its
+// fixed token size: a line may grow up to max bytes.
+type lineReader struct {
+	r    *bufio.Reader
+	max  int
+	line []byte
+	err  error
+}
+
+// newLineReader reads the lines of r, refusing those over max bytes, or
+// over MaxStreamLine when max isn't positive.
+func newLineReader(r io.Reader, max int) *lineReader {
+	if max <= 0 {
+		max = MaxStreamLine
+	}
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), max: max}
+}
+
+// Scan reads the next line, reporting false at the end of the stream or on
+// an error, which Err then returns.
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	l.line = l.line[:0]
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if len(l.line)+len(chunk) > l.max {
+			limit := fmt.Sprintf("%d-byte", l.max)
+			if l.max%(1<<20) == 0 {
+				limit = fmt.Sprintf("%d MB", l.max>>20)
+			}
+			l.err = fmt.Errorf("stream line over the %s limit (%d bytes read); the server sent a whole response as one event, raise the provider's max_stream_line for it", limit, len(l.line)+len(chunk))
+			return false
+		}
+		l.line = append(l.line, chunk...)
+		switch {
+		case err == nil:
+			l.line = bytes.TrimRight(l.line, "\r\n")
+			return true
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF && len(l.line) > 0:
+			l.err = io.EOF // the last line has no newline
+			l.line = bytes.TrimRight(l.line, "\r")
+			return true
+		default:
+			l.err = err
+			return false
+		}
+	}
+}
+
+// Text returns the last line read, without its line ending.
+func (l *lineReader) Text() string { return string(l.line) }
+
+// Err returns the error that ended the stream, nil at its normal end.
+func (l *lineReader) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+	return l.err
+}
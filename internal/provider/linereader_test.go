@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// longStreams are the streams of each provider whose answer is one event
+// of n bytes of text, as gateways that batch a whole response send it.
+var longStreams = []struct {
+	name  string
+	body  func(text string) string
+	setup func(url string, max int) Provider
+}{
+	{
+		name: "openai",
+		body: func(text string) string {
+			return sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"content": text}}}}) +
+				sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{}, "finish_reason": "stop"}}}) +
+				"data: [DONE]\n\n"
+		},
+		setup: func(url string, max int) Provider { return &OpenAI{BaseURL: url, MaxStreamLine: max} },
+	},
+	{
+		name: "anthropic",
+		body: func(text string) string {
+			return sseData(map[string]any{"type": "message_start", "message": map[string]any{"usage": map[string]any{"input_tokens": 1}}}) +
+				sseData(map[string]any{"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text"}}) +
+				sseData(map[string]any{"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": text}}) +
+				sseData(map[string]any{"type": "content_block_stop", "index": 0}) +
+				sseData(map[string]any{"type": "message_delta", "delta": map[string]any{"stop_reason": "end_turn"}, "usage": map[string]any{"output_tokens": 1}}) +
+				sseData(map[string]any{"type": "message_stop"})
+		},
+		setup: func(url string, max int) Provider { return &Anthropic{BaseURL: url, MaxStreamLine: max} },
+	},
+	{
+		name: "ollama",
+		body: func(text string) string {
+			return jsonLine(map[string]any{"message": map[string]any{"role": "assistant", "content": text}, "done": false}) +
+				jsonLine(map[string]any{"done": true, "done_reason": "stop"})
+		},
+		setup: func(url string, max int) Provider { return &Ollama{BaseURL: url, MaxStreamLine: max} },
+	},
+}
+
+func sseData(v any) string {
+	return "data: " + strings.TrimSuffix(jsonLine(v), "\n") + "\n\n"
+}
+
+func jsonLine(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b) + "\n"
+}
+
+func TestLongStreamLine(t *testing.T) {
+	text := strings.Repeat("0123456789abcdef", 5<<20/16) // 5 MB on one line
+	for _, s := range longStreams {
+		for _, tc := range []struct {
+			max     int
+			wantErr string
+		}{
+			{0, ""}, // MaxStreamLine
+			{8 << 20, ""},
+			{1 << 20, "stream line over the 1 MB limit"},
+			{3000000, "stream line over the 3000000-byte limit"},
+		} {
+			t.Run(fmt.Sprintf("%s/%d", s.name, tc.max), func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/event-stream")
+					fmt.Fprint(w, s.body(text))
+				}))
+				defer srv.Close()
+				var got strings.Builder
+				err := s.setup(srv.URL, tc.max).ChatStream(context.Background(), "m", []Message{{Role: "user", Content: "hi"}}, nil, ChatOptions{}, func(d StreamDelta) {
+					got.WriteString(d.Content)
+				})
+				if tc.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+						t.Fatalf("error %v, want one with %q", err, tc.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Len() != len(text) || got.String() != text {
+					t.Errorf("got %d bytes of the answer, want %d", got.Len(), len(text))
+				}
+			})
+		}
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	tests := []struct {
+		in      string
+		max     int
+		want    []string
+		wantErr bool
+	}{
+		{"a\nb\r\n\nc", 0, []string{"a", "b", "", "c"}, false},
+		{"", 0, nil, false},
+		{strings.Repeat("x", 100) + "\nshort\n", 0, []string{strings.Repeat("x", 100), "short"}, false},
+		{"short\n" + strings.Repeat("x", 100) + "\n", 50, []string{"short"}, true},
+		{strings.Repeat("y", 200000) + "\n", 0, []string{strings.Repeat("y", 200000)}, false}, // over bufio's buffer
+	}
+	for _, tt := range tests {
+		r := newLineReader(strings.NewReader(tt.in), tt.max)
+		var got []string
+		for r.Scan() {
+			got = append(got, r.Text())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("%.20q: lines %.40q, want %.40q", tt.in, got, tt.want)
+		}
+		if (r.Err() != nil) != tt.wantErr {
+			t.Errorf("%.20q: error %v, want error %v", tt.in, r.Err(), tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mock is a scripted provider for offline, deterministic runs (type: mock).
+// Each ChatStream call replays the next scripted reply, streaming its content
+// word by word; once the script is exhausted it echoes the last user message.
+type Mock struct {
+	Replies []MockReply
+
+	mu   sync.Mutex
+	next int
+}
+
+// MockReply is one scripted assistant response.
+type MockReply struct {
+	Content   string         `yaml:"content"`
+	ToolCalls []MockToolCall `yaml:"tool_calls"`
+	Error     string         `yaml:"error"`    // if set, ChatStream fails with this message
+	DelayMs   int            `yaml:"delay_ms"` // wait before replying, to exercise slow models
+	Usage     *Usage         `yaml:"usage"`    // token usage to report, e.g. {prompt_tokens: 1200, completion_tokens: 80}
+}
+
+type MockToolCall struct {
+	Name      string         `yaml:"name"`
+	Arguments map[string]any `yaml:"arguments"`
+}
+
+// LoadMockScript reads a YAML list of replies for the mock provider.
+func LoadMockScript(path string) ([]MockReply, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load mock script: %w", err)
+	}
+	var replies []MockReply
+	if err := yaml.Unmarshal(data, &replies); err != nil {
+		return nil, fmt.Errorf("parse mock script: %w", err)
+	}
+	return replies, nil
+}
+
+func (p *Mock) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, opts ChatOptions, onDelta func(StreamDelta)) error {
+	p.mu.Lock()
+	idx := p.next
+	p.next++
+	p.mu.Unlock()
+
+	var reply MockReply
+	if idx < len(p.Replies) {
+		reply = p.Replies[idx]
+	} else {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				reply.Content = "echo: " + messages[i].Content
+				break
+			}
+		}
+	}
+	if reply.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(reply.DelayMs) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("%s", reply.Error)
+	}
+
+	for _, word := range strings.SplitAfter(reply.Content, " ") {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if word != "" {
+			onDelta(StreamDelta{Content: word})
+		}
+	}
+	var tcs []ToolCall
+	for i, mtc := range reply.ToolCalls {
+		args := []byte("{}")
+		if mtc.Arguments != nil {
+			args, _ = json.Marshal(mtc.Arguments)
+		}
+		tc := ToolCall{ID: fmt.Sprintf("mock_%d_%d", idx, i), Type: "function"}
+		tc.Function.Name = mtc.Name
+		tc.Function.Arguments = string(args)
+		tcs = append(tcs, tc)
+	}
+	onDelta(StreamDelta{ToolCalls: tcs, Usage: reply.Usage, Done: true})
+	return nil
+}
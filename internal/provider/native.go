@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// nativeTool is a tool the provider runs itself, such as Anthropic's web
+// search. The model calls it and gets its results within one response, so
+// gal-cli only asks for it and shows what comes back.
+type nativeTool struct {
+	def  map[string]any // Anthropic: the tools entry
+	beta string         // Anthropic: the anthropic-beta feature it needs
+}
+
+// nativeTools are the tools agents can ask for in native_tools, by
+// "<api>:<name>": anthropic for the Anthropic API, openai for OpenAI and
+// compatible APIs.
+var nativeTools = map[string]nativeTool{
+	"anthropic:web_search":     {def: map[string]any{"type": "web_search_20250305", "name": "web_search"}},
+	"anthropic:web_fetch":      {def: map[string]any{"type": "web_fetch_20250910", "name": "web_fetch"}, beta: "web-fetch-2025-09-10"},
+	"anthropic:code_execution": {def: map[string]any{"type": "code_execution_20250522", "name": "code_execution"}, beta: "code-execution-2025-05-22"},
+	"openai:web_search":        {}, // web_search_options, for the search models
+}
+
+// CheckNativeTool rejects a native_tools entry gal-cli doesn't know.
+func CheckNativeTool(name string) error {
+	if _, ok := nativeTools[name]; !ok {
+		return fmt.Errorf("unknown native tool %q (known: %s)", name, strings.Join(slices.Sorted(maps.Keys(nativeTools)), ", "))
+	}
+	return nil
+}
+
+// NativeToolName returns the name the model calls a native tool by, e.g.
+// web_search for anthropic:web_search.
+func NativeToolName(name string) string {
+	_, tool, _ := strings.Cut(name, ":")
+	return tool
+}
+
+// nativeToolsFor returns the native tools of names that api serves; the
+// others belong to the agent's models of other providers.
+func nativeToolsFor(api string, names []string) []nativeTool {
+	var tools []nativeTool
+	for _, name := range names {
+		if t, ok := nativeTools[name]; ok && strings.HasPrefix(name, api+":") {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// citationLinks renders sources an answer cites as markdown links, each
+// once, leaving out those the text already links to.
+func citationLinks(text string, sources [][2]string) string {
+	var links []string
+	seen := map[string]bool{}
+	for _, s := range sources {
+		url, title := s[0], s[1]
+		if url == "" || seen[url] || strings.Contains(text, "("+url+")") {
+			continue
+		}
+		seen[url] = true
+		if title == "" {
+			title = url
+		}
+		links = append(links, fmt.Sprintf("[%s](%s)", strings.NewReplacer("[", "(", "]", ")").Replace(title), url))
+	}
+	if len(links) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(links, ", ") + ")"
+}
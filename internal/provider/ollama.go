@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ollama speaks Ollama's native /api/chat protocol (JSON lines), which unlike
+// the OpenAI-compatible endpoint honors keep_alive and model options such as
+// num_ctx.
+type Ollama struct {
+	BaseURL     string            // default http://localhost:11434
+	KeepAlive   string            // how long the model stays loaded after a request, e.g. "30m"; "" = server default
+	Options     map[string]any    // model options, e.g. num_ctx, temperature
+	Headers     map[string]string // extra request headers, e.g. for a proxy in front of Ollama
+	ModelParams ModelParams       // request body overrides per model
+	Timeout     time.Duration
+	Retries     int
+	Debug       DebugFunc
+	// MaxStreamLine is the longest stream line accepted, in bytes; default
+	// the package's MaxStreamLine.
+	MaxStreamLine int
+	conns         conns // its HTTP connections, kept across requests
+}
+
+// ollamaCallSeq numbers tool calls, since Ollama doesn't assign IDs.
+var ollamaCallSeq atomic.Int64
+
+func (o *Ollama) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, opts ChatOptions, onDelta func(StreamDelta)) error {
+	toolNames := make(map[string]string) // tool call ID → name, for tool results
+	msgs := make([]map[string]any, 0, len(messages))
+	for _, m := range messages {
+		msg := map[string]any{"role": m.Role, "content": m.Content}
+		if len(m.ToolCalls) > 0 {
+			var tcs []map[string]any
+			for _, tc := range m.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				var args any
+				if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil || args == nil {
+					args = map[string]any{}
+				}
+				tcs = append(tcs, map[string]any{
+					"function": map[string]any{"name": tc.Function.Name, "arguments": args},
+				})
+			}
+			msg["tool_calls"] = tcs
+		}
+		if m.Role == "tool" {
+			if name := toolNames[m.ToolCallID]; name != "" {
+				msg["tool_name"] = name
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+
+	body := map[string]any{
+		"model":    model,
+		"messages": msgs,
+		"stream":   true,
+	}
+	if f := opts.ResponseFormat; f != nil && f.Schema != nil {
+		body["format"] = f.Schema
+	} else if f != nil {
+		body["format"] = "json"
+	}
+	if o.KeepAlive != "" {
+		body["keep_alive"] = o.KeepAlive
+	}
+	// agent parameters override the provider's options
+	options := make(map[string]any, len(o.Options)+4)
+	for k, v := range o.Options {
+		options[k] = v
+	}
+	if opts.Temperature != 0 {
+		options["temperature"] = opts.Temperature
+	}
+	if opts.TopP != 0 {
+		options["top_p"] = opts.TopP
+	}
+	if opts.MaxTokens != 0 {
+		options["num_predict"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if len(options) > 0 {
+		body["options"] = options
+	}
+	// Ollama has no tool_choice; "none" is honored by sending no tools
+	if len(tools) > 0 && opts.ToolChoice != ToolChoiceNone {
+		funcs := make([]map[string]any, len(tools))
+		for i, t := range tools {
+			funcs[i] = map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        t.Name,
+					"description": t.Description,
+					"parameters":  t.Parameters,
+				},
+			}
+		}
+		body["tools"] = funcs
+	}
+
+	base := strings.TrimSuffix(o.BaseURL, "/")
+	if base == "" {
+		base = "http://localhost:11434"
+	}
+	o.ModelParams.apply(body, model, o.Debug)
+	payload, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, o.Headers)
+
+	resp, err := doWithRetry(o.conns.get(), req, payload, o.Debug, o.Timeout, o.Retries, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		if o.Debug != nil {
+			o.Debug("API ERROR BODY: %s", string(b))
+		}
+		return newError("Ollama", resp, b)
+	}
+
+	scanner := newLineReader(&idleTimeoutReader{r: resp.Body, timeout: StreamIdleTimeout}, o.MaxStreamLine)
+	var toolCalls []ToolCall
+	chunkCount := 0
+	hasContent := false
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		onDelta(StreamDelta{Ping: true})
+		chunkCount++
+
+		var chunk struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			Done       bool   `json:"done"`
+			DoneReason string `json:"done_reason"`
+			PromptEval int    `json:"prompt_eval_count"`
+			Eval       int    `json:"eval_count"`
+			Error      string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("Ollama error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			hasContent = true
+			onDelta(StreamDelta{Content: chunk.Message.Content})
+		}
+		// tool calls arrive whole, usually in a single chunk before done
+		for _, tc := range chunk.Message.ToolCalls {
+			hasContent = true
+			call := ToolCall{ID: fmt.Sprintf("ollama_%d", ollamaCallSeq.Add(1)), Type: "function"}
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = string(tc.Function.Arguments)
+			if len(tc.Function.Arguments) == 0 || string(tc.Function.Arguments) == "null" {
+				call.Function.Arguments = "{}"
+			}
+			toolCalls = append(toolCalls, call)
+		}
+
+		if chunk.Done {
+			if o.Debug != nil {
+				o.Debug("STREAM DONE: %d chunks received, reason=%s", chunkCount, chunk.DoneReason)
+			}
+			onDelta(StreamDelta{ToolCalls: toolCalls, Done: true, Stop: normalizeStop(chunk.DoneReason), Usage: &Usage{PromptTokens: chunk.PromptEval, CompletionTokens: chunk.Eval}})
+			return nil
+		}
+	}
+	if o.Debug != nil {
+		o.Debug("STREAM END: scanner finished, %d chunks, hasContent=%v, err=%v", chunkCount, hasContent, scanner.Err())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream read error after %d chunks: %w", chunkCount, err)
+	}
+	if chunkCount > 0 {
+		return fmt.Errorf("stream ended without done after %d chunks (connection may have dropped)", chunkCount)
+	}
+	return fmt.Errorf("empty response from Ollama API")
+}
+
+// ListModels returns the locally installed models (/api/tags).
+func (o *Ollama) ListModels(ctx context.Context) ([]string, error) {
+	base := strings.TrimSuffix(o.BaseURL, "/")
+	if base == "" {
+		base = "http://localhost:11434"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, o.Headers)
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(o.conns.get(), req, "Ollama", o.Debug, o.Timeout, o.Retries, "", &resp); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		ids = append(ids, m.Name)
+	}
+	return ids, nil
+}
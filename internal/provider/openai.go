@@ -1,13 +1,13 @@
 package provider
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -18,6 +18,48 @@ type OpenAI struct {
 	Timeout time.Duration
 	Retries int
 	Debug   DebugFunc
+	Headers map[string]string // extra request headers; they win over the defaults except Content-Type
+	// ModelParams are request body overrides per model, e.g. reasoning_effort
+	// for o-series models, or a nil temperature to leave it out.
+	ModelParams ModelParams
+
+	// Azure OpenAI: a non-empty APIVersion switches to deployment-scoped
+	// URLs and the api-key header. Deployment defaults to the model name.
+	APIVersion string
+	Deployment string
+
+	// NoStreamUsage leaves out stream_options for servers that reject it.
+	NoStreamUsage bool
+	// NoStream makes one non-streaming request for gateways without SSE;
+	// the complete response is replayed as deltas.
+	NoStream bool
+	// MaxStreamLine is the longest stream line accepted, in bytes; default
+	// the package's MaxStreamLine.
+	MaxStreamLine int
+
+	conns conns // its HTTP connections, kept across requests
+}
+
+// endpoint returns the chat completions URL for model.
+func (o *OpenAI) endpoint(model string) string {
+	if o.APIVersion == "" {
+		return o.BaseURL + "/chat/completions"
+	}
+	deployment := o.Deployment
+	if deployment == "" {
+		deployment = model
+	}
+	return strings.TrimRight(o.BaseURL, "/") + "/openai/deployments/" + url.PathEscape(deployment) +
+		"/chat/completions?api-version=" + url.QueryEscape(o.APIVersion)
+}
+
+func mentionsJSON(messages []Message) bool {
+	for _, m := range messages {
+		if strings.Contains(strings.ToLower(m.Content), "json") {
+			return true
+		}
+	}
+	return false
 }
 
 // idleTimeoutReader wraps a reader and returns an error if no data is read within the timeout.
@@ -61,7 +103,7 @@ func (itr *idleTimeoutReader) Read(p []byte) (int, error) {
 	}
 }
 
-func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, onDelta func(StreamDelta)) error {
+func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, opts ChatOptions, onDelta func(StreamDelta)) error {
 	// Convert messages to map format, ensuring content is omitted when empty and tool_calls present
 	msgs := make([]map[string]any, len(messages))
 	for i, m := range messages {
@@ -81,7 +123,37 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 	body := map[string]any{
 		"model":    model,
 		"messages": msgs,
-		"stream":   true,
+		"stream":   !o.NoStream,
+	}
+	if opts.Temperature != 0 {
+		body["temperature"] = opts.Temperature
+	}
+	if opts.TopP != 0 {
+		body["top_p"] = opts.TopP
+	}
+	if opts.MaxTokens != 0 {
+		body["max_tokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		body["stop"] = opts.Stop
+	}
+	if f := opts.ResponseFormat; f != nil && f.Schema != nil {
+		body["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": f.name(), "schema": f.Schema},
+		}
+	} else if f != nil {
+		body["response_format"] = map[string]any{"type": "json_object"}
+		// the API rejects json_object unless the messages mention JSON
+		if !mentionsJSON(messages) {
+			body["messages"] = append(msgs, map[string]any{"role": "system", "content": "Answer with a JSON object."})
+		}
+	}
+	if len(nativeToolsFor("openai", opts.NativeTools)) > 0 {
+		body["web_search_options"] = map[string]any{} // the only one so far
+	}
+	if !o.NoStreamUsage && !o.NoStream {
+		body["stream_options"] = map[string]any{"include_usage": true}
 	}
 	if len(tools) > 0 {
 		funcs := make([]map[string]any, len(tools))
@@ -96,19 +168,30 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 			}
 		}
 		body["tools"] = funcs
+		switch opts.ToolChoice {
+		case "":
+		case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+			body["tool_choice"] = opts.ToolChoice
+		default:
+			body["tool_choice"] = map[string]any{"type": "function", "function": map[string]any{"name": opts.ToolChoice}}
+		}
 	}
 
+	o.ModelParams.apply(body, model, o.Debug)
 	payload, _ := json.Marshal(body)
-	req, err := http.NewRequestWithContext(ctx, "POST", o.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(model), bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if o.APIKey != "" {
+	if o.APIKey != "" && o.APIVersion != "" {
+		req.Header.Set("api-key", o.APIKey)
+	} else if o.APIKey != "" {
 		req.Header.Set("Authorization", "Bearer "+o.APIKey)
 	}
+	setHeaders(req, o.Headers)
 
-	resp, err := doWithRetry(req, payload, o.Debug, o.Timeout, o.Retries)
+	resp, err := doWithRetry(o.conns.get(), req, payload, o.Debug, o.Timeout, o.Retries, o.APIKey)
 	if err != nil {
 		return err
 	}
@@ -119,15 +202,18 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 		if o.Debug != nil {
 			o.Debug("API ERROR BODY: %s", string(b))
 		}
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(b))
+		return newError("OpenAI", resp, b)
+	}
+	if o.NoStream {
+		return o.readResponse(resp.Body, onDelta)
 	}
 
-	const streamIdleTimeout = 300 * time.Second // 5 min idle = dead stream (generous for reasoning models)
-
-	scanner := bufio.NewScanner(&idleTimeoutReader{r: resp.Body, timeout: streamIdleTimeout})
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // up to 1MB lines
+	scanner := newLineReader(&idleTimeoutReader{r: resp.Body, timeout: StreamIdleTimeout}, o.MaxStreamLine)
 	// accumulate tool calls across chunks
-	tcAcc := map[int]*ToolCall{}
+	tcAcc := newToolCallAcc()
+	truncated := false        // finish_reason length: the engine reports a cut-off call
+	var text string           // the answer so far, for its citations
+	var citations [][2]string // url_citation annotations, url and title
 	chunkCount := 0
 	hasContent := false
 	lastChunkTime := time.Now()
@@ -138,6 +224,9 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 		lastChunkTime = now
 
 		line := scanner.Text()
+		if line != "" {
+			onDelta(StreamDelta{Ping: true})
+		}
 		if !strings.HasPrefix(line, "data:") {
 			continue
 		}
@@ -151,12 +240,11 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 			if o.Debug != nil {
 				o.Debug("STREAM DONE: %d chunks received", chunkCount)
 			}
+			if links := citationLinks(text, citations); links != "" {
+				onDelta(StreamDelta{Content: links})
+			}
 			// flush accumulated tool calls
-			if len(tcAcc) > 0 {
-				var tcs []ToolCall
-				for _, tc := range tcAcc {
-					tcs = append(tcs, *tc)
-				}
+			if tcs := checkToolCalls(tcAcc.result(), truncated, o.Debug); len(tcs) > 0 {
 				onDelta(StreamDelta{ToolCalls: tcs, Done: true})
 			} else {
 				onDelta(StreamDelta{Done: true})
@@ -167,8 +255,9 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 		var chunk struct {
 			Choices []struct {
 				Delta struct {
-					Content   string `json:"content"`
-					ToolCalls []struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"` // deepseek-reasoner and compatible servers
+					ToolCalls        []struct {
 						Index    int    `json:"index"`
 						ID       string `json:"id"`
 						Function struct {
@@ -176,34 +265,41 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 							Arguments string `json:"arguments"`
 						} `json:"function"`
 					} `json:"tool_calls"`
+					Annotations []openaiAnnotation `json:"annotations"` // web search citations
 				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
 			} `json:"choices"`
+			Usage *openaiUsage `json:"usage"`
 		}
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 			continue
 		}
+		if chunk.Usage != nil {
+			onDelta(StreamDelta{Usage: chunk.Usage.usage()})
+		}
 		if len(chunk.Choices) == 0 {
 			continue
 		}
 		delta := chunk.Choices[0].Delta
+		if r := chunk.Choices[0].FinishReason; r != "" {
+			truncated = normalizeStop(r) == StopMaxTokens
+			onDelta(StreamDelta{Stop: normalizeStop(r)})
+		}
 
+		if delta.ReasoningContent != "" {
+			onDelta(StreamDelta{Reasoning: delta.ReasoningContent})
+		}
 		if delta.Content != "" {
 			hasContent = true
+			text += delta.Content
 			onDelta(StreamDelta{Content: delta.Content})
 		}
+		for _, a := range delta.Annotations {
+			citations = append(citations, a.source())
+		}
 		for _, tc := range delta.ToolCalls {
 			hasContent = true
-			if _, ok := tcAcc[tc.Index]; !ok {
-				tcAcc[tc.Index] = &ToolCall{Type: "function"}
-			}
-			acc := tcAcc[tc.Index]
-			if tc.ID != "" {
-				acc.ID = tc.ID
-			}
-			if tc.Function.Name != "" {
-				acc.Function.Name = tc.Function.Name
-			}
-			acc.Function.Arguments += tc.Function.Arguments
+			tcAcc.add(tc.Index, tc.ID, tc.Function.Name, tc.Function.Arguments)
 		}
 	}
 	if o.Debug != nil {
@@ -222,3 +318,114 @@ func (o *OpenAI) ChatStream(ctx context.Context, model string, messages []Messag
 	}
 	return nil
 }
+
+// openaiAnnotation is a part of an answer marked up by the API; the search
+// models cite their sources with url_citation ones.
+type openaiAnnotation struct {
+	URLCitation struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+	} `json:"url_citation"`
+}
+
+func (a openaiAnnotation) source() [2]string {
+	return [2]string{a.URLCitation.URL, a.URLCitation.Title}
+}
+
+type openaiUsage struct {
+	Usage
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+func (u *openaiUsage) usage() *Usage {
+	r := u.Usage
+	r.CachedTokens = u.PromptTokensDetails.CachedTokens
+	return &r
+}
+
+// readResponse replays a non-streaming completion as the deltas a stream
+// would have produced.
+func (o *OpenAI) readResponse(body io.Reader, onDelta func(StreamDelta)) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if o.Debug != nil {
+		o.Debug("RESPONSE BODY: %s", string(b))
+	}
+	var r struct {
+		Choices []struct {
+			Message struct {
+				Content          string     `json:"content"`
+				ReasoningContent string     `json:"reasoning_content"`
+				ToolCalls        []ToolCall `json:"tool_calls"`
+
+				Annotations []openaiAnnotation `json:"annotations"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *openaiUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("invalid response from API: %w", err)
+	}
+	if len(r.Choices) == 0 || (r.Choices[0].Message.Content == "" && len(r.Choices[0].Message.ToolCalls) == 0) {
+		return fmt.Errorf("empty response from API (%d bytes)", len(b))
+	}
+	c := r.Choices[0]
+	if c.Message.ReasoningContent != "" {
+		onDelta(StreamDelta{Reasoning: c.Message.ReasoningContent})
+	}
+	if c.Message.Content != "" {
+		var sources [][2]string
+		for _, a := range c.Message.Annotations {
+			sources = append(sources, a.source())
+		}
+		onDelta(StreamDelta{Content: c.Message.Content + citationLinks(c.Message.Content, sources)})
+	}
+	if r.Usage != nil {
+		onDelta(StreamDelta{Usage: r.Usage.usage()})
+	}
+	if c.FinishReason != "" {
+		onDelta(StreamDelta{Stop: normalizeStop(c.FinishReason)})
+	}
+	for i := range c.Message.ToolCalls {
+		if c.Message.ToolCalls[i].Type == "" {
+			c.Message.ToolCalls[i].Type = "function"
+		}
+	}
+	calls := checkToolCalls(c.Message.ToolCalls, normalizeStop(c.FinishReason) == StopMaxTokens, o.Debug)
+	onDelta(StreamDelta{ToolCalls: calls, Done: true})
+	return nil
+}
+
+// ListModels returns the model IDs from the /models endpoint. Azure serves
+// deployments, which the data-plane API doesn't list.
+func (o *OpenAI) ListModels(ctx context.Context) ([]string, error) {
+	if o.APIVersion != "" {
+		return nil, fmt.Errorf("Azure OpenAI deployments can't be listed through the API; see the Azure portal")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(o.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	setHeaders(req, o.Headers)
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(o.conns.get(), req, "OpenAI", o.Debug, o.Timeout, o.Retries, o.APIKey, &resp); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
@@ -3,16 +3,37 @@ package provider
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"path"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content,omitempty"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role       string          `json:"role"`
+	Content    string          `json:"content,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Thinking   []ThinkingBlock `json:"thinking,omitempty"` // Anthropic only; other providers ignore it
+	// Session metadata, never sent to a provider: when the engine added a
+	// user or assistant message, and the "provider/model" that wrote an
+	// assistant message. Older sessions don't have them.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	Model     string     `json:"model,omitempty"`
+}
+
+// ThinkingBlock is an Anthropic thinking or redacted_thinking block. The API
+// requires them back, signature included, in the assistant message before
+// tool results, so they are kept on the message.
+type ThinkingBlock struct {
+	Type      string `json:"type"` // "thinking" or "redacted_thinking"
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"` // encrypted content of redacted_thinking
 }
 
 type ToolCall struct {
@@ -31,26 +52,225 @@ type ToolDef struct {
 }
 
 type StreamDelta struct {
-	Content   string     // text chunk
-	ToolCalls []ToolCall // tool call chunks
+	Content   string         // text chunk
+	Reasoning string         // thinking chunk; shown to the user, never sent back as content
+	Thinking  *ThinkingBlock // a complete thinking block, to be kept on the assistant message
+	ToolCalls []ToolCall     // tool call chunks
 	Done      bool
+	Ping      bool   // data arrived (keep-alive, metadata) but there is nothing to show
+	Usage     *Usage // token counts for the request, usually sent once near the end
+	Stop      string // why generation stopped, if reported; StopMaxTokens when truncated
+}
+
+// StopMaxTokens is the StreamDelta.Stop value of a response cut off by the
+// output token limit (Anthropic "max_tokens", OpenAI and Ollama "length").
+const StopMaxTokens = "max_tokens"
+
+func normalizeStop(reason string) string {
+	if reason == "length" {
+		return StopMaxTokens
+	}
+	return reason
+}
+
+// Usage is the token count reported by the API for one request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens" yaml:"completion_tokens"`
+	CachedTokens     int `json:"cached_tokens,omitempty" yaml:"cached_tokens"` // part of PromptTokens read from the prompt cache
+}
+
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.CachedTokens += o.CachedTokens
+}
+
+// OnRetry, if set, is called before each retry of a failed request, with the
+// API host and the status that caused it.
+var OnRetry func(host string, status int)
+
+// StreamIdleTimeout is how long a stream may go without any data before it is
+// treated as dead (generous for reasoning models).
+const StreamIdleTimeout = 300 * time.Second
+
+// ChatOptions are generation parameters for one request. Zero values are
+// left out of the request so the API's own defaults apply.
+type ChatOptions struct {
+	Temperature float64
+	TopP        float64
+	MaxTokens   int
+	Stop        []string
+
+	ResponseFormat *ResponseFormat // nil for free-form text
+	ToolChoice     string          // "" (the API default), ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired or a tool name
+	ThinkingBudget int             // Anthropic extended thinking budget in tokens; 0 = off
+	NativeTools    []string        // tools the provider runs itself, "<api>:<name>"; see CheckNativeTool
+}
+
+// Tool choices besides the name of a tool the model must call.
+const (
+	ToolChoiceAuto     = "auto"     // the model decides
+	ToolChoiceNone     = "none"     // no tool calls
+	ToolChoiceRequired = "required" // at least one tool call
+)
+
+// ForcesTool reports whether a tool choice makes the model call a tool.
+func ForcesTool(choice string) bool {
+	return choice != "" && choice != ToolChoiceAuto && choice != ToolChoiceNone
+}
+
+// ResponseFormat asks for the answer as JSON: any JSON object, or with
+// Schema set, a document matching that JSON schema. OpenAI and Ollama
+// enforce it natively; Anthropic gets a tool whose input is the answer.
+type ResponseFormat struct {
+	Name   string         // schema name, required by OpenAI; default "response"
+	Schema map[string]any // nil for any JSON object
+}
+
+func (f *ResponseFormat) name() string {
+	if f.Name == "" {
+		return "response"
+	}
+	return f.Name
+}
+
+// ModelParams are request body overrides per model, keyed by model ID (the
+// part after "provider/") or a path.Match pattern such as "o3*". A nil value
+// removes the field, for parameters a model rejects.
+type ModelParams map[string]map[string]any
+
+// apply merges the overrides for model into body: matching patterns first,
+// in sorted order, then the exact ID, so the most specific entry wins. It
+// logs the resulting parameters (the body without messages and tools).
+func (mp ModelParams) apply(body map[string]any, model string, dbg DebugFunc) {
+	var keys []string
+	for k := range mp {
+		if ok, _ := path.Match(k, model); ok && k != model {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := mp[model]; ok {
+		keys = append(keys, model)
+	}
+	for _, k := range keys {
+		for name, v := range mp[k] {
+			if v == nil {
+				delete(body, name)
+			} else {
+				body[name] = v
+			}
+		}
+	}
+	if dbg != nil && len(keys) > 0 {
+		params := make(map[string]any, len(body))
+		for k, v := range body {
+			if k != "messages" && k != "tools" && k != "system" {
+				params[k] = v
+			}
+		}
+		b, _ := json.Marshal(params)
+		dbg("REQUEST PARAMS (model_params %s): %s", strings.Join(keys, ", "), b)
+	}
 }
 
 type Provider interface {
-	ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, onDelta func(StreamDelta)) error
+	ChatStream(ctx context.Context, model string, messages []Message, tools []ToolDef, opts ChatOptions, onDelta func(StreamDelta)) error
+}
+
+// ModelLister is implemented by providers that can ask their API which
+// models it serves.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
 }
 
 // DebugFunc is an optional debug logger that providers can use.
 type DebugFunc func(format string, args ...any)
 
-// doWithRetry sends an HTTP request with configurable retries on 429 or 5xx.
-func doWithRetry(req *http.Request, payload []byte, dbg DebugFunc, timeout time.Duration, retries int) (*http.Response, error) {
-	client := &http.Client{Timeout: timeout}
+// maxRetryWait is the longest Retry-After a retry waits for; longer waits
+// are reported to the user instead.
+const maxRetryWait = 30 * time.Second
+
+// minRetryWait is the shortest wait before a retry, whatever Retry-After says.
+var minRetryWait = 2 * time.Second
+
+// isQuotaBody reports whether a 429 body says the quota is exhausted.
+func isQuotaBody(b []byte) bool {
+	e := newError("", &http.Response{StatusCode: 429}, b)
+	return e.Kind == ErrQuotaExhausted
+}
+
+// setHeaders applies a provider's configured headers, which override the
+// defaults except Content-Type.
+func setHeaders(req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) != "Content-Type" {
+			req.Header.Set(k, v)
+		}
+	}
+}
+
+// SecretHeader reports whether a request header named name carries
+// credentials: Authorization, cookies, and names with key, token, secret or
+// auth in them, such as x-api-key.
+func SecretHeader(name string) bool {
+	n := strings.ToLower(name)
+	if n == "cookie" {
+		return true
+	}
+	for _, s := range []string{"auth", "key", "token", "secret"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskHeaders returns a copy of h for logging, with the values of secret
+// headers and values containing the API key replaced.
+func maskHeaders(h http.Header, apiKey string) http.Header {
+	h = h.Clone()
+	for k, vs := range h {
+		for i, v := range vs {
+			if SecretHeader(k) || apiKey != "" && strings.Contains(v, apiKey) {
+				vs[i] = "********"
+			}
+		}
+	}
+	return h
+}
+
+// getJSON sends a GET request, retried like chat requests, and decodes the
+// JSON response into out. Non-200 responses become an *Error from provider.
+func getJSON(client *http.Client, req *http.Request, provider string, dbg DebugFunc, timeout time.Duration, retries int, apiKey string, out any) error {
+	resp, err := doWithRetry(client, req, nil, dbg, timeout, retries, apiKey)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return newError(provider, resp, b)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("unexpected response from %s: %w", req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// doWithRetry sends an HTTP request with configurable retries on 429 or 5xx,
+// on the provider's client. timeout bounds the wait for each response, see
+// send; 0 means none. apiKey is only used to mask headers in the debug log.
+func doWithRetry(client *http.Client, req *http.Request, payload []byte, dbg DebugFunc, timeout time.Duration, retries int, apiKey string) (*http.Response, error) {
 	if dbg != nil {
 		dbg("HTTP %s %s (%d bytes, timeout=%s, retries=%d)", req.Method, req.URL.String(), len(payload), timeout, retries)
-		dbg("Request Headers: %v", req.Header)
+		dbg("Request Headers: %v", maskHeaders(req.Header, apiKey))
 	}
-	resp, err := client.Do(req)
+	resp, err := send(client, req, timeout)
 	if err != nil {
 		if dbg != nil {
 			dbg("HTTP ERROR: %v", err)
@@ -62,13 +282,29 @@ func doWithRetry(req *http.Request, payload []byte, dbg DebugFunc, timeout time.
 		dbg("Response Content-Encoding: %s", resp.Header.Get("Content-Encoding"))
 	}
 	for i := 0; i < retries && (resp.StatusCode == 429 || resp.StatusCode >= 500); i++ {
+		b, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		wait := max(retryAfter(resp.Header), minRetryWait)
+		if wait > maxRetryWait || (resp.StatusCode == 429 && isQuotaBody(b)) {
+			// out of quota or a long wait: let the caller report it
+			resp.Body = io.NopCloser(bytes.NewReader(b))
+			return resp, nil
+		}
 		if dbg != nil {
-			dbg("HTTP RETRY %d/%d: waiting 2s then retrying...", i+1, retries)
+			dbg("HTTP RETRY %d/%d: waiting %s then retrying...", i+1, retries, wait)
+		}
+		if OnRetry != nil {
+			OnRetry(req.URL.Host, resp.StatusCode)
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if payload != nil {
+			req.Body = io.NopCloser(bytes.NewReader(payload))
 		}
-		time.Sleep(2 * time.Second)
-		req.Body = io.NopCloser(bytes.NewReader(payload))
-		resp, err = client.Do(req)
+		resp, err = send(client, req, timeout)
 		if err != nil {
 			return nil, err
 		}
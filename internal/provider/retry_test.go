@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// anthropicServer answers the first fails requests with 429 and the rest
+// with a short stream, after delay, and counts the requests.
+func anthropicServer(t *testing.T, fails int, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	var attempts atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if attempts.Add(1) <= int64(fails) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, longStreams[1].body("ok")) // anthropic's
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func chat(p Provider) (string, error) {
+	var got strings.Builder
+	err := p.ChatStream(context.Background(), "m", []Message{{Role: "user", Content: "hi"}}, nil, ChatOptions{}, func(d StreamDelta) {
+		got.WriteString(d.Content)
+	})
+	return got.String(), err
+}
+
+func TestAnthropicRetries(t *testing.T) {
+	saved := minRetryWait
+	minRetryWait = time.Millisecond
+	t.Cleanup(func() { minRetryWait = saved })
+
+	tests := []struct {
+		retries, fails int
+		wantAttempts   int64
+		wantErr        bool
+	}{
+		{2, 5, 3, true}, // two retries after the first attempt, then the 429 is reported
+		{2, 2, 3, false},
+		{2, 1, 2, false},
+		{0, 5, 1, true},
+		{3, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("retries %d, %d fails", tt.retries, tt.fails), func(t *testing.T) {
+			srv, attempts := anthropicServer(t, tt.fails, 0)
+			got, err := chat(&Anthropic{BaseURL: srv.URL, Retries: tt.retries})
+			if n := attempts.Load(); n != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", n, tt.wantAttempts)
+			}
+			if tt.wantErr {
+				var perr *Error
+				if !errors.As(err, &perr) || perr.Status != http.StatusTooManyRequests {
+					t.Errorf("error %v, want the 429", err)
+				}
+				return
+			}
+			if err != nil || got != "ok" {
+				t.Errorf("got %q, %v; want the answer", got, err)
+			}
+		})
+	}
+}
+
+func TestAnthropicTimeout(t *testing.T) {
+	// a zero timeout sets no deadline: a slow answer still arrives
+	srv, _ := anthropicServer(t, 0, 300*time.Millisecond)
+	a := &Anthropic{BaseURL: srv.URL}
+	if got, err := chat(a); err != nil || got != "ok" {
+		t.Errorf("with no timeout: %q, %v; want the answer", got, err)
+	}
+	if d := a.conns.get().Timeout; d != 0 {
+		t.Errorf("client timeout %s, want none", d)
+	}
+
+	// a timeout shorter than the wait gives up
+	a = &Anthropic{BaseURL: srv.URL, Timeout: 50 * time.Millisecond}
+	if _, err := chat(a); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("with a 50ms timeout: error %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// toolCallSeq numbers the responses whose tool calls needed IDs made up, so
+// the IDs stay unique across a conversation.
+var toolCallSeq atomic.Int64
+
+// toolCallAcc puts streamed tool call chunks back together. Some
+// OpenAI-compatible gateways send chunks without IDs, repeat a call's ID
+// under another index or reuse an index, or an ID, for the next call; the
+// calls still come out whole and in order.
+type toolCallAcc struct {
+	calls   []*ToolCall
+	byIndex map[int]*ToolCall    // the call a chunk with this index continues
+	byID    map[string]*ToolCall // the call a chunk with this ID continues
+}
+
+func newToolCallAcc() *toolCallAcc {
+	return &toolCallAcc{byIndex: map[int]*ToolCall{}, byID: map[string]*ToolCall{}}
+}
+
+// add adds a chunk of the call at index.
+func (a *toolCallAcc) add(index int, id, name, args string) {
+	var tc *ToolCall
+	if id != "" {
+		tc = a.byID[id] // the same call, maybe under another index
+		if cur := a.byIndex[index]; tc == nil && cur != nil && cur.ID == "" {
+			tc = cur // the ID came after the call's first chunk
+		}
+	} else {
+		tc = a.byIndex[index]
+	}
+	if tc != nil && name != "" && tc.Function.Name != "" && json.Valid([]byte(tc.Function.Arguments)) {
+		tc = nil // a finished call's index or ID reused for the next one
+	}
+	if tc == nil {
+		tc = &ToolCall{Type: "function"}
+		a.calls = append(a.calls, tc)
+	}
+	a.byIndex[index] = tc
+	if id != "" {
+		if tc.ID == "" {
+			tc.ID = id
+		}
+		a.byID[id] = tc
+	}
+	if name != "" {
+		tc.Function.Name = name
+	}
+	tc.Function.Arguments += args
+}
+
+// result returns the calls in the order they started.
+func (a *toolCallAcc) result() []ToolCall {
+	calls := make([]ToolCall, len(a.calls))
+	for i, tc := range a.calls {
+		calls[i] = *tc
+	}
+	return calls
+}
+
+// checkToolCalls makes the IDs of calls unique, making up the ones that are
+// missing, and drops calls without a name or with arguments that aren't
+// JSON, which can't run, unless the answer was cut off (truncated): the
+// engine reports that itself. What it drops goes to debug.
+func checkToolCalls(calls []ToolCall, truncated bool, debug DebugFunc) []ToolCall {
+	var seq int64
+	seen := make(map[string]bool, len(calls))
+	out := calls[:0]
+	for i, tc := range calls {
+		args := tc.Function.Arguments
+		if !truncated && (tc.Function.Name == "" || args != "" && !json.Valid([]byte(args))) {
+			if debug != nil {
+				debug("INVALID TOOL_CALL: %q id=%q dropped, its name or arguments are broken: %s", tc.Function.Name, tc.ID, args)
+			}
+			continue
+		}
+		if tc.ID == "" || seen[tc.ID] {
+			if seq == 0 {
+				seq = toolCallSeq.Add(1)
+			}
+			id := fmt.Sprintf("call_%d_%d", seq, i)
+			if debug != nil {
+				debug("TOOL_CALL ID: %s had id %q, now %s", tc.Function.Name, tc.ID, id)
+			}
+			tc.ID = id
+		}
+		seen[tc.ID] = true
+		out = append(out, tc)
+	}
+	return out
+}
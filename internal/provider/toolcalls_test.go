@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tcChunk is an OpenAI stream event with a tool call chunk; empty fields are
+// left out, as gateways do.
+func tcChunk(index int, id, name, args string) string {
+	fn := map[string]any{}
+	if name != "" {
+		fn["name"] = name
+	}
+	if args != "" {
+		fn["arguments"] = args
+	}
+	tc := map[string]any{"index": index, "function": fn}
+	if id != "" {
+		tc["id"], tc["type"] = id, "function"
+	}
+	return sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"tool_calls": []any{tc}}}}})
+}
+
+func finish(reason string) string {
+	return sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{}, "finish_reason": reason}}}) + "data: [DONE]\n\n"
+}
+
+func TestStreamedToolCalls(t *testing.T) {
+	type want struct{ id, name, args string } // id "" is a made-up one
+	tests := []struct {
+		name    string
+		events  []string
+		want    []want
+		dropped int
+	}{
+		{"two calls", []string{
+			tcChunk(0, "call_a", "file_read", `{"path":"a.go"}`),
+			tcChunk(1, "call_b", "file_read", `{"path":"b.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "file_read", `{"path":"a.go"}`}, {"call_b", "file_read", `{"path":"b.go"}`}}, 0},
+		{"arguments in pieces", []string{
+			tcChunk(0, "call_a", "grep", ""),
+			tcChunk(0, "", "", `{"pat`),
+			tcChunk(0, "", "", `tern":"TODO",`),
+			tcChunk(0, "", "", `"path":"."}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "grep", `{"pattern":"TODO","path":"."}`}}, 0},
+		{"partial JSON arguments", []string{
+			tcChunk(0, "call_a", "file_write", `{"path":"a.go","content":"pack`),
+			tcChunk(1, "call_b", "file_read", `{"path":"b.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_b", "file_read", `{"path":"b.go"}`}}, 1},
+		{"partial JSON arguments of a cut-off answer", []string{
+			tcChunk(0, "call_a", "file_write", `{"path":"a.go","content":"pack`),
+			finish("length"),
+		}, []want{{"call_a", "file_write", `{"path":"a.go","content":"pack`}}, 0},
+		{"no name", []string{
+			tcChunk(0, "call_a", "", `{"path":"a.go"}`),
+			finish("tool_calls"),
+		}, nil, 1},
+		{"missing ids", []string{
+			tcChunk(0, "", "file_read", `{"path":"a.go"}`),
+			tcChunk(1, "", "file_read", `{"path":"b.go"}`),
+			finish("tool_calls"),
+		}, []want{{"", "file_read", `{"path":"a.go"}`}, {"", "file_read", `{"path":"b.go"}`}}, 0},
+		{"the id after the first chunk", []string{
+			tcChunk(0, "", "file_read", `{"path":`),
+			tcChunk(0, "call_a", "", `"a.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "file_read", `{"path":"a.go"}`}}, 0},
+		{"an index reused for the next call", []string{
+			tcChunk(0, "call_a", "file_read", `{"path":"a.go"}`),
+			tcChunk(0, "call_b", "file_read", `{"path":"b.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "file_read", `{"path":"a.go"}`}, {"call_b", "file_read", `{"path":"b.go"}`}}, 0},
+		{"an index reused without ids", []string{
+			tcChunk(0, "", "file_read", `{"path":"a.go"}`),
+			tcChunk(0, "", "grep", `{"pattern":"x"}`),
+			finish("tool_calls"),
+		}, []want{{"", "file_read", `{"path":"a.go"}`}, {"", "grep", `{"pattern":"x"}`}}, 0},
+		{"an id continued under another index", []string{
+			tcChunk(0, "call_a", "file_read", `{"path":`),
+			tcChunk(3, "call_a", "", `"a.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "file_read", `{"path":"a.go"}`}}, 0},
+		{"an id reused for the next call", []string{
+			tcChunk(0, "call_a", "file_read", `{"path":"a.go"}`),
+			tcChunk(1, "call_a", "file_read", `{"path":"b.go"}`),
+			finish("tool_calls"),
+		}, []want{{"call_a", "file_read", `{"path":"a.go"}`}, {"", "file_read", `{"path":"b.go"}`}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, strings.Join(tt.events, ""))
+			}))
+			defer srv.Close()
+			var calls []ToolCall
+			var dropped []string
+			o := &OpenAI{BaseURL: srv.URL, Debug: func(format string, args ...any) {
+				if strings.HasPrefix(format, "INVALID TOOL_CALL") {
+					dropped = append(dropped, fmt.Sprintf(format, args...))
+				}
+			}}
+			err := o.ChatStream(context.Background(), "m", []Message{{Role: "user", Content: "hi"}}, nil, ChatOptions{}, func(d StreamDelta) {
+				calls = append(calls, d.ToolCalls...)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(calls) != len(tt.want) {
+				t.Fatalf("%d calls %+v, want %d", len(calls), calls, len(tt.want))
+			}
+			ids := map[string]bool{}
+			for i, w := range tt.want {
+				c := calls[i]
+				if c.Function.Name != w.name || c.Function.Arguments != w.args {
+					t.Errorf("call %d: %s(%s), want %s(%s)", i, c.Function.Name, c.Function.Arguments, w.name, w.args)
+				}
+				if w.id != "" && c.ID != w.id || w.id == "" && !strings.HasPrefix(c.ID, "call_") {
+					t.Errorf("call %d: id %q, want %q", i, c.ID, w.id)
+				}
+				if ids[c.ID] {
+					t.Errorf("call %d: id %q twice", i, c.ID)
+				}
+				ids[c.ID] = true
+			}
+			// a call that can't run is dropped, and debug says so
+			if len(dropped) != tt.dropped {
+				t.Errorf("debug logged %d dropped calls %q, want %d", len(dropped), dropped, tt.dropped)
+			}
+		})
+	}
+}
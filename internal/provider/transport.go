@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Connections a provider keeps open between requests. A tool-heavy turn
+// sends a request every few seconds, so reusing the connection saves a TLS
+// handshake per round; idle ones are closed after idleConnTimeout.
+const (
+	maxIdleConnsPerHost = 8 // background turns and subagents share the provider
+	idleConnTimeout     = 90 * time.Second
+)
+
+// conns is a provider's HTTP client, made on first use. Every request and
+// retry of the provider goes through its transport.
+type conns struct {
+	once   sync.Once
+	client *http.Client
+}
+
+func (c *conns) get() *http.Client {
+	c.once.Do(func() {
+		t := http.DefaultTransport.(*http.Transport).Clone() // proxy settings, dial and TLS handshake timeouts, HTTP/2
+		t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		t.IdleConnTimeout = idleConnTimeout
+		c.client = &http.Client{Transport: t}
+	})
+	return c.client
+}
+
+// send sends req, giving up when no response has arrived within timeout
+// (none if 0). Unlike http.Client.Timeout, the limit ends with the response
+// headers, so it doesn't cut off a long stream; streams are bounded by
+// StreamIdleTimeout instead.
+func send(client *http.Client, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return client.Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := client.Do(req.WithContext(ctx))
+	if !timer.Stop() && req.Context().Err() == nil {
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("no response from %s within %s (timeout)", req.URL.Host, timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of a request when its response is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
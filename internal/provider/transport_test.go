@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// tlsServer is a local TLS server answering every request with a short
+// OpenAI stream, and counting the connections made to it.
+func tlsServer(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	var dials atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"content": "ok"}}}})+
+			sseData(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{}, "finish_reason": "stop"}}})+
+			"data: [DONE]\n\n")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	srv.StartTLS()
+	tb.Cleanup(srv.Close)
+	return srv, &dials
+}
+
+// trusting returns o with its connections trusting srv's certificate.
+func trusting(o *OpenAI, srv *httptest.Server) *OpenAI {
+	o.conns.get().Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	return o
+}
+
+func round(tb testing.TB, o *OpenAI) {
+	err := o.ChatStream(context.Background(), "m", []Message{{Role: "user", Content: "hi"}}, nil, ChatOptions{}, func(StreamDelta) {})
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestConnReuse(t *testing.T) {
+	srv, dials := tlsServer(t)
+	o := trusting(&OpenAI{BaseURL: srv.URL}, srv)
+	for i := 0; i < 5; i++ {
+		round(t, o)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Errorf("5 rounds made %d connections, want 1", n)
+	}
+}
+
+// BenchmarkRound compares rounds over the provider's kept connection with
+// rounds that each make a new one, as every request did before conns: the
+// difference is a TCP and TLS handshake, which takes a few milliseconds here
+// and hundreds over a slow network.
+func BenchmarkRound(b *testing.B) {
+	b.Run("reused", func(b *testing.B) {
+		srv, dials := tlsServer(b)
+		o := trusting(&OpenAI{BaseURL: srv.URL}, srv)
+		round(b, o) // connect outside the timing
+		dials.Store(0)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			round(b, o)
+		}
+		b.ReportMetric(float64(dials.Load())/float64(b.N), "conns/op")
+	})
+	b.Run("new", func(b *testing.B) {
+		srv, dials := tlsServer(b)
+		for i := 0; i < b.N; i++ {
+			o := trusting(&OpenAI{BaseURL: srv.URL}, srv)
+			round(b, o)
+			o.conns.get().CloseIdleConnections()
+		}
+		b.ReportMetric(float64(dials.Load())/float64(b.N), "conns/op")
+	})
+}
@@ -0,0 +1,16 @@
+package record
+
+import (
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Archived is a stretch of the conversation compression, or
+// ClearWithSummary, replaced with a summary: the messages as they were, so
+// what the summary left out can still be looked up.
+type Archived struct {
+	Time     time.Time          `json:"time"`
+	Summary  string             `json:"summary"`
+	Messages []provider.Message `json:"messages"`
+}
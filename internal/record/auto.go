@@ -0,0 +1,63 @@
+package record
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Checkpoint is a progress note the model made during an autonomous run.
+type Checkpoint struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// AutoRun records an autonomous run (/auto, --auto) for the session.
+type AutoRun struct {
+	Task        string       `json:"task"`
+	Start       time.Time    `json:"start"`
+	BudgetMs    int64        `json:"budget_ms"`
+	DurationMs  int64        `json:"duration_ms"`
+	Rounds      int          `json:"rounds"`
+	Tokens      int          `json:"tokens"`
+	Stopped     string       `json:"stopped"` // done, time, rounds, tokens, cancelled or error
+	Checkpoints []Checkpoint `json:"checkpoints,omitempty"`
+	Summary     string       `json:"summary,omitempty"` // the model's closing summary, or else the checkpoints
+}
+
+// CheckpointSummary lists the checkpoints, for runs that ended without the
+// model summing up.
+func (r *AutoRun) CheckpointSummary() string {
+	if len(r.Checkpoints) == 0 {
+		return "No checkpoints were made."
+	}
+	var sb strings.Builder
+	for i, c := range r.Checkpoints {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%s  %s", c.Time.Format("15:04"), c.Text)
+	}
+	return sb.String()
+}
+
+// Report renders the run as "⏱ autonomous run done · 12m04s · 48 rounds ·
+// 1210k tokens · 5 checkpoints".
+func (r *AutoRun) Report() string {
+	status := "done"
+	switch r.Stopped {
+	case "time":
+		status = "stopped: time budget spent"
+	case "rounds":
+		status = "stopped: round limit reached"
+	case "tokens":
+		status = "stopped: token limit reached"
+	case "cancelled":
+		status = "stopped by the user"
+	case "error":
+		status = "failed"
+	}
+	return fmt.Sprintf("⏱ autonomous run %s · %s · %s · %s tokens · %s", status,
+		FormatDuration(time.Duration(r.DurationMs)*time.Millisecond), plural(r.Rounds, "round"),
+		FormatTokens(r.Tokens), plural(len(r.Checkpoints), "checkpoint"))
+}
@@ -0,0 +1,79 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Change is a call to a tool that may change the machine (file writes, bash,
+// custom and MCP tools that aren't read-only), in the session's changelog.
+// Changes stay recorded when their turn is rolled back: what the tools did
+// happened anyway.
+type Change struct {
+	Seq        int       `json:"seq"` // 1-based, in the session
+	Time       time.Time `json:"time"`
+	Tool       string    `json:"tool"`
+	Args       string    `json:"args"`
+	Error      string    `json:"error,omitempty"`   // the call failed; it may have done part of its work
+	Path       string    `json:"path,omitempty"`    // file tools: the file changed
+	Created    bool      `json:"created,omitempty"` // the file didn't exist before
+	Deleted    bool      `json:"deleted,omitempty"` // the tool removed the file
+	Diff       string    `json:"diff,omitempty"`
+	Before     *string   `json:"before,omitempty"` // the file before and after, when both are small enough to keep
+	After      *string   `json:"after,omitempty"`
+	Reverted   bool      `json:"reverted,omitempty"`
+	Checkpoint string    `json:"checkpoint,omitempty"` // file tools: ref of the session's latest checkpoint of the file's repository
+
+	// Mode is a changed file's permissions, which Revert gives it back;
+	// zero in sessions from before it was recorded.
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// Revertible reports whether Revert can undo the change.
+func (c *Change) Revertible() bool {
+	return c.Path != "" && c.Before != nil && !c.Reverted
+}
+
+// Revert puts a changed file back the way it was before the change, removing
+// it if the change created it and writing it again if the change removed it.
+// It refuses when the file was changed again since, so later work isn't
+// lost; revert the later changes first.
+func (c *Change) Revert() error {
+	switch {
+	case c.Path == "":
+		return fmt.Errorf("change #%d (%s) isn't a file change; only those can be reverted", c.Seq, c.Tool)
+	case c.Reverted:
+		return fmt.Errorf("change #%d is reverted already", c.Seq)
+	case c.Before == nil:
+		return fmt.Errorf("change #%d to %s is too large to have been kept; only its diff was", c.Seq, c.Path)
+	}
+	cur, err := os.ReadFile(c.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if c.Deleted {
+		if err == nil {
+			return fmt.Errorf("%s exists again since change #%d removed it; revert the later changes first", c.Path, c.Seq)
+		}
+		os.MkdirAll(filepath.Dir(c.Path), 0755)
+	} else if err != nil || string(cur) != *c.After {
+		return fmt.Errorf("%s changed since change #%d; revert the later changes first", c.Path, c.Seq)
+	}
+	mode := c.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if c.Created {
+		err = os.Remove(c.Path)
+	} else if err = os.WriteFile(c.Path, []byte(*c.Before), mode); err == nil { // changed or deleted
+		err = os.Chmod(c.Path, mode) // WriteFile keeps an existing file's
+	}
+	if err != nil {
+		return err
+	}
+	c.Reverted = true
+	return nil
+}
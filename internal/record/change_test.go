@@ -0,0 +1,64 @@
+package record
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// sameMode reports whether a file's mode is want. Windows keeps only the
+// read-only attribute, so there only the owner's write bit is compared.
+func sameMode(got, want os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return got&0o200 == want&0o200
+	}
+	return got.Perm() == want.Perm()
+}
+
+func TestChangeRevert(t *testing.T) {
+	before, after := "#!/bin/sh\necho one\n", "#!/bin/sh\necho two\n"
+	tests := []struct {
+		name     string
+		change   Change
+		onDisk   *string     // the file now; nil if it doesn't exist
+		mode     os.FileMode // its mode now
+		want     *string     // the file after the revert; nil if removed
+		wantMode os.FileMode
+		wantErr  bool
+	}{
+		{"a changed script", Change{Before: &before, After: &after, Mode: 0o755}, &after, 0o755, &before, 0o755, false},
+		{"a changed file whose mode changed since", Change{Before: &before, After: &after, Mode: 0o600}, &after, 0o644, &before, 0o600, false},
+		{"a removed script", Change{Before: &before, After: new(string), Deleted: true, Mode: 0o755}, nil, 0, &before, 0o755, false},
+		{"a change of an older session", Change{Before: &before, After: new(string), Deleted: true}, nil, 0, &before, 0o644, false},
+		{"a created file", Change{Before: new(string), After: &after, Created: true, Mode: 0o755}, &after, 0o755, nil, 0, false},
+		{"a file changed again", Change{Before: &before, After: &after, Mode: 0o755}, &before, 0o755, &before, 0o755, true},
+		{"a removed file that is back", Change{Before: &before, After: new(string), Deleted: true, Mode: 0o755}, &after, 0o644, &after, 0o644, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.change
+			c.Seq, c.Tool, c.Path = 1, "file_write", filepath.Join(t.TempDir(), "run.sh")
+			if tt.onDisk != nil {
+				if err := os.WriteFile(c.Path, []byte(*tt.onDisk), tt.mode); err != nil {
+					t.Fatal(err)
+				}
+				os.Chmod(c.Path, tt.mode) // past the umask
+			}
+			err := c.Revert()
+			if (err != nil) != tt.wantErr || c.Reverted == tt.wantErr {
+				t.Fatalf("Revert = %v, reverted %v", err, c.Reverted)
+			}
+			data, err := os.ReadFile(c.Path)
+			switch {
+			case tt.want == nil && err == nil:
+				t.Errorf("%s is still there", c.Path)
+			case tt.want != nil && string(data) != *tt.want:
+				t.Errorf("the file holds %q, want %q", data, *tt.want)
+			}
+			if fi, err := os.Stat(c.Path); err == nil && !sameMode(fi.Mode(), tt.wantMode) {
+				t.Errorf("mode %v, want %v", fi.Mode().Perm(), tt.wantMode)
+			}
+		})
+	}
+}
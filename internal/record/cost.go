@@ -0,0 +1,39 @@
+package record
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cost is money spent on API calls as far as it is known: tokens of models
+// without a price are counted instead of guessed at.
+type Cost struct {
+	USD            float64 `json:"usd"`
+	UnpricedTokens int     `json:"unpriced_tokens,omitempty"`
+}
+
+func (c *Cost) Add(o Cost) {
+	c.USD += o.USD
+	c.UnpricedTokens += o.UnpricedTokens
+}
+
+// String renders the cost as "$0.042", with "+ 12k tokens unpriced" when
+// part of the usage has no price, or "" when nothing was spent.
+func (c Cost) String() string {
+	var parts []string
+	if c.USD > 0 {
+		parts = append(parts, FormatUSD(c.USD))
+	}
+	if c.UnpricedTokens > 0 {
+		parts = append(parts, FormatTokens(c.UnpricedTokens)+" tokens unpriced")
+	}
+	return strings.Join(parts, " + ")
+}
+
+// FormatUSD renders dollars with cents, or tenths of a cent below a cent.
+func FormatUSD(usd float64) string {
+	if usd < 0.01 {
+		return fmt.Sprintf("$%.4f", usd)
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
@@ -0,0 +1,5 @@
+// Package record holds what a session keeps of the work its engine did:
+// turn stats, cost, autonomous runs, the changelog of tool calls and the
+// messages compression archived. Engines produce them and sessions store
+// them, so neither package needs the other for its types.
+package record
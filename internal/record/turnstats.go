@@ -0,0 +1,83 @@
+package record
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// TurnStats records what one user turn cost: wall-clock time, LLM rounds,
+// tool calls, the context size before and after, and the API-reported tokens
+// summed over all rounds (zero when the provider doesn't report usage).
+type TurnStats struct {
+	Start            time.Time `json:"start"`
+	DurationMs       int64     `json:"duration_ms"`
+	Model            string    `json:"model"`
+	Rounds           int       `json:"rounds"`
+	ToolCalls        int       `json:"tool_calls"`
+	TokensBefore     int       `json:"tokens_before"`
+	TokensAfter      int       `json:"tokens_after"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	CachedTokens     int       `json:"cached_tokens,omitempty"`
+	Cost             float64   `json:"cost_usd,omitempty"` // of priced models only
+	Replays          int       `json:"replays,omitempty"`  // repeated answer endings dropped, see collapseReplay
+	Retry            bool      `json:"retry,omitempty"`    // the turn answered its message again, see RetryLast
+	Error            string    `json:"error,omitempty"`
+}
+
+func (s TurnStats) Duration() time.Duration {
+	return time.Duration(s.DurationMs) * time.Millisecond
+}
+
+// Summary renders the stats as a one-liner like
+// "◷ 42s · 6 rounds · 9 tools · 18k→21k ctx · 95k in/2k out · $0.31".
+func (s TurnStats) Summary() string {
+	line := fmt.Sprintf("◷ %s · %s · %s · %s→%s ctx",
+		FormatDuration(s.Duration()), plural(s.Rounds, "round"), plural(s.ToolCalls, "tool"),
+		FormatTokens(s.TokensBefore), FormatTokens(s.TokensAfter))
+	if s.PromptTokens+s.CompletionTokens > 0 {
+		line += " · " + FormatUsage(provider.Usage{PromptTokens: s.PromptTokens, CompletionTokens: s.CompletionTokens, CachedTokens: s.CachedTokens})
+	}
+	if s.Cost > 0 {
+		line += " · " + FormatUSD(s.Cost)
+	}
+	return line
+}
+
+// FormatUsage renders token usage as "95k in/2k out", or
+// "95k in (80k cached)/2k out" when part of the prompt came from the cache.
+func FormatUsage(u provider.Usage) string {
+	in := FormatTokens(u.PromptTokens) + " in"
+	if u.CachedTokens > 0 {
+		in += " (" + FormatTokens(u.CachedTokens) + " cached)"
+	}
+	return in + "/" + FormatTokens(u.CompletionTokens) + " out"
+}
+
+// FormatDuration renders a duration as "4.2s", "42s" or "3m07s".
+func FormatDuration(d time.Duration) string {
+	if d < 10*time.Second {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// FormatTokens abbreviates a token count, e.g. 18k.
+func FormatTokens(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%dk", (n+500)/1000)
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
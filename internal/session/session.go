@@ -1,22 +1,33 @@
 package session
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/gal-cli/gal-cli/internal/config"
 	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
 )
 
-const (
-	Dir       = "/tmp/gal-sessions"
-	MaxAge    = 7 * 24 * time.Hour
-)
+// MaxAge is how long Cleanup leaves the file backups of a session that no
+// longer exists.
+const MaxAge = 7 * 24 * time.Hour
+
+// Dir returns the directory sessions are stored in (<state dir>/sessions).
+func Dir() string {
+	return filepath.Join(config.StateDir(), "sessions")
+}
 
 type Session struct {
 	ID        string             `json:"id"`
@@ -25,6 +36,11 @@ type Session struct {
 	CreatedAt time.Time          `json:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at"`
 	Messages  []provider.Message `json:"messages"`
+	Turns     []record.TurnStats `json:"turns,omitempty"`     // per-turn timing and work, oldest first
+	Usage     provider.Usage     `json:"usage"`               // API-reported tokens across all turns
+	Cost      record.Cost        `json:"cost"`                // what Usage cost, as far as models have prices
+	AutoRuns  []record.AutoRun   `json:"auto_runs,omitempty"` // autonomous runs (/auto, --auto) with their checkpoints
+	Changes   []record.Change    `json:"changes,omitempty"`   // calls to tools that may change the machine (session changes)
 }
 
 func NewID() string {
@@ -34,7 +50,7 @@ func NewID() string {
 }
 
 func path(id string) string {
-	return filepath.Join(Dir, id+".json")
+	return filepath.Join(Dir(), id+".json")
 }
 
 func New(id, agent, model string) *Session {
@@ -57,22 +73,119 @@ func Load(id string) (*Session, error) {
 	return &s, nil
 }
 
+// Save writes the session to its file. It writes a temp file and renames it
+// over the old one, so a crash mid-write leaves the previous save intact.
 func (s *Session) Save() error {
-	os.MkdirAll(Dir, 0755)
+	os.MkdirAll(Dir(), 0755)
 	s.UpdatedAt = time.Now()
 	data, err := json.Marshal(s)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path(s.ID), data, 0644)
+	f, err := os.CreateTemp(Dir(), s.ID+".json.tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path(s.ID))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
 }
 
 func Remove(id string) error {
+	os.Remove(archivePath(id))
+	os.RemoveAll(BackupDir(id))
 	return os.Remove(path(id))
 }
 
+// BackupDir is where the file tools copy the files the session's calls
+// change before changing them, for file_undo: <state dir>/backups/<id>.
+func BackupDir(id string) string {
+	return filepath.Join(config.StateDir(), "backups", id)
+}
+
+// archivePath is the file beside a session's that keeps the messages
+// compression replaced, one record.Archived per line. It is kept apart so
+// the session file stays as small as the live conversation.
+func archivePath(id string) string {
+	return filepath.Join(Dir(), id+".archive.jsonl")
+}
+
+// AppendArchive adds messages compression replaced to the session's archive.
+func AppendArchive(id string, a record.Archived) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(Dir(), 0755)
+	f, err := os.OpenFile(archivePath(id), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// maxArchiveLine bounds a line of the archive: one compression's messages,
+// tool results and all.
+const maxArchiveLine = 64 << 20
+
+// LoadArchive reads the session's archive, oldest first; a session
+// compression never ran on has none. Lines that can't be read, such as one
+// cut short by a crash, are skipped, and skipped counts them.
+func LoadArchive(id string) (archive []record.Archived, skipped int, err error) {
+	f, err := os.Open(archivePath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxArchiveLine)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var a record.Archived
+		if err := json.Unmarshal(line, &a); err != nil {
+			skipped++
+			continue
+		}
+		archive = append(archive, a)
+	}
+	if err := sc.Err(); err != nil {
+		return archive, skipped, fmt.Errorf("archive of %s: %w", id, err)
+	}
+	return archive, skipped, nil
+}
+
+// ArchiveSize is the size of the session's archive in bytes, 0 if it has none.
+func ArchiveSize(id string) int64 {
+	fi, err := os.Stat(archivePath(id))
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
 func List() ([]*Session, error) {
-	entries, err := os.ReadDir(Dir)
+	entries, err := os.ReadDir(Dir())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -97,23 +210,32 @@ func List() ([]*Session, error) {
 	return sessions, nil
 }
 
-func Cleanup() {
-	entries, err := os.ReadDir(Dir)
-	if err != nil {
-		return
+// Cleanup removes the sessions unused for maxAge, with their backups; with
+// a maxAge of 0 sessions stay until they are removed with Remove (gal-cli
+// session rm). It also removes the file backups of sessions that were never
+// saved, or were deleted by hand, once they are MaxAge old.
+func Cleanup(maxAge time.Duration) {
+	if maxAge > 0 {
+		entries, _ := os.ReadDir(Dir())
+		expired := time.Now().Add(-maxAge)
+		for _, e := range entries {
+			id, ok := strings.CutSuffix(e.Name(), ".json")
+			if e.IsDir() || !ok {
+				continue
+			}
+			if s, err := Load(id); err == nil && s.UpdatedAt.Before(expired) {
+				Remove(id)
+			}
+		}
 	}
 	cutoff := time.Now().Add(-MaxAge)
-	for _, e := range entries {
-		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
-			continue
-		}
-		id := e.Name()[:len(e.Name())-5]
-		s, err := Load(id)
-		if err != nil {
+	backups, _ := os.ReadDir(filepath.Join(config.StateDir(), "backups"))
+	for _, e := range backups {
+		if _, err := os.Stat(path(e.Name())); err == nil {
 			continue
 		}
-		if s.UpdatedAt.Before(cutoff) {
-			os.Remove(path(id))
+		if fi, err := e.Info(); err == nil && fi.ModTime().Before(cutoff) {
+			os.RemoveAll(BackupDir(e.Name()))
 		}
 	}
 }
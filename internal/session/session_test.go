@@ -0,0 +1,109 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/record"
+)
+
+func TestLoadArchive(t *testing.T) {
+	t.Setenv("GAL_STATE_DIR", t.TempDir())
+	const id = "abc123"
+	if archive, skipped, err := LoadArchive(id); err != nil || archive != nil || skipped != 0 {
+		t.Fatalf("no archive: got %v, %d, %v", archive, skipped, err)
+	}
+
+	big := strings.Repeat("x", 2<<20) // longer than a default bufio.Scanner line
+	summaries := []string{"first", "big", "after the bad line"}
+	for _, s := range summaries[:2] {
+		a := record.Archived{Summary: s, Messages: []provider.Message{{Role: "user", Content: "hi"}}}
+		if s == "big" {
+			a.Messages[0].Content = big
+		}
+		if err := AppendArchive(id, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.OpenFile(archivePath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n\n")
+	f.Close()
+	if err := AppendArchive(id, record.Archived{Summary: summaries[2]}); err != nil {
+		t.Fatal(err)
+	}
+	f, _ = os.OpenFile(archivePath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	f.WriteString(`{"summary": "cut short by a cra`)
+	f.Close()
+
+	archive, skipped, err := LoadArchive(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 2 {
+		t.Errorf("skipped = %d, want 2", skipped)
+	}
+	if len(archive) != len(summaries) {
+		t.Fatalf("read %d entries, want %d", len(archive), len(summaries))
+	}
+	for i, s := range summaries {
+		if archive[i].Summary != s {
+			t.Errorf("entry %d: summary %q, want %q", i, archive[i].Summary, s)
+		}
+	}
+	if got := archive[1].Messages[0].Content; got != big {
+		t.Errorf("big entry: %d bytes of content, want %d", len(got), len(big))
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	old := time.Now().Add(-2 * MaxAge)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		keep   map[string]bool // sessions, or the backups of sessions that are gone
+	}{
+		{"session_max_age 0 keeps all", 0, map[string]bool{"old": true, "recent": true, "gone": false, "gone-recently": true}},
+		{"the default 7 days", MaxAge, map[string]bool{"old": false, "recent": true, "gone": false, "gone-recently": true}},
+		{"longer than the old one's age", 3 * MaxAge, map[string]bool{"old": true, "recent": true, "gone": false, "gone-recently": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GAL_STATE_DIR", t.TempDir())
+			os.MkdirAll(Dir(), 0o755)
+			for id, at := range map[string]time.Time{"old": old, "recent": time.Now()} {
+				s := New(id, "coder", "mock/m")
+				s.UpdatedAt = at // Save would make it now
+				data, _ := json.Marshal(s)
+				if err := os.WriteFile(path(id), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, id := range []string{"old", "recent", "gone", "gone-recently"} {
+				os.MkdirAll(BackupDir(id), 0o755)
+				if id != "gone-recently" && id != "recent" {
+					os.Chtimes(BackupDir(id), old, old)
+				}
+			}
+
+			Cleanup(tt.maxAge)
+			for id, keep := range tt.keep {
+				_, serr := os.Stat(path(id))
+				_, berr := os.Stat(BackupDir(id))
+				if id == "old" || id == "recent" {
+					if (serr == nil) != keep || (berr == nil) != keep {
+						t.Errorf("session %s: %v, backups %v; want kept %v", id, serr, berr, keep)
+					}
+				} else if (berr == nil) != keep {
+					t.Errorf("backups of %s: %v, want kept %v", id, berr, keep)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Stream calls fn for each message of a session in order, decoding one
+// message at a time so large sessions aren't held in memory. It returns
+// os.ErrNotExist (wrapped) when the session doesn't exist.
+func Stream(id string, fn func(provider.Message) error) error {
+	f, err := os.Open(path(id))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReaderSize(f, 64<<10))
+	if err := expectDelim(dec, '{'); err != nil {
+		return fmt.Errorf("parse session %s: %w", id, err)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("parse session %s: %w", id, err)
+		}
+		if key, _ := tok.(string); key != "messages" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("parse session %s: %w", id, err)
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return fmt.Errorf("parse session %s: %w", id, err)
+		}
+		for dec.More() {
+			var m provider.Message
+			if err := dec.Decode(&m); err != nil {
+				return fmt.Errorf("parse session %s: %w", id, err)
+			}
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return fmt.Errorf("parse session %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return nil
+}
+
+// Masker hides values the user entered into sensitive interactive fields.
+// Feed it every message in order with Observe, then pass text through Mask.
+type Masker struct {
+	fields map[string][]string // interactive tool call ID → sensitive field names
+	values []string
+}
+
+func NewMasker() *Masker {
+	return &Masker{fields: make(map[string][]string)}
+}
+
+// Observe records sensitive fields declared by interactive tool calls and the
+// values returned for them.
+func (mk *Masker) Observe(m provider.Message) {
+	for _, tc := range m.ToolCalls {
+		if tc.Function.Name != "interactive" {
+			continue
+		}
+		var args struct {
+			Fields []struct {
+				Name      string `json:"name"`
+				Sensitive bool   `json:"sensitive"`
+			} `json:"fields"`
+		}
+		json.Unmarshal([]byte(tc.Function.Arguments), &args)
+		for _, f := range args.Fields {
+			if f.Sensitive {
+				mk.fields[tc.ID] = append(mk.fields[tc.ID], f.Name)
+			}
+		}
+	}
+	if m.Role == "tool" && len(mk.fields[m.ToolCallID]) > 0 {
+		var res map[string]any
+		if json.Unmarshal([]byte(m.Content), &res) == nil {
+			for _, name := range mk.fields[m.ToolCallID] {
+				if v, ok := res[name].(string); ok && v != "" {
+					mk.values = append(mk.values, v)
+				}
+			}
+		}
+	}
+}
+
+// Mask replaces every observed sensitive value in s.
+func (mk *Masker) Mask(s string) string {
+	for _, v := range mk.values {
+		s = strings.ReplaceAll(s, v, "********")
+	}
+	return s
+}
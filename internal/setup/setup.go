@@ -0,0 +1,234 @@
+// Package setup builds engines and providers from the configuration, for
+// the chat command and for the agents spawn_agent runs.
+package setup
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/agent"
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// Options are the command line's overrides of agent settings.
+type Options struct {
+	TrimTools   bool    // --trim-tools
+	Temperature float64 // --temperature; 0 keeps the agent's
+}
+
+// Engine builds the engine of an agent: its tools, skills and MCP servers
+// from reg, its model's provider, and the settings gal.yaml and the agent
+// config give it.
+func Engine(cfg *config.Config, agentName string, reg *tool.Registry, opts Options) (*engine.Engine, error) {
+	agentConf, err := config.LoadAgent(agentName)
+	if err != nil {
+		return nil, err
+	}
+	a, err := agent.Build(agentConf, reg)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range agentConf.Subagents {
+		if _, err := config.LoadAgent(name); err != nil {
+			return nil, fmt.Errorf("agent %s: subagent %s: %w", agentConf.Name, name, err)
+		}
+	}
+	for _, name := range agentConf.NativeTools {
+		if err := provider.CheckNativeTool(name); err != nil {
+			return nil, fmt.Errorf("agent %s: native_tools: %w", agentConf.Name, err)
+		}
+	}
+	if len(agentConf.Subagents) > 0 {
+		a.ToolDefs = append(a.ToolDefs, engine.SpawnAgentDef(agentConf.Subagents))
+	}
+	p, err := ModelProvider(cfg, a.CurrentModel)
+	if err != nil {
+		return nil, err
+	}
+	guard := cfg.InjectionGuard || agentConf.InjectionGuard
+	if guard {
+		a.SystemPrompt += engine.InjectionGuardNote
+	}
+	eng := engine.New(a, p)
+	eng.AddSensitive(Secrets(cfg)...)
+	eng.InjectionGuard = guard
+	eng.RequireCleanGit = cfg.RequireCleanGit || agentConf.RequireCleanGit
+	eng.AutoStash = cfg.AutoStash || agentConf.AutoStash
+	eng.Propose = agentConf.Propose
+	eng.ContextLimit = cfg.ContextLimit
+	eng.DebugDir = cfg.DebugDir
+	if eng.DebugDir == "" {
+		eng.DebugDir = config.LogDir()
+	}
+	eng.DebugKeep = cfg.DebugKeep
+	cc := cfg.Compress.Merge(agentConf.Compress)
+	prompt, err := config.ReadPrompt(cc.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	comp := cfg.Compression.Merge(agentConf.Compression)
+	if err := comp.Check(); err != nil {
+		return nil, fmt.Errorf("agent %s: %w", agentConf.Name, err)
+	}
+	eng.Compression = engine.CompressSettings{
+		Prompt:       prompt,
+		Language:     cc.Language,
+		Header:       cc.Header,
+		Disabled:     comp.Enabled != nil && !*comp.Enabled,
+		TriggerRatio: comp.TriggerRatio,
+		TargetRatio:  comp.TargetRatio,
+		KeepLast:     comp.KeepLastMessages,
+	}
+	if cc.Model != "" {
+		eng.Compression.Model = cc.Model
+		eng.Compression.Provider, eng.Compression.ModelErr = ModelProvider(cfg, cc.Model)
+		if eng.Compression.ModelErr != nil {
+			eng.Compression.ModelErr = fmt.Errorf("compression_model %s: %w", cc.Model, eng.Compression.ModelErr)
+		}
+	}
+	if len(agentConf.Subagents) > 0 {
+		eng.NewSubagent = func(name string) (*engine.Engine, error) {
+			return Engine(cfg, name, reg, opts)
+		}
+		eng.MaxSubagentDepth = cfg.SubagentDepth
+	}
+	eng.ToolParallelism = cfg.ToolParallelism
+	eng.ToolResultTokens = cfg.MaxToolResultTokens
+	eng.ApprovedTools = cfg.Tools.Approve
+	eng.TrimTools = agentConf.TrimTools || opts.TrimTools
+	if err := CheckToolChoice(agentConf.ToolChoice, a.ToolDefs); err != nil {
+		return nil, fmt.Errorf("agent %s: %w", agentConf.Name, err)
+	}
+	eng.ToolChoice = agentConf.ToolChoice
+	eng.AutoContinue = agentConf.AutoContinue
+	eng.KeepPartialOnError = agentConf.KeepPartial
+	eng.KeepReplays = cfg.CollapseReplays != nil && !*cfg.CollapseReplays
+	eng.StrictToolArgs = agentConf.StrictToolArgs
+	if agentConf.MaxRounds < 0 {
+		return nil, fmt.Errorf("agent %s: max_rounds must not be negative", agentConf.Name)
+	}
+	eng.MaxRounds = agentConf.MaxRounds
+	eng.AutoLimits = engine.AutoLimits{Approve: agentConf.Auto.Approve, MaxRounds: agentConf.Auto.MaxRounds, MaxTokens: agentConf.Auto.MaxTokens}
+	eng.Pricing = make(map[string]engine.Price, len(cfg.Pricing))
+	for model, p := range cfg.Pricing {
+		eng.Pricing[model] = engine.Price{Input: p.Input, Output: p.Output, Cached: p.Cached}
+	}
+	if opts.Temperature != 0 {
+		a.Params.Temperature = opts.Temperature
+	}
+	eng.ToolLimits = make(map[string]engine.ToolLimit)
+	eng.ModelWindows = make(map[string]int)
+	eng.ModelCaps = make(map[string]engine.Capabilities)
+	for name, pc := range cfg.Providers {
+		eng.ToolLimits[name] = engine.ToolLimit{MaxTools: pc.MaxTools, MaxBytes: pc.MaxToolBytes}
+		for _, mc := range pc.Models {
+			if mc.Context > 0 {
+				eng.ModelWindows[name+"/"+mc.Name] = mc.Context
+			}
+			if c := mc.Capabilities; c.Tools != nil || c.Vision != nil || c.Reasoning != nil {
+				eng.ModelCaps[name+"/"+mc.Name] = modelCapabilities(mc)
+			}
+		}
+	}
+	return eng, nil
+}
+
+// CheckToolChoice rejects a tool_choice naming a tool the agent doesn't have.
+func CheckToolChoice(choice string, defs []provider.ToolDef) error {
+	if !provider.ForcesTool(choice) || choice == provider.ToolChoiceRequired {
+		return nil
+	}
+	if !slices.ContainsFunc(defs, func(d provider.ToolDef) bool { return d.Name == choice }) {
+		return fmt.Errorf("tool_choice %q is not auto, none, required or one of the agent's tools", choice)
+	}
+	return nil
+}
+
+// modelCapabilities applies a model entry's capability overrides to the
+// built-in ones.
+func modelCapabilities(mc config.ModelConf) engine.Capabilities {
+	caps := engine.ModelCapabilities(mc.Name)
+	if c := mc.Capabilities.Tools; c != nil {
+		caps.Tools = *c
+	}
+	if c := mc.Capabilities.Vision; c != nil {
+		caps.Vision = *c
+	}
+	if c := mc.Capabilities.Reasoning; c != nil {
+		caps.Reasoning = *c
+	}
+	return caps
+}
+
+// Secrets returns the API keys and credential headers of the configured
+// providers, for the engine to mask in its debug log.
+func Secrets(cfg *config.Config) []string {
+	var secrets []string
+	for _, pConf := range cfg.Providers {
+		secrets = append(secrets, os.ExpandEnv(pConf.APIKey))
+		for k, v := range pConf.Headers {
+			if provider.SecretHeader(k) {
+				v = os.ExpandEnv(v)
+				secrets = append(secrets, v)
+				if _, token, ok := strings.Cut(v, " "); ok {
+					secrets = append(secrets, token) // "Bearer <token>"
+				}
+			}
+		}
+	}
+	return secrets
+}
+
+// ModelProvider makes the provider of a "provider/model" name.
+func ModelProvider(cfg *config.Config, model string) (provider.Provider, error) {
+	name, id, ok := strings.Cut(model, "/")
+	if !ok || name == "" || id == "" {
+		return nil, fmt.Errorf("invalid model format: %s (expected provider/model)", model)
+	}
+	return Provider(cfg, name)
+}
+
+// Provider makes the provider configured as providerName.
+func Provider(cfg *config.Config, providerName string) (provider.Provider, error) {
+	pConf, ok := cfg.Providers[providerName]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", providerName)
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	retries := cfg.Retries
+	noStreamUsage := pConf.StreamUsage != nil && !*pConf.StreamUsage
+	noStream := pConf.Stream != nil && !*pConf.Stream
+	var headers map[string]string
+	if len(pConf.Headers) > 0 {
+		headers = make(map[string]string, len(pConf.Headers))
+		for k, v := range pConf.Headers {
+			headers[k] = os.ExpandEnv(v)
+		}
+	}
+	switch pConf.Type {
+	case "mock":
+		replies, err := provider.LoadMockScript(os.ExpandEnv(pConf.Script))
+		if err != nil {
+			return nil, err
+		}
+		return &provider.Mock{Replies: replies}, nil
+	case "anthropic":
+		return &provider.Anthropic{APIKey: os.ExpandEnv(pConf.APIKey), BaseURL: pConf.BaseURL, MaxTokens: pConf.MaxTokens, PromptCache: pConf.PromptCache, NoStream: noStream, MaxStreamLine: pConf.MaxStreamLine, Headers: headers, ModelParams: pConf.ModelParams, Timeout: timeout, Retries: retries}, nil
+	case "azure":
+		apiVersion := pConf.APIVersion
+		if apiVersion == "" {
+			apiVersion = "2024-06-01"
+		}
+		return &provider.OpenAI{APIKey: os.ExpandEnv(pConf.APIKey), BaseURL: pConf.BaseURL, APIVersion: apiVersion, Deployment: pConf.Deployment, NoStreamUsage: noStreamUsage, NoStream: noStream, MaxStreamLine: pConf.MaxStreamLine, Headers: headers, ModelParams: pConf.ModelParams, Timeout: timeout, Retries: retries}, nil
+	case "ollama":
+		return &provider.Ollama{BaseURL: pConf.BaseURL, KeepAlive: pConf.KeepAlive, Options: pConf.Options, MaxStreamLine: pConf.MaxStreamLine, Headers: headers, ModelParams: pConf.ModelParams, Timeout: timeout, Retries: retries}, nil
+	default:
+		return &provider.OpenAI{APIKey: os.ExpandEnv(pConf.APIKey), BaseURL: pConf.BaseURL, NoStreamUsage: noStreamUsage, NoStream: noStream, MaxStreamLine: pConf.MaxStreamLine, Headers: headers, ModelParams: pConf.ModelParams, Timeout: timeout, Retries: retries}, nil
+	}
+}
@@ -0,0 +1,93 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+func TestDebugLogMasksSecrets(t *testing.T) {
+	const (
+		key      = "sk-test-9f8e7d6c5b4a39281706"
+		otherKey = "sk-ant-REDACTED"
+		token    = "tok-a1b2c3d4e5f60718293a"
+	)
+	// a gateway that answers, then refuses with the credentials it got in
+	// the error, as some do
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests%2 == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"error":{"message":"rejected %s and %s"}}`, r.Header.Get("Authorization"), r.Header.Get("X-Gateway-Token"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"yes\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	for _, format := range []string{"text", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("GAL_CONFIG_DIR", dir)
+			t.Setenv("GAL_STATE_DIR", filepath.Join(dir, "state"))
+			t.Setenv("HOME", dir)
+			t.Setenv("TEST_API_KEY", key)
+			t.Setenv("TEST_GATEWAY_TOKEN", token)
+			os.Mkdir(filepath.Join(dir, "agents"), 0o755)
+			gal := "default_agent: test\ndebug_dir: " + filepath.Join(dir, "debug") + "\nproviders:\n" +
+				"  gw:\n    type: openai\n    base_url: " + srv.URL + "\n    api_key: ${TEST_API_KEY}\n    headers:\n      X-Gateway-Token: Bearer ${TEST_GATEWAY_TOKEN}\n" +
+				"  other:\n    type: anthropic\n    api_key: " + otherKey + "\n"
+			os.WriteFile(filepath.Join(dir, "gal.yaml"), []byte(gal), 0o644)
+			os.WriteFile(filepath.Join(dir, "agents", "test.yaml"), []byte("name: test\nsystem_prompt: Answer briefly.\nmodels: [gw/m]\ndefault_model: gw/m\n"), 0o644)
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			eng, err := Engine(cfg, "test", tool.NewRegistry(), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			eng.DebugFormat = format
+			eng.InitDebug()
+			// a key the user pastes into the chat is masked as well
+			if err := eng.SendWithInteractive(context.Background(), "is "+otherKey+" still valid?", nil, nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := eng.SendWithInteractive(context.Background(), "and now?", nil, nil, nil, nil); err == nil {
+				t.Fatal("the gateway's refusal was no error")
+			}
+			eng.Close()
+
+			logs, _ := filepath.Glob(filepath.Join(dir, "debug", "gal-debug-*"))
+			if len(logs) != 1 {
+				t.Fatalf("debug logs %v, want one", logs)
+			}
+			data, err := os.ReadFile(logs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			log := string(data)
+			if !strings.Contains(log, "rejected") {
+				t.Fatalf("the log lacks the refusal:\n%s", log)
+			}
+			for _, secret := range []string{key, otherKey, token} {
+				if strings.Contains(log, secret) {
+					t.Errorf("the log has %s:\n%s", secret, log)
+				}
+			}
+			if fi, err := os.Stat(logs[0]); err == nil && fi.Mode().Perm() != 0o600 {
+				t.Errorf("the log's mode is %v, want 0600", fi.Mode().Perm())
+			}
+		})
+	}
+}
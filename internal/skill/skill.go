@@ -6,8 +6,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/gal-cli/gal-cli/internal/config"
 	"github.com/gal-cli/gal-cli/internal/provider"
 	"github.com/gal-cli/gal-cli/internal/tool"
 )
@@ -43,9 +45,9 @@ func Load(dir string) (*Skill, error) {
 		if e.IsDir() {
 			continue
 		}
-		// skip non-executable files
+		// skip files that are neither executable nor runnable via a known interpreter
 		info, err := e.Info()
-		if err != nil || info.Mode()&0111 == 0 {
+		if err != nil || (!isExecutable(info) && interpreterFor(e.Name()) == nil) {
 			fmt.Fprintf(os.Stderr, "⚠ skill %s: skipping non-executable %s\n", name, e.Name())
 			continue
 		}
@@ -77,8 +79,7 @@ func Load(dir string) (*Skill, error) {
 // Resolve finds a skill directory by name, searching local then global paths.
 func Resolve(name string) (string, error) {
 	// user-global (standard directory)
-	home, _ := os.UserHomeDir()
-	global := filepath.Join(home, ".gal", "skills", name)
+	global := filepath.Join(config.GalDir(), "skills", name)
 	if info, err := os.Stat(global); err == nil && info.IsDir() {
 		return global, nil
 	}
@@ -111,6 +112,7 @@ func RegisterScripts(s *Skill, reg *tool.Registry) {
 		// ensure script is executable
 		os.Chmod(fullPath, 0755)
 		fp := fullPath // capture
+		reg.SetConflictGroup(def.Name, "skill:"+s.Name)
 		reg.Register(def, func(ctx context.Context, args map[string]any) (string, error) {
 			input, _ := args["input"].(string)
 			cmdArgs, _ := args["args"].(string)
@@ -118,7 +120,7 @@ func RegisterScripts(s *Skill, reg *tool.Registry) {
 			if cmdArgs != "" {
 				parts = strings.Fields(cmdArgs)
 			}
-			cmd := exec.CommandContext(ctx, fp, parts...)
+			cmd := scriptCommand(ctx, fp, parts)
 			if input != "" {
 				cmd.Stdin = strings.NewReader(input)
 			}
@@ -131,3 +133,46 @@ func RegisterScripts(s *Skill, reg *tool.Registry) {
 		})
 	}
 }
+
+// interpreters maps script extensions to the command used to run them when the
+// file can't be executed directly (always the case on Windows).
+var interpreters = map[string][]string{
+	".sh":  {"bash"},
+	".py":  {"python3"},
+	".js":  {"node"},
+	".rb":  {"ruby"},
+	".ps1": {"powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File"},
+	".bat": {"cmd", "/C"},
+	".cmd": {"cmd", "/C"},
+}
+
+// interpreterFor returns the interpreter command for a script, or nil when the
+// script should be executed directly.
+func interpreterFor(file string) []string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if runtime.GOOS == "windows" && ext == ".py" {
+		return []string{"python"}
+	}
+	return interpreters[ext]
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+	}
+	return info.Mode()&0111 != 0
+}
+
+// scriptCommand runs executable scripts directly and falls back to the
+// interpreter map for everything else.
+func scriptCommand(ctx context.Context, path string, args []string) *exec.Cmd {
+	if info, err := os.Stat(path); err == nil && isExecutable(info) {
+		return exec.CommandContext(ctx, path, args...)
+	}
+	interp := interpreterFor(path)
+	if interp == nil {
+		return exec.CommandContext(ctx, path, args...)
+	}
+	argv := append(append(append([]string{}, interp[1:]...), path), args...)
+	return exec.CommandContext(ctx, interp[0], argv...)
+}
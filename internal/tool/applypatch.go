@@ -0,0 +1,620 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// maxPatchFuzz is how many context lines at a hunk's ends may be left
+// unmatched, as patch's fuzz factor.
+const maxPatchFuzz = 2
+
+func (r *Registry) registerApplyPatch() {
+	r.RegisterV2(provider.ToolDef{
+		Name:        "apply_patch",
+		Description: "Apply a unified diff to one or more files at once, for changes in several places or files: \"--- a/path\" and \"+++ b/path\" headers, then @@ hunks of context (' '), removed ('-') and added ('+') lines. Hunks may be some lines off from their @@ numbers. --- /dev/null creates a file, +++ /dev/null deletes one. Nothing is written unless every hunk of every file applies; then fix the failing hunks and send the whole patch again",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"patch": map[string]any{"type": "string", "description": "The unified diff; paths are relative to the working directory"},
+			},
+			"required": []string{"patch"},
+		},
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		files, err := parsePatch(getStr(args, "patch"))
+		if err != nil {
+			return ToolResult{}, err
+		}
+		// work out every file's new content first, so that a hunk that
+		// doesn't apply leaves all files alone
+		var plans []*patchPlan
+		var report []string
+		failed := false
+		for _, f := range files {
+			plan, err := f.plan()
+			if err != nil {
+				failed = true
+				report = append(report, fmt.Sprintf("✗ %s: %v", f.name(), err))
+				continue
+			}
+			plans = append(plans, plan)
+			report = append(report, "✓ "+plan.summary())
+		}
+		if failed {
+			return ToolResult{}, fmt.Errorf("the patch doesn't apply, nothing was written:\n%s", strings.Join(report, "\n"))
+		}
+		if err := r.writePlans(ctx, plans); err != nil {
+			return ToolResult{}, err
+		}
+
+		var sb strings.Builder
+		var diffs []string
+		paths := make([]string, len(plans))
+		var changes []*FileChange
+		fmt.Fprintf(&sb, "applied the patch to %s\n", countFiles(len(plans)))
+		for i, p := range plans {
+			paths[i] = showPath(p.path)
+			changes = append(changes, p.changes()...)
+			sb.WriteString(report[i] + "\n")
+			if p.diff != "" {
+				diffs = append(diffs, p.diff)
+				sb.WriteString(p.diff + "\n")
+			}
+		}
+		return ToolResult{
+			Text:    strings.TrimRight(sb.String(), "\n"),
+			Meta:    map[string]any{"paths": paths, "files": len(plans), "diff": strings.Join(diffs, "\n")},
+			Changes: changes,
+		}, nil
+	})
+}
+
+// PatchFiles returns the files a unified diff changes, absolute, or nil if
+// it doesn't parse.
+func PatchFiles(patch string) []string {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, f := range files {
+		for _, p := range []string{f.oldPath, f.newPath} {
+			if abs, err := resolvePath(p); p != "" && err == nil {
+				paths = append(paths, abs)
+			}
+		}
+	}
+	return paths
+}
+
+// filePatch is one file's part of a unified diff. oldPath is "" for a file
+// the diff creates, newPath "" for one it deletes.
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []*hunk
+}
+
+// hunk is one @@ section: its lines keep their ' ', '-' or '+'.
+type hunk struct {
+	header   string
+	oldStart int // 1-based, as the header has it; 0 when it has no numbers
+	oldCount int // -1 when the header has no numbers
+	lines    []string
+	noEOL    []int // the lines "\ No newline at end of file" follows
+}
+
+// parsePatch splits a unified diff into its files. Text before the first
+// header, "diff --git" and "index" lines are skipped.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var files []*filePatch
+	var f *filePatch
+	var h *hunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			f = &filePatch{oldPath: patchPath(line[4:], "a/"), newPath: patchPath(lines[i+1][4:], "b/")}
+			if f.oldPath == "" && f.newPath == "" {
+				return nil, fmt.Errorf("line %d: both paths are /dev/null", i+1)
+			}
+			files = append(files, f)
+			h = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if f == nil {
+				return nil, fmt.Errorf("line %d: hunk before any --- / +++ file header", i+1)
+			}
+			h = &hunk{header: line}
+			h.oldStart, h.oldCount = parseHunkHeader(line)
+			f.hunks = append(f.hunks, h)
+		case h == nil:
+			// headers and text around the diff
+		case strings.HasPrefix(line, `\`):
+			if len(h.lines) > 0 {
+				h.noEOL = append(h.noEOL, len(h.lines)-1)
+			}
+		case line == "":
+			h.lines = append(h.lines, " ") // an empty context line whose space got lost
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			h.lines = append(h.lines, line)
+		default:
+			h = nil // the hunk is over; what follows is text until the next header
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file headers (--- a/path, +++ b/path) in the patch")
+	}
+	seen := map[string]bool{}
+	for _, f := range files {
+		for _, h := range f.hunks {
+			h.trimBlankTail()
+		}
+		if len(f.hunks) == 0 && f.oldPath != "" && f.newPath != "" {
+			return nil, fmt.Errorf("%s: no hunks", f.name())
+		}
+		// a second section would apply to what the first one left, with
+		// line numbers of the file as it was; which is meant isn't clear
+		keys := map[string]bool{}
+		for _, p := range []string{f.oldPath, f.newPath} {
+			if p == "" {
+				continue
+			}
+			key := p
+			if abs, err := resolvePath(p); err == nil {
+				key = abs
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("%s: the patch has two sections for it; put all its hunks under one --- / +++ header", p)
+			}
+			keys[key] = true
+		}
+		for key := range keys {
+			seen[key] = true
+		}
+	}
+	return files, nil
+}
+
+// patchPath returns a header's path without git's a/ or b/ prefix and the
+// timestamp diff puts after a tab; "" for /dev/null.
+func patchPath(s, prefix string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, prefix) {
+		if _, err := os.Stat(s); err != nil {
+			s = s[len(prefix):]
+		}
+	}
+	return s
+}
+
+// parseHunkHeader reads the old side of "@@ -12,5 +12,7 @@"; the new side
+// follows from the lines. A bare "@@" gives 0 and -1: the hunk is looked
+// for after the one before.
+func parseHunkHeader(line string) (start, count int) {
+	old, ok := strings.CutPrefix(line, "@@ -")
+	if !ok {
+		return 0, -1
+	}
+	old, _, _ = strings.Cut(old, " ")
+	s, c, ok := strings.Cut(old, ",")
+	start, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, -1
+	}
+	count = 1
+	if ok {
+		if count, err = strconv.Atoi(c); err != nil {
+			return 0, -1
+		}
+	}
+	return start, count
+}
+
+// trimBlankTail drops the empty lines between a hunk and what follows it,
+// which the parser took for context: those beyond the header's count, or
+// all of them without one. Less context still applies.
+func (h *hunk) trimBlankTail() {
+	old := 0
+	for _, l := range h.lines {
+		if l[0] != '+' {
+			old++
+		}
+	}
+	for (h.oldCount < 0 || old > h.oldCount) && len(h.lines) > 0 && h.lines[len(h.lines)-1] == " " {
+		h.lines = h.lines[:len(h.lines)-1]
+		old--
+	}
+}
+
+// old returns the lines the hunk expects in the file: its context and
+// removed lines.
+func (h *hunk) old() []string {
+	var old []string
+	for _, l := range h.lines {
+		if l[0] != '+' {
+			old = append(old, l[1:])
+		}
+	}
+	return old
+}
+
+// replace returns what the hunk makes of the file lines it matched, all but
+// head and tail context lines at its ends. Context lines stay as the file
+// has them, which may differ in trailing whitespace.
+func (h *hunk) replace(matched []string, head, tail int) []string {
+	var out []string
+	j := 0
+	for _, l := range h.lines[head : len(h.lines)-tail] {
+		switch l[0] {
+		case ' ':
+			out = append(out, matched[j])
+			j++
+		case '-':
+			j++
+		case '+':
+			out = append(out, l[1:])
+		}
+	}
+	return out
+}
+
+// endsWithoutEOL tells whether the hunk says the file's last line has no
+// line end, before and after it applies.
+func (h *hunk) endsWithoutEOL() (old, new bool) {
+	lastOld, lastNew := -1, -1
+	for i, l := range h.lines {
+		if l[0] != '+' {
+			lastOld = i
+		}
+		if l[0] != '-' {
+			lastNew = i
+		}
+	}
+	return slices.Contains(h.noEOL, lastOld), slices.Contains(h.noEOL, lastNew)
+}
+
+func (f *filePatch) name() string {
+	if f.newPath != "" {
+		return f.newPath
+	}
+	return f.oldPath
+}
+
+// patchPlan is what applying one file's hunks comes to, before any of it
+// is written.
+type patchPlan struct {
+	path    string // absolute; where the result goes
+	from    string // absolute; the file renamed to path, if any
+	before  string
+	after   string
+	created bool
+	deleted bool
+	mode    fs.FileMode // the file's permissions, which a renamed one keeps
+	hunks   int
+	notes   []string // hunks that applied off their line or with fuzz
+	diff    string
+}
+
+// plan applies f's hunks to its file's content in memory.
+func (f *filePatch) plan() (*patchPlan, error) {
+	p := &patchPlan{hunks: len(f.hunks), mode: 0644}
+	var err error
+	if f.newPath != "" {
+		if p.path, err = resolvePath(f.newPath); err != nil {
+			return nil, err
+		}
+	}
+	var content string
+	if f.oldPath == "" {
+		p.created = true
+		if _, err := os.Stat(p.path); err == nil {
+			return nil, errors.New("the diff creates it, but it exists already")
+		}
+	} else {
+		from, err := resolvePath(f.oldPath)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(from)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.New("no such file")
+		} else if err != nil {
+			return nil, err
+		}
+		content = string(data)
+		p.before = content
+		if fi, err := os.Stat(from); err == nil {
+			p.mode = fi.Mode().Perm()
+		}
+		switch {
+		case f.newPath == "":
+			p.path, p.deleted = from, true
+		case from != p.path:
+			p.from = from
+		}
+	}
+
+	lines, eol, finalEOL := splitLines(content)
+	if p.created {
+		finalEOL = true
+	}
+	var diff []string
+	pos, offset := 0, 0 // where the next hunk may start, and how far hunks have been off
+	for i, h := range f.hunks {
+		old := h.old()
+		want := h.oldStart - 1 + offset
+		switch h.oldCount {
+		case 0:
+			want++ // "-5,0" adds after line 5
+		case -1:
+			want = pos
+		}
+		start, head, tail, err := findHunk(lines, old, h, want, pos)
+		if err != nil {
+			return nil, fmt.Errorf("hunk %d (%s) %v", i+1, h.header, err)
+		}
+		if start != want+head && h.oldCount >= 0 || head+tail > 0 {
+			note := fmt.Sprintf("hunk %d at line %d", i+1, start-head+1)
+			if head+tail > 0 {
+				note += fmt.Sprintf(" with fuzz %d", max(head, tail))
+			}
+			p.notes = append(p.notes, note)
+		}
+		matched := len(old) - head - tail
+		repl := h.replace(lines[start:start+matched], head, tail)
+		lines = append(lines[:start], append(repl, lines[start+matched:]...)...)
+		if start+len(repl) == len(lines) {
+			// the hunk reaches the end of the file
+			switch oldEnd, newEnd := h.endsWithoutEOL(); {
+			case newEnd:
+				finalEOL = false
+			case oldEnd:
+				finalEOL = true
+			}
+		}
+		offset += len(repl) - matched
+		pos = start + len(repl)
+		diff = append(diff, hunkDiff(h))
+	}
+	if p.deleted {
+		if len(lines) > 0 && !(len(lines) == 1 && lines[0] == "") {
+			return nil, fmt.Errorf("the diff deletes it, but %d lines are left after its hunks", len(lines))
+		}
+	} else {
+		p.after = joinLines(lines, eol, finalEOL)
+	}
+	p.diff = strings.Join(diff, "\n ...\n")
+	return p, nil
+}
+
+// findHunk finds where a hunk's old lines are in lines, at or after from and
+// nearest to want: exactly, then ignoring trailing whitespace, then leaving
+// up to maxPatchFuzz context lines at either end unmatched. It returns the
+// line the matched lines start at and how many context lines were left
+// unmatched at the start (head) and end (tail).
+func findHunk(lines, old []string, h *hunk, want, from int) (start, head, tail int, err error) {
+	if len(old) == 0 {
+		// only added lines: they go where the header says
+		return max(from, min(want, len(lines))), 0, 0, nil
+	}
+	lead, trail := h.contextEnds()
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		head, tail = fuzzEnd(lead, fuzz), fuzzEnd(trail, fuzz)
+		if fuzz > 0 && head+tail == 0 || head+tail >= len(old) {
+			break
+		}
+		core := old[head : len(old)-tail]
+		for _, eq := range []func(a, b string) bool{
+			func(a, b string) bool { return a == b },
+			func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+		} {
+			if at, ok := nearestMatch(lines, core, want+head, from, eq); ok {
+				return at, head, tail, nil
+			}
+		}
+	}
+	return 0, 0, 0, mismatch(lines, old, max(want, from))
+}
+
+// fuzzEnd is how many of the n context lines at a hunk's end fuzz leaves
+// unmatched.
+func fuzzEnd(n, fuzz int) int {
+	return min(n, fuzz)
+}
+
+// contextEnds counts the context lines a hunk starts and ends with.
+func (h *hunk) contextEnds() (lead, trail int) {
+	for lead < len(h.lines) && h.lines[lead][0] == ' ' {
+		lead++
+	}
+	for trail < len(h.lines)-lead && h.lines[len(h.lines)-1-trail][0] == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// nearestMatch finds core in lines at or after from, nearest to want.
+func nearestMatch(lines, core []string, want, from int, eq func(a, b string) bool) (int, bool) {
+	matches := func(at int) bool {
+		if at < from || at+len(core) > len(lines) {
+			return false
+		}
+		for i, l := range core {
+			if !eq(lines[at+i], l) {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; d <= max(want, len(lines)); d++ {
+		if matches(want - d) {
+			return want - d, true
+		}
+		if d > 0 && matches(want+d) {
+			return want + d, true
+		}
+	}
+	return 0, false
+}
+
+// mismatch says where a hunk's old lines and the file part ways, at the
+// line the hunk was expected at.
+func mismatch(lines, old []string, at int) error {
+	for i, l := range old {
+		n := at + i
+		if n >= len(lines) {
+			return fmt.Errorf("doesn't match: the file ends at line %d, the hunk expects %q at line %d", len(lines), l, n+1)
+		}
+		if lines[n] != l {
+			return fmt.Errorf("doesn't match: line %d is %q, the hunk expects %q (nor does it match elsewhere)", n+1, lines[n], l)
+		}
+	}
+	return errors.New("doesn't match the file")
+}
+
+// hunkDiff renders a hunk the way FormatDiff renders changes: removed and
+// added lines, the context left out.
+func hunkDiff(h *hunk) string {
+	var out []string
+	for _, l := range h.lines {
+		if l[0] != ' ' {
+			out = append(out, l[:1]+" "+l[1:])
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitLines splits content into lines without their line ends, which it
+// returns: "\r\n" if the file uses them. finalEOL tells whether the last
+// line has one.
+func splitLines(content string) (lines []string, eol string, finalEOL bool) {
+	eol = "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	if content == "" {
+		return nil, eol, false
+	}
+	lines = strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1], eol, true
+	}
+	return lines, eol, false
+}
+
+func joinLines(lines []string, eol string, finalEOL bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	s := strings.Join(lines, eol)
+	if finalEOL {
+		s += eol
+	}
+	return s
+}
+
+// summary is the plan's line in the report.
+func (p *patchPlan) summary() string {
+	var s string
+	switch {
+	case p.created:
+		s = "created " + showPath(p.path)
+	case p.deleted:
+		s = "deleted " + showPath(p.path)
+	case p.from != "":
+		s = fmt.Sprintf("renamed %s to %s", showPath(p.from), showPath(p.path))
+	default:
+		s = "patched " + showPath(p.path)
+	}
+	if p.hunks > 0 {
+		s += fmt.Sprintf(" (%d %s", p.hunks, map[bool]string{true: "hunk", false: "hunks"}[p.hunks == 1])
+		if len(p.notes) > 0 {
+			s += "; " + strings.Join(p.notes, ", ")
+		}
+		s += ")"
+	}
+	return s
+}
+
+// changes is the plan as the session's changelog has it: a rename is the
+// old file removed and the new one created.
+func (p *patchPlan) changes() []*FileChange {
+	switch {
+	case p.deleted:
+		return []*FileChange{{Path: p.path, Before: p.before, Deleted: true, Mode: p.mode}}
+	case p.from != "":
+		return []*FileChange{{Path: p.from, Before: p.before, Deleted: true, Mode: p.mode}, {Path: p.path, After: p.after, Created: true}}
+	}
+	return []*FileChange{{Path: p.path, Before: p.before, After: p.after, Created: p.created}}
+}
+
+// writePlans writes the plans' results, backing each file up first. When a
+// write fails, the files written before it are put back.
+func (r *Registry) writePlans(ctx context.Context, plans []*patchPlan) error {
+	var done []*patchPlan
+	undo := func() {
+		for _, p := range done {
+			if p.created || p.from != "" {
+				os.Remove(p.path)
+			}
+			if !p.created {
+				orig := p.path
+				if p.from != "" {
+					orig = p.from
+				}
+				os.WriteFile(orig, []byte(p.before), p.mode)
+				os.Chmod(orig, p.mode)
+			}
+		}
+	}
+	for _, p := range plans {
+		for _, path := range []string{p.from, p.path} {
+			if path != "" {
+				if err := r.backup(ctx, "apply_patch", path); err != nil {
+					undo()
+					return err
+				}
+			}
+		}
+		var err error
+		switch {
+		case p.deleted:
+			err = os.Remove(p.path)
+		default:
+			os.MkdirAll(filepath.Dir(p.path), 0755)
+			if err = os.WriteFile(p.path, []byte(p.after), p.mode); err == nil && p.from != "" {
+				if err = os.Chmod(p.path, p.mode); err == nil {
+					err = os.Remove(p.from)
+				}
+			}
+		}
+		if err != nil {
+			undo()
+			return fmt.Errorf("writing %s failed, the files written before it were put back: %w", showPath(p.path), err)
+		}
+		done = append(done, p)
+	}
+	return nil
+}
+
+// countFiles says "1 file" or "n files".
+func countFiles(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
@@ -0,0 +1,181 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const abc = "a\nb\nc\n"
+	tests := []struct {
+		name    string
+		files   map[string]string // before, by name in the test's directory
+		patch   string            // DIR/ stands for the test's directory
+		want    map[string]string // after; "" for a file that mustn't exist
+		wantErr string
+		note    string // in the result, for hunks that applied off their line
+	}{
+		{
+			name:  "exact",
+			files: map[string]string{"f": abc},
+			patch: "--- a/DIR/f\n+++ b/DIR/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"f": "a\nB\nc\n"},
+		},
+		{
+			name:  "drift",
+			files: map[string]string{"f": "x\ny\nz\n" + abc},
+			patch: "--- DIR/f\n+++ DIR/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"f": "x\ny\nz\na\nB\nc\n"},
+			note:  "hunk 1 at line 4",
+		},
+		{
+			name:  "fuzz",
+			files: map[string]string{"f": "A\nb\nc\n"},
+			patch: "--- DIR/f\n+++ DIR/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"f": "A\nB\nc\n"},
+			note:  "with fuzz 1",
+		},
+		{
+			name:    "context mismatch",
+			files:   map[string]string{"f": "a\nq\nc\n"},
+			patch:   "--- DIR/f\n+++ DIR/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    map[string]string{"f": "a\nq\nc\n"},
+			wantErr: `line 2 is "q", the hunk expects "b"`,
+		},
+		{
+			name:  "CRLF file",
+			files: map[string]string{"f": "a\r\nb\r\nc\r\n"},
+			patch: "--- DIR/f\n+++ DIR/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"f": "a\r\nB\r\nc\r\n"},
+		},
+		{
+			name:  "CRLF patch",
+			files: map[string]string{"f": abc},
+			patch: "--- DIR/f\r\n+++ DIR/f\r\n@@ -1,3 +1,3 @@\r\n a\r\n-b\r\n+B\r\n c\r\n",
+			want:  map[string]string{"f": "a\nB\nc\n"},
+		},
+		{
+			name:  "no newline at end of file",
+			files: map[string]string{"f": "a\nb"},
+			patch: "--- DIR/f\n+++ DIR/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+B\n\\ No newline at end of file\n",
+			want:  map[string]string{"f": "a\nB"},
+		},
+		{
+			name:  "newline added at end of file",
+			files: map[string]string{"f": "a\nb"},
+			patch: "--- DIR/f\n+++ DIR/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			want:  map[string]string{"f": "a\nb\n"},
+		},
+		{
+			name:  "create",
+			patch: "--- /dev/null\n+++ b/DIR/new\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+			want:  map[string]string{"new": "x\ny\n"},
+		},
+		{
+			name:    "create over a file",
+			files:   map[string]string{"new": "old\n"},
+			patch:   "--- /dev/null\n+++ b/DIR/new\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+			want:    map[string]string{"new": "old\n"},
+			wantErr: "exists already",
+		},
+		{
+			name:  "delete",
+			files: map[string]string{"f": abc},
+			patch: "--- a/DIR/f\n+++ /dev/null\n@@ -1,3 +0,0 @@\n-a\n-b\n-c\n",
+			want:  map[string]string{"f": ""},
+		},
+		{
+			name:    "delete with lines left",
+			files:   map[string]string{"f": abc},
+			patch:   "--- a/DIR/f\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+			want:    map[string]string{"f": abc},
+			wantErr: "1 lines are left",
+		},
+		{
+			name:  "rename",
+			files: map[string]string{"f": abc},
+			patch: "--- a/DIR/f\n+++ b/DIR/g\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  map[string]string{"f": "", "g": "a\nB\nc\n"},
+		},
+		{
+			name:    "a file twice",
+			files:   map[string]string{"f": abc},
+			patch:   "--- DIR/f\n+++ DIR/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n--- DIR/f\n+++ DIR/f\n@@ -3 +3 @@\n-c\n+C\n",
+			want:    map[string]string{"f": abc},
+			wantErr: "two sections",
+		},
+		{
+			name:    "a rename onto a patched file",
+			files:   map[string]string{"f": abc, "g": abc},
+			patch:   "--- DIR/g\n+++ DIR/g\n@@ -1 +1 @@\n-a\n+A\n--- DIR/f\n+++ DIR/g\n@@ -1 +1 @@\n-a\n+A\n",
+			want:    map[string]string{"f": abc, "g": abc},
+			wantErr: "two sections",
+		},
+		{
+			name:    "one bad hunk writes nothing",
+			files:   map[string]string{"f": abc, "g": abc},
+			patch:   "--- DIR/f\n+++ DIR/f\n@@ -1 +1 @@\n-a\n+A\n--- DIR/g\n+++ DIR/g\n@@ -1 +1 @@\n-z\n+Z\n",
+			want:    map[string]string{"f": abc, "g": abc},
+			wantErr: "nothing was written",
+		},
+	}
+	r := NewRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			patch := strings.ReplaceAll(tt.patch, "DIR/", dir+"/")
+			res, err := r.ExecuteV2(context.Background(), "apply_patch", map[string]any{"patch": patch})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error %v, want one with %q", err, tt.wantErr)
+			case tt.note != "" && !strings.Contains(res.Text, tt.note):
+				t.Errorf("result %q doesn't say %q", res.Text, tt.note)
+			}
+			for name, want := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				switch {
+				case want == "" && err == nil:
+					t.Errorf("%s exists, want it gone", name)
+				case want != "" && err != nil:
+					t.Errorf("%s: %v", name, err)
+				case string(data) != want:
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPatchRenameKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows files have no executable bit to keep")
+	}
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "run.sh"), filepath.Join(dir, "start.sh")
+	if err := os.WriteFile(from, []byte("#!/bin/sh\necho hi\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(from, 0o755)
+	patch := "--- a/" + from + "\n+++ b/" + to + "\n@@ -1,2 +1,2 @@\n #!/bin/sh\n-echo hi\n+echo hello\n"
+	if _, err := NewRegistry().ExecuteV2(context.Background(), "apply_patch", map[string]any{"patch": patch}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o755 {
+		t.Errorf("renamed file's mode is %v, want %v", fi.Mode().Perm(), os.FileMode(0o755))
+	}
+}
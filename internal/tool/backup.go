@@ -0,0 +1,315 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Backup is a copy of a file taken before a file tool changed it, in the
+// session's journal.
+type Backup struct {
+	Seq  int // 1-based, also across runs of the session
+	Path string
+	File string      // the copy; "" when the tool created the file
+	Mode fs.FileMode // the file's permissions when it was copied
+	Tool string
+	Time time.Time
+}
+
+// backupJournal is what SetBackupDir turns on: the backups the session
+// took, oldest first, those file_undo restored left out. The copies stay in
+// dir until the session is removed.
+type backupJournal struct {
+	mu   sync.Mutex
+	dir  string
+	seq  int
+	list []Backup
+}
+
+// backupIndex is the file in the backup dir that keeps what the copies'
+// names, <seq>_<base name>, don't tell: one backupEntry per line, appended
+// as backups are taken and restored.
+const backupIndex = "index.jsonl"
+
+// backupEntry is a line of backupIndex: a backup, or with Undone, that
+// file_undo restored backup Seq.
+type backupEntry struct {
+	Seq    int         `json:"seq"`
+	Path   string      `json:"path,omitempty"`
+	File   string      `json:"file,omitempty"` // the copy's name in the dir
+	Mode   fs.FileMode `json:"mode,omitempty"`
+	Tool   string      `json:"tool,omitempty"`
+	Time   time.Time   `json:"time"`
+	Undone bool        `json:"undone,omitempty"`
+}
+
+// SetBackupDir makes file_write, file_edit, file_patch and apply_patch copy
+// a file into dir before changing it, for file_undo; "" turns that off.
+// The journal a previous run of the session left in dir is read back, and
+// numbering goes on from it.
+func (r *Registry) SetBackupDir(dir string) {
+	j := &r.backups
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.dir, j.seq, j.list = dir, 0, nil
+	if dir != "" {
+		j.load()
+	}
+}
+
+// load rebuilds the journal from the copies in j.dir and its index. Backups
+// whose copy is gone are left out.
+func (j *backupJournal) load() {
+	copies := map[string]bool{}
+	entries, _ := os.ReadDir(j.dir)
+	for _, e := range entries {
+		n, base, _ := strings.Cut(e.Name(), "_")
+		if seq, err := strconv.Atoi(n); err == nil && base != "" {
+			j.seq = max(j.seq, seq)
+			copies[e.Name()] = true
+		}
+	}
+	f, err := os.Open(filepath.Join(j.dir, backupIndex))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e backupEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil || e.Seq <= 0 {
+			continue // cut short by a crash
+		}
+		j.seq = max(j.seq, e.Seq)
+		if e.Undone {
+			if i := j.find(e.Seq); i >= 0 {
+				j.list = append(j.list[:i], j.list[i+1:]...)
+			}
+			continue
+		}
+		b := Backup{Seq: e.Seq, Path: e.Path, Mode: e.Mode, Tool: e.Tool, Time: e.Time}
+		if e.File != "" {
+			if !copies[e.File] {
+				continue
+			}
+			b.File = filepath.Join(j.dir, e.File)
+		}
+		j.list = append(j.list, b)
+	}
+}
+
+// find returns the index in the journal of backup seq, or -1.
+func (j *backupJournal) find(seq int) int {
+	for i, b := range j.list {
+		if b.Seq == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+// note appends e to the index.
+func (j *backupJournal) note(e backupEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(j.dir, backupIndex), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Backups returns the journal, oldest first.
+func (r *Registry) Backups() []Backup {
+	j := &r.backups
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Backup(nil), j.list...)
+}
+
+type noBackupKey struct{}
+
+// WithoutBackup marks calls that don't need backups, those working on a
+// temp copy of the file.
+func WithoutBackup(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noBackupKey{}, true)
+}
+
+// backup copies the file at abs into the backup dir, or notes that it
+// doesn't exist yet, before tool changes it.
+func (r *Registry) backup(ctx context.Context, tool, abs string) error {
+	j := &r.backups
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.dir == "" || ctx.Value(noBackupKey{}) != nil {
+		return nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("can't back up %s: %w", showPath(abs), err)
+	}
+	b := Backup{Seq: j.seq + 1, Path: abs, Tool: tool, Time: time.Now()}
+	e := backupEntry{Seq: b.Seq, Path: abs, Tool: tool, Time: b.Time}
+	if err == nil {
+		if fi, err := os.Stat(abs); err == nil {
+			b.Mode = fi.Mode().Perm()
+		}
+		e.File = fmt.Sprintf("%d_%s", b.Seq, filepath.Base(abs))
+		e.Mode = b.Mode
+		b.File = filepath.Join(j.dir, e.File)
+		if err := os.MkdirAll(j.dir, 0700); err != nil {
+			return fmt.Errorf("can't back up %s: %w", showPath(abs), err)
+		}
+		if err := os.WriteFile(b.File, data, 0600); err != nil {
+			return fmt.Errorf("can't back up %s: %w", showPath(abs), err)
+		}
+	}
+	if err := j.note(e); err != nil {
+		return fmt.Errorf("can't back up %s: %w", showPath(abs), err)
+	}
+	j.seq = b.Seq
+	j.list = append(j.list, b)
+	debugLog("BACKUP #%d: %s %s -> %s", b.Seq, tool, abs, b.File)
+	return nil
+}
+
+// restore puts back the file at abs as its latest backup has it, removing
+// the file if a tool created it, and takes the backup out of the journal so
+// the next call goes one further back.
+func (r *Registry) restore(abs string) (ToolResult, error) {
+	j := &r.backups
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	i := len(j.list) - 1
+	for i >= 0 && j.list[i].Path != abs {
+		i--
+	}
+	p := showPath(abs)
+	if i < 0 {
+		return ToolResult{}, fmt.Errorf("no backup of %s in this session; file_undo without a path lists them", p)
+	}
+	b := j.list[i]
+	meta := map[string]any{"path": p, "backup": b.Seq, "tool": b.Tool}
+	cur, curErr := os.ReadFile(abs)
+	var res ToolResult
+	if b.File == "" {
+		if err := os.Remove(abs); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return ToolResult{}, err
+		}
+		meta["removed"] = true
+		res = ToolResult{Text: fmt.Sprintf("removed %s, which %s created (backup #%d)", p, b.Tool, b.Seq), Meta: meta}
+	} else {
+		data, err := os.ReadFile(b.File)
+		if err != nil {
+			return ToolResult{}, fmt.Errorf("backup #%d of %s is gone: %w", b.Seq, p, err)
+		}
+		mode := b.Mode
+		if mode == 0 {
+			mode = 0644
+		}
+		os.MkdirAll(filepath.Dir(abs), 0755)
+		if err := os.WriteFile(abs, data, mode); err != nil {
+			return ToolResult{}, err
+		}
+		if err := os.Chmod(abs, mode); err != nil { // WriteFile keeps an existing file's
+			return ToolResult{}, err
+		}
+		res = ToolResult{
+			Text:   fmt.Sprintf("restored %s as it was before %s at %s (backup #%d)", p, b.Tool, b.Time.Format("15:04:05"), b.Seq),
+			Meta:   meta,
+			Change: &FileChange{Path: abs, Before: string(cur), After: string(data), Created: curErr != nil},
+		}
+		if diff := FormatDiff(string(cur), string(data)); curErr == nil && diff != "" {
+			res.Text += "\n" + diff
+			meta["diff"] = diff
+		}
+	}
+	if err := j.note(backupEntry{Seq: b.Seq, Time: time.Now(), Undone: true}); err != nil {
+		debugLog("BACKUP #%d: can't note the undo: %v", b.Seq, err)
+	}
+	j.list = append(j.list[:i], j.list[i+1:]...)
+	switch n := j.count(abs); {
+	case n == 1:
+		res.Text += fmt.Sprintf("\n1 older backup of %s left", p)
+	case n > 1:
+		res.Text += fmt.Sprintf("\n%d older backups of %s left", n, p)
+	}
+	return res, nil
+}
+
+// count returns how many backups of abs the journal holds.
+func (j *backupJournal) count(abs string) int {
+	n := 0
+	for _, b := range j.list {
+		if b.Path == abs {
+			n++
+		}
+	}
+	return n
+}
+
+// listBackups renders the journal, newest first.
+func (r *Registry) listBackups() string {
+	list := r.Backups()
+	if len(list) == 0 {
+		return "no backups in this session: file_write, file_edit, file_patch and apply_patch haven't changed any file yet"
+	}
+	var sb strings.Builder
+	n := fmt.Sprintf("%d backups", len(list))
+	if len(list) == 1 {
+		n = "1 backup"
+	}
+	fmt.Fprintf(&sb, "[%s in this session, newest first; file_undo with a path restores its latest]\n", n)
+	for i := len(list) - 1; i >= 0; i-- {
+		b := list[i]
+		fmt.Fprintf(&sb, "#%d %s %s %s", b.Seq, b.Time.Format("15:04:05"), b.Tool, showPath(b.Path))
+		if b.File == "" {
+			sb.WriteString(" (created; undo removes it)")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (r *Registry) registerFileUndo() {
+	r.RegisterV2(provider.ToolDef{
+		Name:        "file_undo",
+		Description: "Undo file_write, file_edit, file_patch and apply_patch: restore a file as it was before the latest of them changed it (a file one of them created is removed). Call it again to go further back. Without path, lists the backups taken in this session",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{"type": "string", "description": "File to restore. Optional; without it, list the backups"},
+			},
+		},
+	}, func(_ context.Context, args map[string]any) (ToolResult, error) {
+		if strings.TrimSpace(getStr(args, "path")) == "" {
+			return ToolResult{Text: r.listBackups()}, nil
+		}
+		abs, err := pathArg(args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return r.restore(abs)
+	})
+}
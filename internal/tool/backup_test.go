@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// sameMode reports whether a file's mode is want. Windows keeps only the
+// read-only attribute, so there only the owner's write bit is compared.
+func sameMode(got, want fs.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return got&0o200 == want&0o200
+	}
+	return got.Perm() == want.Perm()
+}
+
+func TestBackupUndo(t *testing.T) {
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	file := filepath.Join(dir, "a.txt")
+	created := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(file, []byte("v0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(file, 0o755) // whatever the umask
+
+	r := NewRegistry()
+	r.SetBackupDir(backups)
+	ctx := context.Background()
+	write := func(path, content string) {
+		t.Helper()
+		if _, err := r.ExecuteV2(ctx, "file_write", map[string]any{"path": path, "content": content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(file, "v1")
+	os.Chmod(file, 0o600)
+	write(file, "v2")
+	write(created, "x")
+	write(file, "v3")
+
+	// a later run of the session finds the journal where this one left it
+	undo := func(r *Registry, path, want string, mode fs.FileMode) {
+		t.Helper()
+		if _, err := r.ExecuteV2(ctx, "file_undo", map[string]any{"path": path}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if want == "" {
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("%s: want it removed, got %q, %v", path, data, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s: got %q, want %q", path, data, want)
+		}
+		if fi, err := os.Stat(path); err != nil || !sameMode(fi.Mode(), mode) {
+			t.Errorf("%s: mode %v, want %v", path, fi.Mode().Perm(), mode)
+		}
+	}
+	undo(r, file, "v2", 0o600)
+
+	again := NewRegistry()
+	again.SetBackupDir(backups)
+	if got, want := len(again.Backups()), len(r.Backups()); got != want {
+		t.Fatalf("reloaded journal has %d backups, want %d", got, want)
+	}
+	undo(again, created, "", 0)
+	undo(again, file, "v1", 0o600)
+	undo(again, file, "v0", 0o755)
+	if _, err := again.ExecuteV2(ctx, "file_undo", map[string]any{"path": file}); err == nil {
+		t.Error("undo past the first backup succeeded")
+	}
+
+	// numbering goes on after the undone backups
+	r = again
+	write(file, "v4")
+	if list := r.Backups(); list[len(list)-1].Seq != 5 {
+		t.Errorf("next backup is #%d, want #5", list[len(list)-1].Seq)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -18,10 +19,44 @@ type browserInstance struct {
 	mu      sync.Mutex
 	browser *rod.Browser
 	page    *rod.Page
+	idle    *time.Timer // closes the browser after browserIdleTimeout without calls
 }
 
 var globalBrowser = &browserInstance{}
 
+// browserIdleTimeout is how long the browser may sit unused before it is shut
+// down; the next call relaunches it. Zero or less keeps it open until exit.
+var browserIdleTimeout = 10 * time.Minute
+
+// SetBrowserIdleTimeout overrides the idle period after which the browser is closed.
+func SetBrowserIdleTimeout(d time.Duration) {
+	globalBrowser.mu.Lock()
+	defer globalBrowser.mu.Unlock()
+	browserIdleTimeout = d
+}
+
+// touch restarts the idle timer after a call. Callers hold b.mu.
+func (b *browserInstance) touch() {
+	if b.idle != nil {
+		b.idle.Stop()
+		b.idle = nil
+	}
+	if b.browser == nil || browserIdleTimeout <= 0 {
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(browserIdleTimeout, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.idle != t {
+			return // a later call restarted the timer
+		}
+		debugLog("BROWSER: idle for %v, closing", browserIdleTimeout)
+		b.close()
+	})
+	b.idle = t
+}
+
 func (b *browserInstance) ensureBrowser() error {
 	if b.browser != nil {
 		return nil
@@ -37,6 +72,7 @@ func (b *browserInstance) ensureBrowser() error {
 	if err != nil {
 		return fmt.Errorf("launch browser: %w", err)
 	}
+	debugLog("BROWSER: launched %s", u)
 	b.browser = rod.New().ControlURL(u)
 	if err := b.browser.Connect(); err != nil {
 		b.browser = nil
@@ -76,6 +112,10 @@ window.navigator.permissions.query = (parameters) => (
 `
 
 func (b *browserInstance) close() string {
+	if b.idle != nil {
+		b.idle.Stop()
+		b.idle = nil
+	}
 	if b.page != nil {
 		b.page.Close()
 		b.page = nil
@@ -146,7 +186,7 @@ func (r *Registry) registerBrowser() {
 				"selector":   map[string]any{"type": "string", "description": "CSS selector for target element"},
 				"value":      map[string]any{"type": "string", "description": "Value to fill or select"},
 				"expression": map[string]any{"type": "string", "description": "JavaScript expression to evaluate (for eval)"},
-				"path":       map[string]any{"type": "string", "description": "File path for screenshot (default: /tmp/screenshot.png)"},
+				"path":       map[string]any{"type": "string", "description": "File path for screenshot (default: screenshot.png in the system temp dir)"},
 				"direction":  map[string]any{"type": "string", "description": "Scroll direction: up or down"},
 				"timeout":    map[string]any{"type": "integer", "description": "Timeout in seconds (for wait, default 10)"},
 			},
@@ -156,6 +196,7 @@ func (r *Registry) registerBrowser() {
 		action := getStr(args, "action")
 		globalBrowser.mu.Lock()
 		defer globalBrowser.mu.Unlock()
+		defer globalBrowser.touch()
 
 		if action == "close" {
 			return globalBrowser.close(), nil
@@ -238,7 +279,7 @@ func (r *Registry) registerBrowser() {
 		case "screenshot":
 			p := getStr(args, "path")
 			if p == "" {
-				p = "/tmp/screenshot.png"
+				p = filepath.Join(os.TempDir(), "screenshot.png")
 			}
 			data, err := page.Screenshot(true, nil)
 			if err != nil {
@@ -322,6 +363,7 @@ func (r *Registry) registerBrowser() {
 			return "", fmt.Errorf("unknown action: %s (available: navigate, click, fill, select, screenshot, get_text, get_elements, eval, scroll, wait, close)", action)
 		}
 	})
+	r.SetUntrusted("browser")
 }
 
 func writeFile(path string, data []byte) error {
@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"context"
+	"time"
+)
+
+// defaultExternalConcurrency caps simultaneous calls of tools that hit
+// external services (http, web_search, fetch, browser, MCP servers) unless configured otherwise.
+const defaultExternalConcurrency = 3
+
+// SetConcurrency limits how many calls of a tool, or of all tools of an MCP
+// server ("mcp:<server>"), may run at once. Excess calls wait in Execute.
+// n < 0 removes the limit; n == 0 keeps the default.
+func (r *Registry) SetConcurrency(key string, n int) {
+	if n != 0 {
+		r.concurrency[key] = n
+	}
+}
+
+// limitKey is the key a tool's calls are counted under: the tool's named
+// conflict group (e.g. "mcp:<server>"), so all tools of one backend share a
+// limit, or else the tool name.
+func (r *Registry) limitKey(name string) string {
+	if g := r.conflict[name]; g != "" && g != PathGroup && g != ExclusiveKey {
+		return g
+	}
+	return name
+}
+
+// acquire waits for a free slot for a call of name. The returned function
+// releases it.
+func (r *Registry) acquire(ctx context.Context, name string) (release func(), err error) {
+	key := r.limitKey(name)
+	n, ok := r.concurrency[key]
+	if !ok {
+		n, ok = r.concurrency[name]
+	}
+	if !ok && r.external[name] {
+		n = defaultExternalConcurrency
+	}
+	if n <= 0 {
+		return func() {}, nil
+	}
+
+	r.semMu.Lock()
+	sem := r.sems[key]
+	if sem == nil {
+		sem = make(chan struct{}, n)
+		r.sems[key] = sem
+	}
+	r.semMu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	default:
+	}
+	start := time.Now()
+	debugLog("TOOL_QUEUE: %s waiting, %d calls of %s already running", name, cap(sem), key)
+	select {
+	case sem <- struct{}{}:
+		debugLog("TOOL_QUEUE: %s waited %s", name, time.Since(start).Round(time.Millisecond))
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
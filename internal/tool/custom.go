@@ -0,0 +1,219 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+const defaultCustomOutput = 64 << 10 // 64KB of combined output returned to the LLM
+
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// RegisterCustom registers a command declared under custom_tools as a tool.
+// With args "stdin" (the default) the call arguments are written to the
+// command's stdin as JSON; with "argv" each command element is a text/template
+// rendered against the arguments, e.g. ["mycli", "deploy", "--env", "{{.env}}"].
+func (r *Registry) RegisterCustom(c config.CustomToolConf) error {
+	if !toolNameRe.MatchString(c.Name) {
+		return fmt.Errorf("custom tool %q: name must be 1-64 letters, digits, '_' or '-'", c.Name)
+	}
+	if _, ok := r.tools[c.Name]; ok && !r.custom[c.Name] {
+		return fmt.Errorf("custom tool %s: a tool with this name already exists", c.Name)
+	}
+	if len(c.Command) == 0 {
+		return fmt.Errorf("custom tool %s: command is required", c.Name)
+	}
+	params := c.Parameters
+	if params == nil {
+		params = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if err := ValidateSchema(params); err != nil {
+		return fmt.Errorf("custom tool %s: parameters: %w", c.Name, err)
+	}
+
+	var argv []*template.Template
+	switch c.Args {
+	case "", "stdin":
+	case "argv":
+		for i, a := range c.Command {
+			t, err := template.New(fmt.Sprint(i)).Option("missingkey=error").Parse(a)
+			if err != nil {
+				return fmt.Errorf("custom tool %s: command[%d]: %w", c.Name, i, err)
+			}
+			argv = append(argv, t)
+		}
+	default:
+		return fmt.Errorf("custom tool %s: args must be \"stdin\" or \"argv\", got %q", c.Name, c.Args)
+	}
+
+	timeout := time.Duration(c.Timeout) * time.Second
+	if c.Timeout <= 0 {
+		timeout = defaultTimeout * time.Second
+	}
+	maxOut := c.MaxOutput
+	if maxOut <= 0 {
+		maxOut = defaultCustomOutput
+	}
+	props, _ := params["properties"].(map[string]any)
+
+	def := provider.ToolDef{Name: c.Name, Description: c.Description, Parameters: params}
+	h := func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		var cmd *exec.Cmd
+		if argv != nil {
+			// declared but omitted arguments render as ""; undeclared ones are an error
+			data := make(map[string]any, len(props)+len(args))
+			for k := range props {
+				data[k] = ""
+			}
+			for k, v := range args {
+				data[k] = v
+			}
+			parts := make([]string, len(argv))
+			for i, t := range argv {
+				var sb strings.Builder
+				if err := t.Execute(&sb, data); err != nil {
+					return ToolResult{}, fmt.Errorf("render command[%d]: %w", i, err)
+				}
+				parts[i] = sb.String()
+			}
+			cmd = exec.CommandContext(ctx, parts[0], parts[1:]...)
+		} else {
+			input, _ := json.Marshal(args)
+			cmd = exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+			cmd.Stdin = bytes.NewReader(input)
+		}
+		cmd.Dir = c.Dir
+		setProcessGroup(cmd)
+
+		out, err := cmd.CombinedOutput()
+		res := string(out)
+		if len(res) > maxOut {
+			res = clipUTF8(res, maxOut) + fmt.Sprintf("\n...(truncated, %d bytes total)", len(out))
+		}
+		meta := map[string]any{"exit_code": 0}
+		if err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return ToolResult{}, err // didn't run
+			}
+			meta["exit_code"] = exitErr.ExitCode()
+			status := fmt.Sprintf("[exit code %d]", exitErr.ExitCode())
+			if sig := exitSignal(exitErr); sig != "" {
+				meta["signal"] = sig
+				status = fmt.Sprintf("[killed by signal: %s]", sig)
+			}
+			return ToolResult{Text: status + "\n" + res, Meta: meta}, nil
+		}
+		if res == "" {
+			res = "(no output)"
+		}
+		return ToolResult{Text: res, Meta: meta}, nil
+	}
+
+	r.custom[c.Name] = true
+	r.options[c.Name] = ToolOptions{Timeout: timeout} // ExecuteV2 gives ctx the deadline
+	if c.ReadOnly {
+		r.RegisterReadOnlyV2(def, h)
+	} else {
+		r.RegisterV2(def, h)
+		// calls of one custom tool run in order; different custom tools may overlap
+		r.SetConflictGroup(c.Name, "custom:"+c.Name)
+	}
+	return nil
+}
+
+// ValidateSchema checks that a tool's parameters block is a well-formed JSON
+// schema object, so a typo surfaces at startup instead of as a provider 400.
+func ValidateSchema(params map[string]any) error {
+	if t, _ := params["type"].(string); t != "object" {
+		return fmt.Errorf(`top-level type must be "object"`)
+	}
+	return validateSchemaNode("", params)
+}
+
+func validateSchemaNode(path string, node map[string]any) error {
+	where := func() string {
+		if path == "" {
+			return "schema"
+		}
+		return path
+	}
+	t, hasType := node["type"]
+	switch tv := t.(type) {
+	case nil:
+		if !hasType {
+			_, enum := node["enum"]
+			_, anyOf := node["anyOf"]
+			_, oneOf := node["oneOf"]
+			if !enum && !anyOf && !oneOf {
+				return fmt.Errorf("%s: missing type", where())
+			}
+		}
+	case string:
+		if !schemaTypes[tv] {
+			return fmt.Errorf("%s: unknown type %q", where(), tv)
+		}
+	case []any:
+		for _, x := range tv {
+			if s, _ := x.(string); !schemaTypes[s] {
+				return fmt.Errorf("%s: unknown type %v", where(), x)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: type must be a string", where())
+	}
+
+	if raw, ok := node["properties"]; ok {
+		props, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: properties must be a mapping", where())
+		}
+		for name, p := range props {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s.%s: must be a mapping", where(), name)
+			}
+			if err := validateSchemaNode(strings.TrimPrefix(path+"."+name, "."), pm); err != nil {
+				return err
+			}
+		}
+		if raw, ok := node["required"]; ok {
+			req, ok := raw.([]any)
+			if !ok {
+				return fmt.Errorf("%s: required must be a list", where())
+			}
+			for _, x := range req {
+				s, _ := x.(string)
+				if _, ok := props[s]; !ok {
+					return fmt.Errorf("%s: required field %v is not in properties", where(), x)
+				}
+			}
+		}
+	}
+	if t == "array" {
+		items, ok := node["items"].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: array needs an items schema", where())
+		}
+		if err := validateSchemaNode(path+"[]", items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package tool
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+)
+
+func TestCustomResult(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are POSIX shell")
+	}
+	tests := []struct {
+		name      string
+		script    string
+		maxOutput int
+		want      string
+		wantCode  int
+	}{
+		{"success", "echo ok", 0, "ok\n", 0},
+		{"no output", "true", 0, "(no output)", 0},
+		{"failure", "echo nope; exit 3", 0, "[exit code 3]\nnope\n", 3},
+		{"cut inside a character", "printf 'aé日本語'", 4, "aé\n...(truncated, 12 bytes total)", 0},
+		{"cut failure output", "printf '日本語'; exit 1", 5, "[exit code 1]\n日\n...(truncated, 9 bytes total)", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			if err := r.RegisterCustom(config.CustomToolConf{Name: "mine", Command: []string{"sh", "-c", tt.script}, MaxOutput: tt.maxOutput}); err != nil {
+				t.Fatal(err)
+			}
+			res, err := r.ExecuteV2(context.Background(), "mine", map[string]any{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Text != tt.want {
+				t.Errorf("text %q, want %q", res.Text, tt.want)
+			}
+			if code := res.Meta["exit_code"]; code != tt.wantCode {
+				t.Errorf("exit_code %v, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	// a command that can't start is an error, not an exit code
+	r := NewRegistry()
+	if err := r.RegisterCustom(config.CustomToolConf{Name: "gone", Command: []string{"gal-no-such-command"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ExecuteV2(context.Background(), "gone", map[string]any{}); err == nil {
+		t.Error("missing command: no error")
+	}
+}
@@ -0,0 +1,221 @@
+package tool
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"golang.org/x/net/html/charset"
+)
+
+// Limits of one fetch call, in bytes of markdown.
+const (
+	defaultFetchLength = 20000
+	maxFetchLength     = 100000
+)
+
+// fetchTimeout bounds the request of one fetch call.
+const fetchTimeout = 30 * time.Second
+
+// fetchCacheTTL is how long a converted page is kept for the calls that
+// page through it with continue_from.
+const fetchCacheTTL = 10 * time.Minute
+
+// maxFetchCache is how many converted pages are kept.
+const maxFetchCache = 16
+
+// fetchedPage is a page converted to markdown.
+type fetchedPage struct {
+	url   string // where it was fetched from, after redirects
+	title string
+	md    string
+	html  int // bytes of HTML it came from
+	time  time.Time
+}
+
+// fetchCache keeps the pages fetch converted lately by the URL asked for,
+// so paging through one doesn't download and convert it again.
+var fetchCache = struct {
+	mu    sync.Mutex
+	pages map[string]*fetchedPage
+}{pages: map[string]*fetchedPage{}}
+
+func cachedPage(rawURL string) *fetchedPage {
+	fetchCache.mu.Lock()
+	defer fetchCache.mu.Unlock()
+	p := fetchCache.pages[rawURL]
+	if p == nil || time.Since(p.time) > fetchCacheTTL {
+		return nil
+	}
+	return p
+}
+
+func cachePage(rawURL string, p *fetchedPage) {
+	fetchCache.mu.Lock()
+	defer fetchCache.mu.Unlock()
+	for u, old := range fetchCache.pages {
+		if time.Since(old.time) > fetchCacheTTL {
+			delete(fetchCache.pages, u)
+		}
+	}
+	if len(fetchCache.pages) >= maxFetchCache {
+		var oldest string
+		for u, old := range fetchCache.pages {
+			if oldest == "" || old.time.Before(fetchCache.pages[oldest].time) {
+				oldest = u
+			}
+		}
+		delete(fetchCache.pages, oldest)
+	}
+	fetchCache.pages[rawURL] = p
+}
+
+func (r *Registry) registerFetch() {
+	r.RegisterReadOnlyV2(provider.ToolDef{
+		Name:        "fetch",
+		Description: "Read a web page: GET the URL and return its main content as markdown, with headings, links, lists, tables and code blocks kept and menus, scripts and ads left out. Use it to read articles and documentation instead of http, which returns raw HTML. Long pages come in parts: the result's header says where the next part starts; pass that as continue_from. Other content types (JSON, plain text, images) are returned as http returns them.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url":           map[string]any{"type": "string", "description": "Complete URL of the page"},
+				"max_length":    map[string]any{"type": "integer", "description": fmt.Sprintf("Bytes of markdown to return (default %d, at most %d)", defaultFetchLength, maxFetchLength)},
+				"continue_from": map[string]any{"type": "integer", "description": "Byte offset in the page's markdown to start at, from the previous part's header. Optional; default 0"},
+			},
+			"required": []string{"url"},
+		},
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		rawURL := strings.TrimSpace(getStr(args, "url"))
+		if rawURL == "" {
+			return ToolResult{}, errors.New("url is empty")
+		}
+		if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+			rawURL = "https://" + rawURL
+		}
+		limit := toInt(args["max_length"])
+		if limit <= 0 {
+			limit = defaultFetchLength
+		}
+		limit = min(limit, maxFetchLength)
+		from := max(toInt(args["continue_from"]), 0)
+
+		start := time.Now()
+		meta := map[string]any{"url": rawURL}
+		page := cachedPage(rawURL)
+		if page == nil || from == 0 {
+			var res *ToolResult
+			var err error
+			if page, res, err = fetchPage(ctx, rawURL); err != nil {
+				return ToolResult{}, err
+			} else if res != nil {
+				return *res, nil
+			}
+			cachePage(rawURL, page)
+		} else {
+			meta["cached"] = true
+		}
+		meta["duration_ms"] = time.Since(start).Milliseconds()
+		meta["html_bytes"], meta["markdown_bytes"] = page.html, len(page.md)
+		if page.url != rawURL {
+			meta["final_url"] = page.url
+		}
+		if page.md == "" {
+			return ToolResult{Text: fmt.Sprintf("[fetch %s: no readable text in the page (%d bytes of HTML); it may need JavaScript, try the browser tool]", page.url, page.html), Meta: meta}, nil
+		}
+		if from >= len(page.md) {
+			return ToolResult{Text: fmt.Sprintf("[fetch %s: continue_from %d is past the end; the page has %d bytes of markdown]", page.url, from, len(page.md)), Meta: meta}, nil
+		}
+		for from > 0 && !utf8.RuneStart(page.md[from]) {
+			from-- // not inside a character
+		}
+		part := pagePart(page.md[from:], limit)
+		end := from + len(part)
+		var head strings.Builder
+		fmt.Fprintf(&head, "[fetch %s", page.url)
+		if page.title != "" {
+			fmt.Fprintf(&head, ": %q", page.title)
+		}
+		if from == 0 && end == len(page.md) {
+			fmt.Fprintf(&head, ", %d bytes]\n", len(page.md))
+		} else {
+			fmt.Fprintf(&head, ", bytes %d-%d of %d", from, end, len(page.md))
+			if end < len(page.md) {
+				fmt.Fprintf(&head, "; more from continue_from %d", end)
+			}
+			head.WriteString("]\n")
+		}
+		return ToolResult{Text: head.String() + part, Meta: meta}, nil
+	})
+	r.SetUntrusted("fetch")
+	r.SetResultTokens("fetch", -1) // max_length cuts it
+}
+
+// fetchPage GETs rawURL and converts it to markdown. When the answer isn't
+// an HTML page it returns the http tool's result for it instead.
+func fetchPage(ctx context.Context, rawURL string) (*fetchedPage, *ToolResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "GAL-CLI/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	final := resp.Request.URL
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		note := fmt.Sprintf("not an HTML page (%s), so it is returned as the http tool returns it", cmp.Or(mediaType, "unknown type"))
+		res := httpResult(http.MethodGet, final.String(), resp, body, time.Since(start).Milliseconds(), map[string]any{"note": note})
+		return nil, &res, nil
+	}
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("%s: %s", final, resp.Status)
+	}
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		r = bytes.NewReader(body) // unknown charset; take it as UTF-8
+	}
+	title, md, err := htmlToMarkdown(r, final)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", final, err)
+	}
+	return &fetchedPage{url: final.String(), title: title, md: md, html: len(body), time: time.Now()}, nil, nil
+}
+
+// pagePart returns the start of md up to limit bytes, cut at a paragraph
+// or line break when there is one in its last part, and never inside a
+// character.
+func pagePart(md string, limit int) string {
+	if len(md) <= limit {
+		return md
+	}
+	part := clipUTF8(md, limit)
+	for _, sep := range []string{"\n\n", "\n"} {
+		if i := strings.LastIndex(part, sep); i > len(part)*3/4 {
+			return part[:i+len(sep)]
+		}
+	}
+	return part
+}
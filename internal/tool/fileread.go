@@ -0,0 +1,146 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Limits of one file_read call.
+const (
+	defaultReadBytes = 256 << 10 // bytes returned when no limit is given
+	binarySniff      = 8000      // bytes looked at for a NUL, as git does
+)
+
+func (r *Registry) registerFileRead() {
+	r.RegisterReadOnlyV2(provider.ToolDef{
+		Name:        "file_read",
+		Description: "Read the contents of a file at the given path. For a long file, read a range of lines with offset and limit; a range comes with line numbers, the ones file_edit takes, and its header says how many lines the file has",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":      map[string]any{"type": "string", "description": "File path to read"},
+				"offset":    map[string]any{"type": "integer", "description": "First line to read (1-based). Optional; default 1"},
+				"limit":     map[string]any{"type": "integer", "description": "Maximum lines to read. Optional; default all"},
+				"max_bytes": map[string]any{"type": "integer", "description": fmt.Sprintf("Without limit, return at most this many bytes of lines (default %d); read the rest with offset and limit", defaultReadBytes)},
+			},
+			"required": []string{"path"},
+		},
+	}, func(_ context.Context, args map[string]any) (ToolResult, error) {
+		abs, err := pathArg(args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		f, err := os.Open(abs)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return ToolResult{}, err
+		}
+		p := showPath(abs)
+		if fi.IsDir() {
+			return ToolResult{}, fmt.Errorf("%s is a directory; list it with file_list", p)
+		}
+		size := int(fi.Size())
+		br := bufio.NewReader(f)
+		if head, _ := br.Peek(binarySniff); bytes.IndexByte(head, 0) >= 0 {
+			return ToolResult{
+				Text: fmt.Sprintf("[read %s: binary file, %d bytes; not shown as text]", p, size),
+				Meta: map[string]any{"path": p, "bytes": size, "binary": true},
+			}, nil
+		}
+
+		offset, limit := toInt(args["offset"]), toInt(args["limit"])
+		maxBytes := toInt(args["max_bytes"])
+		if maxBytes <= 0 {
+			maxBytes = defaultReadBytes
+		}
+		first := max(offset, 1)
+		var kept []string // the lines returned, without their "\n"
+		keptBytes, lines := 0, 0
+		capped, cut := false, false
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return ToolResult{}, err
+			}
+			lines++ // what follows the last "\n" is a line too, as file_edit counts
+			if lines >= first && (limit <= 0 || lines < first+limit) && !capped {
+				switch {
+				case limit > 0 || keptBytes+len(line) <= maxBytes:
+					kept = append(kept, strings.TrimSuffix(line, "\n"))
+					keptBytes += len(line)
+				case len(kept) == 0:
+					// a line longer than the cap, like minified code
+					kept = append(kept, clipUTF8(line, maxBytes))
+					capped, cut = true, true
+				default:
+					capped = true
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+
+		if first > lines {
+			return ToolResult{
+				Text: fmt.Sprintf("[read %s: offset %d is past the end; the file has %d lines]", p, first, lines),
+				Meta: map[string]any{"path": p, "lines": 0, "bytes": size},
+			}, nil
+		}
+		if offset <= 1 && limit <= 0 && !capped {
+			return ToolResult{
+				Text: fmt.Sprintf("[read %s: %d lines, %d bytes]\n%s", p, lines, size, strings.Join(kept, "\n")),
+				Meta: map[string]any{"path": p, "lines": lines, "bytes": size},
+			}, nil
+		}
+		last := first + len(kept) - 1
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "[read %s: lines %d-%d of %d, %d bytes", p, first, last, lines, size)
+		switch {
+		case cut:
+			fmt.Fprintf(&sb, "; line %d is longer than max_bytes (%d) and was cut", first, maxBytes)
+		case capped:
+			fmt.Fprintf(&sb, "; stopped at max_bytes (%d), read on with offset %d and limit", maxBytes, last+1)
+		case last < lines:
+			fmt.Fprintf(&sb, "; more from offset %d", last+1)
+		}
+		sb.WriteString("]\n")
+		width := len(strconv.Itoa(last))
+		for i, line := range kept {
+			fmt.Fprintf(&sb, "%*d: %s\n", width, first+i, line)
+		}
+		return ToolResult{
+			Text: sb.String(),
+			Meta: map[string]any{"path": p, "lines": len(kept), "bytes": size},
+		}, nil
+	})
+}
+
+// ClipUTF8 returns at most n bytes of s, without splitting a character.
+func ClipUTF8(s string, n int) string {
+	return clipUTF8(s, n)
+}
+
+// clipUTF8 returns at most n bytes of s, without splitting a character.
+func clipUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,156 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+func (r *Registry) registerFileWrite() {
+	r.RegisterV2(provider.ToolDef{
+		Name:        "file_write",
+		Description: "Write content to a file at the given path, creating directories as needed. mode overwrite (the default) replaces the file, append adds content to its end, no need to read the file first (for logs and notes), create makes a new file and fails if the path exists",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":    map[string]any{"type": "string", "description": "File path to write"},
+				"content": map[string]any{"type": "string", "description": "Content to write"},
+				"mode":    map[string]any{"type": "string", "enum": []string{"overwrite", "append", "create"}, "description": "overwrite (default), append or create"},
+			},
+			"required": []string{"path", "content"},
+		},
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		abs, err := pathArg(args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		content, _ := args["content"].(string)
+		mode := getStr(args, "mode")
+		switch mode {
+		case "", "overwrite":
+		case "append":
+			return r.appendFile(ctx, abs, content)
+		case "create":
+			return r.createFile(ctx, abs, content)
+		default:
+			return ToolResult{}, fmt.Errorf("unknown mode %q; use overwrite, append or create", mode)
+		}
+		os.MkdirAll(filepath.Dir(abs), 0755)
+		// check if file exists for diff
+		oldData, readErr := os.ReadFile(abs)
+		if err := r.backup(ctx, "file_write", abs); err != nil {
+			return ToolResult{}, err
+		}
+		if err := os.WriteFile(abs, []byte(content), 0644); err != nil {
+			return ToolResult{}, err
+		}
+		p := showPath(abs)
+		lines := strings.Count(content, "\n") + 1
+		meta := map[string]any{"path": p, "lines": lines, "bytes": len(content), "created": readErr != nil}
+		change := &FileChange{Path: abs, Before: string(oldData), After: content, Created: readErr != nil}
+		if readErr != nil {
+			return ToolResult{Text: fmt.Sprintf("created %s (%d lines, %d bytes)", p, lines, len(content)), Meta: meta, Change: change}, nil
+		}
+		result := fmt.Sprintf("wrote %s (%d lines, %d bytes)", p, lines, len(content))
+		if diff := FormatDiff(string(oldData), content); diff != "" {
+			result += "\n" + diff
+			meta["diff"] = diff
+		}
+		return ToolResult{Text: result, Meta: meta, Change: change}, nil
+	})
+}
+
+// appendFile adds content to the end of the file at abs, creating it if
+// needed. Other writers appending at the same time don't lose their lines,
+// and the result shows only what was added.
+func (r *Registry) appendFile(ctx context.Context, abs, content string) (ToolResult, error) {
+	os.MkdirAll(filepath.Dir(abs), 0755)
+	oldData, readErr := os.ReadFile(abs)
+	if err := r.backup(ctx, "file_write", abs); err != nil {
+		return ToolResult{}, err
+	}
+	f, err := os.OpenFile(abs, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ToolResult{}, err
+	}
+	size := len(oldData) + len(content)
+	if fi, err := os.Stat(abs); err == nil {
+		size = int(fi.Size())
+	}
+	p := showPath(abs)
+	lines := strings.Count(content, "\n") + 1
+	created := readErr != nil
+	meta := map[string]any{"path": p, "lines": lines, "bytes": len(content), "size": size, "created": created, "mode": "append"}
+	change := &FileChange{Path: abs, Before: string(oldData), After: string(oldData) + content, Created: created}
+	if created {
+		return ToolResult{Text: fmt.Sprintf("created %s (%d lines, %d bytes)", p, lines, len(content)), Meta: meta, Change: change}, nil
+	}
+	result := fmt.Sprintf("appended %d lines, %d bytes to %s (now %d bytes)", textLines(content), len(content), p, size)
+	if content != "" {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, " ... (%d lines before)\n", textLines(string(oldData)))
+		for _, l := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			sb.WriteString("+ " + l + "\n")
+		}
+		diff := strings.TrimRight(sb.String(), "\n")
+		result += "\n" + diff
+		meta["diff"] = diff
+	}
+	return ToolResult{Text: result, Meta: meta, Change: change}, nil
+}
+
+// createFile writes content to a new file at abs and fails if there is one
+// already, so nothing gets overwritten by accident.
+func (r *Registry) createFile(ctx context.Context, abs, content string) (ToolResult, error) {
+	p := showPath(abs)
+	os.MkdirAll(filepath.Dir(abs), 0755)
+	exists := fmt.Errorf("%s already exists; mode create only makes new files, so pick another name, or use mode overwrite to replace it", p)
+	if _, err := os.Lstat(abs); err == nil {
+		return ToolResult{}, exists
+	}
+	if err := r.backup(ctx, "file_write", abs); err != nil {
+		return ToolResult{}, err
+	}
+	f, err := os.OpenFile(abs, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return ToolResult{}, exists // created since
+	}
+	if err != nil {
+		return ToolResult{}, err
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ToolResult{}, err
+	}
+	lines := strings.Count(content, "\n") + 1
+	return ToolResult{
+		Text:   fmt.Sprintf("created %s (%d lines, %d bytes)", p, lines, len(content)),
+		Meta:   map[string]any{"path": p, "lines": lines, "bytes": len(content), "created": true, "mode": "create"},
+		Change: &FileChange{Path: abs, After: content, Created: true},
+	}, nil
+}
+
+// textLines counts the lines of s, a last one without "\n" included.
+func textLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,227 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Limits of the git tool's log action.
+const (
+	defaultGitLog = 10
+	maxGitLog     = 200
+)
+
+// gitActions are the git tool's actions; those true change nothing.
+var gitActions = map[string]bool{
+	"status": true, "diff": true, "log": true, "show": true, "branch": true,
+	"add": false, "commit": false, "stash": false,
+}
+
+// gitReadOnly reports whether a git call changes nothing: the actions that
+// only look, and branch without a name, which lists the branches.
+func gitReadOnly(args map[string]any) bool {
+	action := getStr(args, "action")
+	return gitActions[action] && (action != "branch" || strings.TrimSpace(getStr(args, "name")) == "")
+}
+
+func (r *Registry) registerGit() {
+	r.RegisterV2(provider.ToolDef{
+		Name: "git",
+		Description: "Run git in a repository without going through bash. status, diff, log and show only look, and can run alongside other tools; add, commit and stash change the repository. " +
+			"diff and show give a diffstat, and the patch too with full: true; diff compares the working tree with the index, or the index with HEAD with staged: true, or with ref. " +
+			"branch lists the branches, or creates name (at ref) without switching to it. add stages paths, or everything with all: true; commit commits what's staged (paths or all: true commit those instead) with message; stash stashes the changes, or only paths'.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":  map[string]any{"type": "string", "enum": []string{"status", "diff", "log", "show", "branch", "add", "commit", "stash"}},
+				"paths":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Files or directories to limit the action to. Optional"},
+				"ref":     map[string]any{"type": "string", "description": "Commit, branch or range, e.g. HEAD~2 or main..HEAD: what diff compares with, where log starts, what show shows (default HEAD), where branch creates name. Optional"},
+				"message": map[string]any{"type": "string", "description": "Message of commit (required) and stash"},
+				"count":   map[string]any{"type": "integer", "description": fmt.Sprintf("log: commits to list (default %d, at most %d)", defaultGitLog, maxGitLog)},
+				"name":    map[string]any{"type": "string", "description": "branch: the branch to create"},
+				"staged":  map[string]any{"type": "boolean", "description": "diff: the staged changes"},
+				"full":    map[string]any{"type": "boolean", "description": "diff, show: the patch after the diffstat; log: each commit's diffstat"},
+				"all":     map[string]any{"type": "boolean", "description": "add: stage all changes, new files too; commit: commit all changes to tracked files"},
+				"repo":    map[string]any{"type": "string", "description": "Directory in the repository. Optional; default the working directory"},
+			},
+			"required": []string{"action"},
+		},
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		action := getStr(args, "action")
+		if _, ok := gitActions[action]; !ok {
+			return ToolResult{}, fmt.Errorf("unknown action %q: use status, diff, log, show, branch, add, commit or stash", action)
+		}
+		dir := "."
+		if p := getStr(args, "repo"); strings.TrimSpace(p) != "" {
+			dir = p
+		}
+		abs, err := resolvePath(dir)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		if fi, err := os.Stat(abs); err != nil {
+			return ToolResult{}, err
+		} else if !fi.IsDir() {
+			return ToolResult{}, fmt.Errorf("%s is a file; repo is a directory in the repository", showPath(abs))
+		}
+		gitArgs, err := gitCommand(action, args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return runGit(ctx, abs, action, gitArgs)
+	})
+	r.readonlyCall["git"] = gitReadOnly
+	r.SetResultTokens("git", -1) // runGit cuts the output
+}
+
+// gitCommand returns the git arguments for a call of action.
+func gitCommand(action string, args map[string]any) ([]string, error) {
+	paths := stringList(args["paths"])
+	ref := strings.TrimSpace(getStr(args, "ref"))
+	message := strings.TrimSpace(getStr(args, "message"))
+	name := strings.TrimSpace(getStr(args, "name"))
+	full, _ := args["full"].(bool)
+	all, _ := args["all"].(bool)
+	for _, s := range []string{ref, name} {
+		if strings.HasPrefix(s, "-") {
+			return nil, fmt.Errorf("%q isn't a ref; options can't be passed through ref or name", s)
+		}
+	}
+	var cmd []string
+	switch action {
+	case "status":
+		cmd = []string{"status", "--short", "--branch"}
+	case "diff":
+		cmd = []string{"diff", "--stat"}
+		if full {
+			cmd = append(cmd, "--patch")
+		}
+		if staged, _ := args["staged"].(bool); staged {
+			cmd = append(cmd, "--cached")
+		}
+		if ref != "" {
+			cmd = append(cmd, ref)
+		}
+	case "log":
+		count := toInt(args["count"])
+		if count <= 0 {
+			count = defaultGitLog
+		}
+		cmd = []string{"log", "-n", fmt.Sprint(min(count, maxGitLog)), "--date=short", "--format=%h %ad %an%d %s"}
+		if full {
+			cmd = append(cmd, "--stat")
+		}
+		if ref != "" {
+			cmd = append(cmd, ref)
+		}
+	case "show":
+		cmd = []string{"show", "--stat", "--format=fuller"}
+		if full {
+			cmd = append(cmd, "--patch")
+		}
+		if ref == "" {
+			ref = "HEAD"
+		}
+		cmd = append(cmd, ref)
+	case "branch":
+		if name == "" {
+			return []string{"branch", "-vv"}, nil
+		}
+		cmd = []string{"branch", name}
+		if ref != "" {
+			cmd = append(cmd, ref)
+		}
+		return cmd, nil
+	case "add":
+		switch {
+		case all:
+			cmd = []string{"add", "-A"}
+		case len(paths) == 0:
+			return nil, errors.New("add needs paths, or all: true to stage everything")
+		default:
+			cmd = []string{"add"}
+		}
+	case "commit":
+		if message == "" {
+			return nil, errors.New("commit needs a message")
+		}
+		cmd = []string{"commit", "-m", message}
+		if all {
+			cmd = append(cmd, "-a")
+		}
+	case "stash":
+		cmd = []string{"stash", "push"}
+		if message != "" {
+			cmd = append(cmd, "-m", message)
+		}
+	}
+	if len(paths) > 0 {
+		cmd = append(append(cmd, "--"), paths...)
+	}
+	return cmd, nil
+}
+
+// runGit runs git in dir without colors, pager or editor. A failing git
+// command is a result, with git's message and exit code, as a failing bash
+// command is.
+func runGit(ctx context.Context, dir, action string, args []string) (ToolResult, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "color.ui=false", "-c", "core.quotepath=false", "-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_PAGER=cat", "GIT_EDITOR=true", "GIT_TERMINAL_PROMPT=0")
+	out := &streamBuffer{limit: maxStreamBytes}
+	cmd.Stdout, cmd.Stderr = out, out
+	start := time.Now()
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return ToolResult{}, fmt.Errorf("git %s timed out", action)
+	}
+	meta := map[string]any{"exit_code": 0, "duration_ms": time.Since(start).Milliseconds(), "action": action}
+	text := strings.TrimRight(out.String(), "\n")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return ToolResult{}, fmt.Errorf("can't run git: %w", err) // not installed
+		}
+		meta["exit_code"] = exitErr.ExitCode()
+		if text == "" {
+			text = "(no output)"
+		}
+		return ToolResult{Text: fmt.Sprintf("%s\n[git %s failed: exit code %d]", text, action, exitErr.ExitCode()), Meta: meta}, nil
+	}
+	switch {
+	case out.total > maxStreamBytes:
+		text += "\n[output cut; narrow it with paths, ref or count]"
+	case text == "" && action == "diff":
+		text = "no changes"
+	case text == "":
+		text = fmt.Sprintf("git %s: done", strings.Join(args, " "))
+	case (action == "diff" || action == "show") && !slices.Contains(args, "--patch"):
+		text += "\n[diffstat only; full: true for the patch]"
+	}
+	return ToolResult{Text: text, Meta: meta}, nil
+}
+
+// stringList returns a list argument's strings; a lone string is a list of one.
+func stringList(v any) []string {
+	var list []string
+	switch v := v.(type) {
+	case string:
+		if strings.TrimSpace(v) != "" {
+			list = append(list, v)
+		}
+	case []any:
+		for _, s := range v {
+			if s, ok := s.(string); ok && strings.TrimSpace(s) != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
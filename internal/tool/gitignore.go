@@ -0,0 +1,138 @@
+package tool
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitIgnore tells grep, file_list and glob which paths .gitignore files
+// exclude: those of the directory searched and of its parents up to the
+// repository's top, and those of the directories walked into. It covers
+// what projects put there, negation (!keep.log), directory-only patterns
+// (build/), anchored patterns (/dist, src/gen) and **, but not git's global
+// excludes or .git/info/exclude.
+type gitIgnore struct {
+	rules  []ignoreRule
+	loaded map[string]bool // directories whose .gitignore has been read
+}
+
+// ignoreRule is a line of a .gitignore.
+type ignoreRule struct {
+	base    string   // the directory of the .gitignore
+	segs    []string // the pattern's path segments; unanchored ones start with **
+	negate  bool
+	dirOnly bool
+}
+
+// newGitIgnore reads the .gitignore files that apply to root.
+func newGitIgnore(root string) *gitIgnore {
+	g := &gitIgnore{loaded: map[string]bool{}}
+	dirs := []string{root}
+	for dir := root; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break // the repository's top
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			dirs = dirs[:1] // not in a repository: only root's own
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g.enter(dirs[i])
+	}
+	return g
+}
+
+// enter reads the .gitignore of dir, once; its rules come after those of the
+// directories above, so they win.
+func (g *gitIgnore) enter(dir string) {
+	if g.loaded[dir] {
+		return
+	}
+	g.loaded[dir] = true
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(dir, sc.Text()); ok {
+			g.rules = append(g.rules, r)
+		}
+	}
+}
+
+// parseIgnoreLine parses a .gitignore line in dir; blank lines and comments
+// aren't rules.
+func parseIgnoreLine(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: dir}
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// a slash anywhere but at the end anchors the pattern to dir
+	anchored := strings.Contains(line, "/")
+	r.segs = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	if !anchored {
+		r.segs = append([]string{"**"}, r.segs...)
+	}
+	if r.segs[len(r.segs)-1] == "**" {
+		// "dir/**" is what is inside dir, not dir itself
+		r.segs = append(r.segs, "*")
+	}
+	return r, true
+}
+
+// ignored reports whether the .gitignore rules exclude path, a directory if
+// isDir. The last rule that matches decides. Walks skip excluded
+// directories, so what is inside them isn't asked about.
+func (g *gitIgnore) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir || r.negate != ignored {
+			continue // can't match, or wouldn't change the answer
+		}
+		rel, err := filepath.Rel(r.base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if matchSegments(r.segs, strings.Split(filepath.ToSlash(rel), "/")) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// ignoreVCSParam is the ignore_vcs parameter of the tools that walk trees.
+var ignoreVCSParam = map[string]any{"type": "boolean", "description": "Skip what .gitignore files exclude (default true); false searches those paths too"}
+
+// ignoreVCS returns the .gitignore rules for a walk of root, or nil when the
+// call's ignore_vcs is false.
+func ignoreVCS(args map[string]any, root string) *gitIgnore {
+	if v, ok := args["ignore_vcs"].(bool); ok && !v {
+		return nil
+	}
+	return newGitIgnore(root)
+}
@@ -0,0 +1,205 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Limits of one glob call.
+const (
+	defaultGlobLimit = 100
+	maxGlobLimit     = 1000
+)
+
+// globSkipDirs are left out of a glob's walk unless the pattern names them.
+var globSkipDirs = []string{".git", "node_modules", "vendor", "__pycache__"}
+
+func (r *Registry) registerGlob() {
+	r.RegisterReadOnly(provider.ToolDef{
+		Name:        "glob",
+		Description: "Find files by name pattern, newest first. Use it to locate files instead of listing whole trees or running find; then grep them. Patterns match paths relative to path: * and ? within a name, ** any number of directories, {a,b} alternatives, e.g. \"**/*.go\", \"src/**/*_test.{ts,tsx}\". .git, node_modules, vendor and __pycache__ are skipped unless the pattern names them, and so is what .gitignore files exclude.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern":    map[string]any{"type": "string", "description": "Glob pattern, e.g. \"**/*.go\""},
+				"path":       map[string]any{"type": "string", "description": "Directory to search in. Optional; default the working directory"},
+				"limit":      map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum paths to return (default %d, at most %d)", defaultGlobLimit, maxGlobLimit)},
+				"ignore_vcs": ignoreVCSParam,
+			},
+			"required": []string{"pattern"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		pattern := strings.TrimSpace(getStr(args, "pattern"))
+		if pattern == "" {
+			return "", errors.New("pattern is empty")
+		}
+		pattern = filepath.ToSlash(pattern)
+		if strings.HasPrefix(pattern, "/") || filepath.IsAbs(pattern) {
+			return "", errors.New("pattern is relative to path; put the directory in path")
+		}
+		root := "."
+		if p := getStr(args, "path"); strings.TrimSpace(p) != "" {
+			root = p
+		}
+		abs, err := resolvePath(root)
+		if err != nil {
+			return "", err
+		}
+		if fi, err := os.Stat(abs); err != nil {
+			return "", err
+		} else if !fi.IsDir() {
+			return "", fmt.Errorf("%s is a file; path is the directory to search", showPath(abs))
+		}
+		limit := toInt(args["limit"])
+		if limit <= 0 {
+			limit = defaultGlobLimit
+		}
+		limit = min(limit, maxGlobLimit)
+
+		var patterns [][]string // each alternative, split into path segments
+		for _, alt := range expandBraces(pattern) {
+			segs := strings.Split(strings.Trim(alt, "/"), "/")
+			for _, s := range segs {
+				if _, err := filepath.Match(s, ""); err != nil {
+					return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+				}
+			}
+			patterns = append(patterns, segs)
+		}
+
+		type match struct {
+			path string
+			mod  time.Time
+		}
+		var matches []match
+		ig := ignoreVCS(args, abs)
+		err = filepath.WalkDir(abs, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if fpath == abs {
+					return err
+				}
+				return nil // unreadable; skip it
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if fpath == abs {
+				return nil
+			}
+			if ig != nil && ig.ignored(fpath, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if slices.Contains(globSkipDirs, d.Name()) && !strings.Contains(pattern, d.Name()) {
+					return filepath.SkipDir
+				}
+				if ig != nil {
+					ig.enter(fpath)
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(abs, fpath)
+			if err != nil {
+				return nil
+			}
+			segs := strings.Split(filepath.ToSlash(rel), "/")
+			for _, p := range patterns {
+				if matchSegments(p, segs) {
+					var mod time.Time
+					if fi, err := d.Info(); err == nil {
+						mod = fi.ModTime()
+					}
+					matches = append(matches, match{fpath, mod})
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		p := showPath(abs)
+		if len(matches) == 0 {
+			return fmt.Sprintf("no files matching '%s' in %s", pattern, p), nil
+		}
+		slices.SortStableFunc(matches, func(a, b match) int { return b.mod.Compare(a.mod) })
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "[%d files matching '%s' in %s, newest first]\n", len(matches), pattern, p)
+		for _, m := range matches[:min(limit, len(matches))] {
+			sb.WriteString(showPath(m.path) + "\n")
+		}
+		if len(matches) > limit {
+			fmt.Fprintf(&sb, "... (%d more; narrow the pattern or raise limit)\n", len(matches)-limit)
+		}
+		return sb.String(), nil
+	})
+}
+
+// matchSegments matches a path's segments against a pattern's, where a "**"
+// segment matches any number of them, none included.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchSegments(pattern[1:], path[1:])
+}
+
+// expandBraces expands the first {a,b} group of pattern and, recursively,
+// the rest: "*.{go,md}" is "*.go" and "*.md". Unbalanced braces are left as
+// they are.
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+	depth := 0
+	var alts []string
+	last := start + 1
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				alts = append(alts, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				alts = append(alts, pattern[last:i])
+				var out []string
+				for _, alt := range alts {
+					out = append(out, expandBraces(pattern[:start]+alt+pattern[i+1:])...)
+				}
+				return out
+			}
+		}
+	}
+	return []string{pattern}
+}
@@ -0,0 +1,498 @@
+package tool
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// dropTags are elements that are never part of a page's content.
+var dropTags = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Form: true, atom.Dialog: true,
+	atom.Iframe: true, atom.Svg: true, atom.Canvas: true, atom.Object: true, atom.Embed: true,
+	atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true, atom.Label: true,
+}
+
+// dropRoles are ARIA roles of page furniture.
+var dropRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"search": true, "menu": true, "menubar": true, "dialog": true, "alertdialog": true,
+}
+
+// Words in class and id attributes: boilerplateWords mark ads, menus and
+// the like, unless a contentWords word says the element holds the content.
+var (
+	boilerplateWords = map[string]bool{
+		"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true, "footer": true,
+		"comment": true, "comments": true, "share": true, "sharing": true, "social": true,
+		"ad": true, "ads": true, "advert": true, "advertisement": true, "sponsor": true, "sponsored": true, "promo": true,
+		"cookie": true, "cookies": true, "consent": true, "banner": true, "breadcrumb": true, "breadcrumbs": true,
+		"related": true, "recommended": true, "subscribe": true, "newsletter": true, "popup": true, "modal": true,
+		"toc": true, "skip": true, "masthead": true, "pagination": true,
+	}
+	contentWords = map[string]bool{"article": true, "content": true, "main": true, "post": true, "entry": true, "story": true, "body": true}
+)
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// htmlToMarkdown returns the title of an HTML page and its main content as
+// markdown: headings, paragraphs, lists, tables, quotes, code blocks, and
+// links and images with URLs resolved against base. Scripts, menus, ads and
+// other page furniture are left out.
+func htmlToMarkdown(r io.Reader, base *url.URL) (title, md string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+	c := &mdConverter{base: base}
+	for n := range descendants(doc) {
+		switch {
+		case n.DataAtom == atom.Title && title == "":
+			title = collapseSpace(textOf(n))
+		case n.DataAtom == atom.Base && c.base != nil:
+			if u, err := c.base.Parse(attr(n, "href")); err == nil && attr(n, "href") != "" {
+				c.base = u
+			}
+		}
+	}
+	prune(doc, false)
+	md = c.blocks(mainContent(doc))
+	md = blankRun.ReplaceAllString(strings.TrimSpace(md), "\n\n")
+	return strings.TrimSpace(title), md, nil
+}
+
+// descendants yields the nodes below n in document order.
+func descendants(n *html.Node) iter.Seq[*html.Node] {
+	return func(yield func(*html.Node) bool) {
+		var walk func(*html.Node) bool
+		walk = func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if !yield(c) || !walk(c) {
+					return false
+				}
+			}
+			return true
+		}
+		walk(n)
+	}
+}
+
+// prune removes the elements below n that aren't content. inContent says
+// n is inside an article or main element, where a header is the article's.
+func prune(n *html.Node, inContent bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			n.RemoveChild(c)
+		case c.Type != html.ElementNode:
+		case isBoilerplate(c, inContent):
+			n.RemoveChild(c)
+		default:
+			prune(c, inContent || c.DataAtom == atom.Article || c.DataAtom == atom.Main || attr(c, "role") == "main")
+		}
+		c = next
+	}
+}
+
+// isBoilerplate reports whether the element n isn't content.
+func isBoilerplate(n *html.Node, inContent bool) bool {
+	switch {
+	case dropTags[n.DataAtom], n.DataAtom == atom.Header && !inContent, dropRoles[attr(n, "role")]:
+		return true
+	case attr(n, "aria-hidden") == "true", hasAttr(n, "hidden"):
+		return true
+	case strings.Contains(strings.ReplaceAll(attr(n, "style"), " ", ""), "display:none"):
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Main, atom.Article:
+		return false
+	}
+	words := strings.Fields(nonWord.ReplaceAllString(strings.ToLower(attr(n, "class")+" "+attr(n, "id")), " "))
+	drop := false
+	for _, w := range words {
+		if contentWords[w] {
+			return false
+		}
+		drop = drop || boilerplateWords[w]
+	}
+	return drop
+}
+
+// mainContent returns the element holding the page's content: its largest
+// article, its main element, or else the element whose paragraphs have the
+// most text, scored the way readability tools do. It falls back to the body
+// when that element holds less than half the page's text, which is then
+// spread over the page.
+func mainContent(doc *html.Node) *html.Node {
+	body := doc
+	var article, main *html.Node
+	for n := range descendants(doc) {
+		switch {
+		case n.DataAtom == atom.Body:
+			body = n
+		case n.DataAtom == atom.Article && (article == nil || textLen(n) > textLen(article)):
+			article = n
+		case main == nil && (n.DataAtom == atom.Main || attr(n, "role") == "main"):
+			main = n
+		}
+	}
+	bodyLen := textLen(body)
+	for _, n := range []*html.Node{article, main} {
+		if n != nil && textLen(n) >= bodyLen/3 {
+			return n
+		}
+	}
+
+	scores := map[*html.Node]float64{}
+	for n := range descendants(body) {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre {
+			continue
+		}
+		text := textOf(n)
+		if len(text) < 25 || n.Parent == nil {
+			continue
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		scores[n.Parent] += score
+		if gp := n.Parent.Parent; gp != nil {
+			scores[gp] += score / 2
+		}
+	}
+	var best *html.Node
+	bestScore := 0.0
+	for n, s := range scores {
+		if s *= 1 - linkDensity(n); s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil || textLen(best) < bodyLen/2 {
+		return body
+	}
+	// take in the headings and short parts around the paragraphs
+	for best.Parent != nil && best.Parent != body && float64(textLen(best)) >= 0.75*float64(textLen(best.Parent)) {
+		best = best.Parent
+	}
+	return best
+}
+
+// linkDensity is the share of n's text that is link text.
+func linkDensity(n *html.Node) float64 {
+	total := textLen(n)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	for d := range descendants(n) {
+		if d.DataAtom == atom.A {
+			links += textLen(d)
+		}
+	}
+	return float64(links) / float64(total)
+}
+
+// mdConverter renders HTML as markdown.
+type mdConverter struct {
+	base *url.URL
+}
+
+// blockTags are the elements rendered as blocks of their own.
+var blockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Center: true,
+	atom.Details: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Fieldset: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true,
+	atom.Li: true, atom.Main: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Summary: true, atom.Table: true, atom.Ul: true, atom.Body: true, atom.Html: true,
+}
+
+// blocks renders n's children as markdown blocks separated by blank lines.
+func (c *mdConverter) blocks(n *html.Node) string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		var lines []string
+		for _, l := range strings.Split(inline.String(), "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) > 0 {
+			out = append(out, strings.Join(lines, "\n"))
+		}
+		inline.Reset()
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type != html.ElementNode || !blockTags[ch.DataAtom] {
+			inline.WriteString(c.inline(ch))
+			continue
+		}
+		flush()
+		if b := c.block(ch); strings.TrimSpace(b) != "" {
+			out = append(out, b)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n\n")
+}
+
+// block renders the block element n.
+func (c *mdConverter) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapseSpace(c.inline(n))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", int(n.Data[1]-'0')) + " " + text
+	case atom.Pre:
+		code := strings.Trim(textOf(n), "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return fence + codeLanguage(n) + "\n" + code + "\n" + fence
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Blockquote:
+		return prefixLines(c.blocks(n), "> ", "> ")
+	case atom.Hr:
+		return "---"
+	case atom.Table:
+		return c.table(n)
+	case atom.Dt:
+		return "**" + collapseSpace(c.inline(n)) + "**"
+	}
+	return c.blocks(n)
+}
+
+// list renders a ul or ol, items indented under their markers.
+func (c *mdConverter) list(n *html.Node) string {
+	var items []string
+	i := 1
+	if start := toInt(attr(n, "start")); start > 0 {
+		i = start
+	}
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+			i++
+		}
+		body := c.blocks(li)
+		if li.DataAtom != atom.Li {
+			body = c.block(li) // a nested list or stray element
+		}
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		items = append(items, prefixLines(body, marker, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as a pipe table, its first row the header.
+func (c *mdConverter) table(n *html.Node) string {
+	var rows [][]string
+	for tr := range descendants(n) {
+		if tr.DataAtom != atom.Tr {
+			continue
+		}
+		var row []string
+		for td := tr.FirstChild; td != nil; td = td.NextSibling {
+			if td.DataAtom == atom.Td || td.DataAtom == atom.Th {
+				row = append(row, strings.ReplaceAll(collapseSpace(c.inline(td)), "|", `\|`))
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var sb strings.Builder
+	for i, row := range rows {
+		row = append(row, make([]string, cols-len(row))...)
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// inline renders n as inline markdown, white space collapsed and line
+// breaks as newlines.
+func (c *mdConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaceRun.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Img:
+		alt, src := collapseSpace(attr(n, "alt")), c.resolve(attr(n, "src"))
+		if alt == "" || src == "" || strings.HasPrefix(src, "data:") {
+			return "" // decorative
+		}
+		return fmt.Sprintf("![%s](%s)", alt, src)
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		code := collapseSpace(textOf(n))
+		if code == "" {
+			return ""
+		}
+		if strings.Contains(code, "`") {
+			return "`` " + code + " ``"
+		}
+		return "`" + code + "`"
+	}
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && blockTags[ch.DataAtom] {
+			sb.WriteString(" " + c.inline(ch) + " ") // a block inside a link or the like
+			continue
+		}
+		sb.WriteString(c.inline(ch))
+	}
+	text := sb.String()
+	switch n.DataAtom {
+	case atom.A:
+		href := attr(n, "href")
+		if strings.TrimSpace(text) == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		return wrapInline(text, "[", "]("+c.resolve(href)+")")
+	case atom.Strong, atom.B:
+		return wrapInline(text, "**", "**")
+	case atom.Em, atom.I:
+		return wrapInline(text, "*", "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(text, "~~", "~~")
+	}
+	return text
+}
+
+// resolve makes a link absolute.
+func (c *mdConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if c.base == nil || href == "" {
+		return href
+	}
+	u, err := c.base.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.String()
+}
+
+var (
+	spaceRun = regexp.MustCompile(`\s+`)
+	blankRun = regexp.MustCompile(`\n{3,}`)
+)
+
+// wrapInline puts open and close around text, inside its outer spaces,
+// so "<b> bold </b>" gives " **bold** ".
+func wrapInline(text, open, close string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + open + trimmed + close + trail
+}
+
+// codeLanguage returns the language a pre element (or its code element)
+// declares in a class such as language-go or lang-go.
+func codeLanguage(n *html.Node) string {
+	classes := attr(n, "class")
+	if code := n.FirstChild; code != nil && code.DataAtom == atom.Code {
+		classes += " " + attr(code, "class")
+	}
+	for _, cl := range strings.Fields(classes) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(cl, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// prefixLines puts first before the first line of s and rest before the
+// others that aren't empty.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + l
+		case l != "":
+			lines[i] = rest + l
+		case strings.TrimSpace(rest) != "":
+			lines[i] = strings.TrimSpace(rest) // a blank line in a quote
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textOf returns the text below n as it is in the page.
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for d := range descendants(n) {
+		if d.Type == html.TextNode {
+			sb.WriteString(d.Data)
+		}
+	}
+	return sb.String()
+}
+
+// textLen is the length of the text below n, white space runs counted once.
+func textLen(n *html.Node) int {
+	return len(collapseSpace(textOf(n)))
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(spaceRun.ReplaceAllString(s, " "))
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
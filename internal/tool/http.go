@@ -21,7 +21,9 @@ const (
 )
 
 func (r *Registry) registerHTTP() {
-	r.RegisterReadOnly(provider.ToolDef{
+	// the call's own timeout argument bounds it, up to maxTimeout
+	r.options["http"] = ToolOptions{Timeout: maxTimeout * time.Second}
+	r.RegisterReadOnlyV2(provider.ToolDef{
 		Name:        "http",
 		Description: "The primary tool for all HTTP/REST/API requests — always use this FIRST instead of curl/wget in bash. Advantages over bash+curl: structured JSON output (no jq needed), automatic error handling, faster (no shell startup), better readability. Supports GET, POST, PUT, DELETE, PATCH, HEAD, OPTIONS. Returns status, headers, body, size, and timing as JSON. Only fall back to bash for features this tool lacks (e.g. file upload, WebSocket, streaming download). For sensitive data (API keys, tokens), use the 'interactive' tool to collect them first, then pass via headers.",
 		Parameters: map[string]any{
@@ -37,11 +39,11 @@ func (r *Registry) registerHTTP() {
 			},
 			"required": []string{"method", "url"},
 		},
-	}, func(ctx context.Context, args map[string]any) (string, error) {
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
 		method := strings.ToUpper(getStr(args, "method"))
 		rawURL := getStr(args, "url")
 		if rawURL == "" {
-			return errJSON("url is required"), nil
+			return ToolResult{Text: errJSON("url is required")}, nil
 		}
 		if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
 			rawURL = "http://" + rawURL
@@ -58,7 +60,7 @@ func (r *Registry) registerHTTP() {
 		// build URL with query params
 		parsedURL, err := url.Parse(rawURL)
 		if err != nil {
-			return errJSON("invalid URL: " + err.Error()), nil
+			return ToolResult{Text: errJSON("invalid URL: " + err.Error())}, nil
 		}
 		if query, ok := args["query"].(map[string]any); ok {
 			q := parsedURL.Query()
@@ -77,7 +79,7 @@ func (r *Registry) registerHTTP() {
 		defer cancel()
 		req, err := http.NewRequestWithContext(ctx, method, parsedURL.String(), bodyReader)
 		if err != nil {
-			return errJSON(err.Error()), nil
+			return ToolResult{Text: errJSON(err.Error())}, nil
 		}
 		req.Header.Set("User-Agent", "GAL-CLI/1.0")
 		if headers, ok := args["headers"].(map[string]any); ok {
@@ -99,38 +101,59 @@ func (r *Registry) registerHTTP() {
 		resp, err := client.Do(req)
 		elapsed := time.Since(start).Milliseconds()
 		if err != nil {
-			return errJSON(err.Error()), nil
+			return ToolResult{Text: errJSON(err.Error())}, nil
 		}
 		defer resp.Body.Close()
 
 		// read body (capped)
 		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		return httpResult(method, parsedURL.String(), resp, respBody, elapsed, nil), nil
+	})
+	r.SetUntrusted("http")
+}
 
-		// collect response headers
-		respHeaders := make(map[string]string)
-		for k := range resp.Header {
-			respHeaders[k] = resp.Header.Get(k)
-		}
+// httpResult is the http tool's answer for resp, whose body (read up to
+// maxResponseSize) is respBody: status, headers and the body's start as
+// JSON, with the fields of extra added.
+func httpResult(method, rawURL string, resp *http.Response, respBody []byte, elapsed int64, extra map[string]any) ToolResult {
+	// collect response headers
+	respHeaders := make(map[string]string)
+	for k := range resp.Header {
+		respHeaders[k] = resp.Header.Get(k)
+	}
 
-		// truncate body for LLM context (keep full size info)
-		bodyStr := string(respBody)
-		truncated := false
-		if len(bodyStr) > maxBodyPreview {
-			bodyStr = bodyStr[:maxBodyPreview] + "...(truncated)"
-			truncated = true
-		}
+	// truncate body for LLM context (keep full size info)
+	bodyStr := string(respBody)
+	truncated := false
+	if len(bodyStr) > maxBodyPreview {
+		bodyStr = bodyStr[:maxBodyPreview] + "...(truncated)"
+		truncated = true
+	}
 
-		result, _ := json.Marshal(map[string]any{
-			"status":      resp.StatusCode,
-			"status_text": resp.Status,
-			"headers":     respHeaders,
-			"body":        bodyStr,
-			"size":        len(respBody),
-			"truncated":   truncated,
-			"time_ms":     elapsed,
-		})
-		return string(result), nil
-	})
+	fields := map[string]any{
+		"status":      resp.StatusCode,
+		"status_text": resp.Status,
+		"headers":     respHeaders,
+		"body":        bodyStr,
+		"size":        len(respBody),
+		"truncated":   truncated,
+		"time_ms":     elapsed,
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	result, _ := json.Marshal(fields)
+	return ToolResult{
+		Text: string(result),
+		Meta: map[string]any{
+			"method":       method,
+			"url":          rawURL,
+			"status":       resp.StatusCode,
+			"bytes":        len(respBody),
+			"content_type": resp.Header.Get("Content-Type"),
+			"duration_ms":  elapsed,
+		},
+	}
 }
 
 func getStr(m map[string]any, key string) string {
@@ -0,0 +1,337 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Limits of one log_read call.
+const (
+	defaultLogLines = 100
+	maxLogLines     = 1000
+	maxLogScan      = 8 << 20  // bytes read from the file
+	maxLogOutput    = 32 << 10 // bytes of lines returned
+	maxLogLineLen   = 2000     // longer lines are clipped
+	maxLogFollow    = 120      // seconds
+	logPollInterval = 500 * time.Millisecond
+)
+
+func (r *Registry) registerLogRead() {
+	r.RegisterReadOnly(provider.ToolDef{
+		Name:        "log_read",
+		Description: "Read the tail of a log file, optionally filtered by a regular expression and a start time. The result header gives the byte offset reached: pass it back as offset to get only the lines written since. With follow_seconds, waits up to that long for new matching lines (returning as soon as some arrive), so you can watch a log without polling with bash.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":           map[string]any{"type": "string", "description": "Log file path"},
+				"lines":          map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum lines to return (default %d, at most %d). Without offset these are the last matching lines", defaultLogLines, maxLogLines)},
+				"grep":           map[string]any{"type": "string", "description": "Only return lines matching this regular expression (RE2 syntax; prefix with (?i) to ignore case)"},
+				"since":          map[string]any{"type": "string", "description": "Only return lines logged at or after this time: a timestamp like 2024-05-01T10:00:00, 2024-05-01 10:00 or 10:00 (today), or a duration ago like 15m or 2h. Needs ISO, syslog or access-log timestamps in the file; lines without one belong to the line above"},
+				"offset":         map[string]any{"type": "integer", "description": "Byte offset from a previous call's header: return the lines after it, oldest first"},
+				"follow_seconds": map[string]any{"type": "integer", "description": fmt.Sprintf("Wait up to this many seconds (at most %d) for new matching lines. Without offset, only lines written after the call count", maxLogFollow)},
+			},
+			"required": []string{"path"},
+		},
+	}, func(ctx context.Context, args map[string]any) (string, error) {
+		p, err := pathArg(args)
+		if err != nil {
+			return "", err
+		}
+		n := toInt(args["lines"])
+		if n <= 0 {
+			n = defaultLogLines
+		}
+		n = min(n, maxLogLines)
+		q := &logQuery{}
+		if pat := getStr(args, "grep"); pat != "" {
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				return "", fmt.Errorf("invalid grep pattern: %w", err)
+			}
+			q.re = re
+		}
+		if s := getStr(args, "since"); s != "" {
+			t, err := parseSince(s, time.Now())
+			if err != nil {
+				return "", err
+			}
+			q.since = t
+		}
+		follow := min(max(toInt(args["follow_seconds"]), 0), maxLogFollow)
+
+		offset := int64(-1)
+		if v, ok := args["offset"]; ok && v != nil {
+			offset = max(int64(toInt(v)), 0)
+		}
+		res, err := readLog(p, offset, n, q)
+		if err != nil {
+			return "", err
+		}
+		if follow > 0 {
+			if offset < 0 {
+				// only lines written from now on
+				res.lines, res.start, res.scanned, res.notes = nil, res.next, 0, nil
+			}
+			deadline := time.Now().Add(time.Duration(follow) * time.Second)
+			for len(res.lines) == 0 && !res.more && time.Now().Before(deadline) {
+				select {
+				case <-ctx.Done():
+					return "", ctx.Err()
+				case <-time.After(logPollInterval):
+				}
+				next, err := readLog(p, res.next, n, q)
+				if err != nil {
+					return "", err
+				}
+				next.start = res.start
+				next.notes = append(res.notes, next.notes...)
+				next.scanned += res.scanned
+				res = next
+			}
+			if len(res.lines) == 0 {
+				res.notes = append(res.notes, fmt.Sprintf("no new matching lines within %ds", follow))
+			}
+		}
+		return res.format(showPath(p), q), nil
+	})
+}
+
+// logQuery filters log lines by pattern and time.
+type logQuery struct {
+	re     *regexp.Regexp
+	since  time.Time
+	lastTS time.Time // timestamp of the last stamped line, for continuation lines
+}
+
+func (q *logQuery) filtered() bool { return q.re != nil || !q.since.IsZero() }
+
+// match reports whether line passes the filter. It must see every line in
+// order, since lines without a timestamp (stack traces, wrapped messages)
+// take the one of the line above.
+func (q *logQuery) match(line string) bool {
+	if !q.since.IsZero() {
+		if t, ok := logTimestamp(line); ok {
+			q.lastTS = t
+		}
+		if q.lastTS.IsZero() || q.lastTS.Before(q.since) {
+			return false
+		}
+	}
+	return q.re == nil || q.re.MatchString(line)
+}
+
+// logResult is what one read of the file produced.
+type logResult struct {
+	lines   []string
+	start   int64 // where reading started
+	next    int64 // offset to continue from
+	size    int64
+	scanned int  // lines looked at
+	more    bool // stopped before the end of the file
+	notes   []string
+}
+
+// readLog reads complete lines from the file. With offset < 0 it returns the
+// last n matching lines of (at most the last maxLogScan bytes of) the file;
+// otherwise the first n matching lines after offset. A trailing line without
+// its newline is left for the next call, as the writer may be mid-line.
+func readLog(path string, offset int64, n int, q *logQuery) (*logResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	res := &logResult{size: info.Size()}
+
+	tail := offset < 0
+	if tail {
+		offset = max(res.size-maxLogScan, 0)
+		if offset > 0 {
+			res.notes = append(res.notes, fmt.Sprintf("only the last %d MB were searched", maxLogScan>>20))
+		}
+	} else if offset > res.size {
+		res.notes = append(res.notes, fmt.Sprintf("file is now %d bytes, shorter than offset %d (rotated or truncated?); read from the start", res.size, offset))
+		offset = 0
+	}
+
+	buf := make([]byte, min(res.size-offset, maxLogScan))
+	if _, err := io.ReadFull(io.NewSectionReader(f, offset, int64(len(buf))), buf); err != nil {
+		return nil, err
+	}
+	if tail && offset > 0 {
+		// skip the partial line the window starts in
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			buf, offset = buf[i+1:], offset+int64(i+1)
+		} else {
+			buf = nil
+		}
+	}
+	res.start = offset
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	if end == 0 && len(buf) == maxLogScan {
+		return nil, fmt.Errorf("no line break in %d MB after offset %d; not a text log?", maxLogScan>>20, offset)
+	}
+	res.next = offset + int64(end)
+	if !tail {
+		res.more = len(buf) == maxLogScan && offset+maxLogScan < res.size
+	}
+
+	outBytes := 0
+	for pos := 0; pos < end; {
+		i := bytes.IndexByte(buf[pos:], '\n')
+		line := buf[pos : pos+i]
+		lineStart := pos
+		pos += i + 1
+		res.scanned++
+		text := strings.TrimSuffix(string(line), "\r")
+		if !q.match(text) {
+			continue
+		}
+		text = clipLogLine(text)
+		if tail {
+			res.lines = append(res.lines, text)
+			outBytes += len(text) + 1
+			if len(res.lines) > n {
+				outBytes -= len(res.lines[0]) + 1
+				res.lines = res.lines[1:]
+			}
+			continue
+		}
+		if len(res.lines) == n || outBytes+len(text)+1 > maxLogOutput {
+			// stop before this line; the next call starts with it
+			res.next, res.more = offset+int64(lineStart), true
+			res.scanned--
+			break
+		}
+		res.lines = append(res.lines, text)
+		outBytes += len(text) + 1
+	}
+	// keep the newest lines within the output cap
+	for tail && outBytes > maxLogOutput {
+		outBytes -= len(res.lines[0]) + 1
+		res.lines = res.lines[1:]
+		res.notes = appendOnce(res.notes, fmt.Sprintf("output capped at %d KB; older lines left out", maxLogOutput>>10))
+	}
+	return res, nil
+}
+
+func (res *logResult) format(path string, q *logQuery) string {
+	var sb strings.Builder
+	what := fmt.Sprintf("%d lines", len(res.lines))
+	if q.filtered() {
+		what = fmt.Sprintf("%d of %d lines matched", len(res.lines), res.scanned)
+	}
+	fmt.Fprintf(&sb, "[log_read %s: %s, bytes %d-%d of %d; continue with offset=%d", path, what, res.start, res.next, res.size, res.next)
+	if res.more {
+		sb.WriteString("; more lines follow")
+	}
+	sb.WriteString("]\n")
+	for _, note := range res.notes {
+		sb.WriteString("(" + note + ")\n")
+	}
+	for _, l := range res.lines {
+		sb.WriteString(l + "\n")
+	}
+	return sb.String()
+}
+
+func clipLogLine(s string) string {
+	if len(s) <= maxLogLineLen {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxLogLineLen], "") + fmt.Sprintf("… (%d bytes)", len(s))
+}
+
+func appendOnce(list []string, s string) []string {
+	if len(list) > 0 && list[len(list)-1] == s {
+		return list
+	}
+	return append(list, s)
+}
+
+// Timestamps recognized at the start of log lines (after an optional level or
+// bracket): ISO 8601 / RFC 3339, syslog ("Jan  2 15:04:05") and the common
+// access log format ("[02/Jan/2006:15:04:05 -0700]").
+var (
+	isoStamp    = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2})(?:[.,]\d+)?\s?(Z|[+-]\d{2}:?\d{2})?`)
+	syslogStamp = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`)
+	accessStamp = regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`)
+)
+
+// logStampWindow is how far into a line a timestamp is looked for.
+const logStampWindow = 100
+
+// logTimestamp returns the time a log line was written, if it has a
+// recognizable timestamp. Times without a zone are taken as local time.
+func logTimestamp(line string) (time.Time, bool) {
+	head := line[:min(len(line), logStampWindow)]
+	if m := isoStamp.FindStringSubmatch(head); m != nil {
+		if m[3] != "" {
+			zone := m[3]
+			if zone != "Z" && !strings.Contains(zone, ":") {
+				zone = zone[:3] + ":" + zone[3:]
+			}
+			t, err := time.Parse(time.RFC3339, m[1]+"T"+m[2]+zone)
+			return t, err == nil
+		}
+		t, err := time.ParseInLocation("2006-01-02T15:04:05", m[1]+"T"+m[2], time.Local)
+		return t, err == nil
+	}
+	if m := syslogStamp.FindStringSubmatch(head); m != nil {
+		t, err := time.ParseInLocation("Jan _2 15:04:05", m[1], time.Local)
+		if err != nil {
+			return time.Time{}, false
+		}
+		// syslog leaves out the year: take the latest one not in the future
+		now := time.Now()
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, true
+	}
+	if m := accessStamp.FindStringSubmatch(head); m != nil {
+		t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// parseSince parses the since argument: a duration ago, a full timestamp, a
+// date, or a time of day today.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d.Abs()), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			y, m, d := now.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse since %q: use a timestamp like 2024-05-01T10:00:00, a time of day like 10:00, or a duration like 15m", s)
+}
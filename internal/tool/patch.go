@@ -10,7 +10,7 @@ import (
 )
 
 func (r *Registry) registerPatch() {
-	r.Register(provider.ToolDef{
+	r.RegisterV2(provider.ToolDef{
 		Name:        "file_patch",
 		Description: "Edit a file by replacing an exact string match. More precise than file_edit (line-based). The old_str must match exactly one location in the file. Use for surgical edits where you know the exact text to change.",
 		Parameters: map[string]any{
@@ -22,31 +22,43 @@ func (r *Registry) registerPatch() {
 			},
 			"required": []string{"path", "old_str", "new_str"},
 		},
-	}, func(_ context.Context, args map[string]any) (string, error) {
-		p, _ := args["path"].(string)
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		abs, err := pathArg(args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		p := showPath(abs)
 		oldStr, _ := args["old_str"].(string)
 		newStr, _ := args["new_str"].(string)
 
-		data, err := os.ReadFile(p)
+		data, err := os.ReadFile(abs)
 		if err != nil {
-			return "", err
+			return ToolResult{}, err
 		}
 		content := string(data)
 
 		count := strings.Count(content, oldStr)
 		if count == 0 {
-			return "", fmt.Errorf("old_str not found in %s", p)
+			return ToolResult{}, fmt.Errorf("old_str not found in %s", p)
 		}
 		if count > 1 {
-			return "", fmt.Errorf("old_str matches %d locations in %s (must be unique)", count, p)
+			return ToolResult{}, fmt.Errorf("old_str matches %d locations in %s (must be unique)", count, p)
 		}
 
 		newContent := strings.Replace(content, oldStr, newStr, 1)
-		if err := os.WriteFile(p, []byte(newContent), 0644); err != nil {
-			return "", err
+		if err := r.backup(ctx, "file_patch", abs); err != nil {
+			return ToolResult{}, err
+		}
+		if err := os.WriteFile(abs, []byte(newContent), 0644); err != nil {
+			return ToolResult{}, err
 		}
 
-		return fmt.Sprintf("patched %s\n%s", p, FormatDiff(oldStr, newStr)), nil
+		diff := FormatDiff(oldStr, newStr)
+		return ToolResult{
+			Text:   fmt.Sprintf("patched %s\n%s", p, diff),
+			Meta:   map[string]any{"path": p, "bytes": len(newContent), "diff": diff},
+			Change: &FileChange{Path: abs, Before: content, After: newContent},
+		}, nil
 	})
 }
 
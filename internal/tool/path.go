@@ -0,0 +1,87 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath turns a path the model wrote into the absolute path the file
+// tools work on. Models write paths the way a shell user would, so:
+//
+//   - surrounding whitespace, quotes or backticks and a file:// prefix are dropped
+//   - "~" and "~/..." are the home directory; "~user" is refused rather than
+//     taken as a directory named "~user"
+//   - backslashes are separators where the OS doesn't use them ("src\main.go")
+//   - the result is cleaned ("./a/../b" is "b") and relative paths are
+//     resolved against gal-cli's working directory, which only /cd moves
+//     (shell mode's cd keeps a directory of its own)
+//
+// Empty paths and paths with NUL bytes are errors. Every file tool goes
+// through here, so a limit on where they may read and write belongs here too.
+func resolvePath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if len(p) >= 2 && strings.ContainsRune("\"'`", rune(p[0])) && p[len(p)-1] == p[0] {
+		p = strings.TrimSpace(p[1 : len(p)-1])
+	}
+	p = strings.TrimPrefix(p, "file://")
+	if p == "" {
+		return "", errors.New("path is empty")
+	}
+	if strings.ContainsRune(p, 0) {
+		return "", fmt.Errorf("path %q contains a NUL byte", p)
+	}
+	if filepath.Separator == '/' {
+		p = strings.ReplaceAll(p, `\`, "/")
+	}
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("can't expand ~ in %s: %w", p, err)
+		}
+		p = filepath.Join(home, p[1:])
+	} else if strings.HasPrefix(p, "~") {
+		return "", fmt.Errorf("path %s: ~user paths aren't supported; write the full path", p)
+	}
+	if !filepath.IsAbs(p) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("resolve %s: %w", p, err)
+		}
+		p = filepath.Join(cwd, p)
+	}
+	return filepath.Clean(p), nil
+}
+
+// showPath is how results name a resolved path: relative to the working
+// directory when it is inside it, as models usually write them, and
+// absolute otherwise.
+func showPath(abs string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(cwd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
+
+// ResolvePath resolves a path argument the way the file tools will, for
+// checks made before they run.
+func ResolvePath(p string) (string, error) {
+	return resolvePath(p)
+}
+
+// ShowPath names a resolved path the way the file tools' results do.
+func ShowPath(abs string) string {
+	return showPath(abs)
+}
+
+// pathArg reads and resolves a tool's path argument.
+func pathArg(args map[string]any) (string, error) {
+	return resolvePath(getStr(args, "path"))
+}
@@ -0,0 +1,87 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir moves the test into dir until it ends.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+func TestResolvePath(t *testing.T) {
+	cwd := t.TempDir()
+	home := t.TempDir()
+	chdir(t, cwd)
+	cwd, _ = os.Getwd()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // os.UserHomeDir on Windows
+	// an absolute path outside cwd: /etc/hosts, or C:\etc\hosts on Windows
+	vol := filepath.VolumeName(cwd)
+	hosts := filepath.Join(vol+string(filepath.Separator), "etc", "hosts")
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"main.go", filepath.Join(cwd, "main.go"), false},
+		{"./src/../main.go", filepath.Join(cwd, "main.go"), false},
+		{"  main.go\n", filepath.Join(cwd, "main.go"), false},
+		{`"src/main.go"`, filepath.Join(cwd, "src", "main.go"), false},
+		{"'src/main.go'", filepath.Join(cwd, "src", "main.go"), false},
+		{"`src/main.go`", filepath.Join(cwd, "src", "main.go"), false},
+		{`"unbalanced.go`, filepath.Join(cwd, `"unbalanced.go`), false},
+		{"file://" + filepath.ToSlash(hosts), hosts, false},
+		{vol + "/etc//hosts/", hosts, false},
+		{"..", filepath.Dir(cwd), false},
+		{"~", home, false},
+		{"~/notes.md", filepath.Join(home, "notes.md"), false},
+		{`~\notes.md`, filepath.Join(home, "notes.md"), false},
+		{`src\main.go`, filepath.Join(cwd, "src", "main.go"), false},
+		{"~bob/notes.md", "", true},
+		{"", "", true},
+		{"  ", "", true},
+		{`""`, "", true},
+		{"a\x00b", "", true},
+	}
+	for _, tt := range tests {
+		got, err := resolvePath(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolvePath(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestShowPath(t *testing.T) {
+	cwd := t.TempDir()
+	chdir(t, cwd)
+	cwd, _ = os.Getwd() // it may be reached through a symlink (macOS's /var)
+	hosts := filepath.Join(filepath.VolumeName(cwd)+string(filepath.Separator), "etc", "hosts")
+	tests := []struct {
+		abs  string
+		want string
+	}{
+		{filepath.Join(cwd, "main.go"), "main.go"},
+		{filepath.Join(cwd, "src", "main.go"), filepath.Join("src", "main.go")},
+		{cwd, "."},
+		{filepath.Join(cwd, "..foo"), "..foo"},
+		{filepath.Dir(cwd), filepath.Dir(cwd)},
+		{filepath.Join(filepath.Dir(cwd), "other", "a.go"), filepath.Join(filepath.Dir(cwd), "other", "a.go")},
+		{hosts, hosts},
+	}
+	for _, tt := range tests {
+		if got := showPath(tt.abs); got != tt.want {
+			t.Errorf("showPath(%q) = %q, want %q", tt.abs, got, tt.want)
+		}
+	}
+}
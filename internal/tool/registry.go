@@ -3,13 +3,14 @@ package tool
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/gal-cli/gal-cli/internal/provider"
@@ -17,25 +18,109 @@ import (
 
 type Handler func(ctx context.Context, args map[string]any) (string, error)
 
+// ToolResult is a tool's answer. Text goes to the model; Meta holds facts
+// about the call (exit code, HTTP status, path, diff) for the chat UI and
+// --json output, and is never sent to the model.
+type ToolResult struct {
+	Text   string
+	Meta   map[string]any
+	Change *FileChange // what a file tool did to the file, for the session's changelog
+	// Changes is Change for a tool that changes several files, one for each.
+	Changes []*FileChange
+}
+
+// FileChange is a file's content before and after a tool wrote it.
+type FileChange struct {
+	Path          string // absolute
+	Before, After string
+	Created       bool        // the file didn't exist before
+	Deleted       bool        // the tool removed the file
+	Mode          os.FileMode // the file's permissions before, if the tool removed it; else those it has
+}
+
+// HandlerV2 is a Handler that also returns metadata.
+type HandlerV2 func(ctx context.Context, args map[string]any) (ToolResult, error)
+
 type Registry struct {
-	tools    map[string]Handler
+	tools    map[string]HandlerV2
 	toolDefs map[string]provider.ToolDef
 	readonly map[string]bool
+	conflict map[string]string // tool name → conflict group (see ConflictKey)
+	custom   map[string]bool   // tools from custom_tools, which may be redefined
+	external map[string]bool   // tools returning third-party content (web pages, MCP servers)
+	results  map[string]int    // result size limits in tokens, see SetResultTokens
+
+	options     map[string]ToolOptions   // as registered, see RegisterWithOptions
+	timeouts    map[string]time.Duration // tools.timeouts overrides, see SetTimeout
+	concurrency map[string]int           // configured limits, see SetConcurrency
+	semMu       sync.Mutex
+	sems        map[string]chan struct{} // by limitKey
+
+	backups backupJournal // see SetBackupDir
+
+	// readonlyCall tells, for tools that only sometimes change something,
+	// which calls don't (see IsReadOnlyCall).
+	readonlyCall map[string]func(args map[string]any) bool
+}
+
+// ExclusiveKey is the conflict key of calls that must not run alongside any other call.
+const ExclusiveKey = "*"
+
+// PathGroup is the conflict group for tools whose calls only conflict when they touch the same path.
+const PathGroup = "path"
+
+var debug provider.DebugFunc
+
+// SetDebug wires a debug logger for tool internals such as the browser lifecycle.
+func SetDebug(f provider.DebugFunc) {
+	debug = f
+}
+
+func debugLog(format string, args ...any) {
+	if debug != nil {
+		debug(format, args...)
+	}
 }
 
 func NewRegistry() *Registry {
 	r := &Registry{
-		tools:    make(map[string]Handler),
+		tools:    make(map[string]HandlerV2),
 		toolDefs: make(map[string]provider.ToolDef),
 		readonly: make(map[string]bool),
+		conflict: make(map[string]string),
+		custom:   make(map[string]bool),
+		external: make(map[string]bool),
+		results:  make(map[string]int),
+
+		options:     make(map[string]ToolOptions),
+		timeouts:    make(map[string]time.Duration),
+		concurrency: make(map[string]int),
+		sems:        make(map[string]chan struct{}),
+
+		readonlyCall: make(map[string]func(args map[string]any) bool),
 	}
 	r.registerBuiltins()
 	return r
 }
 
 func (r *Registry) Register(def provider.ToolDef, h Handler) {
-	r.tools[def.Name] = h
-	r.toolDefs[def.Name] = def
+	r.RegisterV2(def, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		text, err := h(ctx, args)
+		return ToolResult{Text: text}, err
+	})
+}
+
+// ToolOptions are a tool's settings besides its definition and handler.
+type ToolOptions struct {
+	// Timeout bounds each call; 0 uses DefaultTimeout, < 0 never times out.
+	// tools.timeouts in gal.yaml overrides it, see SetTimeout.
+	Timeout time.Duration
+}
+
+// RegisterWithOptions registers a tool with options.
+func (r *Registry) RegisterWithOptions(def provider.ToolDef, h Handler, opts ToolOptions) {
+	r.Register(def, h)
+	r.options[def.Name] = opts
 }
 
 func (r *Registry) RegisterReadOnly(def provider.ToolDef, h Handler) {
@@ -43,10 +128,195 @@ func (r *Registry) RegisterReadOnly(def provider.ToolDef, h Handler) {
 	r.readonly[def.Name] = true
 }
 
+// RegisterV2 registers a tool that returns metadata with its text.
+func (r *Registry) RegisterV2(def provider.ToolDef, h HandlerV2) {
+	r.tools[def.Name] = h
+	r.toolDefs[def.Name] = def
+}
+
+// RegisterReadOnlyV2 is RegisterReadOnly for a HandlerV2.
+func (r *Registry) RegisterReadOnlyV2(def provider.ToolDef, h HandlerV2) {
+	r.RegisterV2(def, h)
+	r.readonly[def.Name] = true
+}
+
 func (r *Registry) IsReadOnly(name string) bool {
 	return r.readonly[name]
 }
 
+// IsReadOnlyCall reports whether a call changes nothing: it calls a
+// read-only tool, or a tool like git whose arguments say this call only
+// looks (git status, but not git commit). Such calls run alongside others
+// and without approval, as read-only tools' do.
+func (r *Registry) IsReadOnlyCall(name string, args map[string]any) bool {
+	if r.readonly[name] {
+		return true
+	}
+	f := r.readonlyCall[name]
+	return f != nil && f(args)
+}
+
+// SetResultTokens sets how many tokens of a tool's result go to the model
+// before the engine cuts it (max_tool_result_tokens otherwise). n < 0 never
+// cuts, for tools that limit their own output; n == 0 keeps the default.
+func (r *Registry) SetResultTokens(name string, n int) {
+	if n != 0 {
+		r.results[name] = n
+	}
+}
+
+// ResultTokens returns the limit SetResultTokens set for a tool, if any.
+func (r *Registry) ResultTokens(name string) (n int, ok bool) {
+	n, ok = r.results[name]
+	return n, ok
+}
+
+// DefaultTimeout bounds calls of tools that don't set a timeout of their own.
+const DefaultTimeout = 120 * time.Second
+
+// SetTimeout overrides how long a call of a tool may run before Execute
+// gives up on it (tools.timeouts); d < 0 never times out, d == 0 keeps what
+// the tool was registered with.
+func (r *Registry) SetTimeout(name string, d time.Duration) {
+	if d != 0 {
+		r.timeouts[name] = d
+	}
+}
+
+// Timeout returns how long a call of a tool may run: SetTimeout's, else the
+// tool's own, else DefaultTimeout. 0 means no limit.
+func (r *Registry) Timeout(name string) time.Duration {
+	d, ok := r.timeouts[name]
+	if !ok {
+		d = r.options[name].Timeout
+	}
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return DefaultTimeout
+	}
+	return d
+}
+
+// SetUntrusted marks a tool whose results come from third parties (web
+// pages, APIs, MCP servers) and may contain instructions aimed at the model.
+func (r *Registry) SetUntrusted(name string) {
+	r.external[name] = true
+}
+
+// IsUntrusted reports whether a tool's results are third-party content.
+func (r *Registry) IsUntrusted(name string) bool {
+	return r.external[name]
+}
+
+// SetConflictGroup declares which calls of a tool may not run concurrently.
+// Use PathGroup for file tools, or a shared name (e.g. "mcp:<server>") to
+// serialize all tools of one backend.
+func (r *Registry) SetConflictGroup(name, group string) {
+	r.conflict[name] = group
+}
+
+// ConflictKey returns the key used to schedule a call. Calls whose keys
+// conflict (see Conflicts) run in order; others may run concurrently. An empty
+// key means the call conflicts with nothing; ExclusiveKey means it conflicts
+// with everything. Mutating tools without a declared group (e.g. bash) are
+// exclusive. Calls of PathGroup tools get a key of the path they touch:
+// "path:<p>" when they change something there and "read:<p>" when they only
+// look, so readers of a path run alongside each other but not alongside a
+// write to it.
+func (r *Registry) ConflictKey(name string, args map[string]any) string {
+	switch group := r.conflict[name]; group {
+	case "":
+		if r.IsReadOnlyCall(name, args) {
+			return ""
+		}
+		return ExclusiveKey
+	case PathGroup:
+		readOnly := r.IsReadOnlyCall(name, args)
+		p := getStr(args, "path")
+		if p == "" {
+			p = getStr(args, "repo") // git's
+		}
+		if strings.TrimSpace(p) == "" && readOnly {
+			p = "." // glob and git default to the working directory
+		}
+		abs, err := resolvePath(p)
+		if err != nil {
+			return ExclusiveKey
+		}
+		if readOnly {
+			return readKey + canonicalPath(abs)
+		}
+		return PathGroup + ":" + canonicalPath(abs)
+	default:
+		return group
+	}
+}
+
+// readKey prefixes the conflict keys of calls that only read a path.
+const readKey = "read:"
+
+// Conflicts reports whether calls with conflict keys a and b must not run
+// concurrently: both name the same group, or they touch the same path or
+// one inside the other and at least one of them writes there.
+func Conflicts(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == ExclusiveKey || b == ExclusiveKey {
+		return true
+	}
+	pa, aRead := keyPath(a)
+	pb, bRead := keyPath(b)
+	if pa == "" || pb == "" {
+		return a == b
+	}
+	if aRead && bRead {
+		return false
+	}
+	return pathWithin(pa, pb) || pathWithin(pb, pa)
+}
+
+// keyPath returns the path of a PathGroup conflict key and whether the call
+// only reads it, or "" for other keys.
+func keyPath(key string) (p string, read bool) {
+	if p, ok := strings.CutPrefix(key, readKey); ok {
+		return p, true
+	}
+	if p, ok := strings.CutPrefix(key, PathGroup+":"); ok {
+		return p, false
+	}
+	return "", false
+}
+
+// pathWithin reports whether p is dir or inside it.
+func pathWithin(p, dir string) bool {
+	if p == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(p, dir)
+}
+
+// canonicalPath resolves the symlinks in abs, so that two names of one file
+// share a conflict key. Of a path that doesn't exist yet, such as a file
+// about to be written, the longest existing parent is resolved.
+func canonicalPath(abs string) string {
+	var rest []string
+	for p := filepath.Clean(abs); ; p = filepath.Dir(p) {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		if filepath.Dir(p) == p {
+			return filepath.Clean(abs)
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
+
 func (r *Registry) GetDefs(names []string) []provider.ToolDef {
 	if len(names) == 0 {
 		defs := make([]provider.ToolDef, 0, len(r.toolDefs))
@@ -65,76 +335,100 @@ func (r *Registry) GetDefs(names []string) []provider.ToolDef {
 }
 
 func (r *Registry) Execute(ctx context.Context, name string, args map[string]any) (string, error) {
+	res, err := r.ExecuteV2(ctx, name, args)
+	return res.Text, err
+}
+
+// ExecuteV2 runs a tool like Execute and also returns its metadata.
+func (r *Registry) ExecuteV2(ctx context.Context, name string, args map[string]any) (ToolResult, error) {
 	h, ok := r.tools[name]
 	if !ok {
-		return "", fmt.Errorf("unknown tool: %s", name)
+		return ToolResult{}, fmt.Errorf("unknown tool: %s", name)
+	}
+	release, err := r.acquire(ctx, name)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	defer release()
+	timeout := r.Timeout(name)
+	if timeout == 0 {
+		return h(ctx, args)
+	}
+
+	// the deadline is the handler's to honor; one that doesn't (an MCP
+	// request, say) is left running, and its result dropped
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type outcome struct {
+		res ToolResult
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		res, err := h(callCtx, args)
+		done <- outcome{res, err}
+	}()
+	var out outcome
+	select {
+	case out = <-done:
+	case <-callCtx.Done():
 	}
-	return h(ctx, args)
+	switch {
+	case ctx.Err() != nil:
+		return ToolResult{}, ctx.Err() // cancelled: that wins over the timeout
+	case callCtx.Err() == context.DeadlineExceeded:
+		debugLog("TOOL_TIMEOUT: %s after %s", name, timeout)
+		return ToolResult{}, fmt.Errorf("tool %s timed out after %ds", name, int(timeout.Seconds()))
+	}
+	return out.res, out.err
+}
+
+// shellResult formats what a command printed for the model: each stream
+// that printed anything in its own section, then how the command ended.
+func shellResult(stdout, stderr *streamBuffer, status string) string {
+	var sb strings.Builder
+	for _, s := range []struct {
+		name string
+		buf  *streamBuffer
+	}{{"stdout", stdout}, {"stderr", stderr}} {
+		if s.buf.total == 0 {
+			continue
+		}
+		out := s.buf.String()
+		sb.WriteString("[" + s.name + "]\n" + out)
+		if !strings.HasSuffix(out, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("(no output)\n")
+	}
+	return sb.String() + status
 }
 
+// bashTimeout is the bash tool's timeout unless gal.yaml sets one.
+const bashTimeout = 30 * time.Second
+
 func (r *Registry) registerBuiltins() {
 	r.registerHTTP()
 	r.registerPatch()
 	r.registerBrowser()
-
-	// file_read
-	r.RegisterReadOnly(provider.ToolDef{
-		Name:        "file_read",
-		Description: "Read the contents of a file at the given path",
-		Parameters: map[string]any{
-			"type": "object",
-			"properties": map[string]any{
-				"path": map[string]any{"type": "string", "description": "File path to read"},
-			},
-			"required": []string{"path"},
-		},
-	}, func(_ context.Context, args map[string]any) (string, error) {
-		p, _ := args["path"].(string)
-		data, err := os.ReadFile(p)
-		if err != nil {
-			return "", err
-		}
-		lines := strings.Count(string(data), "\n") + 1
-		size := len(data)
-		return fmt.Sprintf("[read %s: %d lines, %d bytes]\n%s", p, lines, size, string(data)), nil
-	})
-
-	// file_write
-	r.Register(provider.ToolDef{
-		Name:        "file_write",
-		Description: "Write content to a file at the given path, creating directories as needed",
-		Parameters: map[string]any{
-			"type": "object",
-			"properties": map[string]any{
-				"path":    map[string]any{"type": "string", "description": "File path to write"},
-				"content": map[string]any{"type": "string", "description": "Content to write"},
-			},
-			"required": []string{"path", "content"},
-		},
-	}, func(_ context.Context, args map[string]any) (string, error) {
-		p, _ := args["path"].(string)
-		content, _ := args["content"].(string)
-		if idx := strings.LastIndex(p, "/"); idx > 0 {
-			os.MkdirAll(p[:idx], 0755)
-		}
-		// check if file exists for diff
-		oldData, readErr := os.ReadFile(p)
-		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
-			return "", err
-		}
-		lines := strings.Count(content, "\n") + 1
-		if readErr != nil {
-			return fmt.Sprintf("created %s (%d lines, %d bytes)", p, lines, len(content)), nil
-		}
-		result := fmt.Sprintf("wrote %s (%d lines, %d bytes)", p, lines, len(content))
-		if diff := FormatDiff(string(oldData), content); diff != "" {
-			result += "\n" + diff
-		}
-		return result, nil
-	})
+	r.registerLogRead()
+	r.registerGlob()
+	r.registerGit()
+	r.registerFetch()
+	r.registerFileRead()
+	r.registerFileWrite()
+	r.registerFileUndo()
+	r.registerApplyPatch()
+	// grep stops at 100 matches, log_read at 32KB and glob at its limit;
+	// cutting their output again would drop the notes they end with
+	r.SetResultTokens("grep", -1)
+	r.SetResultTokens("log_read", -1)
+	r.SetResultTokens("glob", -1)
 
 	// file_edit
-	r.Register(provider.ToolDef{
+	r.RegisterV2(provider.ToolDef{
 		Name:        "file_edit",
 		Description: "Edit a file by replacing lines between start_line and end_line (1-based, inclusive) with new content. More efficient than file_write for partial edits.",
 		Parameters: map[string]any{
@@ -147,23 +441,27 @@ func (r *Registry) registerBuiltins() {
 			},
 			"required": []string{"path", "start_line", "end_line", "content"},
 		},
-	}, func(_ context.Context, args map[string]any) (string, error) {
-		p, _ := args["path"].(string)
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		abs, err := pathArg(args)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		p := showPath(abs)
 		startLine := toInt(args["start_line"])
 		endLine := toInt(args["end_line"])
 		content, _ := args["content"].(string)
 
 		if startLine < 1 || endLine < startLine {
-			return "", fmt.Errorf("invalid line range: %d-%d", startLine, endLine)
+			return ToolResult{}, fmt.Errorf("invalid line range: %d-%d", startLine, endLine)
 		}
 
-		data, err := os.ReadFile(p)
+		data, err := os.ReadFile(abs)
 		if err != nil {
-			return "", err
+			return ToolResult{}, err
 		}
 		lines := strings.Split(string(data), "\n")
 		if startLine > len(lines) {
-			return "", fmt.Errorf("start_line %d exceeds file length %d", startLine, len(lines))
+			return ToolResult{}, fmt.Errorf("start_line %d exceeds file length %d", startLine, len(lines))
 		}
 		if endLine > len(lines) {
 			endLine = len(lines)
@@ -174,17 +472,23 @@ func (r *Registry) registerBuiltins() {
 		result = append(result, content)
 		result = append(result, lines[endLine:]...)
 
-		if err := os.WriteFile(p, []byte(strings.Join(result, "\n")), 0644); err != nil {
-			return "", err
+		edited := strings.Join(result, "\n")
+		if err := r.backup(ctx, "file_edit", abs); err != nil {
+			return ToolResult{}, err
+		}
+		if err := os.WriteFile(abs, []byte(edited), 0644); err != nil {
+			return ToolResult{}, err
 		}
 		oldChunk := strings.Join(lines[startLine-1:endLine], "\n")
 		newLines := strings.Count(content, "\n") + 1
 		replaced := endLine - startLine + 1
 		msg := fmt.Sprintf("edited %s: replaced lines %d-%d (%d lines) with %d lines", p, startLine, endLine, replaced, newLines)
+		meta := map[string]any{"path": p, "start_line": startLine, "end_line": endLine, "lines": newLines}
 		if diff := FormatDiff(oldChunk, content); diff != "" {
 			msg += "\n" + diff
+			meta["diff"] = diff
 		}
-		return msg, nil
+		return ToolResult{Text: msg, Meta: meta, Change: &FileChange{Path: abs, Before: string(data), After: edited}}, nil
 	})
 
 	// file_list
@@ -194,17 +498,23 @@ func (r *Registry) registerBuiltins() {
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"path":  map[string]any{"type": "string", "description": "Directory path to list"},
-				"depth": map[string]any{"type": "integer", "description": "Max depth to recurse (default 3)"},
+				"path":       map[string]any{"type": "string", "description": "Directory path to list"},
+				"depth":      map[string]any{"type": "integer", "description": "Max depth to recurse (default 3)"},
+				"ignore_vcs": ignoreVCSParam,
 			},
 			"required": []string{"path"},
 		},
 	}, func(_ context.Context, args map[string]any) (string, error) {
-		p, _ := args["path"].(string)
+		abs, err := pathArg(args)
+		if err != nil {
+			return "", err
+		}
+		p := showPath(abs)
 		maxDepth := toInt(args["depth"])
 		if maxDepth <= 0 {
 			maxDepth = 3
 		}
+		ig := ignoreVCS(args, abs)
 
 		var sb strings.Builder
 		count := 0
@@ -219,6 +529,9 @@ func (r *Registry) registerBuiltins() {
 			if err != nil {
 				return
 			}
+			if ig != nil {
+				ig.enter(dir)
+			}
 			for _, e := range entries {
 				if count >= maxEntries {
 					sb.WriteString(prefix + "... (truncated)\n")
@@ -229,6 +542,9 @@ func (r *Registry) registerBuiltins() {
 				if name == ".git" || name == "node_modules" || name == "__pycache__" || name == ".DS_Store" {
 					continue
 				}
+				if ig != nil && ig.ignored(filepath.Join(dir, name), e.IsDir()) {
+					continue
+				}
 				if e.IsDir() {
 					sb.WriteString(prefix + name + "/\n")
 					count++
@@ -240,7 +556,7 @@ func (r *Registry) registerBuiltins() {
 			}
 		}
 
-		walk(p, "", 1)
+		walk(abs, "", 1)
 		if count == 0 {
 			return fmt.Sprintf("%s: empty directory", p), nil
 		}
@@ -254,15 +570,20 @@ func (r *Registry) registerBuiltins() {
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"pattern": map[string]any{"type": "string", "description": "Text pattern to search for (substring match, case-insensitive)"},
-				"path":    map[string]any{"type": "string", "description": "File or directory to search in"},
-				"include": map[string]any{"type": "string", "description": "File glob filter (e.g. \"*.go\", \"*.py\"). Optional."},
+				"pattern":    map[string]any{"type": "string", "description": "Text pattern to search for (substring match, case-insensitive)"},
+				"path":       map[string]any{"type": "string", "description": "File or directory to search in"},
+				"include":    map[string]any{"type": "string", "description": "File glob filter (e.g. \"*.go\", \"*.py\"). Optional."},
+				"ignore_vcs": ignoreVCSParam,
 			},
 			"required": []string{"pattern", "path"},
 		},
 	}, func(_ context.Context, args map[string]any) (string, error) {
 		pattern, _ := args["pattern"].(string)
-		p, _ := args["path"].(string)
+		abs, err := pathArg(args)
+		if err != nil {
+			return "", err
+		}
+		p := showPath(abs)
 		include, _ := args["include"].(string)
 		patternLower := strings.ToLower(pattern)
 
@@ -270,7 +591,7 @@ func (r *Registry) registerBuiltins() {
 		matches := 0
 		maxMatches := 100
 
-		info, err := os.Stat(p)
+		info, err := os.Stat(abs)
 		if err != nil {
 			return "", err
 		}
@@ -297,7 +618,7 @@ func (r *Registry) registerBuiltins() {
 				lineNum++
 				line := scanner.Text()
 				if strings.Contains(strings.ToLower(line), patternLower) {
-					sb.WriteString(fmt.Sprintf("%s:%d: %s\n", fpath, lineNum, line))
+					sb.WriteString(fmt.Sprintf("%s:%d: %s\n", showPath(fpath), lineNum, line))
 					matches++
 					if matches >= maxMatches {
 						sb.WriteString("... (truncated at 100 matches)\n")
@@ -308,14 +629,27 @@ func (r *Registry) registerBuiltins() {
 		}
 
 		if !info.IsDir() {
-			searchFile(p)
+			searchFile(abs)
 		} else {
-			filepath.Walk(p, func(fpath string, fi os.FileInfo, err error) error {
-				if err != nil || fi.IsDir() {
+			ig := ignoreVCS(args, abs)
+			filepath.Walk(abs, func(fpath string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return nil // unreadable, e.g. permission denied; skip it
+				}
+				if fpath != abs && ig != nil && ig.ignored(fpath, fi.IsDir()) {
+					if fi.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if fi.IsDir() {
 					name := fi.Name()
-					if name == ".git" || name == "node_modules" || name == "__pycache__" || name == "vendor" {
+					if fpath != abs && (name == ".git" || name == "node_modules" || name == "__pycache__" || name == "vendor") {
 						return filepath.SkipDir
 					}
+					if ig != nil {
+						ig.enter(fpath)
+					}
 					return nil
 				}
 				searchFile(fpath)
@@ -333,17 +667,22 @@ func (r *Registry) registerBuiltins() {
 	})
 
 	// bash
-	r.Register(provider.ToolDef{
+	shellDesc := fmt.Sprintf("Execute a %s command and return its output. For commands requiring passwords (sudo, ssh), use the 'interactive' tool to collect the password first, then use 'sudo -S' or 'sshpass'. For interactive editors (vim, nano), use file_write/file_edit tools instead. Commands time out (after 30 seconds unless configured otherwise).", ShellName())
+	if !IsPOSIXShell() {
+		shellDesc = fmt.Sprintf("Execute a %s command on Windows and return its output. Write %s syntax, not bash. For interactive editors, use file_write/file_edit tools instead. Commands time out (after 30 seconds unless configured otherwise).", ShellName(), ShellName())
+	}
+	r.options["bash"] = ToolOptions{Timeout: bashTimeout}
+	r.RegisterV2(provider.ToolDef{
 		Name:        "bash",
-		Description: "Execute a bash command and return its output. For commands requiring passwords (sudo, ssh), use the 'interactive' tool to collect the password first, then use 'sudo -S' or 'sshpass'. For interactive editors (vim, nano), use file_write/file_edit tools instead. Commands timeout after 30 seconds.",
+		Description: shellDesc,
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"command": map[string]any{"type": "string", "description": "Bash command to execute"},
+				"command": map[string]any{"type": "string", "description": "Command to execute in " + ShellName()},
 			},
 			"required": []string{"command"},
 		},
-	}, func(ctx context.Context, args map[string]any) (string, error) {
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
 		command, _ := args["command"].(string)
 		
 		// Check for interactive commands
@@ -351,39 +690,43 @@ func (r *Registry) registerBuiltins() {
 		interactiveCmds := []string{"vim", "vi", "nano", "emacs", "top", "htop", "less", "more"}
 		for _, icmd := range interactiveCmds {
 			if strings.HasPrefix(trimmedCmd, icmd+" ") || trimmedCmd == icmd {
-				return "", fmt.Errorf("interactive command '%s' not supported - use file_write/file_edit for editing, or run command manually", icmd)
+				return ToolResult{}, fmt.Errorf("interactive command '%s' not supported - use file_write/file_edit for editing, or run command manually", icmd)
 			}
 		}
 		
 		// Check for sudo without -S flag
-		if strings.Contains(trimmedCmd, "sudo ") && !strings.Contains(trimmedCmd, "sudo -S") && !strings.Contains(trimmedCmd, "NOPASSWD") {
-			return "", fmt.Errorf("sudo requires password - use 'interactive' tool to collect password, then use 'echo $password | sudo -S command'")
+		if IsPOSIXShell() && strings.Contains(trimmedCmd, "sudo ") && !strings.Contains(trimmedCmd, "sudo -S") && !strings.Contains(trimmedCmd, "NOPASSWD") {
+			return ToolResult{}, fmt.Errorf("sudo requires password - use 'interactive' tool to collect password, then use 'echo $password | sudo -S command'")
 		}
 		
-		// Add timeout
-		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
-		
-		cmd := exec.CommandContext(ctx, "bash", "-c", command)
-		// Kill entire process group on timeout/cancel so background children
-		// don't hold stdout/stderr pipes open and block CombinedOutput forever.
-		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-		cmd.Cancel = func() error {
-			return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
-		}
+		// ctx carries the tool's timeout (tools.timeouts.bash), see ExecuteV2
+		cmd := ShellCommand(ctx, command)
 		
 		// Capture output for non-interactive commands
-		out, err := cmd.CombinedOutput()
+		stdout := &streamBuffer{limit: maxStreamBytes}
+		stderr := &streamBuffer{limit: maxStreamBytes}
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		start := time.Now()
+		err := cmd.Run()
 		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("command timeout after 30 seconds - may be waiting for input")
+			return ToolResult{}, fmt.Errorf("command timed out - may be waiting for input")
 		}
+		elapsed := time.Since(start)
+		meta := map[string]any{"exit_code": 0, "duration_ms": elapsed.Milliseconds(), "stdout_bytes": stdout.total, "stderr_bytes": stderr.total}
+		status := fmt.Sprintf("[exit code 0, %.1fs]", elapsed.Seconds())
 		if err != nil {
-			return fmt.Sprintf("[exit %s]\n%s", err.Error(), string(out)), nil
-		}
-		if len(out) == 0 {
-			return "(no output)", nil
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return ToolResult{}, err // didn't run
+			}
+			meta["exit_code"] = exitErr.ExitCode()
+			status = fmt.Sprintf("[exit code %d, %.1fs]", exitErr.ExitCode(), elapsed.Seconds())
+			if sig := exitSignal(exitErr); sig != "" {
+				meta["signal"] = sig
+				status = fmt.Sprintf("[killed by signal: %s, %.1fs]", sig, elapsed.Seconds())
+			}
 		}
-		return string(out), nil
+		return ToolResult{Text: shellResult(stdout, stderr, status), Meta: meta}, nil
 	})
 
 	// interactive
@@ -391,7 +734,7 @@ func (r *Registry) registerBuiltins() {
 		Name:        "interactive",
 		Description: "Collect user input interactively. RULE: You MUST ALWAYS use this tool to collect ANY information from the user (credentials, phone numbers, verification codes, choices, confirmations, etc.). NEVER ask for user input via plain text response — always call this tool instead. " +
 			"If a bash command requires interactive input (sudo password, SSH passphrase, database credentials), use this tool FIRST to collect the information, then use the values in your command. " +
-			"Before performing write operations, dangerous operations, privacy-related actions, or system modifications, you MUST use this tool to get user confirmation with options [\"yes\", \"no\", \"trust\"]. Only proceed if user selects \"yes\" or \"trust\". If \"trust\" is selected, skip confirmation for similar operations in this conversation. " +
+			"You don't need to ask before writing files or running commands: the user is asked to approve those calls when they run. " +
 			"You can request multiple fields at once, and the user will be prompted for each one sequentially. Returns a JSON object with all collected values.",
 		Parameters: map[string]any{
 			"type": "object",
@@ -437,10 +780,18 @@ func (r *Registry) registerBuiltins() {
 			"required": []string{"fields"},
 		},
 	}, func(ctx context.Context, args map[string]any) (string, error) {
-		// This is a placeholder - the actual interactive collection
-		// is handled by the engine when it detects type="interactive_input"
-		return "interactive input collected", nil
+		// The engine answers the call with what the user entered when its
+		// turn has someone to ask; otherwise the model has to do without
+		return "", errors.New("interactive input not available in non-interactive mode; provide values in the prompt")
 	})
+
+	// file tools only conflict on the same path, and those that look only with
+	// those that write there; the browser is a single shared page
+	for _, name := range []string{"file_read", "file_write", "file_edit", "file_patch", "file_undo",
+		"file_list", "grep", "glob", "log_read", "git"} {
+		r.SetConflictGroup(name, PathGroup)
+	}
+	r.SetConflictGroup("browser", "browser")
 }
 
 // toInt converts a JSON number (float64) or string to int.
@@ -0,0 +1,80 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConflictKey(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(dir, "real")
+	if err := os.Mkdir(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	r := NewRegistry()
+	tests := []struct {
+		name string
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"write", "file_write", map[string]any{"path": filepath.Join(real, "a.txt")}, "path:" + filepath.Join(real, "a.txt")},
+		{"read", "file_read", map[string]any{"path": filepath.Join(real, "a.txt")}, "read:" + filepath.Join(real, "a.txt")},
+		{"cleaned", "file_write", map[string]any{"path": real + filepath.FromSlash("/./b/../a.txt")}, "path:" + filepath.Join(real, "a.txt")},
+		{"through a symlink", "file_edit", map[string]any{"path": filepath.Join(link, "a.txt")}, "path:" + filepath.Join(real, "a.txt")},
+		{"new file under a symlink", "file_write", map[string]any{"path": filepath.Join(link, "new", "b.txt")}, "path:" + filepath.Join(real, "new", "b.txt")},
+		{"listing", "file_list", map[string]any{"path": link}, "read:" + real},
+		{"grep", "grep", map[string]any{"pattern": "x", "path": real}, "read:" + real},
+		{"git status", "git", map[string]any{"action": "status", "repo": link}, "read:" + real},
+		{"git commit", "git", map[string]any{"action": "commit", "repo": real}, "path:" + real},
+		{"no path", "file_write", map[string]any{}, ExclusiveKey},
+		{"bash", "bash", map[string]any{"command": "ls"}, ExclusiveKey},
+		{"http", "http", map[string]any{"url": "http://x"}, ""},
+		{"browser", "browser", map[string]any{}, "browser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.ConflictKey(tt.tool, tt.args); got != tt.want {
+				t.Errorf("ConflictKey(%s, %v) = %q, want %q", tt.tool, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	// key is a path key with the OS's separators
+	key := func(kind, p string) string { return kind + ":" + filepath.FromSlash(p) }
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", key("path", "/a"), false},
+		{"", ExclusiveKey, false},
+		{ExclusiveKey, key("read", "/a"), true},
+		{key("path", "/a"), key("path", "/a"), true},
+		{key("path", "/a"), key("path", "/b"), false},
+		{key("read", "/a"), key("read", "/a"), false},
+		{key("read", "/a"), key("path", "/a"), true},
+		{key("path", "/a"), key("read", "/a"), true},
+		{key("read", "/d"), key("path", "/d/f"), true},
+		{key("path", "/d"), key("read", "/d/f"), true},
+		{key("read", "/d"), key("path", "/dir/f"), false},
+		{key("read", "/"), key("path", "/a"), true},
+		{"browser", "browser", true},
+		{"browser", "mcp:x", false},
+		{"mcp:x", key("path", "/a"), false},
+	}
+	for _, tt := range tests {
+		if got := Conflicts(tt.a, tt.b); got != tt.want {
+			t.Errorf("Conflicts(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
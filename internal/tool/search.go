@@ -0,0 +1,262 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/provider"
+)
+
+// Limits of one web_search call.
+const (
+	defaultSearchCount = 5
+	maxSearchCount     = 10
+)
+
+// searchTimeout bounds a search engine's answer.
+const searchTimeout = 20 * time.Second
+
+// SearchResult is one hit of a web search.
+type SearchResult struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// SearchBackend is a search engine web_search can query. Search returns up
+// to count results for query, best first.
+type SearchBackend interface {
+	Search(ctx context.Context, query string, count int) ([]SearchResult, error)
+}
+
+// searchBackends builds the backend of each search.provider from the
+// search block of gal.yaml. Adding an engine is adding an entry.
+var searchBackends = map[string]func(c config.SearchConf) (SearchBackend, error){
+	"brave":   newBraveSearch,
+	"serpapi": newSerpAPISearch,
+	"searxng": newSearXNGSearch,
+}
+
+// RegisterSearch registers web_search, querying the engine c configures.
+// Without it there is no web_search, so models aren't offered a tool that
+// can't work.
+func (r *Registry) RegisterSearch(c config.SearchConf) error {
+	newBackend, ok := searchBackends[c.Provider]
+	if !ok {
+		var names []string
+		for name := range searchBackends {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("search: unknown provider %q (want %s)", c.Provider, strings.Join(names, ", "))
+	}
+	backend, err := newBackend(c)
+	if err != nil {
+		return fmt.Errorf("search: %s: %w", c.Provider, err)
+	}
+	r.RegisterReadOnlyV2(provider.ToolDef{
+		Name:        "web_search",
+		Description: "Search the web for current information: news, releases, documentation, error messages. Returns the top results as title, URL and snippet; read a result's page with fetch when the snippet isn't enough.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{"type": "string", "description": "What to search for, as you'd type it into a search engine"},
+				"count": map[string]any{"type": "integer", "description": fmt.Sprintf("Results to return (default %d, at most %d)", defaultSearchCount, maxSearchCount)},
+			},
+			"required": []string{"query"},
+		},
+	}, func(ctx context.Context, args map[string]any) (ToolResult, error) {
+		query := strings.TrimSpace(getStr(args, "query"))
+		if query == "" {
+			return ToolResult{}, errors.New("query is empty")
+		}
+		count := toInt(args["count"])
+		if count <= 0 {
+			count = defaultSearchCount
+		}
+		count = min(count, maxSearchCount)
+		start := time.Now()
+		results, err := backend.Search(ctx, query, count)
+		if err != nil {
+			return ToolResult{}, fmt.Errorf("%s search: %w", c.Provider, err)
+		}
+		if len(results) > count {
+			results = results[:count]
+		}
+		meta := map[string]any{"provider": c.Provider, "results": len(results), "duration_ms": time.Since(start).Milliseconds()}
+		if len(results) == 0 {
+			return ToolResult{Text: fmt.Sprintf("no results for %q", query), Meta: meta}, nil
+		}
+		var sb strings.Builder
+		for i, res := range results {
+			fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, res.Title, res.URL)
+			if res.Snippet != "" {
+				fmt.Fprintf(&sb, "   %s\n", res.Snippet)
+			}
+		}
+		return ToolResult{Text: strings.TrimSuffix(sb.String(), "\n"), Meta: meta}, nil
+	})
+	r.SetUntrusted("web_search")
+	return nil
+}
+
+// braveSearch queries the Brave Search API.
+type braveSearch struct{ endpoint, key string }
+
+func newBraveSearch(c config.SearchConf) (SearchBackend, error) {
+	if c.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	return &braveSearch{endpoint: cmp.Or(c.Endpoint, "https://api.search.brave.com/res/v1/web/search"), key: c.APIKey}, nil
+}
+
+func (b *braveSearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	q := url.Values{"q": {query}, "count": {fmt.Sprint(count)}}
+	if err := searchGet(ctx, b.endpoint, q, map[string]string{"X-Subscription-Token": b.key}, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.Web.Results {
+		results = append(results, newSearchResult(r.Title, r.URL, r.Description))
+	}
+	return results, nil
+}
+
+// serpAPISearch queries Google through SerpApi.
+type serpAPISearch struct{ endpoint, key string }
+
+func newSerpAPISearch(c config.SearchConf) (SearchBackend, error) {
+	if c.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	return &serpAPISearch{endpoint: cmp.Or(c.Endpoint, "https://serpapi.com/search.json"), key: c.APIKey}, nil
+}
+
+func (s *serpAPISearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		Error   string `json:"error"`
+		Results []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"organic_results"`
+	}
+	q := url.Values{"engine": {"google"}, "q": {query}, "num": {fmt.Sprint(count)}, "api_key": {s.key}}
+	if err := searchGet(ctx, s.endpoint, q, nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" && !strings.Contains(resp.Error, "hasn't returned any results") {
+		return nil, errors.New(resp.Error)
+	}
+	var results []SearchResult
+	for _, r := range resp.Results {
+		results = append(results, newSearchResult(r.Title, r.Link, r.Snippet))
+	}
+	return results, nil
+}
+
+// searXNGSearch queries a SearXNG instance, which must allow the JSON
+// format (search.formats in its settings.yml).
+type searXNGSearch struct{ endpoint, key string }
+
+func newSearXNGSearch(c config.SearchConf) (SearchBackend, error) {
+	if c.Endpoint == "" {
+		return nil, errors.New("endpoint is required: the instance's URL, e.g. http://localhost:8080")
+	}
+	endpoint := strings.TrimSuffix(c.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/search") {
+		endpoint += "/search"
+	}
+	return &searXNGSearch{endpoint: endpoint, key: c.APIKey}, nil
+}
+
+func (s *searXNGSearch) Search(ctx context.Context, query string, count int) ([]SearchResult, error) {
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	var headers map[string]string
+	if s.key != "" {
+		headers = map[string]string{"Authorization": "Bearer " + s.key}
+	}
+	if err := searchGet(ctx, s.endpoint, url.Values{"q": {query}, "format": {"json"}}, headers, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.Results {
+		if len(results) == count {
+			break
+		}
+		results = append(results, newSearchResult(r.Title, r.URL, r.Content))
+	}
+	return results, nil
+}
+
+// searchGet GETs endpoint with query and decodes the JSON answer into v.
+func searchGet(ctx context.Context, endpoint string, query url.Values, headers map[string]string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "GAL-CLI/1.0")
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if uerr := (*url.Error)(nil); errors.As(err, &uerr) {
+			return uerr.Err // its URL may hold the API key
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, clipUTF8(strings.TrimSpace(string(body)), 300))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unexpected answer (%w): %s", err, clipUTF8(string(body), 300))
+	}
+	return nil
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// newSearchResult returns a result with the markup engines put in titles
+// and snippets (<strong>, &amp;) and runs of white space taken out.
+func newSearchResult(title, url, snippet string) SearchResult {
+	clean := func(s string) string {
+		return strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(s, ""))), " ")
+	}
+	return SearchResult{Title: clean(title), URL: url, Snippet: clean(snippet)}
+}
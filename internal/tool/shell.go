@@ -0,0 +1,116 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// shellProgram is the shell used by the bash tool and shell mode. Empty means
+// the platform default: bash on Unix, PowerShell on Windows.
+var shellProgram string
+
+// SetShell selects the shell program (bash, sh, zsh, powershell, pwsh or cmd).
+// It must be called before NewRegistry so tool descriptions match the shell.
+func SetShell(name string) {
+	shellProgram = strings.ToLower(strings.TrimSpace(name))
+}
+
+// ShellName returns the effective shell program name.
+func ShellName() string {
+	if shellProgram != "" {
+		return shellProgram
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "bash"
+}
+
+// ShellCommand builds a command that runs the given script in the configured shell.
+func ShellCommand(ctx context.Context, script string) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch sh := ShellName(); sh {
+	case "powershell", "pwsh":
+		cmd = exec.CommandContext(ctx, sh, "-NoProfile", "-NonInteractive", "-Command", script)
+	case "cmd":
+		cmd = exec.CommandContext(ctx, "cmd", "/C", script)
+	default:
+		cmd = exec.CommandContext(ctx, sh, "-c", script)
+	}
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// IsPOSIXShell reports whether the configured shell understands sh syntax.
+func IsPOSIXShell() bool {
+	switch ShellName() {
+	case "powershell", "pwsh", "cmd":
+		return false
+	}
+	return true
+}
+
+// maxStreamBytes is how much of each of a command's output streams the bash
+// tool keeps: a third from the start and the rest from the end.
+const maxStreamBytes = 64 * 1024
+
+// streamBuffer captures one output stream of a command, keeping its first
+// and last bytes when there are more than fit, so a command writing
+// megabytes holds on to no more than limit of them.
+type streamBuffer struct {
+	limit int
+	head  []byte
+	tail  []byte // the latest bytes after head, at most twice the tail's share
+	total int
+}
+
+func (b *streamBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	n := len(p)
+	if room := b.limit/3 - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	b.tail = append(b.tail, p...)
+	if keep := b.limit - b.limit/3; len(b.tail) > 2*keep {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-keep:]...)
+	}
+	return n, nil
+}
+
+// String returns the captured output, with a note where a middle part was
+// dropped. Cuts fall at line breaks when there are some near them, and never
+// inside a character.
+func (b *streamBuffer) String() string {
+	keep := b.limit - b.limit/3
+	if len(b.head)+len(b.tail) == b.total && len(b.tail) <= keep {
+		return string(b.head) + string(b.tail)
+	}
+	tail := b.tail[max(0, len(b.tail)-keep):]
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)/2 {
+		tail = tail[i+1:]
+	}
+	head := b.head
+	if i := len(head) - 1; i >= 0 {
+		for i > 0 && !utf8.RuneStart(head[i]) {
+			i--
+		}
+		if !utf8.FullRune(head[i:]) {
+			head = head[:i] // a character cut in two
+		}
+	}
+	if i := bytes.LastIndexByte(head, '\n'); i > len(head)/2 {
+		head = head[:i+1]
+	}
+	cut := b.total - len(head) - len(tail)
+	return fmt.Sprintf("%s[... %d bytes cut ...]\n%s", head, cut, tail)
+}
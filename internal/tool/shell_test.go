@@ -0,0 +1,117 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStreamBuffer(t *testing.T) {
+	const limit = 4096
+	lines := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+		return sb.String()
+	}
+	tests := []struct {
+		name  string
+		out   string
+		chunk int // bytes per Write
+	}{
+		{"short", lines(10), 7},
+		{"exactly the limit", strings.Repeat("x", limit), 100},
+		{"megabytes in pipe-sized writes", lines(300000), 32 << 10},
+		{"megabytes a byte at a time", lines(100000), 1},
+		{"megabytes in one write", lines(300000), 1 << 30},
+		{"megabytes without line breaks", strings.Repeat("0123456789", 200000), 4096},
+		{"megabytes of wide characters", strings.Repeat("日本語のエラー出力\n", 100000), 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &streamBuffer{limit: limit}
+			maxCap := 0
+			for p := tt.out; p != ""; {
+				k := min(tt.chunk, len(p))
+				if n, err := b.Write([]byte(p[:k])); n != k || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+				p = p[k:]
+				maxCap = max(maxCap, cap(b.head)+cap(b.tail))
+			}
+			if b.total != len(tt.out) {
+				t.Errorf("total %d, want %d", b.total, len(tt.out))
+			}
+			// memory stays bounded by the limit and the size of a write,
+			// with room for append to grow, however much is written
+			if bound := 2 * (2*limit + min(tt.chunk, len(tt.out))); maxCap > bound {
+				t.Errorf("held %d bytes, want at most %d", maxCap, bound)
+			}
+			got := b.String()
+			if len(tt.out) <= limit {
+				if got != tt.out {
+					t.Errorf("kept %q, want all of %q", got, tt.out)
+				}
+				return
+			}
+			head, tail, ok := strings.Cut(got, "[... ")
+			if !ok {
+				t.Fatalf("no cut in %d of %d bytes", len(got), len(tt.out))
+			}
+			cut, tail, _ := strings.Cut(tail, " bytes cut ...]\n")
+			if !strings.HasPrefix(tt.out, head) || !strings.HasSuffix(tt.out, tail) {
+				t.Errorf("head %.40q... and ...%.40q aren't the output's start and end", head, tail[max(0, len(tail)-40):])
+			}
+			if len(head) < limit/4 || len(tail) < limit/2 {
+				t.Errorf("kept %d bytes of the start and %d of the end of %d", len(head), len(tail), limit)
+			}
+			if want := fmt.Sprint(len(tt.out) - len(head) - len(tail)); cut != want {
+				t.Errorf("says %s bytes were cut, want %s", cut, want)
+			}
+			if len(got) > limit+64 {
+				t.Errorf("%d bytes kept, want about %d", len(got), limit)
+			}
+			if !utf8.ValidString(got) {
+				t.Error("a character was cut in two")
+			}
+		})
+	}
+}
+
+func TestBashLargeStderr(t *testing.T) {
+	if !IsPOSIXShell() {
+		t.Skip("needs a POSIX shell")
+	}
+	// about 7 MB on stderr, a little on stdout
+	res, err := NewRegistry().ExecuteV2(context.Background(), "bash", map[string]any{"command": "seq 1 1000000 >&2; echo done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.Meta["stderr_bytes"].(int); n != 6888896 {
+		t.Errorf("stderr_bytes %v, want 6888896", res.Meta["stderr_bytes"])
+	}
+	_, stderr, ok := strings.Cut(res.Text, "[stderr]\n")
+	if !ok || !strings.HasPrefix(res.Text, "[stdout]\ndone\n") {
+		t.Fatalf("result %.200q", res.Text)
+	}
+	if !strings.HasPrefix(stderr, "1\n2\n3\n") || !strings.Contains(stderr, "999999\n1000000\n[exit code 0") {
+		t.Errorf("stderr lost its start or end: %.40q ... %q", stderr, stderr[max(0, len(stderr)-60):])
+	}
+	if len(res.Text) > maxStreamBytes+200 {
+		t.Errorf("%d bytes of result, want at most about %d", len(res.Text), maxStreamBytes)
+	}
+}
+
+func TestShellDescription(t *testing.T) {
+	t.Cleanup(func() { SetShell("") })
+	for _, shell := range []string{"bash", "zsh", "sh", "pwsh"} {
+		SetShell(shell)
+		defs := NewRegistry().GetDefs([]string{"bash"})
+		if len(defs) != 1 || !strings.Contains(defs[0].Description, "Execute a "+shell+" command") {
+			t.Errorf("with %s the description reads %q", shell, defs)
+		}
+	}
+}
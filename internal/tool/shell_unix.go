@@ -0,0 +1,27 @@
+//go:build !windows
+
+package tool
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the command in its own process group and kills the
+// whole group on cancel, so background children don't hold stdout/stderr
+// pipes open and block CombinedOutput forever.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// exitSignal returns the signal that killed the command ("killed",
+// "segmentation fault"), or "" when it exited.
+func exitSignal(err *exec.ExitError) string {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package tool
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a new process group and kills the
+// whole process tree on cancel via taskkill.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
+
+// exitSignal returns "": Windows processes don't die of signals.
+func exitSignal(err *exec.ExitError) string {
+	return ""
+}
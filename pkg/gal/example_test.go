@@ -0,0 +1,54 @@
+package gal_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gal-cli/gal-cli/pkg/gal"
+)
+
+// A turn of an agent whose model is the mock provider, which answers by
+// echoing the message. The configuration is written to a temporary
+// directory here; programs usually use the user's ~/.gal.
+func ExampleRun() {
+	dir, err := os.MkdirTemp("", "gal-example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "agents"), 0o755)
+	os.WriteFile(filepath.Join(dir, "gal.yaml"), []byte("default_agent: echo\nproviders:\n  mock:\n    type: mock\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "agents", "echo.yaml"), []byte("name: echo\nsystem_prompt: Answer briefly.\nmodels: [mock/m]\ndefault_model: mock/m\n"), 0o644)
+	os.Setenv("GAL_CONFIG_DIR", dir)
+	os.Setenv("GAL_STATE_DIR", filepath.Join(dir, "state"))
+
+	cfg, err := gal.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	reg, err := gal.NewRegistry(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eng, err := gal.NewEngine(cfg, cfg.DefaultAgent, reg, gal.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer eng.Close()
+	// tools that change things would ask here
+	eng.OnToolApproval = func(name string, args map[string]any) (gal.Decision, error) {
+		return gal.Deny, nil
+	}
+	for ev := range gal.Run(context.Background(), eng, "hello") {
+		switch ev.Type {
+		case gal.EventDone:
+			fmt.Println(ev.Content)
+		case gal.EventError:
+			log.Fatal(ev.Err)
+		}
+	}
+	// Output: echo: hello
+}
@@ -0,0 +1,147 @@
+// Package gal embeds gal-cli's agentic loop in other programs: it loads the
+// configuration, builds an agent's engine with its tools and provider, runs
+// turns and keeps sessions, the way the gal-cli chat command does, which
+// uses this package too.
+//
+// A minimal program that asks an agent a question it may use tools for:
+//
+//	cfg, err := gal.LoadConfig()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	reg, err := gal.NewRegistry(cfg)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	eng, err := gal.NewEngine(cfg, cfg.DefaultAgent, reg, gal.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer eng.Close()
+//	for ev := range gal.Run(context.Background(), eng, "Which Go files here have no doc comment?") {
+//		switch ev.Type {
+//		case gal.EventText:
+//			fmt.Print(ev.Content)
+//		case gal.EventToolCall:
+//			fmt.Fprintln(os.Stderr, "tool:", ev.Content)
+//		case gal.EventError:
+//			log.Fatal(ev.Err)
+//		}
+//	}
+//
+// Tools that change things run without asking unless the engine's
+// OnToolApproval is set. The configuration is read from GAL_CONFIG_DIR or
+// ~/.gal, as for the CLI.
+//
+// The types are those of gal-cli's internal packages, under the names below;
+// their exported fields and methods are part of the API too.
+package gal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gal-cli/gal-cli/internal/config"
+	"github.com/gal-cli/gal-cli/internal/engine"
+	"github.com/gal-cli/gal-cli/internal/provider"
+	"github.com/gal-cli/gal-cli/internal/session"
+	"github.com/gal-cli/gal-cli/internal/setup"
+	"github.com/gal-cli/gal-cli/internal/tool"
+)
+
+// Version is the version of this package's API, following semantic
+// versioning: until 1.0.0, a minor version may change it incompatibly.
+const Version = "0.1.0"
+
+type (
+	Config    = config.Config     // gal.yaml
+	Engine    = engine.Engine     // an agent's conversation and the loop that runs its turns
+	Registry  = tool.Registry     // the tools engines can call
+	Provider  = provider.Provider // a model API
+	Message   = provider.Message
+	TurnStats = engine.TurnStats
+	Session   = session.Session // a conversation saved in the state directory
+	Archived  = engine.Archived // messages compression replaced, with their summary
+	Options   = setup.Options   // overrides of agent settings
+
+	ToolDef     = provider.ToolDef     // a tool as models see it: name, description, JSON schema
+	Handler     = tool.Handler         // runs a tool call, for Registry.Register
+	HandlerV2   = tool.HandlerV2       // a Handler that also returns metadata, for Registry.RegisterV2
+	ToolResult  = tool.ToolResult      // what a HandlerV2 returns
+	Decision    = engine.Decision      // the answer to an Approver
+	Approver    = engine.Approver      // Engine.OnToolApproval
+	ChatOptions = provider.ChatOptions // generation parameters of a request
+	StreamDelta = provider.StreamDelta // a piece of a streamed answer, from Provider.ChatStream
+)
+
+// The answers to an Approver.
+const (
+	Deny      = engine.Deny      // don't run the call
+	Allow     = engine.Allow     // run this call
+	AllowTool = engine.AllowTool // run it and the tool's later calls this session
+	AllowAll  = engine.AllowAll  // run every call for the rest of the session
+)
+
+// LoadConfig reads gal.yaml from the config directory.
+func LoadConfig() (*Config, error) {
+	return config.Load()
+}
+
+// NewRegistry returns the built-in tools plus the custom tools of cfg, and
+// web_search when cfg configures a search engine, set up as cfg says:
+// concurrency, result sizes, timeouts, the shell and the browser. The shell
+// and browser settings are global.
+func NewRegistry(cfg *Config) (*Registry, error) {
+	tool.SetShell(cfg.Shell.Program)
+	tool.SetBrowserIdleTimeout(time.Duration(cfg.Browser.IdleTimeout) * time.Second)
+	reg := tool.NewRegistry()
+	for _, ct := range cfg.CustomTools {
+		if err := reg.RegisterCustom(ct); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Search.Provider != "" {
+		if err := reg.RegisterSearch(cfg.Search); err != nil {
+			return nil, err
+		}
+	}
+	for key, n := range cfg.Tools.Concurrency {
+		reg.SetConcurrency(key, n)
+	}
+	for name, n := range cfg.Tools.ResultTokens {
+		reg.SetResultTokens(name, n)
+	}
+	for name, secs := range cfg.Tools.Timeouts {
+		reg.SetTimeout(name, time.Duration(secs)*time.Second)
+	}
+	return reg, nil
+}
+
+// NewEngine builds the engine of the agent agentName (agents/<name>.yaml):
+// its tools from reg plus its skills and MCP servers, its model's provider,
+// and the settings cfg and the agent give it. Close it when done.
+func NewEngine(cfg *Config, agentName string, reg *Registry, opts Options) (*Engine, error) {
+	return setup.Engine(cfg, agentName, reg, opts)
+}
+
+// NewProvider returns the provider cfg configures as name.
+func NewProvider(cfg *Config, name string) (Provider, error) {
+	return setup.Provider(cfg, name)
+}
+
+// SwitchModel moves eng to model, given as "provider/model", with a
+// provider from cfg.
+func SwitchModel(cfg *Config, eng *Engine, model string) error {
+	name, _, ok := strings.Cut(model, "/")
+	if !ok {
+		return fmt.Errorf("model %s: want provider/model", model)
+	}
+	p, err := setup.Provider(cfg, name)
+	if err != nil {
+		return err
+	}
+	eng.Provider = p
+	eng.SwitchModel(model)
+	return nil
+}
@@ -0,0 +1,59 @@
+package gal
+
+import "context"
+
+// Event types, as in the --json output of gal-cli chat -m.
+const (
+	EventText       = "text"        // Content: the next piece of the answer
+	EventReasoning  = "reasoning"   // Content: the next piece of the model's thinking
+	EventToolCall   = "tool_call"   // Content: the name of the tool called
+	EventToolResult = "tool_result" // Content: a preview of the result
+	EventDone       = "done"        // the turn ended in an answer; Content: the answer
+	EventError      = "error"       // Err: why the turn failed
+)
+
+// Event is something that happened in a turn Run runs.
+type Event struct {
+	Type    string
+	Content string
+	Err     error
+}
+
+// Run sends message to eng and runs the turn it starts in the background:
+// the model's answers and tool calls, round after round. The events come in
+// order on the returned channel, ending with a done or error event, after
+// which it is closed. Read it until then: the turn waits for its reader.
+// Cancelling ctx stops the turn; events nobody reads any more are dropped
+// then, and the channel is closed once the turn has ended, perhaps without a
+// last event.
+//
+// eng runs one turn at a time. Run sets its OnReasoning for the turn.
+func Run(ctx context.Context, eng *Engine, message string) <-chan Event {
+	events := make(chan Event, 16)
+	send := func(ev Event) {
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		prev := eng.OnReasoning
+		eng.OnReasoning = func(s string) { send(Event{Type: EventReasoning, Content: s}) }
+		defer func() { eng.OnReasoning = prev }()
+		err := eng.SendWithCallbacks(ctx, message,
+			func(s string) { send(Event{Type: EventText, Content: s}) },
+			func(name string) { send(Event{Type: EventToolCall, Content: name}) },
+			func(preview string) { send(Event{Type: EventToolResult, Content: preview}) })
+		if err != nil {
+			send(Event{Type: EventError, Err: err})
+			return
+		}
+		var answer string
+		if n := len(eng.Messages); n > 0 {
+			answer = eng.Messages[n-1].Content
+		}
+		send(Event{Type: EventDone, Content: answer})
+	}()
+	return events
+}
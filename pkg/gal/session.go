@@ -0,0 +1,86 @@
+package gal
+
+import "github.com/gal-cli/gal-cli/internal/session"
+
+// NewSession starts a session of the agent agentName with a new ID.
+func NewSession(agentName string) *Session {
+	return session.New(session.NewID(), agentName, "")
+}
+
+// LoadSession reads the saved session id.
+func LoadSession(id string) (*Session, error) {
+	return session.Load(id)
+}
+
+// KeepArchive makes eng add the messages compression replaces to the
+// archive of sess, which LoadArchive reads. Failures go to eng.OnStatus.
+func KeepArchive(eng *Engine, sess *Session) {
+	eng.OnArchive = func(a Archived) {
+		if err := session.AppendArchive(sess.ID, a); err != nil && eng.OnStatus != nil {
+			eng.OnStatus("archive: " + err.Error())
+		}
+	}
+}
+
+// LoadArchive reads what compression replaced in the session id, oldest
+// first. skipped counts the entries that couldn't be read, such as one cut
+// short by a crash.
+func LoadArchive(id string) (archive []Archived, skipped int, err error) {
+	return session.LoadArchive(id)
+}
+
+// Resume carries on sess in eng: its conversation, usage, cost, autonomous
+// runs and changes, and its model when cfg still has the provider.
+func Resume(cfg *Config, eng *Engine, sess *Session) {
+	if sess.Model != "" {
+		SwitchModel(cfg, eng, sess.Model)
+	}
+	eng.Messages = sess.Messages
+	eng.Usage = sess.Usage
+	eng.Cost = sess.Cost
+	eng.AutoRuns = sess.AutoRuns
+	eng.Changes = sess.Changes
+}
+
+// Record copies eng's conversation and what Resume restores into sess,
+// without a tool exchange the last turn left open. Appending
+// eng.LastTurn to sess.Turns is up to the caller, who knows whether the
+// turn was recorded already.
+func Record(eng *Engine, sess *Session) {
+	sess.Messages = CleanMessages(eng.Messages)
+	sess.Agent = eng.Agent.Conf.Name
+	sess.Model = eng.Agent.CurrentModel
+	sess.Usage = eng.Usage
+	sess.Cost = eng.Cost
+	sess.AutoRuns = eng.AutoRuns
+	sess.Changes = eng.Changes
+}
+
+// CleanMessages removes trailing incomplete tool_call sequences.
+// A complete sequence ends with assistant{content}. If the tail is
+// tool results or assistant{tool_calls} without a final text response,
+// strip them back to the last clean state.
+func CleanMessages(msgs []Message) []Message {
+	if len(msgs) == 0 {
+		return msgs
+	}
+	last := msgs[len(msgs)-1]
+	// If last message is a complete assistant text response, nothing to clean
+	if last.Role == "assistant" && last.Content != "" && len(last.ToolCalls) == 0 {
+		return msgs
+	}
+	// If last message is user or system, nothing to clean
+	if last.Role == "user" || last.Role == "system" {
+		return msgs
+	}
+	// Strip trailing tool/assistant{tool_calls} messages
+	for len(msgs) > 0 {
+		tail := msgs[len(msgs)-1]
+		if tail.Role == "tool" || (tail.Role == "assistant" && len(tail.ToolCalls) > 0) {
+			msgs = msgs[:len(msgs)-1]
+			continue
+		}
+		break
+	}
+	return msgs
+}